@@ -14,7 +14,6 @@ import (
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/client"
 	"github.com/google/certificate-transparency-go/jsonclient"
-	"github.com/google/certificate-transparency-go/scanner"
 	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
 )
 
@@ -45,7 +44,8 @@ func main() {
 	}
 
 	// Get entries from CT log
-	c, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	entries, getEntriesErr := jsonClient.GetRawEntries(c, certID, certID)
 	if getEntriesErr != nil {
 		log.Fatalln("Error getting entries from CT log: ", getEntriesErr)
@@ -63,19 +63,6 @@ func main() {
 			log.Fatalln("Error parsing certstream entry: ", parseErr)
 		}
 
-		// Check if the entry is a certificate or precertificate
-		if logEntry, toLogEntryErr := rawLogEntry.ToLogEntry(); toLogEntryErr != nil {
-			log.Println("Error converting rawLogEntry to logEntry: ", toLogEntryErr)
-		} else {
-			matcher := scanner.MatchAll{}
-			if logEntry.X509Cert != nil && matcher.CertificateMatches(logEntry.X509Cert) {
-				entry.Data.UpdateType = "X509LogEntry"
-			}
-			if logEntry.Precert != nil && matcher.PrecertificateMatches(logEntry.Precert) {
-				entry.Data.UpdateType = "PrecertLogEntry"
-			}
-		}
-
 		// Remove DER encoding and chain if not requested
 		if !*asDERFlag {
 			entry.Data.LeafCert.AsDER = ""
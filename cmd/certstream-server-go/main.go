@@ -46,5 +46,7 @@ func main() {
 		log.Fatalf("Error while creating certstream server: %v", err)
 	}
 
-	cs.Start()
+	if err = cs.Start(); err != nil {
+		log.Fatalf("Error while running certstream server: %v", err)
+	}
 }
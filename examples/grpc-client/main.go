@@ -0,0 +1,58 @@
+package main
+
+// This is a small example client for the gRPC API of certstream-server-go.
+// It prints the domains of all new certificates, optionally filtered by domain suffixes.
+//
+// Usage: go run ./examples/grpc-client -addr localhost:8081 -domains example.com,example.org
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/letrics/certstream-server-go/pkg/certstreampb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8081", "Address of the gRPC server")
+	domains := flag.String("domains", "", "Comma separated list of domain suffixes to filter for")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	conn, err := grpc.NewClient(*addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalln("Error while connecting to server:", err)
+	}
+	defer conn.Close()
+
+	req := &certstreampb.StreamRequest{}
+	if *domains != "" {
+		req.DomainSuffixes = strings.Split(*domains, ",")
+	}
+
+	stream, err := certstreampb.NewCertStreamClient(conn).StreamCertificates(ctx, req)
+	if err != nil {
+		log.Fatalln("Error while starting stream:", err)
+	}
+
+	for {
+		cert, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("Stream ended:", err)
+			}
+
+			return
+		}
+
+		log.Printf("[%s] %s\n", cert.GetSource().GetName(), strings.Join(cert.GetLeafCert().GetAllDomains(), ", "))
+	}
+}
@@ -0,0 +1,129 @@
+package certificatetransparency
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// ackTracker advances the recovery index of each log only as far as the consumer acknowledged the entries, instead
+// of as soon as they are passed to the output channel. Since entries can be acknowledged out of order, the index is
+// the low watermark: the highest index of a finished entry that no entry in flight precedes. Entries that are
+// discarded by the filters never reach the consumer, so they are finished right away.
+type ackTracker struct {
+	mu   sync.Mutex
+	logs map[string]*logAcks
+}
+
+// logAcks contains the entries of a single log that were passed to the output channel, but not acknowledged yet.
+// Both slices are sorted. They usually stay small, since entries are mostly forwarded and finished in order.
+type logAcks struct {
+	// inFlight are the indexes of the entries that were passed to the output channel, but not acknowledged yet
+	inFlight []uint64
+	// finished are the indexes of acknowledged or discarded entries that wait for an earlier entry in flight
+	finished []uint64
+}
+
+// insertIndex inserts the index into the sorted slice. Indexes mostly arrive in order, so it is usually appended.
+func insertIndex(indexes []uint64, index uint64) []uint64 {
+	if len(indexes) == 0 || indexes[len(indexes)-1] <= index {
+		return append(indexes, index)
+	}
+
+	i, _ := slices.BinarySearch(indexes, index)
+
+	return slices.Insert(indexes, i, index)
+}
+
+// finish marks the entry with the given index as finished and applies the low watermark of the log, if it advanced.
+// The caller must hold the lock.
+func (acks *logAcks) finish(url string, index uint64) {
+	acks.finished = insertIndex(acks.finished, index)
+
+	// The finished entries before the first entry in flight are contiguous, so the last of them is the new index
+	n := len(acks.finished)
+	if len(acks.inFlight) > 0 {
+		n, _ = slices.BinarySearch(acks.finished, acks.inFlight[0])
+	}
+
+	if n == 0 {
+		return
+	}
+
+	metrics.SetCTIndex(url, acks.finished[n-1])
+	acks.finished = acks.finished[n:]
+}
+
+func newAckTracker() *ackTracker {
+	return &ackTracker{logs: make(map[string]*logAcks)}
+}
+
+// get returns the state of the given log. The caller must hold the lock.
+func (t *ackTracker) get(url string) *logAcks {
+	acks, ok := t.logs[url]
+	if !ok {
+		acks = &logAcks{}
+		t.logs[url] = acks
+	}
+
+	return acks
+}
+
+// forwarded marks the entry with the given index of the given log as passed to the output channel. It must be called
+// before the entry is sent, so that it can't be acknowledged before.
+func (t *ackTracker) forwarded(url string, index uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acks := t.get(url)
+	acks.inFlight = insertIndex(acks.inFlight, index)
+}
+
+// skipped records the index of an entry that was discarded by the filters.
+func (t *ackTracker) skipped(url string, index uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.get(url).finish(url, index)
+}
+
+// acknowledged records that the consumer received the entry with the given index of the given log.
+// Acknowledgements of entries that are not in flight are ignored.
+func (t *ackTracker) acknowledged(url string, index uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	acks := t.get(url)
+
+	i, found := slices.BinarySearch(acks.inFlight, index)
+	if !found {
+		return
+	}
+
+	if i == 0 {
+		acks.inFlight = acks.inFlight[1:]
+	} else {
+		acks.inFlight = slices.Delete(acks.inFlight, i, i+1)
+	}
+
+	acks.finish(url, index)
+}
+
+// EnableAcks makes the recovery index of each log advance only for entries that were acknowledged via Acknowledge,
+// so that the saved position reflects what the consumer actually received rather than what was fetched.
+// Every entry passed to the output channel must be acknowledged once. It must be called before the watcher is started.
+func (w *Watcher) EnableAcks() {
+	w.acks = newAckTracker()
+}
+
+// Acknowledge marks the entry as received by the consumer, which advances the recovery index of its log.
+// It is a no-op if acks are not enabled or for heartbeats.
+func (w *Watcher) Acknowledge(entry models.Entry) {
+	if w.acks == nil || entry.IsHeartbeat() {
+		return
+	}
+
+	w.acks.acknowledged(entry.Data.Source.NormalizedURL, entry.Data.CertIndex)
+	w.countConsumed()
+}
@@ -0,0 +1,68 @@
+package certificatetransparency
+
+import "testing"
+
+func TestAckTracker(t *testing.T) {
+	t.Parallel()
+
+	const url = "ct.example.com/acks"
+
+	tracker := newAckTracker()
+
+	// Without entries in flight, skipped entries advance the index right away
+	tracker.skipped(url, 1)
+	if got := metrics.GetCTIndex(url); got != 1 {
+		t.Errorf("index after skipped entry = %d, want 1", got)
+	}
+
+	tracker.forwarded(url, 2)
+	tracker.forwarded(url, 3)
+	tracker.skipped(url, 4)
+
+	// The skipped entry must not advance the index past the entries in flight
+	tracker.acknowledged(url, 2)
+	if got := metrics.GetCTIndex(url); got != 2 {
+		t.Errorf("index after first ack = %d, want 2", got)
+	}
+
+	tracker.acknowledged(url, 3)
+	if got := metrics.GetCTIndex(url); got != 4 {
+		t.Errorf("index after last ack = %d, want 4", got)
+	}
+}
+
+func TestAckTrackerOutOfOrder(t *testing.T) {
+	t.Parallel()
+
+	const url = "ct.example.com/acks-out-of-order"
+
+	tracker := newAckTracker()
+
+	// The entries are forwarded and acknowledged in a different order than in the log
+	tracker.forwarded(url, 11)
+	tracker.forwarded(url, 10)
+	tracker.forwarded(url, 13)
+	tracker.skipped(url, 12)
+	tracker.forwarded(url, 14)
+
+	steps := []struct {
+		ack  uint64
+		want uint64
+	}{
+		// 10 is still in flight, so neither the acknowledged 13 nor the skipped 12 may be saved
+		{ack: 13, want: 0},
+		{ack: 11, want: 0},
+		// Once 10 is acknowledged, everything up to 13 is finished
+		{ack: 10, want: 13},
+		{ack: 14, want: 14},
+		// Duplicate acknowledgements don't move the index
+		{ack: 11, want: 14},
+	}
+
+	for _, step := range steps {
+		tracker.acknowledged(url, step.ack)
+		if got := metrics.GetCTIndex(url); got != step.want {
+			t.Errorf("index after ack of %d = %d, want %d", step.ack, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package certificatetransparency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/scanner"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// backfillProgressInterval is the interval in which the progress of a backfill is reported.
+const backfillProgressInterval = time.Second
+
+// BackfillProgress is the progress of a backfill, as reported to the callback of BackfillWithProgress.
+type BackfillProgress struct {
+	// Start and End are the indexes of the first and last entry of the range, both inclusive.
+	Start uint64
+	End   uint64
+	// Fetched is the number of entries of the range that were fetched so far.
+	Fetched uint64
+	// Index is the highest index fetched so far, or Start if none was fetched yet. The entries are fetched in
+	// parallel, so lower indexes might be missing.
+	Index uint64
+	// Done is set for the last report, once the backfill finished or stopped.
+	Done bool
+	// Err is the reason the backfill stopped before all entries were fetched, e.g. context.Canceled. It is only set
+	// for the last report.
+	Err error
+}
+
+// Total returns the number of entries of the range.
+func (p BackfillProgress) Total() uint64 {
+	return p.End - p.Start + 1
+}
+
+// Percent returns the percentage of the entries of the range that were fetched so far.
+func (p BackfillProgress) Percent() float64 {
+	return float64(p.Fetched) / float64(p.Total()) * 100
+}
+
+// Backfill fetches the entries from start to end, both inclusive, of the CT log with the given name or url, e.g. to
+// reconstruct what the log held during an incident window. The backfill runs independently of the worker following
+// the head of the log. The entries are sent to the returned channel, which is closed once all entries were fetched or
+// the watcher stops. They are not necessarily in order and are not subject to the filters of the watcher.
+// Errors while fetching or parsing the entries are published on the error channel.
+// An error wrapping ErrUnknownLog is returned if the log is not being watched and one wrapping ErrInvalidRange if
+// the range is empty or exceeds the tree size of the log.
+func (w *Watcher) Backfill(logName string, start, end uint64) (<-chan models.Entry, error) {
+	return w.BackfillWithProgress(context.Background(), logName, start, end, nil)
+}
+
+// BackfillWithProgress does the same as Backfill, but stops early if the given context is cancelled. If onProgress
+// is not nil, the progress is reported to it once per second and a last time with Done set before the channel is
+// closed. The last report contains how far the backfill got and, if it stopped early, the reason. onProgress is
+// called from a single goroutine and should return quickly.
+func (w *Watcher) BackfillWithProgress(ctx context.Context, logName string, start, end uint64, onProgress func(BackfillProgress)) (<-chan models.Entry, error) {
+	ctWorker := w.findWorker(logName)
+	if ctWorker == nil {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownLog, logName)
+	}
+
+	if start > end {
+		return nil, fmt.Errorf("%w: start %d is after end %d", ErrInvalidRange, start, end)
+	}
+
+	// The backfill stops if either the given context is cancelled or the watcher stops
+	ctx, cancel := context.WithCancel(ctx)
+	stopAfterWatcher := context.AfterFunc(w.context, cancel)
+
+	// The requests of the backfill don't count towards the stats of the worker
+	jsonClient, err := ctWorker.newLogClient(&logProgress{})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	sth, err := jsonClient.GetSTH(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %w", errFetchingSTHFailed, err)
+	}
+
+	if end >= sth.TreeSize {
+		cancel()
+		return nil, fmt.Errorf("%w: end %d exceeds tree size %d", ErrInvalidRange, end, sth.TreeSize)
+	}
+
+	entryChan := make(chan models.Entry, config.AppConfig.General.BufferSizes.CTLog)
+
+	go func() {
+		defer cancel()
+		defer stopAfterWatcher()
+
+		ctWorker.backfill(ctx, jsonClient, int64(start), int64(end), entryChan, onProgress)
+	}()
+
+	return entryChan, nil
+}
+
+// backfill fetches the entries from start to end, both inclusive, and sends them to the output channel, which is
+// closed afterward. The batch size is capped like for the live worker. If onProgress is not nil, the progress is
+// reported to it. This method is blocking.
+func (w *worker) backfill(ctx context.Context, jsonClient *treeHeadClient, start, end int64, output chan<- models.Entry, onProgress func(BackfillProgress)) {
+	defer close(output)
+
+	w.logger.Info("Starting backfill", "url", w.ctURL, "start", start, "end", end)
+
+	var progress logProgress
+	progress.start(uint64(start))
+
+	report := func(done bool, err error) {
+		if onProgress == nil {
+			return
+		}
+
+		onProgress(BackfillProgress{
+			Start:   uint64(start),
+			End:     uint64(end),
+			Fetched: progress.entries.Load(),
+			// nextIndex is only advanced by fetched entries, so it is start until the first one was fetched
+			Index: max(progress.nextIndex.Load(), uint64(start)+1) - 1,
+			Done:  done,
+			Err:   err,
+		})
+	}
+
+	stopReporting := func() {}
+	if onProgress != nil {
+		reportCtx, cancelReporting := context.WithCancel(ctx)
+		reporterDone := make(chan struct{})
+
+		go func() {
+			defer close(reporterDone)
+
+			ticker := time.NewTicker(backfillProgressInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					report(false, nil)
+				case <-reportCtx.Done():
+					return
+				}
+			}
+		}()
+
+		stopReporting = func() {
+			cancelReporting()
+			<-reporterDone
+		}
+	}
+
+	certScanner := scanner.NewScanner(jsonClient, scanner.ScannerOptions{
+		FetcherOptions: scanner.FetcherOptions{
+			BatchSize:     w.capBatchSize(ctx, jsonClient),
+			ParallelFetch: config.AppConfig.General.ScannerOptions.ParallelFetch,
+			StartIndex:    start,
+			EndIndex:      end + 1,
+		},
+		Matcher:    scanner.MatchAll{},
+		NumWorkers: w.workerCount,
+		BufferSize: config.AppConfig.General.BufferSizes.CTLog,
+	})
+
+	// Entries count as fetched once they were sent or couldn't be parsed, but not if they were dropped because the
+	// backfill stopped, so that the progress tells how far the backfill got
+	send := func(rawEntry *ct.RawLogEntry) {
+		fetchedAt := time.Now()
+
+		entry, parseErr := w.parseEntry(ctx, rawEntry)
+		if parseErr != nil {
+			w.reportError(fmt.Errorf("could not parse backfilled entry %d: %w", rawEntry.Index, parseErr))
+			progress.processed(rawEntry.Index)
+
+			return
+		}
+
+		entry.Data.Seen = models.NewTimestamp(fetchedAt)
+
+		select {
+		case output <- entry:
+			progress.processed(rawEntry.Index)
+		case <-ctx.Done():
+		}
+	}
+
+	scanErr := certScanner.Scan(ctx,
+		send,
+		send,
+	)
+
+	switch {
+	case ctx.Err() != nil:
+		w.logger.Info("Stopped backfill", "url", w.ctURL, "start", start, "end", end, "fetched", progress.entries.Load())
+		scanErr = ctx.Err()
+	case scanErr != nil:
+		w.reportError(fmt.Errorf("backfill of entries %d to %d failed: %w", start, end, scanErr))
+	default:
+		w.logger.Info("Finished backfill", "url", w.ctURL, "start", start, "end", end)
+	}
+
+	// The reporter is stopped before the last report, so that the reports are never concurrent
+	stopReporting()
+	report(true, scanErr)
+}
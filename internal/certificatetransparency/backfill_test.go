@@ -0,0 +1,168 @@
+package certificatetransparency
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// testLeafInput returns the leaf input of a log entry for a self-signed certificate.
+func testLeafInput(tb testing.TB) []byte {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	leafInput, err := tls.Marshal(ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.X509LogEntryType,
+			X509Entry: &ct.ASN1Cert{Data: der},
+		},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return leafInput
+}
+
+func TestBackfill(t *testing.T) {
+	// The scanner options are read from the global config
+	previous := config.AppConfig.General.ScannerOptions
+	config.AppConfig.General.ScannerOptions.ParallelFetch = 1
+	t.Cleanup(func() { config.AppConfig.General.ScannerOptions = previous })
+
+	leafInput := testLeafInput(t)
+
+	// The log holds 10 entries and returns at most 2 per request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+				TreeSize:          10,
+				SHA256RootHash:    make([]byte, 32),
+				TreeHeadSignature: []byte{4, 3, 0, 0},
+			})
+		case "/ct/v1/get-entries":
+			start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+			end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+
+			var resp ct.GetEntriesResponse
+			for range min(end-start+1, 2) {
+				// The extra data is an empty chain
+				resp.Entries = append(resp.Entries, ct.LeafEntry{LeafInput: leafInput, ExtraData: []byte{0, 0, 0}})
+			}
+
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	w := &Watcher{context: t.Context(), errChan: make(chan error, errorChanSize)}
+	w.workers = []*worker{{
+		name:        "Test log",
+		ctURL:       server.URL,
+		workerCount: 1,
+		batchSize:   5,
+		backoff:     newBackoff(config.RetryConfig{InitialDelay: time.Millisecond}),
+		breaker:     newCircuitBreaker(config.CircuitBreakerConfig{}),
+		httpClient:  server.Client(),
+		logger:      logging.Nop(),
+		errChan:     w.errChan,
+	}}
+
+	if _, err := w.Backfill("Test log", 5, 10); !errors.Is(err, ErrInvalidRange) {
+		t.Errorf("got error %v for a range beyond the tree size, want ErrInvalidRange", err)
+	}
+
+	entryChan, err := w.Backfill("Test log", 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var indexes []uint64
+	for entry := range entryChan {
+		indexes = append(indexes, entry.Data.CertIndex)
+	}
+
+	slices.Sort(indexes)
+
+	if want := []uint64{3, 4, 5, 6, 7}; !slices.Equal(indexes, want) {
+		t.Errorf("got entries %v, want %v", indexes, want)
+	}
+
+	if batchSize := w.workers[0].batchSize; batchSize != 2 {
+		t.Errorf("got batch size %d, want it capped to 2", batchSize)
+	}
+
+	select {
+	case err := <-w.errChan:
+		t.Errorf("got error %v", err)
+	default:
+	}
+
+	// The last progress report tells how far the backfill got
+	var last BackfillProgress
+	entryChan, err = w.BackfillWithProgress(t.Context(), "Test log", 2, 9, func(p BackfillProgress) { last = p })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range entryChan {
+	}
+
+	if !last.Done || last.Err != nil || last.Fetched != 8 || last.Index != 9 || last.Percent() != 100 {
+		t.Errorf("got last progress %+v, want 8 of 8 entries fetched up to index 9", last)
+	}
+
+	// A cancelled backfill stops and reports the entries sent until then
+	ctx, cancel := context.WithCancel(t.Context())
+	entryChan, err = w.BackfillWithProgress(ctx, "Test log", 0, 9, func(p BackfillProgress) { last = p })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-entryChan
+	cancel()
+
+	received := 1
+	for range entryChan {
+		received++
+	}
+
+	if !last.Done || !errors.Is(last.Err, context.Canceled) || last.Fetched != uint64(received) {
+		t.Errorf("got last progress %+v after receiving %d entries, want it stopped with context.Canceled", last, received)
+	}
+}
@@ -0,0 +1,181 @@
+package certificatetransparency
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+const (
+	defaultRetryInitialDelay = time.Second
+	defaultRetryMaxDelay     = time.Minute
+	defaultRetryMultiplier   = 2
+)
+
+// backoff calculates the delay after consecutive failed requests to a CT log. Each worker has its own backoff,
+// so that a failing log doesn't slow down the others.
+type backoff struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+
+	mu       sync.Mutex
+	failures int
+}
+
+// newBackoff creates a new backoff from the given config. Unset values are replaced by their defaults.
+func newBackoff(conf config.RetryConfig) *backoff {
+	b := &backoff{
+		initialDelay: conf.InitialDelay,
+		maxDelay:     conf.MaxDelay,
+		multiplier:   conf.Multiplier,
+		jitter:       min(max(conf.Jitter, 0), 1),
+	}
+
+	if b.initialDelay <= 0 {
+		b.initialDelay = defaultRetryInitialDelay
+	}
+
+	if b.maxDelay <= 0 {
+		b.maxDelay = defaultRetryMaxDelay
+	}
+
+	if b.multiplier < 1 {
+		b.multiplier = defaultRetryMultiplier
+	}
+
+	return b
+}
+
+// failure records a failed request. It returns the number of consecutive failures and the delay before the next attempt.
+func (b *backoff) failure() (int, time.Duration) {
+	b.mu.Lock()
+	b.failures++
+	failures := b.failures
+	b.mu.Unlock()
+
+	return failures, b.delay(failures)
+}
+
+// success resets the backoff after a successful request.
+func (b *backoff) success() {
+	b.mu.Lock()
+	b.failures = 0
+	b.mu.Unlock()
+}
+
+// delay returns the delay after the given number of consecutive failures, randomized by the jitter.
+func (b *backoff) delay(failures int) time.Duration {
+	delay := float64(b.initialDelay)
+	for i := 1; i < failures && delay < float64(b.maxDelay); i++ {
+		delay *= b.multiplier
+	}
+
+	delay = min(delay, float64(b.maxDelay))
+
+	if b.jitter > 0 {
+		// Spread the delay evenly within ±jitter, so that the workers of multiple logs don't retry in lockstep
+		delay *= 1 + b.jitter*(2*rand.Float64()-1)
+	}
+
+	return time.Duration(delay)
+}
+
+// backoffTransport records the outcome of each request to a CT log. After a failed request, it waits according to
+// the backoff before returning the error, so that the retries of the scanner don't hammer the log.
+// If the circuit breaker opens, requests are held back until its cool-down is over instead.
+type backoffTransport struct {
+	base http.RoundTripper
+	// timeout limits each attempt, including reading the response body. Zero means no timeout.
+	timeout time.Duration
+	backoff *backoff
+	breaker *circuitBreaker
+	// onFailure is called for each failed request
+	onFailure func(error)
+}
+
+// RoundTrip sends the request and backs off if it failed. Responses with status 429 or 5xx count as failures.
+func (t *backoffTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := req.Context(), func() {}
+	if t.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.timeout)
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+	} else {
+		resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+
+	if req.Context().Err() != nil {
+		// Requests cancelled due to a shutdown are no failures of the log, but a cancelled probe must be released
+		t.breaker.abort()
+		return resp, err
+	}
+
+	var failure error
+	switch {
+	case err != nil:
+		failure = err
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		failure = fmt.Errorf("unexpected status code: %s", resp.Status)
+	default:
+		t.backoff.success()
+		t.breaker.success()
+
+		return resp, nil
+	}
+
+	failures, delay := t.backoff.failure()
+	t.onFailure(fmt.Errorf("request to %s failed %d time(s) in a row, backing off for %s: %w", req.URL.Path, failures, delay.Round(time.Millisecond), failure))
+
+	if t.breaker.failure() {
+		// Only report the opening of the circuit once, the following requests are held back by the breaker
+		t.onFailure(fmt.Errorf("%w after %d consecutive failures, suspending requests for %s", ErrCircuitOpen, failures, t.breaker.openDuration))
+		return resp, err
+	}
+
+	if sleepErr := sleep(req.Context(), delay); sleepErr != nil && resp != nil {
+		resp.Body.Close()
+		return nil, sleepErr
+	}
+
+	return resp, err
+}
+
+// cancelOnClose cancels the context of a request once its response body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the context of the request.
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// sleep waits for the given duration or until the context is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,48 @@
+package certificatetransparency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(config.RetryConfig{InitialDelay: time.Second, MaxDelay: 10 * time.Second, Multiplier: 3})
+
+	for _, want := range []time.Duration{time.Second, 3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if _, got := b.failure(); got != want {
+			t.Errorf("got delay %s, want %s", got, want)
+		}
+	}
+
+	b.success()
+
+	if failures, got := b.failure(); failures != 1 || got != time.Second {
+		t.Errorf("got %d failures and delay %s after success, want 1 and 1s", failures, got)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(config.RetryConfig{InitialDelay: time.Second, Jitter: 0.2})
+
+	for range 100 {
+		if got := b.delay(1); got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("got delay %s, want between 800ms and 1.2s", got)
+		}
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(config.RetryConfig{})
+
+	if b.initialDelay != defaultRetryInitialDelay || b.maxDelay != defaultRetryMaxDelay || b.multiplier != defaultRetryMultiplier {
+		t.Errorf("got %s, %s, %v, want the defaults", b.initialDelay, b.maxDelay, b.multiplier)
+	}
+}
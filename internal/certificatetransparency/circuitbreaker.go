@@ -0,0 +1,166 @@
+package certificatetransparency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+const defaultCircuitOpenDuration = 5 * time.Minute
+
+// circuitState is the state of a circuit breaker.
+type circuitState int
+
+const (
+	// circuitClosed lets all requests pass.
+	circuitClosed circuitState = iota
+	// circuitOpen holds back all requests until the cool-down is over.
+	circuitOpen
+	// circuitHalfOpen lets a single request pass to check whether the log recovered.
+	circuitHalfOpen
+)
+
+// String returns the name of the state as shown in the stats.
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker stops the requests to a CT log for a cool-down period once the log failed too many times in a row.
+// After the cool-down, a single request is let through. If it succeeds, the circuit is closed again, otherwise
+// the next cool-down starts.
+type circuitBreaker struct {
+	// threshold is the number of consecutive failures that open the circuit. Zero disables the circuit breaker.
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	state     circuitState
+	failures  int
+	openUntil time.Time
+	// probeDone is closed once the request let through in the half-open state finished
+	probeDone chan struct{}
+}
+
+// newCircuitBreaker creates a new circuit breaker from the given config.
+func newCircuitBreaker(conf config.CircuitBreakerConfig) *circuitBreaker {
+	cb := &circuitBreaker{
+		threshold:    max(conf.FailureThreshold, 0),
+		openDuration: conf.OpenDuration,
+	}
+
+	if cb.openDuration <= 0 {
+		cb.openDuration = defaultCircuitOpenDuration
+	}
+
+	return cb
+}
+
+// wait blocks while the circuit is open or while the single request of the half-open state is running.
+func (cb *circuitBreaker) wait(ctx context.Context) error {
+	for {
+		cb.mu.Lock()
+
+		switch cb.state {
+		case circuitOpen:
+			remaining := time.Until(cb.openUntil)
+			if remaining <= 0 {
+				// Let this request through to check whether the log recovered
+				cb.state = circuitHalfOpen
+				cb.probeDone = make(chan struct{})
+				cb.mu.Unlock()
+
+				return nil
+			}
+
+			cb.mu.Unlock()
+
+			if err := sleep(ctx, remaining); err != nil {
+				return err
+			}
+		case circuitHalfOpen:
+			probeDone := cb.probeDone
+			cb.mu.Unlock()
+
+			select {
+			case <-probeDone:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		default:
+			cb.mu.Unlock()
+			return nil
+		}
+	}
+}
+
+// success records a successful request and closes the circuit.
+func (cb *circuitBreaker) success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitClosed
+		close(cb.probeDone)
+	}
+}
+
+// failure records a failed request. It returns true if the circuit was opened by this failure.
+func (cb *circuitBreaker) failure() bool {
+	if cb.threshold == 0 {
+		return false
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case circuitHalfOpen:
+		close(cb.probeDone)
+	case circuitClosed:
+		cb.failures++
+		if cb.failures < cb.threshold {
+			return false
+		}
+	default:
+		// Requests sent before the circuit opened don't extend the cool-down
+		return false
+	}
+
+	cb.state = circuitOpen
+	cb.openUntil = time.Now().Add(cb.openDuration)
+
+	return true
+}
+
+// abort records a request that was cancelled before its outcome was known. If it was the probe of the half-open
+// state, the circuit is opened again without a further cool-down, so that the next request becomes the new probe.
+// Otherwise, the waiting requests would be held back until their own context ends.
+func (cb *circuitBreaker) abort() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == circuitHalfOpen {
+		cb.state = circuitOpen
+		cb.openUntil = time.Now()
+		close(cb.probeDone)
+	}
+}
+
+// getState returns the current state of the circuit.
+func (cb *circuitBreaker) getState() circuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state
+}
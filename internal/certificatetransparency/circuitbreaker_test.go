@@ -0,0 +1,128 @@
+package certificatetransparency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 3, OpenDuration: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	// A success resets the consecutive failures
+	cb.failure()
+	cb.failure()
+	cb.success()
+
+	if cb.failure() || cb.failure() {
+		t.Fatal("circuit opened before reaching the threshold")
+	}
+
+	if !cb.failure() || cb.getState() != circuitOpen {
+		t.Fatal("circuit not opened after reaching the threshold")
+	}
+
+	// Late failures of requests sent before the circuit opened don't report another opening
+	if cb.failure() {
+		t.Error("circuit reported as opened twice")
+	}
+
+	start := time.Now()
+	if err := cb.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) < 10*time.Millisecond || cb.getState() != circuitHalfOpen {
+		t.Fatalf("got state %s after %s, want half-open after the cool-down", cb.getState(), time.Since(start))
+	}
+
+	// A failed probe opens the circuit again
+	if !cb.failure() || cb.getState() != circuitOpen {
+		t.Fatal("circuit not opened again after failed probe")
+	}
+
+	if err := cb.wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	cb.success()
+
+	if cb.getState() != circuitClosed {
+		t.Errorf("got state %s after successful probe, want closed", cb.getState())
+	}
+}
+
+func TestCircuitBreakerHalfOpenWaitsForProbe(t *testing.T) {
+	t.Parallel()
+
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Millisecond})
+	cb.failure()
+
+	if err := cb.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Other requests are held back while the probe is running
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := cb.wait(ctx); err == nil {
+		t.Fatal("request passed while the probe was running")
+	}
+
+	done := make(chan error)
+	go func() { done <- cb.wait(context.Background()) }()
+
+	cb.success()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCircuitBreakerCancelledProbe(t *testing.T) {
+	t.Parallel()
+
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Millisecond})
+	cb.failure()
+
+	if err := cb.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() { done <- cb.wait(context.Background()) }()
+
+	// The probe is cancelled, e.g. by a restart of the worker, so the waiting request becomes the next probe
+	cb.abort()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request still held back after the probe was cancelled")
+	}
+
+	if state := cb.getState(); state != circuitHalfOpen {
+		t.Errorf("got state %s, want half-open", state)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	t.Parallel()
+
+	cb := newCircuitBreaker(config.CircuitBreakerConfig{})
+
+	for range 100 {
+		if cb.failure() {
+			t.Fatal("disabled circuit breaker opened")
+		}
+	}
+}
@@ -2,47 +2,73 @@ package certificatetransparency
 
 import (
 	"bytes"
+	"crypto/dsa" //nolint:staticcheck
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha1" //nolint:gosec
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/letrics/certstream-server-go/pkg/config"
 	"github.com/letrics/certstream-server-go/pkg/models"
 	"hash"
-	"log"
 	"math/big"
+	"net"
+	"net/netip"
+	"slices"
 	"strings"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/tls"
 	"github.com/google/certificate-transparency-go/x509"
 	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
 )
 
+// updateType returns the update type of an entry with the given log entry type of its Merkle tree leaf. Entries of
+// unknown types are treated like final certificates, but can't be parsed anyway.
+func updateType(entryType ct.LogEntryType) models.UpdateType {
+	if entryType == ct.PrecertLogEntryType {
+		return models.UpdateTypePrecert
+	}
+
+	return models.UpdateTypeX509
+}
+
 // parseData converts a *ct.RawLogEntry struct into a certstream.Data struct by copying some values and calculating others.
 func parseData(entry *ct.RawLogEntry, operatorName, logName, ctURL string) (models.Data, error) {
 	certLink := fmt.Sprintf("%s/ct/v1/get-entries?start=%d&end=%d", ctURL, entry.Index, entry.Index)
 
+	// Logs from the additional_logs config might not have a description. Use the url instead, so every entry can be
+	// attributed to its source log.
+	if logName == "" {
+		logName = normalizeCtlogURL(ctURL)
+	}
+
 	// Create main data structure
 	data := models.Data{
 		CertIndex: uint64(entry.Index),
 		CertLink:  certLink,
-		Seen:      float64(time.Now().UnixMilli()) / 1_000,
+		Seen:      models.NewTimestamp(time.Now()),
 		Source: models.Source{
 			Name:          logName,
 			URL:           ctURL,
 			Operator:      operatorName,
 			NormalizedURL: normalizeCtlogURL(ctURL),
 		},
-		UpdateType: "X509LogEntry",
+		UpdateType: updateType(entry.Leaf.TimestampedEntry.EntryType),
 	}
 
 	// Convert RawLogEntry to ct.LogEntry
 	logEntry, conversionErr := entry.ToLogEntry()
 	if conversionErr != nil {
-		log.Println("Could not convert entry to LogEntry: ", conversionErr)
-		return models.Data{}, conversionErr
+		return models.Data{}, fmt.Errorf("could not convert entry to LogEntry: %w", conversionErr)
 	}
 
 	var cert *x509.Certificate
@@ -65,39 +91,62 @@ func parseData(entry *ct.RawLogEntry, operatorName, logName, ctURL string) (mode
 	// Calculate certificate hash from the raw DER bytes of the certificate
 	data.LeafCert = leafCertFromX509cert(*cert)
 
+	// Final certificates carrying the poison extension were wrongly submitted as X509 entry, but are precertificates, too
+	data.LeafCert.IsPrecert = isPrecert || data.LeafCert.Extensions.CTLPoisonByte
+
 	// recalculate hashes if the certificate is a precertificate
 	if isPrecert {
-		calculatedHash := calculateSHA1(rawData)
-		data.LeafCert.Fingerprint = calculatedHash
-		data.LeafCert.SHA1 = calculatedHash
-		data.LeafCert.SHA256 = calculateSHA256(rawData)
+		setFingerprints(&data.LeafCert, rawData)
 	}
 
 	certAsDER := base64.StdEncoding.EncodeToString(entry.Cert.Data)
 	data.LeafCert.AsDER = certAsDER
 
+	if config.AppConfig.General.IncludeDER {
+		data.LeafCert.DER = entry.Cert.Data
+	}
+
+	if config.AppConfig.General.IncludeLeafHash {
+		// The leaf input is re-encoded from the parsed leaf, which yields the same bytes as fetched from the log
+		leafHash, err := ct.LeafHashForLeaf(&entry.Leaf)
+		if err != nil {
+			return models.Data{}, fmt.Errorf("could not calculate leaf hash: %w", err)
+		}
+
+		data.LeafHash = base64.StdEncoding.EncodeToString(leafHash[:])
+	}
+
 	var parseErr error
 	data.Chain, parseErr = parseCertificateChain(logEntry)
 	if parseErr != nil {
-		log.Println("Could not parse certificate chain: ", parseErr)
-		return models.Data{}, parseErr
+		return models.Data{}, fmt.Errorf("could not parse certificate chain: %w", parseErr)
 	}
 
 	return data, nil
 }
 
 // parseCertificateChain returns the certificate chain in form of a []LeafCert from the given *ct.LogEntry.
+// The chain consists of the intermediate certificates up to the root, as submitted to the CT log.
 func parseCertificateChain(logEntry *ct.LogEntry) ([]models.LeafCert, error) {
 	chain := make([]models.LeafCert, len(logEntry.Chain))
 
 	for i, chainEntry := range logEntry.Chain {
 		myCert, parseErr := x509.ParseCertificate(chainEntry.Data)
 		if parseErr != nil {
-			log.Println("Error parsing certificate: ", parseErr)
 			return nil, parseErr
 		}
 
 		leafCert := leafCertFromX509cert(*myCert)
+
+		// The DER of the chain certificates makes up a large part of the payload, so it can be left out. A config
+		// without defaults includes it, like before the option existed.
+		switch includeChainDER := config.AppConfig.General.IncludeChainDER; {
+		case includeChainDER != nil && !*includeChainDER:
+			leafCert.AsDER = ""
+		case config.AppConfig.General.IncludeDER:
+			leafCert.DER = chainEntry.Data
+		}
+
 		chain[i] = leafCert
 	}
 
@@ -107,42 +156,41 @@ func parseCertificateChain(logEntry *ct.LogEntry) ([]models.LeafCert, error) {
 // leafCertFromX509cert converts a x509.Certificate to the custom LeafCert data structure.
 func leafCertFromX509cert(cert x509.Certificate) models.LeafCert {
 	leafCert := models.LeafCert{
-		AllDomains:         cert.DNSNames,
 		Extensions:         models.Extensions{},
-		NotAfter:           cert.NotAfter.Unix(),
-		NotBefore:          cert.NotBefore.Unix(),
+		NotAfter:           models.UnixTime(cert.NotAfter.Unix()),
+		NotBefore:          models.UnixTime(cert.NotBefore.Unix()),
+		NotBeforeTime:      cert.NotBefore.UTC(),
+		NotAfterTime:       cert.NotAfter.UTC(),
+		ValidityDays:       validityDays(cert.NotBefore, cert.NotAfter),
 		SerialNumber:       formatSerialNumber(cert.SerialNumber),
 		SignatureAlgorithm: parseSignatureAlgorithm(cert.SignatureAlgorithm),
 		IsCA:               cert.IsCA,
 	}
 
-	// The zero value of DomainsEntry.Data is nil, but we want an empty array - especially for json marshalling later.
-	if leafCert.AllDomains == nil {
-		leafCert.AllDomains = []string{}
-	}
-
 	leafCert.Subject = buildSubject(cert.Subject)
-	if *leafCert.Subject.CN != "" && !leafCert.IsCA {
-		domainAlreadyAdded := false
-		// TODO check if CN matches domain regex
-		for _, domain := range leafCert.AllDomains {
-			if domain == *leafCert.Subject.CN {
-				domainAlreadyAdded = true
-				break
-			}
-		}
 
-		if !domainAlreadyAdded {
-			leafCert.AllDomains = append(leafCert.AllDomains, *leafCert.Subject.CN)
-		}
+	dnsNames, ipAddresses := splitIPAddresses(cert.DNSNames, cert.IPAddresses, *leafCert.Subject.CN)
+	leafCert.IPAddresses = ipAddresses
+
+	if config.AppConfig.General.CompactDomains {
+		setDomainsCompact(&leafCert, dnsNames)
+	} else {
+		setDomains(&leafCert, dnsNames)
 	}
 
 	leafCert.Issuer = buildSubject(cert.Issuer)
+	leafCert.SCTs = parseSCTs(cert)
+	leafCert.PublicKey = parsePublicKey(cert)
+	leafCert.ExtendedKeyUsages = extKeyUsages(cert)
+	leafCert.KeyUsages = keyUsages(cert.KeyUsage)
+	leafCert.CRLDistributionPoints = cert.CRLDistributionPoints
+	leafCert.OCSPServers = cert.OCSPServer
 
 	leafCert.AsDER = base64.StdEncoding.EncodeToString(cert.Raw)
-	leafCert.Fingerprint = calculateSHA1(cert.Raw)
-	leafCert.SHA1 = leafCert.Fingerprint
-	leafCert.SHA256 = calculateSHA256(cert.Raw)
+	setFingerprints(&leafCert, cert.Raw)
+
+	leafCert.SubjectKeyID = strings.ToUpper(hex.EncodeToString(cert.SubjectKeyId))
+	leafCert.AuthorityKeyID = strings.ToUpper(hex.EncodeToString(cert.AuthorityKeyId))
 
 	// TODO fix Extensions - check x509util.go
 	for _, extension := range cert.Extensions {
@@ -193,15 +241,276 @@ func leafCertFromX509cert(cert x509.Certificate) models.LeafCert {
 	return leafCert
 }
 
+// parseSCTs parses the Signed Certificate Timestamps from the SCT list extension of the certificate.
+// For final certificates, the extension contains the SCTs the CA obtained for the corresponding precertificate.
+// Precertificates carry the CT poison extension instead and therefore usually don't contain any SCTs.
+// An empty slice is returned if no SCTs are present. SCTs that can't be parsed are skipped.
+func parseSCTs(cert x509.Certificate) []models.SCT {
+	scts := make([]models.SCT, 0, len(cert.SCTList.SCTList))
+
+	for _, serializedSCT := range cert.SCTList.SCTList {
+		var sct ct.SignedCertificateTimestamp
+		if _, err := tls.Unmarshal(serializedSCT.Val, &sct); err != nil {
+			continue
+		}
+
+		scts = append(scts, models.SCT{
+			LogID:     base64.StdEncoding.EncodeToString(sct.LogID.KeyID[:]),
+			Timestamp: ct.TimestampToTime(sct.Timestamp).UTC(),
+		})
+	}
+
+	return scts
+}
+
+// parsePublicKey extracts the algorithm, size and curve of the public key of the certificate. The SPKI hash is
+// calculated from the raw SubjectPublicKeyInfo, so it is available even if the key itself can't be parsed.
+func parsePublicKey(cert x509.Certificate) models.PublicKey {
+	publicKey := models.PublicKey{
+		Algorithm:  "unknown",
+		SPKISHA256: calculateSHA256(cert.RawSubjectPublicKeyInfo),
+	}
+
+	if cert.PublicKeyAlgorithm != x509.UnknownPublicKeyAlgorithm {
+		publicKey.Algorithm = cert.PublicKeyAlgorithm.String()
+	}
+
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		publicKey.Size = key.N.BitLen()
+	case *ecdsa.PublicKey:
+		params := key.Curve.Params()
+		publicKey.Size = params.BitSize
+		publicKey.Curve = params.Name
+	case ed25519.PublicKey:
+		publicKey.Size = ed25519.PublicKeySize * 8
+	case *dsa.PublicKey:
+		publicKey.Size = key.P.BitLen()
+	default:
+		publicKey.Unparseable = true
+	}
+
+	return publicKey
+}
+
+// splitIPAddresses returns the DNS SANs without the ones that are actually IP addresses, as well as the normalized
+// IP addresses of the IP SANs, the DNS SANs and the CN. IPv4-mapped IPv6 addresses are converted to IPv4 and IPv6
+// addresses are written in their canonical, compressed form, so that the same address is always written the same way.
+// The DNS SANs are only copied if some of them are IP addresses. Nil is returned if there are no IP addresses.
+func splitIPAddresses(dnsNames []string, ipSANs []net.IP, cn string) ([]string, []string) {
+	var ipAddresses []string
+
+	addIP := func(addr netip.Addr) {
+		if ip := addr.Unmap().WithZone("").String(); !slices.Contains(ipAddresses, ip) {
+			ipAddresses = append(ipAddresses, ip)
+		}
+	}
+
+	for _, ip := range ipSANs {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addIP(addr)
+		}
+	}
+
+	if slices.ContainsFunc(dnsNames, isIPAddress) {
+		domains := make([]string, 0, len(dnsNames))
+
+		for _, name := range dnsNames {
+			if addr, ok := parseIPAddress(name); ok {
+				addIP(addr)
+				continue
+			}
+
+			domains = append(domains, name)
+		}
+
+		dnsNames = domains[:len(domains):len(domains)]
+	}
+
+	if addr, ok := parseIPAddress(cn); ok {
+		addIP(addr)
+	}
+
+	return dnsNames, ipAddresses
+}
+
+// parseIPAddress parses the name if it is an IP address. Like isIPAddress, it doesn't allocate for domains.
+func parseIPAddress(name string) (netip.Addr, bool) {
+	if !isIPAddress(name) {
+		return netip.Addr{}, false
+	}
+
+	addr, err := netip.ParseAddr(name)
+
+	return addr, err == nil
+}
+
+// setDomains sets the domains of the leaf certificate: the SANs and the CN, unless it is already among them or an
+// IP address, as well as their Unicode form and their registrable domains.
+func setDomains(leafCert *models.LeafCert, dnsNames []string) {
+	leafCert.AllDomains = dnsNames
+
+	// The zero value of DomainsEntry.Data is nil, but we want an empty array - especially for json marshalling later.
+	if leafCert.AllDomains == nil {
+		leafCert.AllDomains = []string{}
+	}
+
+	if cn := *leafCert.Subject.CN; cn != "" && !leafCert.IsCA && !isIPAddress(cn) && !slices.Contains(leafCert.AllDomains, cn) {
+		// TODO check if CN matches domain regex
+		leafCert.AllDomains = append(leafCert.AllDomains, cn)
+	}
+
+	leafCert.AllDomainsUnicode = decodeDomains(leafCert.AllDomains)
+	leafCert.RegistrableDomains = registrableDomains(leafCert.AllDomains)
+}
+
+// setDomainsCompact sets the same domains as setDomains with fewer allocations, which adds up at full firehose
+// volume: all slices share a single backing array and, unless a domain is internationalized, the Unicode domains
+// share the array of AllDomains. The slices are capped, so appending to one of them doesn't overwrite the others.
+func setDomainsCompact(leafCert *models.LeafCert, dnsNames []string) {
+	cn := *leafCert.Subject.CN
+	addCN := cn != "" && !leafCert.IsCA && !isIPAddress(cn) && !slices.Contains(dnsNames, cn)
+
+	domainCount := len(dnsNames)
+	if addCN {
+		domainCount++
+	}
+
+	internationalized := slices.ContainsFunc(dnsNames, hasACEPrefix) || (addCN && hasACEPrefix(cn))
+
+	// The SANs are only copied if the CN is appended to them
+	size := domainCount
+	if addCN || dnsNames == nil {
+		size += domainCount
+	}
+
+	if internationalized {
+		size += domainCount
+	}
+
+	buf := make([]string, size)
+	next := 0
+	carve := func(n int) []string {
+		s := buf[next : next : next+n]
+		next += n
+
+		return s
+	}
+
+	leafCert.AllDomains = dnsNames[:len(dnsNames):len(dnsNames)]
+	if addCN || dnsNames == nil {
+		leafCert.AllDomains = append(carve(domainCount), dnsNames...)
+		if addCN {
+			leafCert.AllDomains = append(leafCert.AllDomains, cn)
+		}
+	}
+
+	leafCert.AllDomainsUnicode = leafCert.AllDomains
+	if internationalized {
+		leafCert.AllDomainsUnicode = appendDecodedDomains(carve(domainCount), leafCert.AllDomains)
+	}
+
+	leafCert.RegistrableDomains = appendRegistrableDomains(carve(domainCount), leafCert.AllDomains)
+}
+
+// hasACEPrefix returns true if the domain contains a label with the case-insensitive ACE prefix "xn--", which marks
+// internationalized domains. Unlike strings.ToLower, it never allocates.
+func hasACEPrefix(domain string) bool {
+	for i := 0; i+4 <= len(domain); i++ {
+		if strings.EqualFold(domain[i:i+4], "xn--") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeDomains returns the Unicode form of the given domains, e.g. "bücher.example" for "xn--bcher-kva.example".
+// Domains that are not internationalized or can't be decoded are returned unchanged.
+func decodeDomains(domains []string) []string {
+	return appendDecodedDomains(make([]string, 0, len(domains)), domains)
+}
+
+// appendDecodedDomains appends the Unicode form of the given domains to dst. See decodeDomains.
+func appendDecodedDomains(dst, domains []string) []string {
+	for _, domain := range domains {
+		if !hasACEPrefix(domain) {
+			dst = append(dst, domain)
+			continue
+		}
+
+		// The ACE prefix is case-insensitive, but only recognized in lower case by the idna package
+		if unicodeDomain, err := idna.Punycode.ToUnicode(strings.ToLower(domain)); err == nil {
+			domain = unicodeDomain
+		}
+
+		dst = append(dst, domain)
+	}
+
+	return dst
+}
+
+// registrableDomains returns the distinct registrable domains (eTLD+1) of the given domains, e.g. "example.co.uk" for
+// "*.www.example.co.uk". IP addresses and domains without a registrable part, such as public suffixes, are skipped.
+func registrableDomains(domains []string) []string {
+	return appendRegistrableDomains(make([]string, 0, len(domains)), domains)
+}
+
+// appendRegistrableDomains appends the distinct registrable domains of the given domains to dst.
+// See registrableDomains.
+func appendRegistrableDomains(dst, domains []string) []string {
+	start := len(dst)
+
+	for _, domain := range domains {
+		domain = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(domain), "*."), ".")
+		if isIPAddress(domain) {
+			continue
+		}
+
+		registrableDomain, err := publicsuffix.EffectiveTLDPlusOne(domain)
+		if err != nil || slices.Contains(dst[start:], registrableDomain) {
+			continue
+		}
+
+		dst = append(dst, registrableDomain)
+	}
+
+	return dst
+}
+
+// isIPAddress returns true if the domain is an IP address. Domains with other characters than those of IP addresses
+// are rejected upfront, since net.ParseIP allocates an error for each of them.
+func isIPAddress(domain string) bool {
+	for _, c := range domain {
+		if c != '.' && c != ':' && !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') && !('A' <= c && c <= 'F') {
+			return false
+		}
+	}
+
+	return net.ParseIP(domain) != nil
+}
+
+// containsWildcardDomain returns true if at least one of the domains is a wildcard domain (e.g. "*.example.com").
+func containsWildcardDomain(domains []string) bool {
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") {
+			return true
+		}
+	}
+
+	return false
+}
+
 // buildSubject generates a Subject struct from the given pkix.Name.
 func buildSubject(certSubject pkix.Name) models.Subject {
 	subject := models.Subject{
-		C:  parseName(certSubject.Country),
-		CN: &certSubject.CommonName,
-		L:  parseName(certSubject.Locality),
-		O:  parseName(certSubject.Organization),
-		OU: parseName(certSubject.OrganizationalUnit),
-		ST: parseName(certSubject.StreetAddress),
+		C:            parseName(certSubject.Country),
+		CN:           &certSubject.CommonName,
+		L:            parseName(certSubject.Locality),
+		O:            parseName(certSubject.Organization),
+		OU:           parseName(certSubject.OrganizationalUnit),
+		ST:           parseName(certSubject.Province),
+		EmailAddress: parseEmailAddress(certSubject),
 	}
 
 	var aggregated string
@@ -259,6 +568,23 @@ func formatSerialNumber(serialNumber *big.Int) string {
 	return sn
 }
 
+// oidEmailAddress is the OID of the legacy emailAddress attribute of distinguished names (PKCS #9), which pkix.Name
+// doesn't parse into a field of its own.
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+// parseEmailAddress returns the email addresses of the name, joined like the other attributes. Nil if there are none.
+func parseEmailAddress(name pkix.Name) *string {
+	var addresses []string
+
+	for _, attribute := range name.Names {
+		if address, ok := attribute.Value.(string); ok && attribute.Type.Equal(oidEmailAddress) {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return parseName(addresses)
+}
+
 func parseName(input []string) *string {
 	if input == nil {
 		return nil
@@ -276,14 +602,19 @@ func parseName(input []string) *string {
 	return &result
 }
 
+// setFingerprints sets the SHA-1 and SHA-256 fingerprints of the certificate with the given DER encoding.
+func setFingerprints(leafCert *models.LeafCert, der []byte) {
+	leafCert.SHA1 = calculateSHA1(der)
+	leafCert.SHA256 = calculateSHA256(der)
+	leafCert.Fingerprint = leafCert.SHA256
+	leafCert.FingerprintSHA1 = leafCert.SHA1
+}
+
 // calculateHash takes a hash.Hash implementation and calculates the hash of the given data.
 // It returns the hash in the format "XX:XX:XX:...".
 func calculateHash(data []byte, certHasher hash.Hash) string {
-	_, e := certHasher.Write(data)
-	if e != nil {
-		log.Printf("Error while hashing cert: %s\n", e)
-		return ""
-	}
+	// Writing to a hash.Hash never returns an error
+	_, _ = certHasher.Write(data)
 
 	certHash := fmt.Sprintf("%02x", certHasher.Sum(nil))
 	certHash = strings.ToUpper(certHash)
@@ -360,45 +691,74 @@ func commaAppend(buf *bytes.Buffer, s string) {
 	buf.WriteString(s)
 }
 
-func keyUsageToString(k x509.KeyUsage) string {
-	var buf bytes.Buffer
-	if k&x509.KeyUsageDigitalSignature != 0 {
-		commaAppend(&buf, "Digital Signature")
-	}
+// keyUsageNames maps the key usages to their names in the order they are defined in RFC 5280.
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "Digital Signature"},
+	{x509.KeyUsageContentCommitment, "Content Commitment"},
+	{x509.KeyUsageKeyEncipherment, "Key Encipherment"},
+	{x509.KeyUsageDataEncipherment, "Data Encipherment"},
+	{x509.KeyUsageKeyAgreement, "Key Agreement"},
+	{x509.KeyUsageCertSign, "Certificate Signing"},
+	{x509.KeyUsageCRLSign, "CRL Signing"},
+	{x509.KeyUsageEncipherOnly, "Encipher Only"},
+	{x509.KeyUsageDecipherOnly, "Decipher Only"},
+}
 
-	if k&x509.KeyUsageContentCommitment != 0 {
-		commaAppend(&buf, "Content Commitment")
-	}
+// keyUsages returns the names of all key usages set in k.
+func keyUsages(k x509.KeyUsage) []string {
+	usages := make([]string, 0, len(keyUsageNames))
 
-	if k&x509.KeyUsageKeyEncipherment != 0 {
-		commaAppend(&buf, "Key Encipherment")
+	for _, keyUsage := range keyUsageNames {
+		if k&keyUsage.usage != 0 {
+			usages = append(usages, keyUsage.name)
+		}
 	}
 
-	if k&x509.KeyUsageDataEncipherment != 0 {
-		commaAppend(&buf, "Data Encipherment")
-	}
+	return usages
+}
 
-	if k&x509.KeyUsageKeyAgreement != 0 {
-		commaAppend(&buf, "Key Agreement")
-	}
+func keyUsageToString(k x509.KeyUsage) string {
+	return strings.Join(keyUsages(k), ", ")
+}
 
-	if k&x509.KeyUsageCertSign != 0 {
-		commaAppend(&buf, "Certificate Signing")
-	}
+// extKeyUsageNames maps the extended key usages to their short names as used in RFC 5280 and by OpenSSL.
+var extKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "anyExtendedKeyUsage",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCodeSigning",
+	x509.ExtKeyUsageCertificateTransparency:        "certificateTransparency",
+}
 
-	if k&x509.KeyUsageCRLSign != 0 {
-		commaAppend(&buf, "CRL Signing")
-	}
+// extKeyUsages returns the names of the extended key usages of the certificate.
+// Extended key usages without a known name are returned as their dotted OID.
+func extKeyUsages(cert x509.Certificate) []string {
+	usages := make([]string, 0, len(cert.ExtKeyUsage)+len(cert.UnknownExtKeyUsage))
 
-	if k&x509.KeyUsageEncipherOnly != 0 {
-		commaAppend(&buf, "Encipher Only")
+	for _, extKeyUsage := range cert.ExtKeyUsage {
+		if name, ok := extKeyUsageNames[extKeyUsage]; ok {
+			usages = append(usages, name)
+		}
 	}
 
-	if k&x509.KeyUsageDecipherOnly != 0 {
-		commaAppend(&buf, "Decipher Only")
+	for _, oid := range cert.UnknownExtKeyUsage {
+		usages = append(usages, oid.String())
 	}
 
-	return buf.String()
+	return usages
 }
 
 // ParseCertstreamEntry creates an Entry from a ct.RawLogEntry.
@@ -419,3 +779,13 @@ func ParseCertstreamEntry(rawEntry *ct.RawLogEntry, operatorName, logname, ctURL
 
 	return entry, nil
 }
+
+// validityDays returns the number of full days between notBefore and notAfter. Both bounds are inclusive (RFC 5280),
+// so a certificate with a notAfter one second before the end of the 90th day is valid for 90 days.
+func validityDays(notBefore, notAfter time.Time) int {
+	if notAfter.Before(notBefore) {
+		return 0
+	}
+
+	return int((notAfter.Sub(notBefore) + time.Second) / (24 * time.Hour))
+}
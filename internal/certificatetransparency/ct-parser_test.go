@@ -0,0 +1,225 @@
+package certificatetransparency
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestValidityDays(t *testing.T) {
+	t.Parallel()
+
+	notBefore := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		notAfter time.Time
+		want     int
+	}{
+		// Let's Encrypt style: the last second of the 90th day is inclusive
+		{notBefore.Add(90*24*time.Hour - time.Second), 90},
+		{notBefore.Add(90 * 24 * time.Hour), 90},
+		{notBefore.Add(7*24*time.Hour - time.Second), 7},
+		{notBefore.Add(12 * time.Hour), 0},
+		{notBefore.Add(-time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		if got := validityDays(notBefore, tt.notAfter); got != tt.want {
+			t.Errorf("validityDays(%s, %s) = %d, want %d", notBefore, tt.notAfter, got, tt.want)
+		}
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key       any
+		keyAlgo   x509.PublicKeyAlgorithm
+		algorithm string
+		size      int
+		curve     string
+	}{
+		{&rsaKey.PublicKey, x509.RSA, "RSA", 2048, ""},
+		{&ecdsaKey.PublicKey, x509.ECDSA, "ECDSA", 384, "P-384"},
+		{ed25519Key, x509.Ed25519, "Ed25519", 256, ""},
+	}
+
+	for _, tt := range tests {
+		spki, err := x509.MarshalPKIXPublicKey(tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		publicKey, err := x509.ParsePKIXPublicKey(spki)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := parsePublicKey(x509.Certificate{
+			PublicKeyAlgorithm:      tt.keyAlgo,
+			PublicKey:               publicKey,
+			RawSubjectPublicKeyInfo: spki,
+		})
+
+		want := models.PublicKey{Algorithm: tt.algorithm, Size: tt.size, Curve: tt.curve, SPKISHA256: calculateSHA256(spki)}
+		if got != want {
+			t.Errorf("parsePublicKey() = %+v, want %+v", got, want)
+		}
+	}
+
+	got := parsePublicKey(x509.Certificate{RawSubjectPublicKeyInfo: []byte{0x30, 0x00}})
+	if !got.Unparseable || got.Algorithm != "unknown" || got.SPKISHA256 == "" {
+		t.Errorf("parsePublicKey() of unknown key = %+v, want unparseable key with SPKI hash", got)
+	}
+}
+
+func TestExtKeyUsages(t *testing.T) {
+	t.Parallel()
+
+	cert := x509.Certificate{
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		UnknownExtKeyUsage: []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 311, 10, 3, 4}},
+	}
+
+	got := extKeyUsages(cert)
+	want := []string{"serverAuth", "clientAuth", "1.3.6.1.4.1.311.10.3.4"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("extKeyUsages() = %v, want %v", got, want)
+	}
+}
+
+func TestDecodeDomains(t *testing.T) {
+	t.Parallel()
+
+	// The "а" in "pаypal" is a cyrillic letter
+	domains := []string{"example.com", "xn--bcher-kva.example", "*.XN--PYPAL-4VE.com", "xn--99999999.example"}
+	want := []string{"example.com", "bücher.example", "*.pаypal.com", "xn--99999999.example"}
+
+	if got := decodeDomains(domains); !slices.Equal(got, want) {
+		t.Errorf("decodeDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestSplitIPAddresses(t *testing.T) {
+	t.Parallel()
+
+	dnsNames := []string{"example.com", "192.0.2.1", "2001:DB8:0:0::1", "cafe.be"}
+	ipSANs := []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("::ffff:198.51.100.7"), net.ParseIP("2001:db8::2")}
+
+	gotNames, gotIPs := splitIPAddresses(dnsNames, ipSANs, "203.0.113.5")
+
+	if want := []string{"example.com", "cafe.be"}; !slices.Equal(gotNames, want) {
+		t.Errorf("splitIPAddresses() names = %v, want %v", gotNames, want)
+	}
+
+	if want := []string{"192.0.2.1", "198.51.100.7", "2001:db8::2", "2001:db8::1", "203.0.113.5"}; !slices.Equal(gotIPs, want) {
+		t.Errorf("splitIPAddresses() IPs = %v, want %v", gotIPs, want)
+	}
+
+	// Without IP addresses, the DNS names are passed through
+	if gotNames, gotIPs = splitIPAddresses(dnsNames[:1], nil, "example.com"); &gotNames[0] != &dnsNames[0] || gotIPs != nil {
+		t.Errorf("splitIPAddresses() without IPs = %v, %v, want the DNS names and nil", gotNames, gotIPs)
+	}
+}
+
+func TestRegistrableDomains(t *testing.T) {
+	t.Parallel()
+
+	domains := []string{"www.example.com", "*.example.com", "mail.example.co.uk", "Example.co.uk.", "192.0.2.1", "2001:db8::1", "co.uk"}
+	want := []string{"example.com", "example.co.uk"}
+
+	if got := registrableDomains(domains); !slices.Equal(got, want) {
+		t.Errorf("registrableDomains() = %v, want %v", got, want)
+	}
+}
+
+// domainTestCerts are the cases for the domain extraction: the CN among the SANs, the CN missing from the SANs,
+// an internationalized CN and a CA without SANs.
+var domainTestCerts = []struct {
+	name     string
+	dnsNames []string
+	cn       string
+	isCA     bool
+}{
+	{"cn_in_sans", []string{"example.com", "www.example.com", "mail.example.com"}, "example.com", false},
+	{"cn_missing", []string{"www.example.com", "mail.example.co.uk"}, "example.org", false},
+	{"idn", []string{"example.com"}, "xn--bcher-kva.example", false},
+	{"ca", nil, "Example CA", true},
+}
+
+func TestSetDomainsCompact(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range domainTestCerts {
+		want := models.LeafCert{Subject: models.Subject{CN: &tt.cn}, IsCA: tt.isCA}
+		got := want
+
+		setDomains(&want, slices.Clone(tt.dnsNames))
+		setDomainsCompact(&got, slices.Clone(tt.dnsNames))
+
+		if !slices.Equal(got.AllDomains, want.AllDomains) || got.AllDomains == nil ||
+			!slices.Equal(got.AllDomainsUnicode, want.AllDomainsUnicode) || got.AllDomainsUnicode == nil ||
+			!slices.Equal(got.RegistrableDomains, want.RegistrableDomains) || got.RegistrableDomains == nil {
+			t.Errorf("%s: setDomainsCompact() = %v, %v, %v, want %v, %v, %v", tt.name,
+				got.AllDomains, got.AllDomainsUnicode, got.RegistrableDomains,
+				want.AllDomains, want.AllDomainsUnicode, want.RegistrableDomains)
+		}
+
+		// Appending to one slice must not overwrite another one
+		_ = append(got.AllDomains, "appended.example")
+		if !slices.Equal(got.RegistrableDomains, want.RegistrableDomains) {
+			t.Errorf("%s: appending to AllDomains changed RegistrableDomains to %v", tt.name, got.RegistrableDomains)
+		}
+	}
+}
+
+func BenchmarkSetDomains(b *testing.B) {
+	for _, tt := range domainTestCerts {
+		for _, compact := range []bool{false, true} {
+			name := tt.name + "/default"
+			setter := setDomains
+
+			if compact {
+				name = tt.name + "/compact"
+				setter = setDomainsCompact
+			}
+
+			b.Run(name, func(b *testing.B) {
+				b.ReportAllocs()
+
+				for range b.N {
+					leafCert := models.LeafCert{Subject: models.Subject{CN: &tt.cn}, IsCA: tt.isCA}
+					// The SANs are capped like the ones parsed from a certificate, so appending the CN copies them
+					setter(&leafCert, tt.dnsNames[:len(tt.dnsNames):len(tt.dnsNames)])
+				}
+			})
+		}
+	}
+}
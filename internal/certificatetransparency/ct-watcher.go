@@ -2,12 +2,13 @@ package certificatetransparency
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/letrics/certstream-server-go/pkg/config"
 	"github.com/letrics/certstream-server-go/pkg/models"
-	"io"
-	"log"
+	"math"
+	"math/rand/v2"
 	"net/http"
 	"path/filepath"
 	"strings"
@@ -20,9 +21,26 @@ import (
 	"github.com/google/certificate-transparency-go/jsonclient"
 	"github.com/google/certificate-transparency-go/loglist3"
 	"github.com/google/certificate-transparency-go/scanner"
+	"github.com/letrics/certstream-server-go/internal/detection"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
+	// ErrUnknownLog is returned when an operation refers to a CT log that is not being watched.
+	ErrUnknownLog = errors.New("unknown ct log")
+	// ErrLogAlreadyWatched is returned when a CT log should be added that is already being watched.
+	ErrLogAlreadyWatched = errors.New("ct log is already being watched")
+	// ErrCircuitOpen is reported when requests to a CT log are suspended because it failed too many times in a row.
+	ErrCircuitOpen = errors.New("circuit breaker open")
+	// ErrInvalidRange is returned when a backfill is requested for a range of entries that the CT log doesn't hold.
+	ErrInvalidRange = errors.New("invalid entry range")
+	// ErrWatcherStopped is returned when logs should be added or reloaded after the watcher began to shut down.
+	ErrWatcherStopped = errors.New("watcher stopped")
+
 	errCreatingClient    = errors.New("failed to create JSON client")
 	errFetchingSTHFailed = errors.New("failed to fetch STH")
 	userAgent            = fmt.Sprintf("Certstream Server v%s (github.com/letrics/certstream-server-go)", config.Version)
@@ -35,49 +53,287 @@ type Watcher struct {
 	wg         sync.WaitGroup
 	context    context.Context
 	certChan   chan models.Entry
-	workerChan chan models.Entry
+	workerChan chan workerEntry
+	// reloadMu makes sure that only one update of the log list happens at the same time
+	reloadMu sync.Mutex
+	// stopped is set under reloadMu once the watcher shuts down, so that no workers are started afterwards
+	stopped bool
+	// customLogs contains the logs added at runtime via AddLog
+	customLogs []config.LogConfig
+	errChan    chan error
 	cancelFunc context.CancelFunc
+	// done is closed once StartWithContext returned, i.e. after the shutdown sequence completed
+	done chan struct{}
+
+	domainFilter atomic.Pointer[DomainFilter]
+	// lookalike flags certificates for domains that are confusable with a watchlist. Nil if the detection is disabled.
+	lookalike atomic.Pointer[detection.Lookalike]
+	// firstSeen flags certificates for registrable domains seen for the first time. Nil if the detection is disabled.
+	firstSeen atomic.Pointer[detection.FirstSeen]
+	// httpClient is shared by all workers. It is created from the config on first use, unless set via SetHTTPClient.
+	httpClient   *http.Client
+	httpClientMu sync.Mutex
+	// http1Client is the HTTP/1.1-only variant of httpClient for logs with HTTP/2 disabled. Created on first use from
+	// http1Base, so that it is recreated if another client is set.
+	http1Client *http.Client
+	http1Base   *http.Client
+	// recoveryStore persists the indexes of the logs. Nil if recovery is disabled.
+	recoveryStore RecoveryStore
+	// requestLimiter limits the rate of the get-entries requests of all workers. Nil if the rate limit is disabled.
+	requestLimiter *requestLimiter
+	// sampleRate holds the bits of the float64 fraction of entries that are forwarded to the output channel.
+	sampleRate atomic.Uint64
+	// saveEvery is the number of consumed entries after which the indexes are saved, even if the flush interval
+	// didn't elapse yet. Zero if the indexes are only saved in the interval.
+	saveEvery uint64
+	// consumed is the number of entries consumed since the indexes were last saved, see countConsumed.
+	consumed atomic.Uint64
+	// saveNow makes the saver save the indexes right away.
+	saveNow chan struct{}
+	// heartbeatInterval is the time without forwarded entries after which a heartbeat is sent. Zero disables it.
+	heartbeatInterval time.Duration
+	// logger is shared by all workers. It must not be changed once the watcher is started.
+	logger logging.Logger
+	// acks defers the advancement of the recovery indexes until the entries are acknowledged. Nil if disabled.
+	acks *ackTracker
+	// parseQueue passes the raw entries of all workers to the parse workers. Nil if the entries are parsed by the
+	// scanner goroutines of each worker. It is read by the metrics while the watcher starts, hence the atomic.
+	parseQueue atomic.Pointer[chan parseJob]
 }
 
+// workerChanSize is the number of entries buffered between the workers and the certHandler.
+const workerChanSize = 5000
+
 // NewWatcher creates a new Watcher.
 func NewWatcher(certChan chan models.Entry) *Watcher {
-	return &Watcher{
+	w := &Watcher{
 		certChan: certChan,
+		// Internal channel used by workers; decouples worker production from external consumption/broadcast
+		workerChan: make(chan workerEntry, workerChanSize),
+		errChan:    make(chan error, errorChanSize),
+		done:       make(chan struct{}),
+		logger:     logging.Default(),
+	}
+	w.SetSampleRate(1)
+
+	return w
+}
+
+// QueueLength returns the number of entries waiting to be passed from the workers to the output channel.
+func (w *Watcher) QueueLength() int {
+	return len(w.workerChan)
+}
+
+// SetLogger sets the logger of the watcher and its workers. A nil logger discards all logs.
+// It must be called before the watcher is started.
+func (w *Watcher) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = logging.Nop()
 	}
+
+	w.logger = logger
+}
+
+// SetDomainFilter sets the filter that decides which entries are forwarded to the output channel.
+// Entries not matching the filter are discarded before they reach the output channel. A nil filter forwards all entries.
+func (w *Watcher) SetDomainFilter(filter *DomainFilter) {
+	w.domainFilter.Store(filter)
+}
+
+// SetLookalikeDetector sets the detector that flags certificates for domains confusable with a watchlist.
+// A nil detector disables the detection.
+func (w *Watcher) SetLookalikeDetector(detector *detection.Lookalike) {
+	w.lookalike.Store(detector)
+}
+
+// SetFirstSeenDetector sets the detector that flags certificates for registrable domains seen for the first time.
+// A nil detector disables the detection.
+func (w *Watcher) SetFirstSeenDetector(detector *detection.FirstSeen) {
+	w.firstSeen.Store(detector)
+}
+
+// detect runs the detectors on the entry and attaches their findings. Only entries that are forwarded to the output
+// channel are examined. firstSeen is the result of the first seen detector, which has to look at every entry.
+func (w *Watcher) detect(entry *models.Entry, firstSeen bool) {
+	findings := models.Detection{FirstSeenDomain: firstSeen}
+
+	if detector := w.lookalike.Load(); detector != nil {
+		findings.LookalikeMatches = detector.Matches(entry.Data.LeafCert.AllDomains)
+		if cn := entry.Data.LeafCert.Subject.CN; cn != nil && *cn != "" {
+			findings.CommonNameMatches = detector.Matches([]string{*cn})
+		}
+	}
+
+	if len(findings.LookalikeMatches) > 0 || len(findings.CommonNameMatches) > 0 || findings.FirstSeenDomain {
+		entry.Data.Detection = &findings
+	}
+}
+
+// SetSampleRate sets the fraction of entries that are forwarded to the output channel, between 0 and 1.
+// Each entry is forwarded with the given probability, so 1 forwards all entries. Values outside of the range are clamped.
+func (w *Watcher) SetSampleRate(rate float64) {
+	w.sampleRate.Store(math.Float64bits(min(max(rate, 0), 1)))
+}
+
+// SetHeartbeatInterval makes the watcher send a heartbeat entry (see models.NewHeartbeat) to the output channel
+// whenever no entry was forwarded for the given interval. Zero disables the heartbeats, which is the default.
+// It must be called before the watcher is started.
+func (w *Watcher) SetHeartbeatInterval(interval time.Duration) {
+	w.heartbeatInterval = interval
+}
+
+// sampled randomly decides whether an entry is forwarded, according to the sample rate.
+func (w *Watcher) sampled() bool {
+	rate := math.Float64frombits(w.sampleRate.Load())
+	if rate >= 1 {
+		return true
+	}
+
+	return rand.Float64() < rate
+}
+
+// Errors returns a channel on which non-fatal errors of the watcher and its workers are published.
+// Errors that concern a specific CT log are of type *LogError. If the channel is not drained, new errors are dropped.
+// The channel is closed as soon as the watcher stops.
+func (w *Watcher) Errors() <-chan error {
+	return w.errChan
 }
 
 // Start starts the watcher. This method is blocking.
-func (w *Watcher) Start() {
-	w.context, w.cancelFunc = context.WithCancel(context.Background())
+func (w *Watcher) Start() error {
+	return w.StartWithContext(context.Background())
+}
+
+// StartWithContext starts the watcher and derives all worker contexts from the given context.
+// Cancelling the context has the same effect as calling Stop. This method is blocking.
+// Fetching the CT log list is retried at startup (see config.LogListConfig). If it can't be fetched and no cached
+// copy is available, the watcher stops right away and an error wrapping ErrLogListUnavailable is returned.
+//
+// Once the context is cancelled, the watcher shuts down in the following order, so that the saved recovery indexes
+// cover exactly the entries that were passed to the output channel:
+//  1. The workers stop fetching new entries and return.
+//  2. The entries that are still queued for the parse workers are parsed.
+//  3. The remaining entries are passed to the output channel, which advances the indexes of their logs.
+//  4. The indexes are saved to the recovery store a last time.
+//  5. The output channel and the error channel are closed.
+func (w *Watcher) StartWithContext(ctx context.Context) error {
+	w.context, w.cancelFunc = context.WithCancel(ctx)
+
+	if w.done != nil {
+		defer close(w.done)
+	}
+
+	// The log list is fetched before anything else is started, so that the watcher doesn't run without any logs
+	logList, err := w.getAllLogs(w.getStartupLogList)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrLogListUnavailable, err)
+		w.logger.Error("Could not get CT logs, stopping watcher", "error", err)
+
+		return w.abortStart(err)
+	}
 
-	// Internal channel used by workers; decouples worker production from external consumption/broadcast
 	if w.workerChan == nil {
-		w.workerChan = make(chan models.Entry, 5000)
+		w.workerChan = make(chan workerEntry, workerChanSize)
 	}
 
-	if config.AppConfig.General.Recovery.Enabled {
+	// A recovery store set via SetRecoveryStore takes precedence over the index file of the config
+	if w.recoveryStore == nil && config.AppConfig.General.Recovery.Enabled {
 		ctIndexFilePath, err := filepath.Abs(config.AppConfig.General.Recovery.CTIndexFile)
 		if err != nil {
-			log.Printf("Error getting absolute path for CT index file: '%s', %s\n", config.AppConfig.General.Recovery.CTIndexFile, err)
-			return
+			w.logger.Error("Could not get absolute path of CT index file", "path", config.AppConfig.General.Recovery.CTIndexFile, "error", err)
+			return w.abortStart(err)
+		}
+
+		store, err := newFileRecoveryStore(ctIndexFilePath, config.AppConfig.General.Recovery.Compress, w.logger)
+		if err != nil {
+			w.logger.Error("Could not load CT index file, stopping watcher", "path", ctIndexFilePath, "error", err)
+			return w.abortStart(err)
+		}
+
+		w.recoveryStore = store
+	}
+
+	// The indexes are saved until all remaining entries were handled, so that the last save is up to date
+	saveCtx, stopSaving := context.WithCancel(context.Background())
+	saverDone := make(chan struct{})
+
+	if w.recoveryStore != nil {
+		w.saveEvery = uint64(max(config.AppConfig.General.Recovery.SaveEveryN, 0))
+		w.saveNow = make(chan struct{}, 1)
+	}
+
+	go func() {
+		if w.recoveryStore != nil {
+			w.saveIndexesAtInterval(saveCtx, config.AppConfig.General.Recovery.FlushInterval)
 		}
-		// Load Saved CT Indexes
-		metrics.LoadCTIndex(ctIndexFilePath)
-		// Save CTIndexes at regular intervals
-		go metrics.SaveCertIndexesAtInterval(time.Second*30, ctIndexFilePath) // save indexes every X seconds
+		close(saverDone)
+	}()
+
+	w.requestLimiter = newRequestLimiter(config.AppConfig.General.RequestRateLimit)
+
+	// The parse workers are started before the logs, so that the workers pick up the queue
+	var stopParseWorkers func()
+	if n := config.AppConfig.General.ParseWorkers; n > 0 {
+		stopParseWorkers = w.startParseWorkers(n)
 	}
 
-	// initialize the watcher with currently available logs
-	w.updateLogs()
+	// initialize the watcher with the logs of the log list
+	w.reloadMu.Lock()
+	w.applyLogList(logList)
+	w.reloadMu.Unlock()
+
+	w.logger.Info("Started CT watcher")
+
+	logListWatcherDone := make(chan struct{})
+	go func() {
+		w.watchNewLogs()
+		close(logListWatcherDone)
+	}()
 
-	log.Println("Started CT watcher")
-	go w.watchNewLogs()
-	go certHandler(w.workerChan, w.certChan)
+	handlerDone := make(chan struct{})
+	go func() {
+		w.certHandler(w.workerChan, w.certChan)
+		close(handlerDone)
+	}()
 
-	// Wait for all workers to finish
+	// Wait for all workers to finish, then let the parse workers and the certHandler handle the remaining entries.
+	// The indexes are saved before the output is closed, so that they are up to date once the consumer sees the end.
 	w.wg.Wait()
+	w.markStopped()
+	// Workers added while the workers were stopping are stopped by cancelling the context
+	w.cancelFunc()
+	w.wg.Wait()
+	<-logListWatcherDone
+
+	if stopParseWorkers != nil {
+		stopParseWorkers()
+	}
+
 	close(w.workerChan)
+	<-handlerDone
+	stopSaving()
+	<-saverDone
+	close(w.certChan)
+
+	if w.errChan != nil {
+		close(w.errChan)
+	}
+
+	return nil
+}
+
+// abortStart stops the watcher before any worker was started and closes its channels. It returns err.
+func (w *Watcher) abortStart(err error) error {
+	sendError(w.errChan, err)
+	w.markStopped()
+	w.cancelFunc()
 	close(w.certChan)
+
+	if w.errChan != nil {
+		close(w.errChan)
+	}
+
+	return err
 }
 
 // watchNewLogs monitors the ct log list for new logs and starts a worker for each new log found.
@@ -88,7 +344,9 @@ func (w *Watcher) watchNewLogs() {
 	for {
 		select {
 		case <-ticker.C:
-			w.updateLogs()
+			if err := w.updateLogs(); err != nil {
+				w.logger.Error("Error while updating CT logs", "error", err)
+			}
 		case <-w.context.Done():
 			ticker.Stop()
 			return
@@ -96,15 +354,81 @@ func (w *Watcher) watchNewLogs() {
 	}
 }
 
-// updateLogs checks the transparency log list for new logs and adds new 0workers for those to the watcher.
-func (w *Watcher) updateLogs() {
+// ReloadLogList re-fetches the log list, starts workers for newly added logs and stops the workers of logs that
+// were removed from the list or retired in the meantime (unless drop_old_logs is disabled).
+// Workers of unchanged logs keep running and therefore keep their current position.
+// Once the watcher began to shut down, ErrWatcherStopped is returned.
+func (w *Watcher) ReloadLogList() error {
+	if w.context == nil {
+		return errors.New("watcher not started")
+	}
+
+	w.logger.Info("Reloading CT log list")
+
+	return w.updateLogs()
+}
+
+// ReloadLogFilters replaces the options of config.AppConfig that select the watched logs with the ones of conf and
+// reloads the log list, so that the workers of logs that are no longer selected are stopped and newly selected logs
+// are added. The options are disable_default_logs, additional_logs, include_operators, exclude_operators, the log
+// filter (log_filter_file and log_filter_mode) and log_states.
+func (w *Watcher) ReloadLogFilters(conf config.Config) error {
+	w.reloadMu.Lock()
+	general := &config.AppConfig.General
+	general.DisableDefaultLogs = conf.General.DisableDefaultLogs
+	general.AdditionalLogs = conf.General.AdditionalLogs
+	general.IncludeOperators = conf.General.IncludeOperators
+	general.ExcludeOperators = conf.General.ExcludeOperators
+	general.LogFilterFile = conf.General.LogFilterFile
+	general.LogFilterMode = conf.General.LogFilterMode
+	general.LogFilter = conf.General.LogFilter
+	general.LogStates = conf.General.LogStates
+	w.reloadMu.Unlock()
+
+	return w.ReloadLogList()
+}
+
+// markStopped makes AddLog and ReloadLogList fail with ErrWatcherStopped from now on.
+func (w *Watcher) markStopped() {
+	w.reloadMu.Lock()
+	w.stopped = true
+	w.reloadMu.Unlock()
+}
+
+// stoppedLocked reports whether the watcher began to shut down, i.e. its context was cancelled or the workers
+// returned. The caller must hold reloadMu.
+func (w *Watcher) stoppedLocked() bool {
+	return w.stopped || (w.context != nil && w.context.Err() != nil)
+}
+
+// updateLogs checks the transparency log list for new logs and adds new workers for those to the watcher.
+func (w *Watcher) updateLogs() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	if w.stoppedLocked() {
+		return ErrWatcherStopped
+	}
+
 	// Get a list of urls of all CT logs
-	logList, err := getAllLogs()
+	logList, err := w.getAllLogs(w.getGoogleLogList)
 	if err != nil {
-		log.Println(err)
-		return
+		w.logger.Error("Could not get CT logs", "error", err)
+		sendError(w.errChan, err)
+
+		return err
 	}
 
+	w.applyLogList(logList)
+
+	return nil
+}
+
+// applyLogList adds workers for the new logs of the log list and, unless drop_old_logs is disabled, stops the workers
+// of the logs that are no longer on it. The caller must hold reloadMu.
+func (w *Watcher) applyLogList(logList loglist3.LogList) {
+	// Logs added at runtime are treated like the logs from the log list, so they are not dropped
+	mergeLogs(&logList, w.customLogs)
 	w.addNewlyAvailableLogs(logList)
 
 	if *config.AppConfig.General.DropOldLogs {
@@ -114,7 +438,7 @@ func (w *Watcher) updateLogs() {
 
 // addNewlyAvailableLogs checks the transparency log list for new Log servers and adds workers for those to the watcher.
 func (w *Watcher) addNewlyAvailableLogs(logList loglist3.LogList) {
-	log.Println("Checking for new ct logs...")
+	w.logger.Debug("Checking for new CT logs")
 
 	w.workersMu.Lock()
 	defer w.workersMu.Unlock()
@@ -127,11 +451,6 @@ func (w *Watcher) addNewlyAvailableLogs(logList loglist3.LogList) {
 		for _, transparencyLog := range operator.Logs {
 			newURL := normalizeCtlogURL(transparencyLog.URL)
 
-			if transparencyLog.State.LogStatus() == loglist3.RetiredLogStatus {
-				log.Printf("Skipping retired CT log: %s\n", newURL)
-				continue
-			}
-
 			// Check if the log is already being watched
 			alreadyWatched := false
 
@@ -152,17 +471,42 @@ func (w *Watcher) addNewlyAvailableLogs(logList loglist3.LogList) {
 			newCTs++
 
 			// Metrics are initialized with 0.
-			// Only if recovery is enabled, it is initialized with the last saved index.
-			lastCTIndex := metrics.GetCTIndex(normalizeCtlogURL(transparencyLog.URL))
+			// Only if recovery is enabled and an index was saved for the log, the worker resumes at that index.
+			// Otherwise, it starts at the latest STH.
+			lastCTIndex := metrics.GetCTIndex(newURL)
+			resume := false
+			if w.recoveryStore != nil {
+				if lastCTIndex > 0 {
+					resume = true
+				} else if lastCTIndex, resume = w.loadIndex(newURL); resume {
+					// Otherwise, the index would be saved as 0 until the worker processed its first entry
+					metrics.SetCTIndex(newURL, lastCTIndex)
+				}
+			}
+
+			options := w.logOptions(transparencyLog.URL)
 			ctWorker := worker{
-				name:         transparencyLog.Description,
-				operatorName: operator.Name,
-				ctURL:        transparencyLog.URL,
-				entryChan:    w.workerChan,
-				ctIndex:      lastCTIndex,
+				name:            transparencyLog.Description,
+				operatorName:    operator.Name,
+				ctURL:           workerURL(transparencyLog.URL),
+				publicKey:       transparencyLog.Key,
+				workerCount:     options.WorkerCount,
+				batchSize:       options.BatchSize,
+				sthPollInterval: options.STHPollInterval,
+				entryChan:       w.workerChan,
+				parseQueue:      w.getParseQueue(),
+				errChan:         w.errChan,
+				ctIndex:         lastCTIndex,
+				startAtIndex:    resume,
+				backoff:         newBackoff(config.AppConfig.General.Retry),
+				breaker:         newCircuitBreaker(config.AppConfig.General.CircuitBreaker),
+				requestLimiter:  w.requestLimiter,
+				headers:         requestHeaders(config.AppConfig.General.HTTPHeaders),
+				httpClient:      w.workerHTTPClient(options),
+				logger:          w.logger,
 			}
 			w.workers = append(w.workers, &ctWorker)
-			metrics.Init(operator.Name, normalizeCtlogURL(transparencyLog.URL))
+			metrics.Init(operator.Name, newURL)
 
 			// Start a goroutine for each worker
 			go func() {
@@ -173,14 +517,13 @@ func (w *Watcher) addNewlyAvailableLogs(logList loglist3.LogList) {
 		}
 	}
 
-	log.Printf("New ct logs found: %d\n", newCTs)
-	log.Printf("Currently monitored ct logs: %d\n", len(w.workers))
+	w.logger.Info("Added new CT logs", "new", newCTs, "monitored", len(w.workers))
 }
 
 // discardWorker removes a worker from the watcher's list of workers.
 // This needs to be done when a worker stops.
 func (w *Watcher) discardWorker(worker *worker) {
-	log.Println("Removing worker for CT log:", worker.ctURL)
+	w.logger.Debug("Removing worker for CT log", "url", worker.ctURL)
 
 	w.workersMu.Lock()
 	defer w.workersMu.Unlock()
@@ -196,6 +539,9 @@ func (w *Watcher) discardWorker(worker *worker) {
 // dropRemovedLogs checks if any of the currently monitored logs are no longer in the log list or are retired.
 // If they are not, the CT Logs are probably no longer relevant and the corresponding workers will be stopped.
 func (w *Watcher) dropRemovedLogs(logList loglist3.LogList) {
+	w.workersMu.RLock()
+	defer w.workersMu.RUnlock()
+
 	removedCTs := 0
 
 	// Iterate over all workers and check if they are still in the logList
@@ -213,12 +559,6 @@ func (w *Watcher) dropRemovedLogs(logList loglist3.LogList) {
 
 			// Iterate over each log of the operator
 			for _, transparencyLog := range operator.Logs {
-				// Remove retired logs from the list
-				if transparencyLog.State.LogStatus() == loglist3.RetiredLogStatus {
-					// Skip retired logs
-					continue
-				}
-
 				// Check if the log is already being watched
 				logListURL := normalizeCtlogURL(transparencyLog.URL)
 				if workerURL == logListURL {
@@ -244,92 +584,239 @@ func (w *Watcher) dropRemovedLogs(logList loglist3.LogList) {
 
 		// If the log is not in the loglist, stop the worker
 		if !onLogList {
-			log.Printf("Stopping worker. CT URL not found in LogList or retired: '%s'\n", ctWorker.ctURL)
+			w.logger.Info("Stopping worker, since the CT log is not on the log list or its state is not allowed", "url", ctWorker.ctURL)
 			removedCTs++
 			ctWorker.stop()
 		}
 	}
 
-	log.Printf("Removed ct logs: %d\n", removedCTs)
-	log.Printf("Currently monitored ct logs: %d\n", len(w.workers))
+	w.logger.Info("Removed CT logs", "removed", removedCTs, "monitored", len(w.workers))
 }
 
-// Stop stops the watcher.
+// AddLog adds a custom CT log to the watcher, independent of the log list. Before the log is added, it is checked
+// that the log is reachable and responds to get-sth (with a valid signature, if a public key is given).
+// If the watcher is already running, a worker for the log is started right away. Otherwise, it is started with the
+// watcher. Like all other logs, the log participates in recovery and is not dropped when the log list is updated.
+// Once the watcher began to shut down, ErrWatcherStopped is returned.
+func (w *Watcher) AddLog(logConfig config.LogConfig) error {
+	if !config.IsValidLogURL(logConfig.URL) {
+		return fmt.Errorf("invalid ct log url: '%s'", logConfig.URL)
+	}
+
+	if w.findWorker(logConfig.URL) != nil {
+		return fmt.Errorf("%w: '%s'", ErrLogAlreadyWatched, logConfig.URL)
+	}
+
+	if err := checkLog(w.getHTTPClient(), logConfig); err != nil {
+		return err
+	}
+
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	if w.stoppedLocked() {
+		return ErrWatcherStopped
+	}
+
+	w.customLogs = append(w.customLogs, logConfig)
+
+	// If the watcher is not running yet, the log is picked up on start
+	if w.context == nil {
+		return nil
+	}
+
+	var logList loglist3.LogList
+	mergeLogs(&logList, []config.LogConfig{logConfig})
+	w.addNewlyAvailableLogs(logList)
+
+	return nil
+}
+
+// checkLog verifies that the given CT log is reachable and responds to get-sth.
+// If a public key is configured for the log, the signature of the STH is verified as well.
+func checkLog(httpClient *http.Client, logConfig config.LogConfig) error {
+	var publicKey []byte
+	if logConfig.PublicKey != "" {
+		var decodeErr error
+		if publicKey, decodeErr = base64.StdEncoding.DecodeString(logConfig.PublicKey); decodeErr != nil {
+			return fmt.Errorf("invalid public key for ct log '%s': %w", logConfig.URL, decodeErr)
+		}
+	}
+
+	jsonClient, err := client.New(logConfig.URL, httpClient, jsonclient.Options{UserAgent: userAgent, PublicKeyDER: publicKey})
+	if err != nil {
+		return fmt.Errorf("%w: %w", errCreatingClient, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err = jsonClient.GetSTH(ctx); err != nil {
+		return fmt.Errorf("%w for '%s': %w", errFetchingSTHFailed, logConfig.URL, err)
+	}
+
+	return nil
+}
+
+// PauseLog pauses the worker of the CT log with the given name or url. The worker stops processing entries and
+// therefore stops issuing new get-entries requests once the already fetched entries filled its buffer.
+// The position in the log is kept, so the worker continues where it left off when ResumeLog is called.
+func (w *Watcher) PauseLog(name string) error {
+	ctWorker := w.findWorker(name)
+	if ctWorker == nil {
+		return fmt.Errorf("%w: '%s'", ErrUnknownLog, name)
+	}
+
+	w.logger.Info("Pausing worker for CT log", "url", ctWorker.ctURL)
+	ctWorker.pause()
+
+	return nil
+}
+
+// ResumeLog resumes the worker of the CT log with the given name or url after it was paused with PauseLog.
+func (w *Watcher) ResumeLog(name string) error {
+	ctWorker := w.findWorker(name)
+	if ctWorker == nil {
+		return fmt.Errorf("%w: '%s'", ErrUnknownLog, name)
+	}
+
+	w.logger.Info("Resuming worker for CT log", "url", ctWorker.ctURL)
+	ctWorker.resume()
+
+	return nil
+}
+
+// findWorker returns the worker of the CT log with the given name or url, or nil if the log is not being watched.
+func (w *Watcher) findWorker(name string) *worker {
+	w.workersMu.RLock()
+	defer w.workersMu.RUnlock()
+
+	normalizedName := normalizeCtlogURL(name)
+
+	for _, ctWorker := range w.workers {
+		if ctWorker.name == name || normalizeCtlogURL(ctWorker.ctURL) == normalizedName {
+			return ctWorker
+		}
+	}
+
+	return nil
+}
+
+// Stop stops the watcher and blocks until the shutdown sequence described at StartWithContext completed. The output
+// channel must still be consumed meanwhile, since the remaining entries are passed to it.
 func (w *Watcher) Stop() {
-	log.Printf("Stopping watcher\n")
+	if w.cancelFunc == nil {
+		return
+	}
+
+	w.logger.Info("Stopping watcher")
 	w.cancelFunc()
+
+	if w.done != nil {
+		<-w.done
+	}
 }
 
 // CreateIndexFile creates a ct_index.json file based on the current STHs of all availble logs.
 func (w *Watcher) CreateIndexFile(filePath string) error {
-	logs, err := getAllLogs()
+	logs, err := w.getAllLogs(w.getGoogleLogList)
 	if err != nil {
 		return err
 	}
 
+	// The file is created from scratch, so that no indexes of previously watched logs remain
+	store := &FileRecoveryStore{path: filePath, indexes: make(map[string]uint64)}
+
 	w.context, w.cancelFunc = context.WithCancel(context.Background())
-	log.Println("Fetching current STH for all logs...")
+	w.logger.Info("Fetching current STH for all logs")
 	for _, operator := range logs.Operators {
 		// Iterate over each log of the operator
 		for _, transparencyLog := range operator.Logs {
-			// Check if the log is already being watched
-			metrics.Init(operator.Name, normalizeCtlogURL(transparencyLog.URL))
-			log.Println("Fetching STH for", transparencyLog.URL)
+			w.logger.Debug("Fetching STH", "url", transparencyLog.URL)
 
-			hc := http.Client{Timeout: 5 * time.Second}
-			jsonClient, e := client.New(transparencyLog.URL, &hc, jsonclient.Options{UserAgent: userAgent})
+			jsonClient, e := client.New(transparencyLog.URL, w.getHTTPClient(), jsonclient.Options{UserAgent: userAgent})
 			if e != nil {
-				log.Printf("Error creating JSON client: %s\n", e)
+				w.logger.Error("Could not create JSON client", "url", transparencyLog.URL, "error", e)
 				continue
 			}
 
 			sth, getSTHerr := jsonClient.GetSTH(w.context)
 			if getSTHerr != nil {
 				// TODO this can happen due to a 429 error. We should retry the request
-				log.Printf("Could not get STH for '%s': %s\n", transparencyLog.URL, getSTHerr)
+				w.logger.Error("Could not get STH", "url", transparencyLog.URL, "error", getSTHerr)
 				continue
 			}
 
-			metrics.index[transparencyLog.URL] = sth.TreeSize
+			store.indexes[normalizeCtlogURL(transparencyLog.URL)] = sth.TreeSize
 		}
 	}
 	w.cancelFunc()
 
-	tempFilePath := fmt.Sprintf("%s.tmp", filePath)
-	metrics.SaveCertIndexes(tempFilePath, filePath)
-	log.Println("Index file saved to", filePath)
+	if err = store.write(); err != nil {
+		return err
+	}
+
+	w.logger.Info("Index file saved", "path", filePath)
 
 	return nil
 }
 
 // A worker processes a single CT log.
+// workerEntry is an entry passed from the workers to the certHandler.
+type workerEntry struct {
+	entry models.Entry
+	// fetchedAt is the time the entry was handed to the worker by the scanner.
+	fetchedAt time.Time
+}
+
 type worker struct {
 	name         string
 	operatorName string
 	ctURL        string
-	entryChan    chan models.Entry
-	ctIndex      uint64
+	publicKey    []byte
+	workerCount  int
+	// batchSize is the number of entries per get-entries request. It is guarded by mu, since it is capped
+	// once the maximum number of entries the log returns per request is known.
+	batchSize       int
+	batchSizeProbed bool
+	// sthPollInterval is the configured minimum time between two get-sth requests to the log.
+	sthPollInterval time.Duration
+	progress        logProgress
+	backoff         *backoff
+	breaker         *circuitBreaker
+	// requestLimiter is the rate limit of the get-entries requests shared by all workers. Nil if it is disabled.
+	requestLimiter *requestLimiter
+	// headers are set on each request to the log, overriding the default User-Agent.
+	headers    http.Header
+	httpClient *http.Client
+	logger     logging.Logger
+	entryChan  chan workerEntry
+	// parseQueue passes the raw entries to the parse workers of the watcher. Nil if they are parsed right away.
+	parseQueue chan<- parseJob
+	errChan    chan error
+	ctIndex    uint64
+	// startAtIndex indicates whether the worker starts at ctIndex instead of the latest STH
+	startAtIndex bool
 	mu           sync.Mutex
 	running      bool
 	cancel       context.CancelFunc
+	// resumeChan is non-nil while the worker is paused. It gets closed when the worker is resumed.
+	resumeChan chan struct{}
+	pauseMu    sync.Mutex
 }
 
 // startDownloadingCerts starts downloading certificates from the CT log. This method is blocking.
 func (w *worker) startDownloadingCerts(ctx context.Context) {
+	w.mu.Lock()
 	ctx, w.cancel = context.WithCancel(ctx)
+	w.mu.Unlock()
 
-	// Normalize CT URL. We remove trailing slashes and prepend "https://" if it's not already there.
-	w.ctURL = strings.TrimRight(w.ctURL, "/")
-	if !strings.HasPrefix(w.ctURL, "https://") && !strings.HasPrefix(w.ctURL, "http://") {
-		w.ctURL = "https://" + w.ctURL
-	}
-
-	log.Printf("Initializing worker for CT log: %s\n", w.ctURL)
-	defer log.Printf("Stopping worker for CT log: %s\n", w.ctURL)
+	w.logger.Debug("Initializing worker for CT log", "url", w.ctURL)
+	defer w.logger.Debug("Stopping worker for CT log", "url", w.ctURL)
 
 	w.mu.Lock()
 	if w.running {
-		log.Printf("Worker for '%s' already running\n", w.ctURL)
+		w.logger.Warn("Worker already running", "url", w.ctURL)
 		w.mu.Unlock()
 
 		return
@@ -340,191 +827,406 @@ func (w *worker) startDownloadingCerts(ctx context.Context) {
 	w.mu.Unlock()
 
 	for {
-		log.Printf("Starting worker for CT log: %s\n", w.ctURL)
+		w.logger.Debug("Starting worker for CT log", "url", w.ctURL)
 		workerErr := w.runWorker(ctx)
-		if workerErr != nil {
-			if errors.Is(workerErr, errFetchingSTHFailed) {
-				// TODO this could happen due to a 429 error. We should retry the request
-				log.Printf("Worker for '%s' failed - could not fetch STH\n", w.ctURL)
-				return
-			} else if errors.Is(workerErr, errCreatingClient) {
-				log.Printf("Worker for '%s' failed - could not create client\n", w.ctURL)
+		if workerErr != nil && ctx.Err() == nil {
+			w.reportError(workerErr)
+
+			if errors.Is(workerErr, errCreatingClient) {
+				w.logger.Error("Worker failed, could not create client", "url", w.ctURL, "error", workerErr)
 				return
 			} else if strings.Contains(workerErr.Error(), "no such host") {
-				log.Printf("Worker for '%s' failed to resolve host: %s\n", w.ctURL, workerErr)
+				w.logger.Error("Worker failed to resolve host", "url", w.ctURL, "error", workerErr)
 				return
 			}
 
-			log.Printf("Worker for '%s' failed with unexpected error: %s\n", w.ctURL, workerErr)
+			if errors.Is(workerErr, errFetchingSTHFailed) {
+				// The request was already delayed by the backoff, e.g. because the log responded with 429
+				w.logger.Warn("Worker failed, could not fetch STH", "url", w.ctURL, "error", workerErr)
+			} else {
+				w.logger.Error("Worker failed with unexpected error", "url", w.ctURL, "error", workerErr)
+			}
 		}
 
 		// Check if the context was cancelled
+		if ctx.Err() != nil {
+			w.logger.Debug("Context was cancelled, stopping worker", "url", w.ctURL)
+			return
+		}
+
+		// The delay is interrupted by Stop, so that the shutdown doesn't wait for it
+		w.logger.Warn("Restarting worker in 5 seconds due to error", "url", w.ctURL)
 		select {
+		case <-time.After(5 * time.Second):
 		case <-ctx.Done():
-			log.Printf("Context was cancelled; Stopping worker for '%s'\n", w.ctURL)
-
+			w.logger.Debug("Context was cancelled, stopping worker", "url", w.ctURL)
 			return
-		default:
-			log.Printf("Worker for '%s' sleeping for 5 seconds due to error\n", w.ctURL)
-			time.Sleep(5 * time.Second)
-			log.Printf("Restarting worker for '%s'\n", w.ctURL)
-
-			continue
 		}
 	}
 }
 
+// reportError wraps the error with the name and url of the worker's CT log and publishes it on the error channel.
+func (w *worker) reportError(err error) {
+	sendError(w.errChan, &LogError{LogName: w.name, LogURL: w.ctURL, Err: err})
+}
+
+// pause makes the worker wait before processing the next entry until resume is called.
+func (w *worker) pause() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	if w.resumeChan == nil {
+		w.resumeChan = make(chan struct{})
+	}
+}
+
+// resume lets a paused worker continue processing entries.
+func (w *worker) resume() {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	if w.resumeChan != nil {
+		close(w.resumeChan)
+		w.resumeChan = nil
+	}
+}
+
+// isPaused returns true if the worker is currently paused.
+func (w *worker) isPaused() bool {
+	w.pauseMu.Lock()
+	defer w.pauseMu.Unlock()
+
+	return w.resumeChan != nil
+}
+
+// waitWhilePaused blocks as long as the worker is paused or until the context is cancelled.
+func (w *worker) waitWhilePaused(ctx context.Context) {
+	w.pauseMu.Lock()
+	resumeChan := w.resumeChan
+	w.pauseMu.Unlock()
+
+	if resumeChan == nil {
+		return
+	}
+
+	select {
+	case <-resumeChan:
+	case <-ctx.Done():
+	}
+}
+
 func (w *worker) stop() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	// The worker might not have started downloading yet
+	if w.cancel == nil {
+		return
+	}
+
 	w.cancel()
 }
 
+// newLogClient creates the client for the worker's CT log. The HTTP requests are counted in the given progress.
+func (w *worker) newLogClient(progress *logProgress) (*treeHeadClient, error) {
+	// The shared client is wrapped with the configured headers, the backoff of this log and the global rate limit. Its
+	// timeout is applied per attempt by the transport, so that the delays of the backoff and the rate limit don't
+	// count towards it.
+	hc := http.Client{
+		CheckRedirect: w.httpClient.CheckRedirect,
+		Jar:           w.httpClient.Jar,
+		Transport: rateLimitTransport{
+			base: &backoffTransport{
+				base: countingTransport{
+					base:     headerTransport{base: tracing.WrapTransport(w.httpClient.Transport), headers: w.headers},
+					progress: progress,
+				},
+				timeout:   w.httpClient.Timeout,
+				backoff:   w.backoff,
+				breaker:   w.breaker,
+				onFailure: w.reportError,
+			},
+			limiter: w.requestLimiter,
+		},
+	}
+	logClient, err := client.New(w.ctURL, &hc, jsonclient.Options{UserAgent: userAgent, PublicKeyDER: w.publicKey})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errCreatingClient, err)
+	}
+
+	return newTreeHeadClient(logClient, progress, w.sthPollInterval), nil
+}
+
 // runWorker runs a single worker for a single CT log. This method is blocking.
 func (w *worker) runWorker(ctx context.Context) error {
-	hc := http.Client{Timeout: 30 * time.Second}
-	jsonClient, e := client.New(w.ctURL, &hc, jsonclient.Options{UserAgent: userAgent})
+	jsonClient, e := w.newLogClient(&w.progress)
 	if e != nil {
-		log.Printf("Error creating JSON client: %s\n", e)
-		return errCreatingClient
+		return e
 	}
 
 	// If recovery is enabled and the CT index is set, we start at the saved index. Otherwise we start at the latest STH.
-	validSavedCTIndexExists := config.AppConfig.General.Recovery.Enabled
-	if !validSavedCTIndexExists {
+	if !w.startAtIndex {
 		sth, getSTHerr := jsonClient.GetSTH(ctx)
 		if getSTHerr != nil {
-			// TODO this can happen due to a 429 error. We should retry the request
-			log.Printf("Could not get STH for '%s': %s\n", w.ctURL, getSTHerr)
-			return errFetchingSTHFailed
+			return fmt.Errorf("%w: %w", errFetchingSTHFailed, getSTHerr)
 		}
-		// Start at the latest STH to skip all the past certificates
-		w.ctIndex = sth.TreeSize
+
+		// By default, start at the latest STH to skip all the past certificates
+		startIndex, err := config.StartIndex(config.AppConfig.General.StartPosition, sth.TreeSize)
+		if err != nil {
+			return err
+		}
+
+		w.ctIndex = startIndex
+		// The position is known from now on, so that recovery can resume here even if no entry is processed until the next save
+		metrics.SetCTIndex(normalizeCtlogURL(w.ctURL), w.ctIndex)
+		w.progress.treeSize.Store(sth.TreeSize)
+		// Restarts after an error continue where the worker left off instead of applying the start position again
+		w.startAtIndex = true
+	} else if nextIndex := w.progress.nextIndex.Load(); nextIndex > w.ctIndex {
+		w.ctIndex = nextIndex
 	}
 
+	w.progress.start(w.ctIndex)
+
+	// The tree size is polled separately, since the scanner doesn't expose the STHs it fetches
+	pollCtx, cancelPoll := context.WithCancel(ctx)
+	defer cancelPoll()
+
+	go w.pollTreeSize(pollCtx, jsonClient)
+
+	batchSize := w.capBatchSize(ctx, jsonClient)
+
 	certScanner := scanner.NewScanner(jsonClient, scanner.ScannerOptions{
 		FetcherOptions: scanner.FetcherOptions{
-			BatchSize:     config.AppConfig.General.ScannerOptions.BatchSize,
+			BatchSize:     batchSize,
 			ParallelFetch: config.AppConfig.General.ScannerOptions.ParallelFetch,
 			StartIndex:    int64(w.ctIndex),
 			Continuous:    true,
 		},
 		Matcher:     scanner.MatchAll{},
 		PrecertOnly: false,
-		NumWorkers:  config.AppConfig.General.ScannerOptions.NumWorkers,
+		NumWorkers:  w.workerCount,
 		BufferSize:  config.AppConfig.General.BufferSizes.CTLog,
 	})
 
-	scanErr := certScanner.Scan(ctx, w.foundCertCallback, w.foundPrecertCallback)
+	scanErr := certScanner.Scan(ctx,
+		// The update type is taken from the entry itself, so certs and precerts are handled alike
+		func(rawEntry *ct.RawLogEntry) { w.dispatchEntry(ctx, rawEntry) },
+		func(rawEntry *ct.RawLogEntry) { w.dispatchEntry(ctx, rawEntry) },
+	)
 	if scanErr != nil {
-		log.Println("Scan error: ", scanErr)
 		return scanErr
 	}
 
-	log.Printf("Exiting worker %s without error!\n", w.ctURL)
+	w.logger.Debug("Exiting worker without error", "url", w.ctURL)
 
 	return nil
 }
 
-// foundCertCallback is the callback that handles cases where new regular certs are found.
-func (w *worker) foundCertCallback(rawEntry *ct.RawLogEntry) {
-	entry, parseErr := ParseCertstreamEntry(rawEntry, w.operatorName, w.name, w.ctURL)
-	if parseErr != nil {
-		log.Println("Error parsing certstream entry: ", parseErr)
+// dispatchEntry processes the raw entry right away or, if the parse workers are enabled, queues it for them.
+func (w *worker) dispatchEntry(ctx context.Context, rawEntry *ct.RawLogEntry) {
+	// While paused, the scanner's fetchers block as soon as their buffer is full, so no new entries are requested
+	w.waitWhilePaused(ctx)
+
+	fetchedAt := time.Now()
+
+	if w.parseQueue != nil {
+		w.parseQueue <- parseJob{ctx: ctx, worker: w, rawEntry: rawEntry, fetchedAt: fetchedAt}
 		return
 	}
 
-	entry.Data.UpdateType = "X509LogEntry"
-	w.entryChan <- entry
-
-	atomic.AddInt64(&processedCerts, 1)
+	w.processEntry(ctx, rawEntry, fetchedAt)
 }
 
-// foundPrecertCallback is the callback that handles cases where new precerts are found.
-func (w *worker) foundPrecertCallback(rawEntry *ct.RawLogEntry) {
-	entry, parseErr := ParseCertstreamEntry(rawEntry, w.operatorName, w.name, w.ctURL)
+// processEntry parses the raw entry and passes it on to the entry channel, unless it is discarded by one of the filters
+// that can be evaluated right after parsing.
+func (w *worker) processEntry(ctx context.Context, rawEntry *ct.RawLogEntry, fetchedAt time.Time) {
+	defer w.progress.processed(rawEntry.Index)
+
+	entry, parseErr := w.parseEntry(ctx, rawEntry)
 	if parseErr != nil {
-		log.Println("Error parsing certstream entry: ", parseErr)
+		w.logger.Warn("Could not parse entry", "url", w.ctURL, "index", rawEntry.Index, "error", parseErr)
+		w.reportError(fmt.Errorf("could not parse entry %d: %w", rawEntry.Index, parseErr))
+
 		return
 	}
 
-	entry.Data.UpdateType = "PrecertLogEntry"
-	w.entryChan <- entry
+	if config.AppConfig.General.ExcludePrecerts && entry.Data.LeafCert.IsPrecert {
+		atomic.AddInt64(&precertFilteredCerts, 1)
+		return
+	}
 
-	atomic.AddInt64(&processedPrecerts, 1)
-}
+	if config.AppConfig.General.WildcardOnly && !containsWildcardDomain(entry.Data.LeafCert.AllDomains) {
+		atomic.AddInt64(&wildcardFilteredCerts, 1)
+		return
+	}
 
-// certHandler takes the entries out of the entryChan channel and broadcasts them to all clients.
-// Only a single instance of the certHandler runs per certstream server.
-func certHandler(input <-chan models.Entry, output chan<- models.Entry) {
-	var processed int64
+	if config.AppConfig.General.IncludeIPSANOnly && len(entry.Data.LeafCert.IPAddresses) == 0 {
+		atomic.AddInt64(&ipSANFilteredCerts, 1)
+		return
+	}
 
-	for entry := range input {
-		processed++
+	if general := &config.AppConfig.General; !matchesIssuer(entry.Data.LeafCert.Issuer, general.IncludeIssuers, general.ExcludeIssuers) {
+		atomic.AddInt64(&issuerFilteredCerts, 1)
+		return
+	}
 
-		output <- entry
+	if general := &config.AppConfig.General; !inSANRange(entry.Data.LeafCert.SANCount(), general.MinSANs, general.MaxSANs) {
+		atomic.AddInt64(&sanCountFilteredCerts, 1)
+		return
+	}
 
-		// Update metrics
-		url := entry.Data.Source.NormalizedURL
-		operator := entry.Data.Source.Operator
-		index := entry.Data.CertIndex
+	// The entry is seen when the worker received it, not when it was parsed, which might be delayed by the parse queue
+	entry.Data.Seen = models.NewTimestamp(fetchedAt)
+	w.entryChan <- workerEntry{entry: entry, fetchedAt: fetchedAt}
 
-		metrics.Inc(operator, url, index)
+	if entry.Data.UpdateType == models.UpdateTypePrecert {
+		atomic.AddInt64(&processedPrecerts, 1)
+	} else {
+		atomic.AddInt64(&processedCerts, 1)
 	}
 }
 
-// getGoogleLogList fetches the list of all CT logs from Google Chromes CT LogList.
-func getGoogleLogList() (loglist3.LogList, error) {
-	// Download the list of all logs from ctLogInfo and decode json
-	resp, err := http.Get(loglist3.LogListURL)
-	if err != nil {
-		return loglist3.LogList{}, err
+// parseEntry parses the raw entry. If tracing is enabled, the parsing is recorded in a span.
+func (w *worker) parseEntry(ctx context.Context, rawEntry *ct.RawLogEntry) (models.Entry, error) {
+	tracer := tracing.Tracer()
+	if tracer == nil {
+		return ParseCertstreamEntry(rawEntry, w.operatorName, w.name, w.ctURL)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return loglist3.LogList{}, errors.New("failed to download loglist")
-	}
+	_, span := tracer.Start(ctx, "certstream.parse", trace.WithAttributes(
+		attribute.String("ct.log.url", w.ctURL),
+		attribute.Int64("ct.entry.index", rawEntry.Index),
+	))
+	defer span.End()
 
-	bodyBytes, readErr := io.ReadAll(resp.Body)
-	if readErr != nil {
-		log.Panic(readErr)
+	entry, err := ParseCertstreamEntry(rawEntry, w.operatorName, w.name, w.ctURL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
 
-	allLogs, parseErr := loglist3.NewFromJSON(bodyBytes)
-	if parseErr != nil {
-		return loglist3.LogList{}, parseErr
+	return entry, err
+}
+
+// certHandler takes the entries out of the entryChan channel and broadcasts them to all clients.
+// Entries that don't match the watcher's filters are discarded here, so they don't take up space in the output buffer.
+// Only a single instance of the certHandler runs per certstream server.
+func (w *Watcher) certHandler(input <-chan workerEntry, output chan<- models.Entry) {
+	// The heartbeat timer is restarted with each forwarded entry. Its channel stays nil if heartbeats are disabled.
+	var heartbeat *time.Timer
+	var heartbeatC <-chan time.Time
+	if w.heartbeatInterval > 0 {
+		heartbeat = time.NewTimer(w.heartbeatInterval)
+		defer heartbeat.Stop()
+		heartbeatC = heartbeat.C
 	}
 
-	return *allLogs, nil
+	for {
+		var item workerEntry
+		select {
+		case received, ok := <-input:
+			if !ok {
+				return
+			}
+
+			item = received
+		case now := <-heartbeatC:
+			output <- models.NewHeartbeat(now)
+			heartbeat.Reset(w.heartbeatInterval)
+
+			continue
+		}
+
+		entry := item.entry
+		url := entry.Data.Source.NormalizedURL
+		forwarded := false
+
+		// Filtered and sampled out entries are recorded as well, so that a domain is not flagged again once it passes
+		firstSeen := w.firstSeen.Load().Check(entry.Data.LeafCert.RegistrableDomains, item.fetchedAt)
+
+		// Sampling happens after filtering, so that the sample rate applies to the entries the consumer is interested in
+		switch {
+		case !w.domainFilter.Load().Matches(entry.Data.LeafCert.AllDomains):
+		case !w.sampled():
+			atomic.AddInt64(&sampledOutCerts, 1)
+		default:
+			w.detect(&entry, firstSeen)
+
+			if w.acks != nil {
+				w.acks.forwarded(url, entry.Data.CertIndex)
+			}
+
+			output <- entry
+			forwarded = true
+			deliveryLatency.UpdateDuration(item.fetchedAt)
+
+			if heartbeat != nil {
+				heartbeat.Reset(w.heartbeatInterval)
+			}
+		}
+
+		// Update metrics. Filtered entries still count as processed, so that recovery resumes after them.
+		operator := entry.Data.Source.Operator
+		index := entry.Data.CertIndex
+
+		if w.acks == nil {
+			metrics.Inc(operator, url, index)
+			w.countConsumed()
+
+			continue
+		}
+
+		// The index of forwarded entries is advanced once they are acknowledged
+		metrics.IncCount(operator, url)
+
+		if !forwarded {
+			w.acks.skipped(url, index)
+			w.countConsumed()
+		}
+	}
 }
 
-// getAllLogs returns a list of all CT logs.
-func getAllLogs() (loglist3.LogList, error) {
+// getAllLogs returns a list of all CT logs. The log list of Google is obtained with fetchLogList.
+func (w *Watcher) getAllLogs(fetchLogList func() (loglist3.LogList, error)) (loglist3.LogList, error) {
 	var allLogs loglist3.LogList
 	var err error
 
 	// Ability to disable default logs, if the user only wants to monitor custom logs.
 	if !config.AppConfig.General.DisableDefaultLogs {
-		allLogs, err = getGoogleLogList()
+		allLogs, err = fetchLogList()
 		if err != nil {
-			log.Printf("Error fetching log list from Google: %s\n", err)
 			return loglist3.LogList{}, fmt.Errorf("failed to fetch log list from Google: %w", err)
 		}
+
+		// Logs that are e.g. retired or read-only either reject requests or don't receive new certificates anymore
+		filterLogStates(&allLogs, config.AppConfig.General.LogStates, w.logger)
 	}
 
 	// Add manually added logs from config to the allLogs list
-	if config.AppConfig.General.AdditionalLogs == nil {
-		return allLogs, nil
-	}
+	mergeLogs(&allLogs, config.AppConfig.General.AdditionalLogs)
+	filterOperators(&allLogs, config.AppConfig.General.IncludeOperators, config.AppConfig.General.ExcludeOperators)
+	general := &config.AppConfig.General
+	filterLogs(&allLogs, general.LogFilterFile, general.LogFilterMode, general.LogFilter)
+
+	return allLogs, nil
+}
 
-	for _, additionalLog := range config.AppConfig.General.AdditionalLogs {
+// mergeLogs adds the given logs to the log list. Logs are added to the operator with the same name or to a new
+// operator if no such operator exists yet.
+func mergeLogs(allLogs *loglist3.LogList, logs []config.LogConfig) {
+	for _, additionalLog := range logs {
 		customLog := loglist3.Log{
 			URL:         additionalLog.URL,
 			Description: additionalLog.Description,
 		}
 
+		if additionalLog.PublicKey != "" {
+			// The public key was already validated when the config was loaded or the log was added
+			customLog.Key, _ = base64.StdEncoding.DecodeString(additionalLog.PublicKey)
+		}
+
 		operatorFound := false
 		for _, operator := range allLogs.Operators {
 			if operator.Name == additionalLog.Operator {
@@ -544,8 +1246,17 @@ func getAllLogs() (loglist3.LogList, error) {
 			allLogs.Operators = append(allLogs.Operators, &newOperator)
 		}
 	}
+}
 
-	return allLogs, nil
+// workerURL normalizes the CT URL for the worker. We remove trailing slashes and prepend "https://" if it's not
+// already there.
+func workerURL(input string) string {
+	input = strings.TrimRight(input, "/")
+	if !strings.HasPrefix(input, "https://") && !strings.HasPrefix(input, "http://") {
+		input = "https://" + input
+	}
+
+	return input
 }
 
 func normalizeCtlogURL(input string) string {
@@ -0,0 +1,80 @@
+package certificatetransparency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestCertHandlerHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	w := NewWatcher(nil)
+	w.SetHeartbeatInterval(20 * time.Millisecond)
+
+	input := make(chan workerEntry)
+	output := make(chan models.Entry, 1)
+	done := make(chan struct{})
+
+	go func() {
+		w.certHandler(input, output)
+		close(done)
+	}()
+
+	// Without entries, heartbeats are sent in the configured interval
+	for range 2 {
+		if entry := <-output; !entry.IsHeartbeat() || entry.Data.Seen == 0 {
+			t.Fatalf("got %+v, want heartbeat", entry)
+		}
+	}
+
+	var entry models.Entry
+	entry.MessageType = "certificate_update"
+	entry.Data.Source.NormalizedURL = "ct.example.com/heartbeat"
+
+	input <- workerEntry{entry: entry, fetchedAt: time.Now()}
+	if got := <-output; got.IsHeartbeat() {
+		t.Error("got heartbeat, want certificate")
+	}
+
+	close(input)
+	<-done
+}
+
+func TestStoppedWatcher(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+			SHA256RootHash:    make([]byte, sha256.Size),
+			TreeHeadSignature: []byte{4, 3, 0, 0},
+		})
+	}))
+	defer server.Close()
+
+	w := NewWatcher(nil)
+	w.context, w.cancelFunc = context.WithCancel(context.Background())
+	w.cancelFunc()
+	w.markStopped()
+
+	if err := w.AddLog(config.LogConfig{URL: server.URL}); !errors.Is(err, ErrWatcherStopped) {
+		t.Errorf("AddLog returned %v, want %v", err, ErrWatcherStopped)
+	}
+
+	if err := w.ReloadLogList(); !errors.Is(err, ErrWatcherStopped) {
+		t.Errorf("ReloadLogList returned %v, want %v", err, ErrWatcherStopped)
+	}
+
+	if len(w.workers) != 0 {
+		t.Errorf("got %d workers, want none", len(w.workers))
+	}
+}
@@ -0,0 +1,219 @@
+package certificatetransparency
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/certificate-transparency-go/loglist3"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// DomainFilter decides whether an entry should be forwarded based on the domains contained in its certificate.
+// Suffixes and patterns are combined with OR semantics. An empty DomainFilter matches every entry.
+type DomainFilter struct {
+	suffixes []string
+	patterns []*regexp.Regexp
+}
+
+// NewDomainFilter creates a DomainFilter that matches entries containing at least one domain that is equal to
+// or a subdomain of one of the given suffixes, or that matches one of the given regular expressions.
+// Suffixes are normalized, so ".gov", "gov" and "gov." are equivalent. Nil patterns are ignored.
+func NewDomainFilter(suffixes []string, patterns []*regexp.Regexp) *DomainFilter {
+	filter := &DomainFilter{}
+
+	for _, pattern := range patterns {
+		if pattern != nil {
+			filter.patterns = append(filter.patterns, pattern)
+		}
+	}
+
+	for _, suffix := range suffixes {
+		suffix = strings.ToLower(strings.Trim(strings.TrimSpace(suffix), "."))
+		if suffix == "" {
+			continue
+		}
+
+		filter.suffixes = append(filter.suffixes, suffix)
+	}
+
+	return filter
+}
+
+// IsEmpty returns true if the filter has no criteria and therefore matches all entries.
+func (f *DomainFilter) IsEmpty() bool {
+	return f == nil || (len(f.suffixes) == 0 && len(f.patterns) == 0)
+}
+
+// Matches returns true if at least one of the given domains matches the filter.
+// Suffixes are checked first, since they are much cheaper to evaluate than regular expressions.
+func (f *DomainFilter) Matches(domains []string) bool {
+	if f.IsEmpty() {
+		return true
+	}
+
+	for _, domain := range domains {
+		for _, suffix := range f.suffixes {
+			if matchesSuffix(domain, suffix) {
+				return true
+			}
+		}
+	}
+
+	for _, domain := range domains {
+		for _, pattern := range f.patterns {
+			if pattern.MatchString(domain) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchesSuffix checks case-insensitively whether the domain equals the suffix or is a subdomain of it.
+// A trailing dot of the domain is ignored. The suffix must already be normalized.
+func matchesSuffix(domain, suffix string) bool {
+	domain = strings.TrimSuffix(domain, ".")
+	if len(domain) < len(suffix) {
+		return false
+	}
+
+	offset := len(domain) - len(suffix)
+	if !strings.EqualFold(domain[offset:], suffix) {
+		return false
+	}
+
+	return offset == 0 || domain[offset-1] == '.'
+}
+
+// filterOperators removes all operators from the log list that are not contained in include (if it is not empty)
+// or that are contained in exclude. Operator names are compared case-insensitively.
+func filterOperators(logList *loglist3.LogList, include, exclude []string) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return
+	}
+
+	containsOperator := func(names []string, operator string) bool {
+		return slices.ContainsFunc(names, func(name string) bool {
+			return strings.EqualFold(strings.TrimSpace(name), operator)
+		})
+	}
+
+	logList.Operators = slices.DeleteFunc(logList.Operators, func(operator *loglist3.Operator) bool {
+		if len(include) > 0 && !containsOperator(include, operator.Name) {
+			return true
+		}
+
+		return containsOperator(exclude, operator.Name)
+	})
+}
+
+// filterLogs removes the logs from the log list that are not contained in logs if mode is config.LogFilterInclude,
+// or that are contained in logs if mode is config.LogFilterExclude. Logs are identified by their description,
+// compared case-insensitively, or their url. Nothing is removed if no log filter file is configured, even if a mode is.
+func filterLogs(logList *loglist3.LogList, file, mode string, logs []string) {
+	if file == "" || mode == "" {
+		return
+	}
+
+	normalizedURLs := make(map[string]bool, len(logs))
+	for _, log := range logs {
+		normalizedURLs[normalizeCtlogURL(log)] = true
+	}
+
+	listed := func(transparencyLog *loglist3.Log) bool {
+		return normalizedURLs[normalizeCtlogURL(transparencyLog.URL)] || slices.ContainsFunc(logs, func(name string) bool {
+			return transparencyLog.Description != "" && strings.EqualFold(name, transparencyLog.Description)
+		})
+	}
+
+	for _, operator := range logList.Operators {
+		operator.Logs = slices.DeleteFunc(operator.Logs, func(transparencyLog *loglist3.Log) bool {
+			return listed(transparencyLog) != (mode == config.LogFilterInclude)
+		})
+	}
+}
+
+// matchesIssuer returns true if the organization or the common name of the issuer contains one of the include
+// patterns (or include is empty) and none of the exclude patterns. Patterns are matched case-insensitively.
+func matchesIssuer(issuer models.Subject, include, exclude []string) bool {
+	matches := func(patterns []string) bool {
+		return slices.ContainsFunc(patterns, func(pattern string) bool {
+			return (issuer.O != nil && containsFold(*issuer.O, pattern)) || (issuer.CN != nil && containsFold(*issuer.CN, pattern))
+		})
+	}
+
+	if len(include) > 0 && !matches(include) {
+		return false
+	}
+
+	return !matches(exclude)
+}
+
+// inSANRange returns true if the number of SANs is within the range from minSANs to maxSANs. A bound of zero is
+// unbounded.
+func inSANRange(count, minSANs, maxSANs int) bool {
+	return (minSANs <= 0 || count >= minSANs) && (maxSANs <= 0 || count <= maxSANs)
+}
+
+// containsFold reports whether substr is within s, compared case-insensitively. Unlike combining strings.Contains
+// with strings.ToLower, it doesn't allocate.
+func containsFold(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logStatuses maps the log states used in the config to the states of the log list.
+var logStatuses = map[string]loglist3.LogStatus{
+	"pending":   loglist3.PendingLogStatus,
+	"qualified": loglist3.QualifiedLogStatus,
+	"usable":    loglist3.UsableLogStatus,
+	"readonly":  loglist3.ReadOnlyLogStatus,
+	"retired":   loglist3.RetiredLogStatus,
+	"rejected":  loglist3.RejectedLogStatus,
+}
+
+// filterLogStates removes all logs from the log list whose state is not contained in the given states.
+// If no states are given, config.DefaultLogStates is used. Logs without a state are kept. Skipped logs are logged at
+// debug level.
+func filterLogStates(logList *loglist3.LogList, states []string, logger logging.Logger) {
+	if len(states) == 0 {
+		states = config.DefaultLogStates
+	}
+
+	allowed := make(map[loglist3.LogStatus]bool, len(states))
+	for _, state := range states {
+		if status, ok := logStatuses[strings.ToLower(state)]; ok {
+			allowed[status] = true
+		}
+	}
+
+	stateNames := make(map[loglist3.LogStatus]string, len(logStatuses))
+	for name, status := range logStatuses {
+		stateNames[status] = name
+	}
+
+	for _, operator := range logList.Operators {
+		operator.Logs = slices.DeleteFunc(operator.Logs, func(transparencyLog *loglist3.Log) bool {
+			status := transparencyLog.State.LogStatus()
+			if status == loglist3.UndefinedLogStatus {
+				return false
+			}
+
+			if !allowed[status] {
+				logger.Debug("Skipping CT log", "state", stateNames[status], "url", transparencyLog.URL)
+				return true
+			}
+
+			return false
+		})
+	}
+}
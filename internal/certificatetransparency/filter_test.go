@@ -0,0 +1,209 @@
+package certificatetransparency
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/loglist3"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestDomainFilterMatches(t *testing.T) {
+	t.Parallel()
+
+	filter := NewDomainFilter([]string{".gov", "Example.com.", "bank"}, nil)
+
+	tests := []struct {
+		domains []string
+		want    bool
+	}{
+		{[]string{"example.com"}, true},
+		{[]string{"WWW.EXAMPLE.COM"}, true},
+		{[]string{"*.example.com"}, true},
+		{[]string{"www.example.com."}, true},
+		{[]string{"notexample.com"}, false},
+		{[]string{"example.com.evil.org"}, false},
+		{[]string{"foo.org", "whitehouse.gov"}, true},
+		{[]string{"gov"}, true},
+		{[]string{"mybank"}, false},
+		{[]string{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := filter.Matches(tt.domains); got != tt.want {
+			t.Errorf("Matches(%v) = %t, want %t", tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestEmptyDomainFilterMatchesAll(t *testing.T) {
+	t.Parallel()
+
+	var nilFilter *DomainFilter
+	if !nilFilter.Matches([]string{"example.com"}) {
+		t.Error("nil filter should match all entries")
+	}
+
+	if !NewDomainFilter([]string{"", " . "}, []*regexp.Regexp{nil}).Matches(nil) {
+		t.Error("empty filter should match all entries")
+	}
+}
+
+func TestDomainFilterCombinesSuffixesAndPatterns(t *testing.T) {
+	t.Parallel()
+
+	filter := NewDomainFilter([]string{"example.com"}, []*regexp.Regexp{regexp.MustCompile(`paypa[l1]-secure`)})
+
+	tests := []struct {
+		domains []string
+		want    bool
+	}{
+		{[]string{"www.example.com"}, true},
+		{[]string{"login.paypa1-secure.net"}, true},
+		{[]string{"paypal-secure.com"}, true},
+		{[]string{"paypal.com"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := filter.Matches(tt.domains); got != tt.want {
+			t.Errorf("Matches(%v) = %t, want %t", tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestFilterOperators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		include []string
+		exclude []string
+		want    []string
+	}{
+		{nil, nil, []string{"Google", "Cloudflare", "DigiCert"}},
+		{[]string{"google"}, nil, []string{"Google"}},
+		{nil, []string{"CLOUDFLARE"}, []string{"Google", "DigiCert"}},
+		{[]string{"Google", "Cloudflare"}, []string{"cloudflare"}, []string{"Google"}},
+		{[]string{"Unknown"}, nil, []string{}},
+	}
+
+	for _, tt := range tests {
+		logList := loglist3.LogList{Operators: []*loglist3.Operator{
+			{Name: "Google"}, {Name: "Cloudflare"}, {Name: "DigiCert"},
+		}}
+
+		filterOperators(&logList, tt.include, tt.exclude)
+
+		got := []string{}
+		for _, operator := range logList.Operators {
+			got = append(got, operator.Name)
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filterOperators(%v, %v) = %v, want %v", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+}
+
+func TestFilterLogs(t *testing.T) {
+	t.Parallel()
+
+	// Logs are listed by description (case-insensitive) or url
+	logs := []string{"google 'argon2026' LOG", "https://ct.cloudflare.com/logs/nimbus2026/"}
+
+	tests := []struct {
+		file string
+		mode string
+		want []string
+	}{
+		{"logs.txt", "", []string{"Google 'Argon2026' log", "Google 'Xenon2026' log", "Cloudflare 'Nimbus2026' Log"}},
+		{"logs.txt", config.LogFilterInclude, []string{"Google 'Argon2026' log", "Cloudflare 'Nimbus2026' Log"}},
+		{"logs.txt", config.LogFilterExclude, []string{"Google 'Xenon2026' log"}},
+		// A mode without a file doesn't remove any logs
+		{"", config.LogFilterInclude, []string{"Google 'Argon2026' log", "Google 'Xenon2026' log", "Cloudflare 'Nimbus2026' Log"}},
+	}
+
+	for _, tt := range tests {
+		logList := loglist3.LogList{Operators: []*loglist3.Operator{
+			{Name: "Google", Logs: []*loglist3.Log{
+				{Description: "Google 'Argon2026' log", URL: "https://ct.googleapis.com/logs/us1/argon2026/"},
+				{Description: "Google 'Xenon2026' log", URL: "https://ct.googleapis.com/logs/eu1/xenon2026/"},
+			}},
+			{Name: "Cloudflare", Logs: []*loglist3.Log{
+				{Description: "Cloudflare 'Nimbus2026' Log", URL: "https://ct.cloudflare.com/logs/nimbus2026"},
+			}},
+		}}
+
+		filterLogs(&logList, tt.file, tt.mode, logs)
+
+		got := []string{}
+		for _, operator := range logList.Operators {
+			for _, transparencyLog := range operator.Logs {
+				got = append(got, transparencyLog.Description)
+			}
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("filterLogs(%q, %q) = %v, want %v", tt.file, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesIssuer(t *testing.T) {
+	t.Parallel()
+
+	org, cn := "Let's Encrypt", "R11"
+	issuer := models.Subject{O: &org, CN: &cn}
+
+	tests := []struct {
+		include, exclude []string
+		want             bool
+	}{
+		{nil, nil, true},
+		{[]string{"let's encrypt"}, nil, true},
+		{[]string{"DigiCert", "r1"}, nil, true},
+		{[]string{"DigiCert"}, nil, false},
+		{nil, []string{"ENCRYPT"}, false},
+		{[]string{"Let's Encrypt"}, []string{"R10"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := matchesIssuer(issuer, tt.include, tt.exclude); got != tt.want {
+			t.Errorf("matchesIssuer(%v, %v) = %t, want %t", tt.include, tt.exclude, got, tt.want)
+		}
+	}
+
+	// Issuers without organization or common name only match if there is no include filter
+	if matchesIssuer(models.Subject{}, []string{"Let's Encrypt"}, nil) || !matchesIssuer(models.Subject{}, nil, []string{"x"}) {
+		t.Error("matchesIssuer() of empty issuer doesn't match only without include filter")
+	}
+}
+
+func TestFilterLogStates(t *testing.T) {
+	t.Parallel()
+
+	now := &loglist3.LogState{}
+	logList := loglist3.LogList{Operators: []*loglist3.Operator{{
+		Name: "Google",
+		Logs: []*loglist3.Log{
+			{URL: "usable", State: &loglist3.LogStates{Usable: now}},
+			{URL: "qualified", State: &loglist3.LogStates{Qualified: now}},
+			{URL: "readonly", State: &loglist3.LogStates{ReadOnly: &loglist3.ReadOnlyLogState{}}},
+			{URL: "retired", State: &loglist3.LogStates{Retired: now}},
+			{URL: "custom"},
+		},
+	}}}
+
+	filterLogStates(&logList, nil, logging.Nop())
+
+	got := []string{}
+	for _, transparencyLog := range logList.Operators[0].Logs {
+		got = append(got, transparencyLog.URL)
+	}
+
+	if want := []string{"usable", "qualified", "custom"}; !slices.Equal(got, want) {
+		t.Errorf("filterLogStates() kept %v, want %v", got, want)
+	}
+}
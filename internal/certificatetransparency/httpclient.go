@@ -0,0 +1,153 @@
+package certificatetransparency
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+const (
+	// defaultHTTPTimeout is the timeout of requests to the CT logs if none is configured.
+	defaultHTTPTimeout = 30 * time.Second
+	// defaultMaxIdleConnsPerHost is the number of idle connections kept per host if none is configured. Many logs
+	// share a host, e.g. ct.googleapis.com, so that HTTP/1.1 connections would otherwise be closed after each request.
+	defaultMaxIdleConnsPerHost = 16
+)
+
+// newHTTPClient creates the HTTP client shared by all workers from the given config. Since all workers share its
+// transport, requests to logs on the same host reuse the same connections: a single multiplexed connection with
+// HTTP/2 or a pool of keep-alive connections with HTTP/1.1.
+func newHTTPClient(conf config.HTTPClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+
+	if conf.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+
+	if conf.DisableHTTP2 {
+		disableHTTP2(transport)
+	}
+
+	if conf.ProxyURL != "" {
+		proxyURL, err := url.Parse(conf.ProxyURL)
+		if err != nil || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https") {
+			return nil, fmt.Errorf("invalid proxy url: '%s'", conf.ProxyURL)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// disableHTTP2 restricts the transport to HTTP/1.1.
+func disableHTTP2(transport *http.Transport) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	transport.Protocols = &protocols
+}
+
+// SetHTTPClient sets the HTTP client used for all requests to the CT logs and the log list.
+// It only affects workers that are started afterward, so it should be called before the watcher is started.
+func (w *Watcher) SetHTTPClient(httpClient *http.Client) {
+	w.httpClientMu.Lock()
+	defer w.httpClientMu.Unlock()
+
+	w.httpClient = httpClient
+}
+
+// getHTTPClient returns the HTTP client used for all requests to the CT logs. If none was set, it is created from
+// the config. An invalid config falls back to the default settings.
+func (w *Watcher) getHTTPClient() *http.Client {
+	w.httpClientMu.Lock()
+	defer w.httpClientMu.Unlock()
+
+	if w.httpClient == nil {
+		httpClient, err := newHTTPClient(config.AppConfig.General.HTTPClient)
+		if err != nil {
+			w.logger.Error("Could not create HTTP client, using default settings", "error", err)
+			httpClient, _ = newHTTPClient(config.HTTPClientConfig{})
+		}
+
+		w.httpClient = httpClient
+	}
+
+	return w.httpClient
+}
+
+// workerHTTPClient returns the HTTP client for a log with the given options.
+func (w *Watcher) workerHTTPClient(options config.LogOptions) *http.Client {
+	if options.DisableHTTP2 {
+		return w.getHTTP1Client()
+	}
+
+	return w.getHTTPClient()
+}
+
+// getHTTP1Client returns a variant of the shared HTTP client that only uses HTTP/1.1, for logs that misbehave with
+// HTTP/2. The logs using it share its connections. If the shared client doesn't use an *http.Transport, it is
+// returned unchanged.
+func (w *Watcher) getHTTP1Client() *http.Client {
+	httpClient := w.getHTTPClient()
+
+	w.httpClientMu.Lock()
+	defer w.httpClientMu.Unlock()
+
+	if w.http1Client == nil || w.http1Base != httpClient {
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if !ok {
+			return httpClient
+		}
+
+		transport = transport.Clone()
+		disableHTTP2(transport)
+
+		http1Client := *httpClient
+		http1Client.Transport = transport
+		w.http1Client, w.http1Base = &http1Client, httpClient
+	}
+
+	return w.http1Client
+}
+
+// headerTransport sets the configured headers on each request, after the client set its own headers such as the
+// User-Agent, so that they can be overridden.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+// RoundTrip sends a copy of the request with the headers set.
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.headers) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// requestHeaders converts the configured headers to an http.Header with canonical names.
+func requestHeaders(headers map[string]string) http.Header {
+	requestHeaders := make(http.Header, len(headers))
+	for name, value := range headers {
+		requestHeaders.Set(name, value)
+	}
+
+	return requestHeaders
+}
@@ -0,0 +1,148 @@
+package certificatetransparency
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// newTLSTestServer starts a TLS server with HTTP/2 enabled that simulates the latency of a CT log and counts the
+// connections that were opened.
+func newTLSTestServer(tb testing.TB, latency time.Duration) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+
+	var connections atomic.Int64
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+		_, _ = io.WriteString(w, r.Proto)
+	}))
+	server.EnableHTTP2 = true
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.StartTLS()
+	tb.Cleanup(server.Close)
+
+	return server, &connections
+}
+
+// newTestHTTPClient creates an HTTP client from the config that trusts the certificate of the test server.
+func newTestHTTPClient(tb testing.TB, server *httptest.Server, conf config.HTTPClientConfig) *http.Client {
+	tb.Helper()
+
+	httpClient, err := newHTTPClient(conf)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	transport := httpClient.Transport.(*http.Transport)
+	transport.TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	return httpClient
+}
+
+func TestHTTPClientProtocol(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newTLSTestServer(t, 0)
+
+	for _, tt := range []struct {
+		conf      config.HTTPClientConfig
+		wantProto string
+	}{
+		{config.HTTPClientConfig{}, "HTTP/2.0"},
+		{config.HTTPClientConfig{DisableHTTP2: true}, "HTTP/1.1"},
+	} {
+		resp, err := newTestHTTPClient(t, server, tt.conf).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if string(body) != tt.wantProto {
+			t.Errorf("DisableHTTP2 = %v: got %s, want %s", tt.conf.DisableHTTP2, body, tt.wantProto)
+		}
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("User-Agent")+"|"+r.Header.Get("Proxy-Authorization"))
+	}))
+	t.Cleanup(server.Close)
+
+	headers := requestHeaders(map[string]string{"user-agent": "Example Monitor", "proxy-authorization": "Basic dGVzdA=="})
+	httpClient := &http.Client{Transport: headerTransport{base: http.DefaultTransport, headers: headers}}
+
+	// The configured User-Agent replaces the one set by the CT client
+	req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if want := "Example Monitor|Basic dGVzdA=="; string(body) != want {
+		t.Errorf("got headers %s, want %s", body, want)
+	}
+
+	if req.Header.Get("User-Agent") != userAgent {
+		t.Error("the headers of the original request were modified")
+	}
+}
+
+// BenchmarkHTTPClient measures the latency of concurrent requests of multiple workers to the same host, which
+// depends on how many connections have to be established. Like the workers, each goroutine processes the response
+// before it sends the next request, so that the connections are idle in-between.
+func BenchmarkHTTPClient(b *testing.B) {
+	for _, bb := range []struct {
+		name string
+		conf config.HTTPClientConfig
+	}{
+		{"http2", config.HTTPClientConfig{}},
+		{"http1", config.HTTPClientConfig{DisableHTTP2: true}},
+		{"http1_2_idle", config.HTTPClientConfig{DisableHTTP2: true, MaxIdleConnsPerHost: 2}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			server, connections := newTLSTestServer(b, time.Millisecond)
+			httpClient := newTestHTTPClient(b, server, bb.conf)
+
+			b.SetParallelism(8)
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					resp, err := httpClient.Get(server.URL)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+
+					_, _ = io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+
+					time.Sleep(time.Millisecond)
+				}
+			})
+
+			b.ReportMetric(float64(connections.Load()), "conns")
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package certificatetransparency
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// errorChanSize is the number of errors that are buffered before new errors get dropped.
+const errorChanSize = 100
+
+var droppedErrors int64
+
+// LogError is a non-fatal error that occurred while processing a specific CT log.
+type LogError struct {
+	LogName string
+	LogURL  string
+	Err     error
+}
+
+// Error returns the error message including the name and url of the CT log.
+func (e *LogError) Error() string {
+	return fmt.Sprintf("ct log '%s' (%s): %s", e.LogName, e.LogURL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *LogError) Unwrap() error {
+	return e.Err
+}
+
+// sendError passes the error to the given error channel without blocking.
+// If the channel is full, the error is dropped and the drop counter is incremented.
+func sendError(errChan chan error, err error) {
+	if errChan == nil {
+		return
+	}
+
+	select {
+	case errChan <- err:
+	default:
+		atomic.AddInt64(&droppedErrors, 1)
+	}
+}
+
+// GetDroppedErrors returns the number of errors that were dropped because the error channel was full.
+func GetDroppedErrors() int64 {
+	return atomic.LoadInt64(&droppedErrors)
+}
@@ -0,0 +1,168 @@
+package certificatetransparency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/certificate-transparency-go/loglist3"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// ErrLogListUnavailable is returned by Start if the CT log list could neither be fetched nor read from the cache.
+var ErrLogListUnavailable = errors.New("ct log list unavailable")
+
+// logListURL is the url of the CT log list of Google Chrome. It is a variable, so that tests can replace it.
+var logListURL = loglist3.LogListURL
+
+// logListCacheVersion is the version of the schema of the log list cache file. Cache files of other versions are
+// ignored.
+const logListCacheVersion = 1
+
+// logListCache is the content of the log list cache file.
+type logListCache struct {
+	Version   int       `json:"version"`
+	FetchedAt time.Time `json:"fetched_at"`
+	// LogList is the log list as fetched from Google.
+	LogList json.RawMessage `json:"log_list"`
+}
+
+// getGoogleLogList fetches the list of all CT logs from Google Chromes CT LogList. If the cache is enabled, the
+// fetched list is saved to the cache file.
+func (w *Watcher) getGoogleLogList() (loglist3.LogList, error) {
+	data, err := downloadLogList(w.getHTTPClient())
+	if err != nil {
+		return loglist3.LogList{}, err
+	}
+
+	allLogs, err := loglist3.NewFromJSON(data)
+	if err != nil {
+		return loglist3.LogList{}, err
+	}
+
+	if cachePath := config.AppConfig.General.LogListCache.Path; cachePath != "" {
+		if err = saveLogListCache(cachePath, data, time.Now()); err != nil {
+			w.logger.Warn("Could not save CT log list to cache file", "path", cachePath, "error", err)
+		}
+	}
+
+	return *allLogs, nil
+}
+
+// downloadLogList downloads the JSON encoded log list.
+func downloadLogList(httpClient *http.Client) ([]byte, error) {
+	resp, err := httpClient.Get(logListURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download loglist: %s", resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// getStartupLogList returns the cached log list if it is younger than the TTL of the cache. Otherwise, it fetches the
+// log list like getGoogleLogList, but retries with backoff until the configured number of attempts or the startup
+// timeout is exhausted, so that a transient network error doesn't leave the watcher without logs. If all attempts
+// fail, the cached log list is returned regardless of its age, if any.
+func (w *Watcher) getStartupLogList() (loglist3.LogList, error) {
+	conf := config.AppConfig.General.LogList
+	cacheConf := config.AppConfig.General.LogListCache
+
+	if cacheConf.Path != "" {
+		allLogs, fetchedAt, err := loadLogListCache(cacheConf.Path)
+		switch {
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			w.logger.Warn("Ignoring CT log list cache file", "path", cacheConf.Path, "error", err)
+		case err == nil && time.Since(fetchedAt) < cacheConf.TTL:
+			w.logger.Info("Using cached CT log list", "path", cacheConf.Path, "fetched_at", fetchedAt)
+			return allLogs, nil
+		}
+	}
+
+	retry := newBackoff(config.AppConfig.General.Retry)
+	deadline := time.Now().Add(conf.StartupTimeout)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		var allLogs loglist3.LogList
+		if allLogs, err = w.getGoogleLogList(); err == nil {
+			return allLogs, nil
+		}
+
+		_, delay := retry.failure()
+		if attempt >= conf.StartupAttempts || time.Now().Add(delay).After(deadline) {
+			break
+		}
+
+		w.logger.Warn("Could not fetch CT log list, retrying", "attempt", attempt, "delay", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-w.context.Done():
+			return loglist3.LogList{}, w.context.Err()
+		}
+	}
+
+	if cacheConf.Path == "" {
+		return loglist3.LogList{}, err
+	}
+
+	allLogs, fetchedAt, cacheErr := loadLogListCache(cacheConf.Path)
+	if cacheErr != nil {
+		return loglist3.LogList{}, fmt.Errorf("%w (cache file: %w)", err, cacheErr)
+	}
+
+	w.logger.Warn("Could not fetch CT log list, using the cached log list", "path", cacheConf.Path, "fetched_at", fetchedAt, "error", err)
+
+	return allLogs, nil
+}
+
+// saveLogListCache saves the JSON encoded log list along with the time it was fetched. The cache file is written to
+// a temporary file first and then moved to the given path, so that the last good copy is not clobbered by a partial
+// write.
+func saveLogListCache(path string, logList []byte, fetchedAt time.Time) error {
+	data, err := json.Marshal(logListCache{Version: logListCacheVersion, FetchedAt: fetchedAt, LogList: logList})
+	if err != nil {
+		return err
+	}
+
+	tempPath := path + ".tmp"
+	if err = os.WriteFile(tempPath, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
+
+// loadLogListCache reads the log list and the time it was fetched from the cache file. An error is returned if the
+// file has another schema version or doesn't contain a valid log list.
+func loadLogListCache(path string) (loglist3.LogList, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return loglist3.LogList{}, time.Time{}, err
+	}
+
+	var cache logListCache
+	if err = json.Unmarshal(data, &cache); err != nil {
+		return loglist3.LogList{}, time.Time{}, err
+	}
+
+	if cache.Version != logListCacheVersion {
+		return loglist3.LogList{}, time.Time{}, fmt.Errorf("unsupported cache version %d, expected %d", cache.Version, logListCacheVersion)
+	}
+
+	allLogs, err := loglist3.NewFromJSON(cache.LogList)
+	if err != nil {
+		return loglist3.LogList{}, time.Time{}, fmt.Errorf("invalid log list: %w", err)
+	}
+
+	return *allLogs, cache.FetchedAt, nil
+}
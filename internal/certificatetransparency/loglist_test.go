@@ -0,0 +1,79 @@
+package certificatetransparency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestGetStartupLogList(t *testing.T) {
+	// The options are read from the global config
+	previous := config.AppConfig.General
+	t.Cleanup(func() { config.AppConfig.General = previous })
+
+	cacheFile := filepath.Join(t.TempDir(), "log_list.json")
+	config.AppConfig.General.Retry = config.RetryConfig{InitialDelay: time.Millisecond}
+	config.AppConfig.General.LogList = config.LogListConfig{StartupAttempts: 3, StartupTimeout: time.Minute}
+	config.AppConfig.General.LogListCache = config.LogListCacheConfig{Path: cacheFile, TTL: time.Hour}
+
+	// The log list is unavailable until failures drops to zero
+	var failures atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"version": "1", "operators": [{"name": "Test operator", "logs": []}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL := logListURL
+	logListURL = server.URL
+	t.Cleanup(func() { logListURL = previousURL })
+
+	w := &Watcher{context: t.Context(), logger: logging.Nop(), httpClient: server.Client()}
+
+	// The third attempt succeeds and saves the log list to the cache
+	failures.Store(2)
+	if logList, err := w.getStartupLogList(); err != nil || len(logList.Operators) != 1 {
+		t.Fatalf("got %d operators (error %v), want 1", len(logList.Operators), err)
+	}
+
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Fatalf("log list was not cached: %v", err)
+	}
+
+	// The cache is fresh, so the log list is not fetched at all
+	failures.Store(3)
+	if logList, err := w.getStartupLogList(); err != nil || len(logList.Operators) != 1 || failures.Load() != 3 {
+		t.Errorf("got %d operators (error %v) from the fresh cache, want 1 without fetching", len(logList.Operators), err)
+	}
+
+	// All attempts fail, so the expired cache is used as fallback
+	cached := []byte(`{"version": "1", "operators": [{"name": "A", "logs": []}, {"name": "B", "logs": []}]}`)
+	if err := saveLogListCache(cacheFile, cached, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if logList, err := w.getStartupLogList(); err != nil || len(logList.Operators) != 2 || failures.Load() != 0 {
+		t.Errorf("got %d operators (error %v) from the expired cache, want 2 after fetching", len(logList.Operators), err)
+	}
+
+	// A cache of another schema version is not trusted
+	if err := os.WriteFile(cacheFile, []byte(`{"version": 2, "fetched_at": "2026-01-01T00:00:00Z", "log_list": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	failures.Store(3)
+	if _, err := w.getStartupLogList(); err == nil {
+		t.Error("got no error without log list and with a cache of another version")
+	}
+}
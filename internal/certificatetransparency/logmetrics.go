@@ -1,13 +1,11 @@
 package certificatetransparency
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
 	"maps"
-	"os"
 	"sync"
-	"time"
+	"sync/atomic"
+
+	victoriametrics "github.com/VictoriaMetrics/metrics"
 )
 
 type (
@@ -22,9 +20,22 @@ type (
 )
 
 var (
-	processedCerts    int64
-	processedPrecerts int64
-	metrics           = LogMetrics{metrics: make(CTMetrics), index: make(CTCertIndex)}
+	processedCerts        int64
+	processedPrecerts     int64
+	wildcardFilteredCerts int64
+	precertFilteredCerts  int64
+	ipSANFilteredCerts    int64
+	issuerFilteredCerts   int64
+	sanCountFilteredCerts int64
+	sampledOutCerts       int64
+	fetchedBytes          int64
+	metrics               = LogMetrics{metrics: make(CTMetrics), index: make(CTCertIndex)}
+
+	// deliveryLatency measures the time between an entry being handed to a worker and being passed to the output channel.
+	// The buckets range from sub-milliseconds to multiple seconds, since backpressure can stretch the latency.
+	deliveryLatency = victoriametrics.NewPrometheusHistogramExt("certstreamservergo_delivery_latency_seconds", []float64{
+		0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+	})
 )
 
 // LogMetrics is a struct that holds a map of metrics for each CT log grouped by operator.
@@ -127,6 +138,18 @@ func (m *LogMetrics) Inc(operator, url string, index uint64) {
 	m.index[url] = index
 }
 
+// IncCount increments the metric for a given operator and ct url without updating the index.
+func (m *LogMetrics) IncCount(operator, url string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.metrics[operator]; !ok {
+		m.metrics[operator] = make(OperatorMetric)
+	}
+
+	m.metrics[operator][url]++
+}
+
 // GetAllCTIndexes returns a copy of the internal CT index map.
 func (m *LogMetrics) GetAllCTIndexes() CTCertIndex {
 	m.mutex.RLock()
@@ -152,125 +175,58 @@ func (m *LogMetrics) GetCTIndex(url string) uint64 {
 	return index
 }
 
-// LoadCTIndex loads the last cert index processed for each CT url if it exists.
-func (m *LogMetrics) LoadCTIndex(ctIndexFilePath string) {
+// SetCTIndex sets the last cert index processed for a given CT url.
+func (m *LogMetrics) SetCTIndex(url string, index uint64) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	bytes, readErr := os.ReadFile(ctIndexFilePath)
-	if readErr != nil {
-		// Create the file if it doesn't exist
-		if os.IsNotExist(readErr) {
-			err := createCTIndexFile(ctIndexFilePath, m)
-			if err != nil {
-				log.Printf("Error creating CT index file: '%s'\n", ctIndexFilePath)
-				log.Panicln(err)
-			}
-		} else {
-			// If the file exists but we can't read it, log the error and panic
-			log.Panicln(readErr)
-		}
-	}
-
-	jerr := json.Unmarshal(bytes, &m.index)
-	if jerr != nil {
-		log.Printf("Error unmarshalling CT index file: '%s'\n", ctIndexFilePath)
-		log.Panicln(jerr)
-	}
-
-	log.Println("Successfully loaded saved CT indexes")
+	m.index[url] = index
 }
 
-func createCTIndexFile(ctIndexFilePath string, m *LogMetrics) error {
-	log.Printf("Specified CT index file does not exist: '%s'\n", ctIndexFilePath)
-	log.Println("Creating CT index file now!")
-
-	file, createErr := os.Create(ctIndexFilePath)
-	if createErr != nil {
-		log.Printf("Error creating CT index file: '%s'\n", ctIndexFilePath)
-		log.Panicln(createErr)
-	}
-
-	bytes, marshalErr := json.Marshal(m.index)
-	if marshalErr != nil {
-		return marshalErr
-	}
-	_, writeErr := file.Write(bytes)
-	if writeErr != nil {
-		log.Printf("Error writing to CT index file: '%s'\n", ctIndexFilePath)
-		log.Panicln(writeErr)
-	}
-	file.Close()
-
-	return nil
+// GetProcessedCerts returns the total number of processed certificates.
+func GetProcessedCerts() int64 {
+	return atomic.LoadInt64(&processedCerts)
 }
 
-// SaveCertIndexesAtInterval saves the index of CTLogs at given intervals.
-// We first create a temp file and write the index data to it. Only then do we move the temp file to the actual
-// permanent index file. This prevents the last good index file from being clobbered if the program was shutdown/killed
-// in-between the write operation.
-func (m *LogMetrics) SaveCertIndexesAtInterval(interval time.Duration, ctIndexFilePath string) {
-	tempFilePath := fmt.Sprintf("%s.tmp", ctIndexFilePath)
-
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		m.SaveCertIndexes(tempFilePath, ctIndexFilePath)
-	}
+// GetProcessedPrecerts returns the total number of processed precertificates.
+func GetProcessedPrecerts() int64 {
+	return atomic.LoadInt64(&processedPrecerts)
 }
 
-// SaveCertIndexes saves the index of CTLogs to a file.
-func (m *LogMetrics) SaveCertIndexes(tempFilePath, ctIndexFilePath string) {
-	// Get the index data
-	ctIndex := m.GetAllCTIndexes()
-	bytes, cerr := json.MarshalIndent(ctIndex, "", " ")
-	if cerr != nil {
-		log.Panic(cerr)
-	}
+// GetWildcardFilteredCerts returns the number of certificates that were discarded because they contain no wildcard domain.
+func GetWildcardFilteredCerts() int64 {
+	return atomic.LoadInt64(&wildcardFilteredCerts)
+}
 
-	// Save data to a temporary file first
-	file, openErr := os.OpenFile(tempFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	if openErr != nil {
-		log.Println("Could not save CT index to temporary file: ", openErr)
-		return
-	}
+// GetIPSANFilteredCerts returns the number of certificates that were discarded because they contain no IP address.
+func GetIPSANFilteredCerts() int64 {
+	return atomic.LoadInt64(&ipSANFilteredCerts)
+}
 
-	truncateErr := file.Truncate(0)
-	if truncateErr != nil {
-		log.Println("Error truncating CT index temp file: ", truncateErr)
-		return
-	}
-	// TODO: check for short writes
-	_, writeErr := file.Write(bytes)
-	if writeErr != nil {
-		log.Println("Error writing to CT index temp file: ", writeErr)
-		return
-	}
-	syncErr := file.Sync()
-	if syncErr != nil {
-		log.Println("Error syncing CT index temp file: ", syncErr)
-		return
-	}
+// GetIssuerFilteredCerts returns the number of certificates that were discarded because of the issuer filters.
+func GetIssuerFilteredCerts() int64 {
+	return atomic.LoadInt64(&issuerFilteredCerts)
+}
 
-	file.Close()
+// GetSANCountFilteredCerts returns the number of certificates that were discarded because their number of SANs is
+// outside the configured range.
+func GetSANCountFilteredCerts() int64 {
+	return atomic.LoadInt64(&sanCountFilteredCerts)
+}
 
-	// Atomically move the temp file to the permanent file
-	renameErr := os.Rename(tempFilePath, ctIndexFilePath)
-	if renameErr != nil {
-		log.Println("Error renaming CT index temp file: ", renameErr)
-		return
-	}
+// GetPrecertFilteredCerts returns the number of precertificates that were discarded because precertificates are excluded.
+func GetPrecertFilteredCerts() int64 {
+	return atomic.LoadInt64(&precertFilteredCerts)
 }
 
-// GetProcessedCerts returns the total number of processed certificates.
-func GetProcessedCerts() int64 {
-	return processedCerts
+// GetSampledOutCerts returns the number of certificates that were discarded by the sampling.
+func GetSampledOutCerts() int64 {
+	return atomic.LoadInt64(&sampledOutCerts)
 }
 
-// GetProcessedPrecerts returns the total number of processed precertificates.
-func GetProcessedPrecerts() int64 {
-	return processedPrecerts
+// GetFetchedBytes returns the number of bytes of the response bodies received from all CT logs.
+func GetFetchedBytes() int64 {
+	return atomic.LoadInt64(&fetchedBytes)
 }
 
 func GetCertMetrics() CTMetrics {
@@ -0,0 +1,328 @@
+package certificatetransparency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// LogStats contains information about a single watched CT log, including the effective tunables of its worker.
+type LogStats struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Operator    string `json:"operator"`
+	WorkerCount int    `json:"worker_count"`
+	BatchSize   int    `json:"batch_size"`
+	Paused      bool   `json:"paused"`
+	// Entries is the number of entries processed from the log since the worker was started.
+	Entries uint64 `json:"entries"`
+	// Index is the index of the next entry to be processed.
+	Index uint64 `json:"index"`
+	// TreeSize is the last known size of the log's tree. It is polled once per minute.
+	TreeSize uint64 `json:"tree_size"`
+	// Gap is the number of entries in the log that have not been processed yet.
+	Gap uint64 `json:"gap"`
+	// BytesFetched is the number of bytes of the response bodies received from the log since the worker was started.
+	BytesFetched uint64 `json:"bytes_fetched"`
+	// GetEntriesRequests is the number of get-entries requests sent to the log since the worker was started,
+	// including failed attempts.
+	GetEntriesRequests uint64 `json:"get_entries_requests"`
+	// Circuit is the state of the log's circuit breaker: "closed", "open" or "half-open".
+	// Requests to the log are suspended while the circuit is open.
+	Circuit string `json:"circuit"`
+	// STHPollIntervalSeconds is the effective minimum time between two get-sth requests to the log in seconds.
+	// It is the configured interval, unless the log asks for a longer one via the max-age of its get-sth responses.
+	STHPollIntervalSeconds float64 `json:"sth_poll_interval_seconds"`
+	// ClampedRequests is the number of get-entries requests that were clamped to the last known good tree size or
+	// held back until the log caught up, because the log served a stale STH or rejected the range as out of range.
+	ClampedRequests uint64 `json:"clamped_requests"`
+}
+
+// treeSizePollInterval is the interval in which the tree size of each log is polled.
+const treeSizePollInterval = time.Minute
+
+// logProgress tracks how far a worker got in its CT log. All fields can be accessed concurrently.
+type logProgress struct {
+	entries   atomic.Uint64
+	nextIndex atomic.Uint64
+	treeSize  atomic.Uint64
+	bytes     atomic.Uint64
+	// getEntries is the number of get-entries requests sent to the log, including failed attempts.
+	getEntries atomic.Uint64
+	// lastSuccess is the time of the last successful request to the log in unix nanoseconds. Zero if none succeeded.
+	lastSuccess atomic.Int64
+	// clamped is the number of get-entries requests that were clamped to the tree head of the log.
+	clamped atomic.Uint64
+	// sthMaxAge is the max-age of the last get-sth response in nanoseconds. Zero if the log didn't send one.
+	sthMaxAge atomic.Int64
+}
+
+// start sets the index at which the worker starts processing.
+func (p *logProgress) start(index uint64) {
+	p.nextIndex.Store(index)
+}
+
+// processed marks the entry with the given index as processed. Entries may be processed out of order
+// if multiple scanner workers are used, so the next index only ever increases.
+func (p *logProgress) processed(index int64) {
+	p.entries.Add(1)
+
+	next := uint64(index) + 1
+	for {
+		current := p.nextIndex.Load()
+		if next <= current || p.nextIndex.CompareAndSwap(current, next) {
+			return
+		}
+	}
+}
+
+// sthPollInterval returns the effective minimum time between two get-sth requests for the configured interval.
+// A longer max-age of the get-sth responses is respected, since the log won't serve a newer STH before, but only up
+// to the interval in which the tree size is polled for the stats.
+func (p *logProgress) sthPollInterval(configured time.Duration) time.Duration {
+	return max(configured, min(time.Duration(p.sthMaxAge.Load()), treeSizePollInterval))
+}
+
+// gap returns the number of entries between the next index and the tree size.
+func (p *logProgress) gap() uint64 {
+	treeSize, nextIndex := p.treeSize.Load(), p.nextIndex.Load()
+	if treeSize <= nextIndex {
+		return 0
+	}
+
+	return treeSize - nextIndex
+}
+
+// pollTreeSize periodically fetches the STH of the log and updates the tree size until the context is cancelled.
+// After each poll, the progress of the worker is logged at debug level.
+func (w *worker) pollTreeSize(ctx context.Context, jsonClient *treeHeadClient) {
+	ticker := time.NewTicker(treeSizePollInterval)
+	defer ticker.Stop()
+
+	p := &w.progress
+
+	// The tree size is already known if the worker started at the latest STH
+	poll := p.treeSize.Load() == 0
+
+	for {
+		if poll {
+			// The head of the client ignores stale STHs that some logs serve from their CDN
+			if _, err := jsonClient.GetSTH(ctx); err == nil {
+				p.treeSize.Store(jsonClient.head.Load())
+			}
+
+			w.logger.Debug("Fetch progress", "url", w.ctURL, "index", p.nextIndex.Load(),
+				"tree_size", p.treeSize.Load(), "gap", p.gap(), "entries", p.entries.Load())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			poll = true
+		}
+	}
+}
+
+// LogStats returns a snapshot of the stats of all currently watched CT logs.
+func (w *Watcher) LogStats() []LogStats {
+	w.workersMu.RLock()
+	defer w.workersMu.RUnlock()
+
+	stats := make([]LogStats, 0, len(w.workers))
+	for _, ctWorker := range w.workers {
+		ctWorker.mu.Lock()
+		batchSize := ctWorker.batchSize
+		ctWorker.mu.Unlock()
+
+		stats = append(stats, LogStats{
+			Name:                   ctWorker.name,
+			URL:                    ctWorker.ctURL,
+			Operator:               ctWorker.operatorName,
+			WorkerCount:            ctWorker.workerCount,
+			BatchSize:              batchSize,
+			Paused:                 ctWorker.isPaused(),
+			Entries:                ctWorker.progress.entries.Load(),
+			Index:                  ctWorker.progress.nextIndex.Load(),
+			TreeSize:               ctWorker.progress.treeSize.Load(),
+			Gap:                    ctWorker.progress.gap(),
+			BytesFetched:           ctWorker.progress.bytes.Load(),
+			GetEntriesRequests:     ctWorker.progress.getEntries.Load(),
+			Circuit:                ctWorker.breaker.getState().String(),
+			STHPollIntervalSeconds: ctWorker.progress.sthPollInterval(ctWorker.sthPollInterval).Seconds(),
+			ClampedRequests:        ctWorker.progress.clamped.Load(),
+		})
+	}
+
+	return stats
+}
+
+// LastSuccessfulFetch returns the time of the most recent successful request to any of the watched CT logs.
+// The time is zero if no request succeeded yet.
+func (w *Watcher) LastSuccessfulFetch() time.Time {
+	w.workersMu.RLock()
+	defer w.workersMu.RUnlock()
+
+	var last int64
+	for _, ctWorker := range w.workers {
+		last = max(last, ctWorker.progress.lastSuccess.Load())
+	}
+
+	if last == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, last)
+}
+
+// logOptions returns the effective options for the CT log with the given url. Options of logs added via AddLog or
+// the additional logs take precedence over the log options of the config, which in turn take precedence over the
+// global scanner options.
+func (w *Watcher) logOptions(url string) config.LogOptions {
+	normalizedURL := normalizeCtlogURL(url)
+	options := config.LogOptions{}
+
+	applyOptions := func(override config.LogOptions) {
+		if options.WorkerCount <= 0 {
+			options.WorkerCount = override.WorkerCount
+		}
+
+		if options.BatchSize <= 0 {
+			options.BatchSize = override.BatchSize
+		}
+
+		if options.STHPollInterval <= 0 {
+			options.STHPollInterval = override.STHPollInterval
+		}
+
+		options.DisableHTTP2 = options.DisableHTTP2 || override.DisableHTTP2
+	}
+
+	for _, logConfig := range slices.Concat(w.customLogs, config.AppConfig.General.AdditionalLogs) {
+		if normalizeCtlogURL(logConfig.URL) == normalizedURL {
+			applyOptions(logConfig.LogOptions)
+		}
+	}
+
+	for logURL, override := range config.AppConfig.General.LogOptions {
+		if normalizeCtlogURL(logURL) == normalizedURL {
+			applyOptions(override)
+		}
+	}
+
+	applyOptions(config.LogOptions{
+		WorkerCount:     config.AppConfig.General.ScannerOptions.NumWorkers,
+		BatchSize:       config.AppConfig.General.ScannerOptions.BatchSize,
+		STHPollInterval: config.AppConfig.General.STHPollInterval,
+	})
+
+	return options
+}
+
+// capBatchSize caps the batch size of the worker to the maximum number of entries the CT log returns per get-entries
+// request. Logs may return fewer entries than requested, so the limit is determined by requesting a full batch once.
+// It returns the effective batch size.
+func (w *worker) capBatchSize(ctx context.Context, jsonClient *treeHeadClient) int {
+	w.mu.Lock()
+	batchSize, probed := w.batchSize, w.batchSizeProbed
+	w.mu.Unlock()
+
+	if probed || batchSize <= 1 {
+		return batchSize
+	}
+
+	// Logs with fewer entries than the batch size would return fewer entries anyway
+	sth, err := jsonClient.GetSTH(ctx)
+	if err != nil || sth.TreeSize < uint64(batchSize) {
+		return batchSize
+	}
+
+	response, err := jsonClient.GetRawEntries(ctx, 0, int64(batchSize-1))
+	if err != nil {
+		return batchSize
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.batchSizeProbed = true
+
+	if maxEntries := len(response.Entries); maxEntries > 0 && maxEntries < batchSize {
+		w.logger.Debug("Capping batch size to the maximum number of entries the CT log returns per request",
+			"url", w.ctURL, "batch_size", batchSize, "max_entries", maxEntries)
+		w.batchSize = maxEntries
+	}
+
+	return w.batchSize
+}
+
+// countingTransport counts the get-entries requests sent to a CT log and the bytes of the response bodies received
+// from it.
+type countingTransport struct {
+	base     http.RoundTripper
+	progress *logProgress
+}
+
+// RoundTrip sends the request and wraps the response body, so that the bytes are counted as they are read.
+// Responses with status 2xx are recorded as successful requests, and the max-age of successful get-sth responses is
+// recorded for the polling interval.
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, ct.GetEntriesPath) {
+		t.progress.getEntries.Add(1)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, progress: t.progress}
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			t.progress.lastSuccess.Store(time.Now().UnixNano())
+
+			if strings.HasSuffix(req.URL.Path, ct.GetSTHPath) {
+				t.progress.sthMaxAge.Store(int64(maxAge(resp.Header)))
+			}
+		}
+	}
+
+	return resp, err
+}
+
+// maxAge returns the max-age of the Cache-Control header. Zero if the header doesn't contain a valid max-age.
+func maxAge(header http.Header) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-age") {
+			continue
+		}
+
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return 0
+}
+
+// countingBody adds the bytes read from the body to the fetched bytes of the log and the total.
+type countingBody struct {
+	io.ReadCloser
+	progress *logProgress
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.progress.bytes.Add(uint64(n))
+		atomic.AddInt64(&fetchedBytes, int64(n))
+	}
+
+	return n, err
+}
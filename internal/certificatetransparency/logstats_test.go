@@ -0,0 +1,84 @@
+package certificatetransparency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+func TestMaxAge(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		cacheControl string
+		want         time.Duration
+	}{
+		{"", 0},
+		{"no-cache", 0},
+		{"max-age=10", 10 * time.Second},
+		{"public, Max-Age=30, must-revalidate", 30 * time.Second},
+		{"max-age=abc", 0},
+		{"max-age=-5", 0},
+	} {
+		header := http.Header{}
+		header.Set("Cache-Control", tt.cacheControl)
+
+		if got := maxAge(header); got != tt.want {
+			t.Errorf("maxAge(%q) = %s, want %s", tt.cacheControl, got, tt.want)
+		}
+	}
+}
+
+func TestSTHPollInterval(t *testing.T) {
+	t.Parallel()
+
+	var p logProgress
+
+	if got := p.sthPollInterval(5 * time.Second); got != 5*time.Second {
+		t.Errorf("got interval %s without max-age, want 5s", got)
+	}
+
+	p.sthMaxAge.Store(int64(20 * time.Second))
+
+	if got := p.sthPollInterval(5 * time.Second); got != 20*time.Second {
+		t.Errorf("got interval %s with max-age 20s, want 20s", got)
+	}
+
+	// The max-age is capped to the interval in which the tree size is polled for the stats
+	p.sthMaxAge.Store(int64(time.Hour))
+
+	if got := p.sthPollInterval(5 * time.Second); got != treeSizePollInterval {
+		t.Errorf("got interval %s with max-age 1h, want %s", got, treeSizePollInterval)
+	}
+}
+
+func TestCountingTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "12345")
+	}))
+	t.Cleanup(server.Close)
+
+	var progress logProgress
+	httpClient := &http.Client{Transport: countingTransport{base: http.DefaultTransport, progress: &progress}}
+
+	for _, path := range []string{ct.GetSTHPath, ct.GetEntriesPath, ct.GetEntriesPath} {
+		resp, err := httpClient.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	// Only the get-entries requests are counted, but the bytes of all responses
+	if requests, fetched := progress.getEntries.Load(), progress.bytes.Load(); requests != 2 || fetched != 15 {
+		t.Errorf("got %d get-entries requests and %d bytes, want 2 and 15", requests, fetched)
+	}
+}
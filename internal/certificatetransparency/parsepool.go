@@ -0,0 +1,63 @@
+package certificatetransparency
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+)
+
+// parseQueueSize is the number of raw entries buffered between the workers and the parse workers.
+const parseQueueSize = 1000
+
+// parseJob is a raw entry of a worker that waits to be parsed by one of the parse workers.
+type parseJob struct {
+	ctx       context.Context
+	worker    *worker
+	rawEntry  *ct.RawLogEntry
+	fetchedAt time.Time
+}
+
+// startParseWorkers starts n goroutines that parse the entries of all workers, so that a burst of a single log is
+// spread across all cores. The entries are passed on in no particular order. The returned function waits until all
+// queued entries were parsed and must only be called once no worker is running anymore.
+func (w *Watcher) startParseWorkers(n int) func() {
+	parseQueue := make(chan parseJob, parseQueueSize)
+	w.parseQueue.Store(&parseQueue)
+
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range parseQueue {
+				job.worker.processEntry(job.ctx, job.rawEntry, job.fetchedAt)
+			}
+		}()
+	}
+
+	w.logger.Info("Started parse workers", "count", n)
+
+	return func() {
+		close(parseQueue)
+		wg.Wait()
+	}
+}
+
+// getParseQueue returns the queue of the parse workers or nil if they are disabled.
+func (w *Watcher) getParseQueue() chan parseJob {
+	if parseQueue := w.parseQueue.Load(); parseQueue != nil {
+		return *parseQueue
+	}
+
+	return nil
+}
+
+// ParseQueueLength returns the number of raw entries waiting for the parse workers.
+// It is always 0 if the parse workers are disabled.
+func (w *Watcher) ParseQueueLength() int {
+	return len(w.getParseQueue())
+}
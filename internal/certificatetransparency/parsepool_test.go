@@ -0,0 +1,62 @@
+package certificatetransparency
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/letrics/certstream-server-go/internal/logging"
+)
+
+// BenchmarkProcessEntry measures the throughput of parsing entries right away in a single goroutine compared to
+// passing them to a pool with one parse worker per CPU core.
+func BenchmarkProcessEntry(b *testing.B) {
+	// The extra data is an empty chain
+	rawEntry, err := ct.RawLogEntryFromLeaf(0, &ct.LeafEntry{LeafInput: testLeafInput(b), ExtraData: []byte{0, 0, 0}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, pool := range []bool{false, true} {
+		name := "direct"
+		if pool {
+			name = "pool"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			entryChan := make(chan workerEntry, parseQueueSize)
+			w := &worker{name: "Test log", logger: logging.Nop(), entryChan: entryChan}
+
+			drained := make(chan struct{})
+			go func() {
+				defer close(drained)
+
+				for range b.N {
+					<-entryChan
+				}
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			if pool {
+				watcher := &Watcher{logger: logging.Nop()}
+				stop := watcher.startParseWorkers(runtime.GOMAXPROCS(0))
+				parseQueue := watcher.getParseQueue()
+
+				for range b.N {
+					parseQueue <- parseJob{ctx: b.Context(), worker: w, rawEntry: rawEntry, fetchedAt: time.Now()}
+				}
+
+				stop()
+			} else {
+				for range b.N {
+					w.processEntry(b.Context(), rawEntry, time.Now())
+				}
+			}
+
+			<-drained
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package certificatetransparency
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// requestLimiter is a token bucket shared by all workers that limits the rate of the get-entries requests to all CT
+// logs together. Unlike the backoff of each log, it caps the total load on the logs. Requests that exceed the rate
+// are delayed, not dropped. It is safe for concurrent use.
+type requestLimiter struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// allowed and limited are the number of requests that were sent right away and that had to wait for a token.
+	allowed atomic.Uint64
+	limited atomic.Uint64
+}
+
+// newRequestLimiter creates a requestLimiter from the given config. It returns nil if the rate limit is disabled.
+func newRequestLimiter(conf config.RequestRateLimitConfig) *requestLimiter {
+	if conf.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	burst := conf.Burst
+	if burst <= 0 {
+		burst = max(1, int(math.Ceil(conf.RequestsPerSecond)))
+	}
+
+	return &requestLimiter{rate: conf.RequestsPerSecond, burst: float64(burst), tokens: float64(burst)}
+}
+
+// reserve takes a token from the bucket and returns how long the caller has to wait until the token is available.
+// The tokens may become negative, so that waiting requests are served in the order they arrived.
+func (l *requestLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Concurrent callers might pass slightly older times, which must not take tokens away
+	if now.After(l.last) {
+		if !l.last.IsZero() {
+			l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		}
+
+		l.last = now
+	}
+
+	l.tokens--
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// cancel returns a token that was reserved, but not used.
+func (l *requestLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// wait blocks until a request may be sent or the context is done.
+func (l *requestLimiter) wait(ctx context.Context) error {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		l.allowed.Add(1)
+		return nil
+	}
+
+	l.limited.Add(1)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// rateLimitTransport delays the get-entries requests according to the shared limiter. Other requests, e.g. get-sth,
+// are sent right away.
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	limiter *requestLimiter
+}
+
+// RoundTrip waits for the limiter and sends the request.
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.limiter != nil && strings.HasSuffix(req.URL.Path, ct.GetEntriesPath) {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// RequestRateLimitStats returns the number of get-entries requests that were sent right away and that were delayed
+// by the global rate limit. Both are zero if the rate limit is disabled.
+func (w *Watcher) RequestRateLimitStats() (allowed, limited uint64) {
+	if w.requestLimiter == nil {
+		return 0, 0
+	}
+
+	return w.requestLimiter.allowed.Load(), w.requestLimiter.limited.Load()
+}
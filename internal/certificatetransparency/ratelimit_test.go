@@ -0,0 +1,48 @@
+package certificatetransparency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestRequestLimiter(t *testing.T) {
+	t.Parallel()
+
+	if newRequestLimiter(config.RequestRateLimitConfig{}) != nil {
+		t.Fatal("got limiter for a rate of 0")
+	}
+
+	limiter := newRequestLimiter(config.RequestRateLimitConfig{RequestsPerSecond: 10, Burst: 2})
+	now := time.Now()
+
+	// The burst is available right away, further requests wait for the next tokens in the order they arrived
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if delay := limiter.reserve(now); delay != want {
+			t.Errorf("request %d: got delay %s, want %s", i, delay, want)
+		}
+	}
+
+	// After a second, the bucket is full again, but never holds more than the burst
+	now = now.Add(time.Second)
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond} {
+		if delay := limiter.reserve(now); delay != want {
+			t.Errorf("request %d after refill: got delay %s, want %s", i, delay, want)
+		}
+	}
+
+	// A cancelled wait returns its token
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := limiter.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for a cancelled wait, want context.Canceled", err)
+	}
+
+	if allowed, limited := limiter.allowed.Load(), limiter.limited.Load(); allowed != 0 || limited != 1 {
+		t.Errorf("got %d allowed and %d limited requests, want 0 and 1", allowed, limited)
+	}
+}
@@ -0,0 +1,364 @@
+package certificatetransparency
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/logging"
+)
+
+// defaultRecoveryFlushInterval is the interval in which the indexes are saved to the recovery store if none is configured.
+const defaultRecoveryFlushInterval = 5 * time.Second
+
+// RecoveryStore persists the index of the last processed entry of each CT log, so that processing can be resumed
+// after a restart. The logs are identified by their url without scheme and trailing slash.
+// Implementations must be safe for concurrent use.
+type RecoveryStore interface {
+	// Load returns the saved index for the given log. ok is false if no index was saved for the log yet.
+	Load(logName string) (index uint64, ok bool, err error)
+	// Save stores the index for the given log.
+	Save(logName string, index uint64) error
+}
+
+// indexFileVersion is the current version of the format of the index file. Files of older versions are migrated
+// when they are loaded and written in the current format on the next save.
+//
+// Version 0 (no version field) is a plain JSON object mapping the log urls to their indexes.
+// Version 1 contains the version and the indexes per log url in the "logs" field.
+const indexFileVersion = 1
+
+// indexFile is the content of the index file.
+type indexFile struct {
+	Version int               `json:"version"`
+	Logs    map[string]uint64 `json:"logs"`
+}
+
+// gzipMagic are the first bytes of gzip compressed files. Compressed index files are detected by them, so that
+// switching the compression on or off doesn't break existing files.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// batchSaver is implemented by recovery stores that can save the indexes of multiple logs at once more efficiently
+// than one by one.
+type batchSaver interface {
+	SaveAll(indexes map[string]uint64) error
+}
+
+// FileRecoveryStore is a RecoveryStore that keeps the indexes of all logs in a single JSON file.
+// The file is replaced atomically on each save, so that a crash never leaves a partially written file behind.
+// The previous version of the file is kept with the suffix ".bak" and used if the file can't be read.
+type FileRecoveryStore struct {
+	path     string
+	compress bool
+	mu       sync.Mutex
+	indexes  map[string]uint64
+}
+
+// NewFileRecoveryStore creates a FileRecoveryStore for the given path. If the file exists, the saved indexes are
+// loaded, otherwise the file is created. If the file is missing or corrupt, but a backup of the previous save
+// exists, the indexes are loaded from the backup instead.
+func NewFileRecoveryStore(path string) (*FileRecoveryStore, error) {
+	return newFileRecoveryStore(path, false, logging.Default())
+}
+
+// NewCompressedFileRecoveryStore does the same as NewFileRecoveryStore, but the file is gzip compressed on each save.
+// Both load compressed and uncompressed files.
+func NewCompressedFileRecoveryStore(path string) (*FileRecoveryStore, error) {
+	return newFileRecoveryStore(path, true, logging.Default())
+}
+
+func newFileRecoveryStore(path string, compress bool, logger logging.Logger) (*FileRecoveryStore, error) {
+	s := &FileRecoveryStore{path: path, compress: compress, indexes: make(map[string]uint64)}
+
+	indexes, err := readIndexFile(path, logger)
+	if err != nil {
+		backupIndexes, backupErr := readIndexFile(s.backupPath(), logger)
+		if backupErr != nil {
+			if errors.Is(err, os.ErrNotExist) && errors.Is(backupErr, os.ErrNotExist) {
+				logger.Info("CT index file does not exist, creating it", "path", path)
+
+				return s, s.write()
+			}
+
+			return nil, err
+		}
+
+		logger.Warn("Could not load CT index file, using backup of the previous save instead", "error", err)
+		indexes = backupIndexes
+	}
+
+	s.indexes = indexes
+	logger.Info("Loaded saved CT indexes", "path", path)
+
+	return s, nil
+}
+
+// readIndexFile reads and parses the index file at the given path. Files of older versions are migrated and gzip
+// compressed files are decompressed.
+func readIndexFile(path string, logger logging.Logger) (map[string]uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CT index file: %w", err)
+	}
+
+	if bytes.HasPrefix(data, gzipMagic) {
+		if data, err = gunzip(data); err != nil {
+			return nil, fmt.Errorf("failed to decompress CT index file '%s': %w", path, err)
+		}
+	}
+
+	var file indexFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse CT index file '%s': %w", path, err)
+	}
+
+	switch file.Version {
+	case 0:
+		logger.Info("Migrating CT index file", "path", path, "version", indexFileVersion)
+
+		file.Logs = make(map[string]uint64)
+		if err = json.Unmarshal(data, &file.Logs); err != nil {
+			return nil, fmt.Errorf("failed to parse CT index file '%s': %w", path, err)
+		}
+	case indexFileVersion:
+		if file.Logs == nil {
+			file.Logs = make(map[string]uint64)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported version %d of CT index file '%s'", file.Version, path)
+	}
+
+	return file.Logs, nil
+}
+
+// gunzip returns the decompressed gzip data.
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// backupPath returns the path of the backup of the previous save.
+func (s *FileRecoveryStore) backupPath() string {
+	return s.path + ".bak"
+}
+
+// Load returns the saved index for the given log.
+func (s *FileRecoveryStore) Load(logName string) (uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index, ok := s.indexes[logName]
+
+	return index, ok, nil
+}
+
+// Save stores the index for the given log and writes the file.
+func (s *FileRecoveryStore) Save(logName string, index uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.indexes[logName] = index
+
+	return s.write()
+}
+
+// SaveAll stores the indexes of multiple logs and writes the file only once.
+func (s *FileRecoveryStore) SaveAll(indexes map[string]uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	maps.Copy(s.indexes, indexes)
+
+	return s.write()
+}
+
+// write writes the indexes to a temporary file in the same directory first, syncs it and then moves it to the actual
+// path. This prevents the last good index file from being clobbered if the program is killed or the machine loses
+// power in-between the write operation. The previous file is kept as backup. The caller must hold the lock.
+func (s *FileRecoveryStore) write() error {
+	data, err := json.MarshalIndent(indexFile{Version: indexFileVersion, Logs: s.indexes}, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if s.compress {
+		var buf bytes.Buffer
+
+		writer := gzip.NewWriter(&buf)
+		_, writeErr := writer.Write(data)
+		if err = errors.Join(writeErr, writer.Close()); err != nil {
+			return fmt.Errorf("could not compress CT index: %w", err)
+		}
+
+		data = buf.Bytes()
+	}
+
+	tempPath := s.path + ".tmp"
+
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not save CT index to temporary file: %w", err)
+	}
+
+	_, writeErr := file.Write(data)
+	syncErr := file.Sync()
+	closeErr := file.Close()
+
+	if err = errors.Join(writeErr, syncErr, closeErr); err != nil {
+		return fmt.Errorf("error writing CT index temp file: %w", err)
+	}
+
+	// If the machine crashes between both renames, the backup is loaded on the next start
+	if err = os.Rename(s.path, s.backupPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error backing up CT index file: %w", err)
+	}
+
+	if err = os.Rename(tempPath, s.path); err != nil {
+		return fmt.Errorf("error renaming CT index temp file: %w", err)
+	}
+
+	syncDir(filepath.Dir(s.path))
+
+	return nil
+}
+
+// syncDir syncs the directory, so that renames within it survive a crash. Errors are ignored, since not all
+// platforms support syncing directories.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer dir.Close()
+
+	_ = dir.Sync()
+}
+
+// SetRecoveryStore sets the store used to resume processing after a restart and enables recovery.
+// It must be called before the watcher is started.
+func (w *Watcher) SetRecoveryStore(store RecoveryStore) {
+	w.recoveryStore = store
+}
+
+// loadIndex returns the saved index of the given log. ok is false if there is no saved index for the log.
+func (w *Watcher) loadIndex(url string) (uint64, bool) {
+	index, ok, err := w.recoveryStore.Load(url)
+	if err != nil {
+		w.logger.Error("Could not load CT index", "url", url, "error", err)
+		sendError(w.errChan, fmt.Errorf("failed to load CT index for '%s': %w", url, err))
+
+		return 0, false
+	}
+
+	return index, ok
+}
+
+// SaveIndexes saves the current indexes of all logs to the recovery store, e.g. after the consumer acknowledged the
+// entries that were still buffered when the watcher stopped. It is a no-op if recovery is disabled.
+func (w *Watcher) SaveIndexes() {
+	if w.recoveryStore == nil {
+		return
+	}
+
+	w.saveIndexes(make(CTCertIndex))
+}
+
+// saveIndexesAtInterval saves the indexes of all logs that changed since the last save to the recovery store,
+// until the context is cancelled. The indexes are saved a last time before returning. If saveEvery is set, they are
+// also saved as soon as that many entries were consumed, whichever comes first.
+func (w *Watcher) saveIndexesAtInterval(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRecoveryFlushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	saved := make(CTCertIndex)
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.saveNow:
+			// The interval starts over, so that the next save isn't due right after this one
+			ticker.Reset(interval)
+		case <-ctx.Done():
+			w.saveIndexes(saved)
+			return
+		}
+
+		w.consumed.Store(0)
+		w.saveIndexes(saved)
+	}
+}
+
+// countConsumed counts an entry whose index was handed to the recovery position, i.e. which was passed on, filtered
+// or acknowledged. Once saveEvery entries were counted since the last save, the indexes are saved right away.
+func (w *Watcher) countConsumed() {
+	if w.saveEvery == 0 || w.consumed.Add(1) < w.saveEvery {
+		return
+	}
+
+	select {
+	case w.saveNow <- struct{}{}:
+	default:
+		// A save is pending already
+	}
+}
+
+// saveIndexes saves the indexes that differ from the given previously saved ones and updates them accordingly.
+func (w *Watcher) saveIndexes(saved CTCertIndex) {
+	indexes := metrics.GetAllCTIndexes()
+
+	// Logs whose worker didn't start yet have no position, so they are not saved
+	changed := maps.Clone(indexes)
+	maps.DeleteFunc(changed, func(url string, index uint64) bool {
+		previous, ok := saved[url]
+		return index == 0 || (ok && previous == index)
+	})
+
+	if store, ok := w.recoveryStore.(batchSaver); ok {
+		if len(changed) == 0 {
+			return
+		}
+
+		if err := store.SaveAll(changed); err != nil {
+			w.logger.Error("Could not save CT indexes", "error", err)
+			sendError(w.errChan, fmt.Errorf("failed to save CT indexes: %w", err))
+
+			return
+		}
+
+		maps.Copy(saved, changed)
+	} else {
+		for url, index := range changed {
+			if err := w.recoveryStore.Save(url, index); err != nil {
+				w.logger.Error("Could not save CT index", "url", url, "error", err)
+				sendError(w.errChan, fmt.Errorf("failed to save CT index for '%s': %w", url, err))
+
+				continue
+			}
+
+			saved[url] = index
+		}
+	}
+
+	maps.DeleteFunc(saved, func(url string, _ uint64) bool {
+		_, ok := indexes[url]
+		return !ok
+	})
+}
@@ -0,0 +1,350 @@
+package certificatetransparency
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestFileRecoveryStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ct_index.json")
+
+	store, err := NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := store.Load("ct.example.com/log"); ok {
+		t.Error("got index for unknown log")
+	}
+
+	if err = store.Save("ct.example.com/log", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	// The indexes are loaded from the file by a new store
+	store, err = NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, ok, _ := store.Load("ct.example.com/log"); !ok || index != 42 {
+		t.Errorf("got index %d, %t, want 42, true", index, ok)
+	}
+}
+
+func TestFileRecoveryStoreFallsBackToBackup(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ct_index.json")
+
+	store, err := NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Save("ct.example.com/log", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Save("ct.example.com/log", 43); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a file truncated by a crash
+	if err = os.WriteFile(path, []byte(`{"ct.exam`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, ok, _ := store.Load("ct.example.com/log"); !ok || index != 42 {
+		t.Errorf("got index %d, %t, want 42 from the backup", index, ok)
+	}
+
+	// Without a valid backup, a corrupt file is an error
+	if err = os.WriteFile(path+".bak", []byte(`{`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewFileRecoveryStore(path); err == nil {
+		t.Error("got no error for corrupt file and backup")
+	}
+}
+
+func TestFileRecoveryStoreCompression(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ct_index.json")
+
+	store, err := NewCompressedFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = store.Save("ct.example.com/log", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Fatalf("index file is not compressed: %q", data)
+	}
+
+	// An uncompressed store loads the compressed file and saves it uncompressed
+	plainStore, err := NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, ok, _ := plainStore.Load("ct.example.com/log"); !ok || index != 42 {
+		t.Errorf("got index %d, %t from compressed file, want 42, true", index, ok)
+	}
+
+	if err = plainStore.Save("ct.example.com/log", 43); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err = os.ReadFile(path); err != nil || bytes.HasPrefix(data, gzipMagic) {
+		t.Fatalf("index file is still compressed (error %v)", err)
+	}
+
+	// The compressed store loads the uncompressed file as well
+	store, err = NewCompressedFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, ok, _ := store.Load("ct.example.com/log"); !ok || index != 43 {
+		t.Errorf("got index %d, %t from uncompressed file, want 43, true", index, ok)
+	}
+}
+
+func TestFileRecoveryStoreMigratesLegacyFormat(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ct_index.json")
+	if err := os.WriteFile(path, []byte(`{"ct.example.com/log": 42}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileRecoveryStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index, ok, _ := store.Load("ct.example.com/log"); !ok || index != 42 {
+		t.Errorf("got index %d, %t, want 42, true", index, ok)
+	}
+
+	if err = store.Save("ct.example.com/other", 7); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := readIndexFile(path, logging.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(indexes) != 2 || indexes["ct.example.com/log"] != 42 || indexes["ct.example.com/other"] != 7 {
+		t.Errorf("got %v after migration", indexes)
+	}
+
+	if err = os.WriteFile(path, []byte(`{"version": 99, "logs": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = readIndexFile(path, logging.Nop()); err == nil {
+		t.Error("got no error for unsupported version")
+	}
+}
+
+func TestStopSavesConsumedIndex(t *testing.T) {
+	// The watcher reads its options from the global config
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	leafInput := testLeafInput(t)
+
+	// The log is large enough that the watcher is still fetching when it is stopped
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+				TreeSize:          1000000,
+				SHA256RootHash:    make([]byte, 32),
+				TreeHeadSignature: []byte{4, 3, 0, 0},
+			})
+		case "/ct/v1/get-entries":
+			start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+			end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+
+			var resp ct.GetEntriesResponse
+			for range min(end-start+1, 10) {
+				resp.Entries = append(resp.Entries, ct.LeafEntry{LeafInput: leafInput, ExtraData: []byte{0, 0, 0}})
+			}
+
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	indexFile := filepath.Join(t.TempDir(), "ct_index.json")
+
+	conf := config.Config{}
+	conf.ApplyDefaults()
+	conf.General.DisableDefaultLogs = true
+	conf.General.StartPosition = "tail"
+	// A single fetcher and worker pass the entries on in order
+	conf.General.ScannerOptions.ParallelFetch = 1
+	conf.General.AdditionalLogs = []config.LogConfig{{
+		Operator:   "Test",
+		URL:        server.URL,
+		LogOptions: config.LogOptions{WorkerCount: 1, BatchSize: 10},
+	}}
+	conf.General.Recovery.Enabled = true
+	conf.General.Recovery.CTIndexFile = indexFile
+	config.AppConfig = conf
+
+	certChan := make(chan models.Entry)
+	w := NewWatcher(certChan)
+	w.SetLogger(logging.Nop())
+	w.SetHTTPClient(server.Client())
+
+	startErr := make(chan error, 1)
+	go func() { startErr <- w.Start() }()
+
+	// The watcher is stopped mid-stream, but the entries it already fetched are still passed on until it is done
+	var consumed int
+	var lastIndex uint64
+	for entry := range certChan {
+		consumed++
+		lastIndex = entry.Data.CertIndex
+
+		if consumed == 25 {
+			go w.Stop()
+		}
+	}
+
+	// The index was saved before the channel was closed, so the consumer can rely on it as soon as it sees the end
+	indexes, err := readIndexFile(indexFile, logging.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index := indexes[normalizeCtlogURL(server.URL)]; index != lastIndex {
+		t.Errorf("got saved index %d, want %d of the last consumed entry (consumed %d entries)", index, lastIndex, consumed)
+	}
+
+	if err = <-startErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateIndexFile(t *testing.T) {
+	// The watcher reads its options from the global config
+	previous := config.AppConfig
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+			TreeSize:          1234,
+			SHA256RootHash:    make([]byte, 32),
+			TreeHeadSignature: []byte{4, 3, 0, 0},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	conf := config.Config{}
+	conf.ApplyDefaults()
+	conf.General.DisableDefaultLogs = true
+	conf.General.AdditionalLogs = []config.LogConfig{{Operator: "Test", URL: server.URL}}
+	config.AppConfig = conf
+
+	// The watcher is used as by the --create-index-file flag, without being started
+	indexFile := filepath.Join(t.TempDir(), "ct_index.json")
+	if err := NewWatcher(nil).CreateIndexFile(indexFile); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := readIndexFile(indexFile, logging.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index := indexes[normalizeCtlogURL(server.URL)]; index != 1234 {
+		t.Errorf("got index %d, want 1234", index)
+	}
+}
+
+func TestSaveEveryN(t *testing.T) {
+	t.Parallel()
+
+	const url = "ct.example.com/save-every-n"
+
+	indexFile := filepath.Join(t.TempDir(), "ct_index.json")
+	store, err := newFileRecoveryStore(indexFile, false, logging.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &Watcher{recoveryStore: store, logger: logging.Nop(), saveEvery: 3, saveNow: make(chan struct{}, 1)}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		// The interval is too long to be reached during the test
+		w.saveIndexesAtInterval(ctx, time.Hour)
+		close(done)
+	}()
+
+	savedIndex := func() uint64 {
+		indexes, err := readIndexFile(indexFile, logging.Nop())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return indexes[url]
+	}
+
+	metrics.SetCTIndex(url, 10)
+	w.countConsumed()
+	w.countConsumed()
+
+	time.Sleep(50 * time.Millisecond)
+	if index := savedIndex(); index != 0 {
+		t.Fatalf("got saved index %d after 2 consumed entries, want no save yet", index)
+	}
+
+	w.countConsumed()
+
+	for deadline := time.Now().Add(5 * time.Second); savedIndex() != 10; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("index was not saved after 3 consumed entries")
+		}
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,132 @@
+package certificatetransparency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// treeHeadClient wraps the client of a CT log, so that the scanner copes with logs that serve get-sth from a CDN.
+// It also limits how often the STH is fetched: STHs younger than the polling interval are served from a cache.
+// Such logs occasionally return a stale tree size or serve get-entries from a backend that lags behind the STH,
+// which rejects the newest indices as out of range.
+// The client keeps the requested ranges within the last known good tree size. If the log rejects a range, it
+// re-fetches the STH and waits for the log to catch up instead of failing, since the scanner would otherwise retry
+// the request right away.
+type treeHeadClient struct {
+	*client.LogClient
+	progress *logProgress
+	// wait is the backoff between the STH re-fetches while the log can't serve the requested range yet.
+	wait *backoff
+	// head is the last known good tree size. Zero until the first STH is fetched.
+	head atomic.Uint64
+	// pollInterval is the configured minimum time between two get-sth requests.
+	pollInterval time.Duration
+
+	sthMu sync.Mutex
+	// sth is the last fetched STH. Nil until the first STH is fetched.
+	sth        *ct.SignedTreeHead
+	sthFetched time.Time
+}
+
+func newTreeHeadClient(logClient *client.LogClient, progress *logProgress, pollInterval time.Duration) *treeHeadClient {
+	return &treeHeadClient{
+		LogClient:    logClient,
+		progress:     progress,
+		wait:         newBackoff(config.RetryConfig{MaxDelay: 30 * time.Second}),
+		pollInterval: pollInterval,
+	}
+}
+
+// GetSTH returns the STH of the log. It is only fetched if the last one is older than the polling interval.
+// The tree size of a fetched STH becomes the new head if it is bigger than the known one, so that a stale STH
+// doesn't shrink the ranges that are requested.
+func (c *treeHeadClient) GetSTH(ctx context.Context) (*ct.SignedTreeHead, error) {
+	c.sthMu.Lock()
+	defer c.sthMu.Unlock()
+
+	if c.sth != nil && time.Since(c.sthFetched) < c.progress.sthPollInterval(c.pollInterval) {
+		return c.sth, nil
+	}
+
+	sth, err := c.LogClient.GetSTH(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.sth, c.sthFetched = sth, time.Now()
+
+	for {
+		head := c.head.Load()
+		if sth.TreeSize <= head || c.head.CompareAndSwap(head, sth.TreeSize) {
+			return sth, nil
+		}
+	}
+}
+
+// GetRawEntries fetches the entries from start to end, both inclusive. The end is clamped to the head.
+// Ranges that start beyond the head or that the log rejects as out of range are held back until a re-fetched STH
+// shows that the log caught up. Each clamped or held back request is counted in the progress of the worker.
+func (c *treeHeadClient) GetRawEntries(ctx context.Context, start, end int64) (*ct.GetEntriesResponse, error) {
+	if c.head.Load() == 0 {
+		if _, err := c.GetSTH(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	clamped := false
+	clamp := func() {
+		if !clamped {
+			clamped = true
+			c.progress.clamped.Add(1)
+		}
+	}
+
+	for {
+		if head := int64(c.head.Load()); start < head {
+			if end >= head {
+				end = head - 1
+				clamp()
+			}
+
+			// Some logs answer with an empty list instead of an error, which would make the scanner spin as well
+			resp, err := c.LogClient.GetRawEntries(ctx, start, end)
+			if err == nil && len(resp.Entries) > 0 || err != nil && !isOutOfRange(err) {
+				c.wait.success()
+
+				return resp, err
+			}
+		}
+
+		clamp()
+
+		_, delay := c.wait.failure()
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		sth, err := c.LogClient.GetSTH(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// The log evidently can't serve entries beyond this tree size yet, even if an earlier STH was bigger
+		c.head.Store(sth.TreeSize)
+	}
+}
+
+// isOutOfRange returns true if the log rejected a get-entries request because the range lies beyond its tree.
+func isOutOfRange(err error) bool {
+	var rspErr jsonclient.RspError
+
+	return errors.As(err, &rspErr) &&
+		(rspErr.StatusCode == http.StatusBadRequest || rspErr.StatusCode == http.StatusRequestedRangeNotSatisfiable)
+}
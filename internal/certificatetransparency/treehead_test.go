@@ -0,0 +1,102 @@
+package certificatetransparency
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// newCDNTestLog starts a CT log whose get-sth reports sthSize entries, while get-entries only serves the first
+// served entries and rejects ranges beyond them as out of range. If caughtUp is set, the log serves all entries
+// from the second get-sth request on.
+func newCDNTestLog(tb testing.TB, sthSize, served int64, caughtUp bool) *treeHeadClient {
+	tb.Helper()
+
+	var sthRequests, servedEntries atomic.Int64
+	servedEntries.Store(served)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			if sthRequests.Add(1) > 1 && caughtUp {
+				servedEntries.Store(sthSize)
+			}
+
+			_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+				TreeSize:          uint64(sthSize),
+				SHA256RootHash:    make([]byte, 32),
+				TreeHeadSignature: []byte{4, 3, 0, 0},
+			})
+		case "/ct/v1/get-entries":
+			start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+			end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+
+			served := servedEntries.Load()
+			if start >= served {
+				http.Error(w, "index out of range", http.StatusBadRequest)
+				return
+			}
+
+			var resp ct.GetEntriesResponse
+			for range min(end, served-1) - start + 1 {
+				resp.Entries = append(resp.Entries, ct.LeafEntry{LeafInput: []byte{0}})
+			}
+
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	tb.Cleanup(server.Close)
+
+	logClient, err := client.New(server.URL, server.Client(), jsonclient.Options{})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	c := newTreeHeadClient(logClient, &logProgress{}, 0)
+	c.wait = newBackoff(config.RetryConfig{InitialDelay: time.Millisecond})
+
+	return c
+}
+
+func TestTreeHeadClientClampsToHead(t *testing.T) {
+	t.Parallel()
+
+	c := newCDNTestLog(t, 10, 10, false)
+
+	resp, err := c.GetRawEntries(t.Context(), 5, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Entries) != 5 || c.progress.clamped.Load() != 1 {
+		t.Errorf("got %d entries and %d clamped requests, want 5 and 1", len(resp.Entries), c.progress.clamped.Load())
+	}
+
+	if _, err := c.GetRawEntries(t.Context(), 0, 4); err != nil || c.progress.clamped.Load() != 1 {
+		t.Errorf("got error %v and %d clamped requests for a range within the head, want none and 1", err, c.progress.clamped.Load())
+	}
+}
+
+func TestTreeHeadClientWaitsForLaggingLog(t *testing.T) {
+	t.Parallel()
+
+	c := newCDNTestLog(t, 12, 8, true)
+
+	resp, err := c.GetRawEntries(t.Context(), 8, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resp.Entries) != 4 || c.progress.clamped.Load() != 1 {
+		t.Errorf("got %d entries and %d clamped requests, want 4 and 1", len(resp.Entries), c.progress.clamped.Load())
+	}
+}
@@ -5,22 +5,45 @@ package certstream
 // It also handles signals for graceful shutdown of the server.
 
 import (
+	"context"
+	"fmt"
 	"github.com/letrics/certstream-server-go/pkg/config"
 	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/internal/detection"
+	"github.com/letrics/certstream-server-go/internal/grpcserver"
+	"github.com/letrics/certstream-server-go/internal/logging"
 	"github.com/letrics/certstream-server-go/internal/metrics"
+	"github.com/letrics/certstream-server-go/internal/sink"
+	"github.com/letrics/certstream-server-go/internal/tracing"
 	"github.com/letrics/certstream-server-go/internal/web"
+	"github.com/letrics/certstream-server-go/pkg/models"
 )
 
 type Certstream struct {
 	webserver     *web.WebServer
 	metricsServer *web.WebServer
+	grpcServer    *grpcserver.Server
 	watcher       *certificatetransparency.Watcher
 	config        config.Config
+	// configPath is the path of the config file, which is read again on SIGHUP. Empty if the config wasn't read from a file.
+	configPath string
+	// logLevel is the minimum level of the logs of the watcher, which can be changed by reloading the config.
+	logLevel *slog.LevelVar
+	// shutdownTracing flushes the remaining spans. Nil if tracing is disabled.
+	shutdownTracing func(context.Context) error
+	// startedAt is the time Start was called, used for the uptime in the stats.
+	startedAt time.Time
+	// stopOnce makes sure that the server is stopped only once, even if Start returns while the signal handler stops it.
+	stopOnce sync.Once
 }
 
 func NewRawCertstream(config config.Config) *Certstream {
@@ -39,12 +62,79 @@ func NewCertstreamServer(config config.Config) (*Certstream, error) {
 	webserver := web.NewWebsocketServer(config.Webserver.ListenAddr, config.Webserver.ListenPort, config.Webserver.CertPath, config.Webserver.CertKeyPath)
 	cs.webserver = webserver
 
+	// The watcher feeds the broadcast manager, which is initialized together with the webserver
+	cs.watcher = certificatetransparency.NewWatcher(web.ClientHandler.Broadcast)
+	cs.logLevel = new(slog.LevelVar)
+	cs.logLevel.Set(logging.ParseLevel(config.General.LogLevel, config.General.Quiet))
+	cs.watcher.SetLogger(logging.NewWithLevel(cs.logLevel))
+	setTimeFormat(config.General.TimeFormat)
+	cs.watcher.SetSampleRate(config.General.SampleRate)
+	cs.watcher.SetLookalikeDetector(detection.NewLookalike(config.Detection.Lookalike))
+	cs.watcher.SetFirstSeenDetector(detection.NewFirstSeen(config.Detection.FirstSeen))
+
+	webserver.RegisterHealth(cs.liveness, cs.readiness)
+
 	// Setup metrics server
 	cs.setupMetrics(webserver)
 
+	if config.General.Tracing.Enabled {
+		shutdown, err := tracing.Init(context.Background(), config.General.Tracing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize tracing: %w", err)
+		}
+
+		cs.shutdownTracing = shutdown
+	}
+
+	if err := cs.setupOutputs(); err != nil {
+		return nil, err
+	}
+
 	return &cs, nil
 }
 
+// setupOutputs creates the additional outputs configured in the config and registers them at the broadcast manager.
+func (cs *Certstream) setupOutputs() error {
+	if cs.config.Output.Kafka != nil {
+		kafkaSink, err := sink.NewKafkaSink(*cs.config.Output.Kafka)
+		if err != nil {
+			return fmt.Errorf("failed to create kafka output: %w", err)
+		}
+
+		web.ClientHandler.RegisterSink(kafkaSink)
+	}
+
+	if cs.config.GRPC.Enabled {
+		grpcServer, err := grpcserver.NewServer(cs.config.GRPC.ListenAddr, cs.config.GRPC.ListenPort, cs.config.GRPC.CertPath, cs.config.GRPC.CertKeyPath, cs.config.General.BufferSizes.Websocket)
+		if err != nil {
+			return fmt.Errorf("failed to create grpc server: %w", err)
+		}
+
+		cs.grpcServer = grpcServer
+		web.ClientHandler.RegisterSink(grpcServer)
+	}
+
+	if cs.config.Output.Webhook != nil {
+		webhookSink, err := sink.NewWebhookSink(*cs.config.Output.Webhook)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook output: %w", err)
+		}
+
+		web.ClientHandler.RegisterSink(webhookSink)
+	}
+
+	if cs.config.Output.File != nil {
+		fileSink, err := sink.NewFileSink(*cs.config.Output.File)
+		if err != nil {
+			return fmt.Errorf("failed to create file output: %w", err)
+		}
+
+		web.ClientHandler.RegisterSink(fileSink)
+	}
+
+	return nil
+}
+
 // NewCertstreamFromConfigFile creates a new Certstream server from a config file.
 func NewCertstreamFromConfigFile(configPath string) (*Certstream, error) {
 	conf, err := config.ReadConfig(configPath)
@@ -52,40 +142,99 @@ func NewCertstreamFromConfigFile(configPath string) (*Certstream, error) {
 		return nil, err
 	}
 
-	return NewCertstreamServer(conf)
+	cs, err := NewCertstreamServer(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	cs.configPath = configPath
+
+	return cs, nil
 }
 
-// setupMetrics configures the webserver to handle prometheus metrics according to the config.
+// setupMetrics configures the webserver to handle prometheus metrics and the stats and clients endpoints according to
+// the config. The stats and clients endpoints are also served if prometheus is disabled.
 func (cs *Certstream) setupMetrics(webserver *web.WebServer) {
+	if !cs.config.Prometheus.Enabled && cs.config.Prometheus.StatsURL == "" && cs.config.Prometheus.ClientsURL == "" {
+		return
+	}
+
+	// If the interface is either unconfigured or same as webserver config, use existing webserver
+	server := webserver
+	if (cs.config.Prometheus.ListenAddr == "" || cs.config.Prometheus.ListenAddr == cs.config.Webserver.ListenAddr) &&
+		(cs.config.Prometheus.ListenPort == 0 || cs.config.Prometheus.ListenPort == cs.config.Webserver.ListenPort) {
+		log.Println("Starting metrics server on same interface as webserver")
+	} else {
+		log.Println("Starting metrics server on new interface")
+		cs.metricsServer = web.NewMetricsServer(cs.config.Prometheus.ListenAddr, cs.config.Prometheus.ListenPort, cs.config.Prometheus.CertPath, cs.config.Prometheus.CertKeyPath)
+		server = cs.metricsServer
+	}
+
 	if cs.config.Prometheus.Enabled {
-		// If prometheus is enabled, and interface is either unconfigured or same as webserver config, use existing webserver
-		if (cs.config.Prometheus.ListenAddr == "" || cs.config.Prometheus.ListenAddr == cs.config.Webserver.ListenAddr) &&
-			(cs.config.Prometheus.ListenPort == 0 || cs.config.Prometheus.ListenPort == cs.config.Webserver.ListenPort) {
-			log.Println("Starting prometheus server on same interface as webserver")
-			webserver.RegisterPrometheus(cs.config.Prometheus.MetricsURL, metrics.WritePrometheus)
-		} else {
-			log.Println("Starting prometheus server on new interface")
-			cs.metricsServer = web.NewMetricsServer(cs.config.Prometheus.ListenAddr, cs.config.Prometheus.ListenPort, cs.config.Prometheus.CertPath, cs.config.Prometheus.CertKeyPath)
-			cs.metricsServer.RegisterPrometheus(cs.config.Prometheus.MetricsURL, metrics.WritePrometheus)
-		}
+		metrics.SetWatcher(cs.watcher)
+		server.RegisterPrometheus(cs.config.Prometheus.MetricsURL, metrics.WritePrometheus)
+	}
+
+	if cs.config.Prometheus.StatsURL != "" {
+		server.RegisterStats(cs.config.Prometheus.StatsURL, cs.stats)
+	}
+
+	if cs.config.Prometheus.ClientsURL != "" {
+		cs.setupClients(server, server == webserver)
+	}
+}
+
+// setupClients registers the clients endpoint. Since it allows disconnecting clients, it is only served if it is
+// protected by the API keys of the webserver or by the IP whitelist of the interface it is served on.
+func (cs *Certstream) setupClients(server *web.WebServer, sharedInterface bool) {
+	var keys []config.APIKeyConfig
+	if auth := cs.config.Webserver.Auth; auth != nil {
+		keys = auth.Keys
+	}
+
+	whitelist := cs.config.Prometheus.Whitelist
+	if sharedInterface {
+		whitelist = cs.config.Webserver.Whitelist
+	}
+
+	if len(keys) == 0 && len(whitelist) == 0 {
+		log.Printf("Not serving the clients endpoint at %s, since neither webserver.auth nor a whitelist is configured\n",
+			cs.config.Prometheus.ClientsURL)
+
+		return
+	}
+
+	// The routes of the webserver already require the API keys
+	if sharedInterface {
+		keys = nil
 	}
+
+	server.RegisterClients(cs.config.Prometheus.ClientsURL, keys)
 }
 
 // Start starts the webserver and the watcher.
-// This is a blocking function that will run until the server is stopped.
-func (cs *Certstream) Start() {
+// This is a blocking function that will run until the server is stopped. It returns an error if the watcher can't be
+// started, e.g. because the CT log list is unavailable.
+func (cs *Certstream) Start() error {
 	log.Printf("Starting certstream-server-go v%s\n", config.Version)
+	cs.startedAt = time.Now()
 
 	// handle signals in a separate goroutine
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	go signalHandler(signals, cs.Stop)
 
-	// If there is no watcher initialized, create a new one
+	// If there is no watcher initialized, create a new one that feeds the broadcast manager
 	if cs.watcher == nil {
-		cs.watcher = &certificatetransparency.Watcher{}
+		cs.watcher = certificatetransparency.NewWatcher(web.ClientHandler.Broadcast)
+		cs.watcher.SetLogger(logging.New(cs.config.General.LogLevel, cs.config.General.Quiet))
 	}
 
+	// Reload the config and the CT log list on SIGHUP
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	go reloadSignalHandler(reloadSignals, cs.reload)
+
 	// Start webserver and metrics server
 	if cs.webserver == nil {
 		log.Fatalln("Webserver not initialized! Exiting...")
@@ -97,14 +246,33 @@ func (cs *Certstream) Start() {
 		go cs.metricsServer.Start()
 	}
 
-	// Start the watcher - this is a blocking function
-	cs.watcher.Start()
+	if cs.grpcServer != nil {
+		go cs.grpcServer.Start()
+	}
+
+	// Start the watcher - this is a blocking function that returns once the watcher was stopped
+	err := cs.watcher.Start()
+
+	// If the signal handler is stopping the server, this waits until it is done, so that the process doesn't exit
+	// before the outputs were closed
+	cs.Stop()
+
+	return err
 }
 
-// Stop stops the watcher and the webserver.
+// Stop stops the server. The watcher is stopped first, which passes the remaining entries to the broadcast manager,
+// saves the recovery indexes and closes the broadcast channel. Once the broadcast manager passed the remaining entries
+// on, the webservers are stopped, the outputs are closed and the dedup filter is saved.
+// Concurrent and repeated calls block until the first one is done.
 func (cs *Certstream) Stop() {
+	cs.stopOnce.Do(cs.stop)
+}
+
+// stop stops the watcher, the webservers and the outputs in this order.
+func (cs *Certstream) stop() {
 	if cs.watcher != nil {
 		cs.watcher.Stop()
+		web.ClientHandler.WaitBroadcast()
 	}
 
 	if cs.webserver != nil {
@@ -114,6 +282,101 @@ func (cs *Certstream) Stop() {
 	if cs.metricsServer != nil {
 		cs.metricsServer.Stop()
 	}
+
+	web.ClientHandler.CloseSinks()
+	web.ClientHandler.ClosePersistentDedup()
+
+	if cs.shutdownTracing != nil {
+		if err := cs.shutdownTracing(context.Background()); err != nil {
+			log.Printf("Error while shutting down tracing: %s\n", err)
+		}
+	}
+}
+
+// reload reads the config file again and applies the options that can be changed at runtime (see config.Config.Reload).
+// Then it re-fetches the CT log list and updates the workers of the watcher accordingly.
+// If the config file is invalid, the current config is kept.
+func (cs *Certstream) reload() {
+	var err error
+	if cs.configPath != "" {
+		if err = cs.reloadConfig(); err != nil {
+			log.Printf("Error while reloading config, keeping the current config: %s\n", err)
+		}
+	}
+
+	// The watcher reads the log filters from config.AppConfig, so they are only replaced by the watcher itself
+	if cs.configPath != "" && err == nil {
+		err = cs.watcher.ReloadLogFilters(cs.config)
+	} else {
+		err = cs.watcher.ReloadLogList()
+	}
+
+	if err != nil {
+		log.Println("Error while reloading CT log list: ", err)
+	}
+}
+
+// reloadConfig reads the config file and applies the options that can be changed at runtime.
+// The changes of all other options are logged and ignored.
+func (cs *Certstream) reloadConfig() error {
+	next, err := config.LoadConfig(cs.configPath)
+	if err != nil {
+		return err
+	}
+
+	reloaded, ignored := cs.config.Reload(next)
+	for _, field := range ignored {
+		log.Printf("Ignoring changed option '%s' - it can only be changed with a restart\n", field)
+	}
+
+	cs.logLevel.Set(logging.ParseLevel(reloaded.General.LogLevel, reloaded.General.Quiet))
+	setTimeFormat(reloaded.General.TimeFormat)
+	web.Reload(reloaded)
+
+	output := &cs.config.Output
+	reloadSink("kafka", &output.Kafka, reloaded.Output.Kafka, sink.NewKafkaSink)
+	reloadSink("webhook", &output.Webhook, reloaded.Output.Webhook, sink.NewWebhookSink)
+	reloadSink("file", &output.File, reloaded.Output.File, sink.NewFileSink)
+
+	// Only the reloaded options are written, since the other options are read concurrently
+	cs.config.General = reloaded.General
+	cs.config.Webserver.Limits = reloaded.Webserver.Limits
+
+	log.Println("Reloaded config")
+
+	return nil
+}
+
+// reloadSink replaces the sink of an output with a new one if its config changed. If the output was removed from the
+// config, the sink is closed. If the new sink can't be created, the current sink and config are kept.
+func reloadSink[C any, S web.Sink](name string, current **C, next *C, newSink func(C) (S, error)) {
+	if reflect.DeepEqual(*current, next) {
+		return
+	}
+
+	var s web.Sink
+	if next != nil {
+		created, err := newSink(*next)
+		if err != nil {
+			log.Printf("Error while reloading %s output, keeping the current output: %s\n", name, err)
+			return
+		}
+
+		s = created
+	}
+
+	web.ClientHandler.ReplaceSink(name, s)
+	*current = next
+}
+
+// setTimeFormat sets the format of the timestamps in the JSON encoding of the entries.
+func setTimeFormat(name string) {
+	format, err := models.ParseTimeFormat(name)
+	if err != nil {
+		log.Printf("Invalid time format: %v - defaulting to %s\n", err, format)
+	}
+
+	models.SetTimeFormat(format)
 }
 
 // CreateIndexFile creates the index file for the certificate transparency logs.
@@ -121,7 +384,8 @@ func (cs *Certstream) Stop() {
 func (cs *Certstream) CreateIndexFile() error {
 	// If there is no watcher initialized, create a new one
 	if cs.watcher == nil {
-		cs.watcher = &certificatetransparency.Watcher{}
+		cs.watcher = certificatetransparency.NewWatcher(nil)
+		cs.watcher.SetLogger(logging.New(cs.config.General.LogLevel, cs.config.General.Quiet))
 	}
 
 	return cs.watcher.CreateIndexFile(cs.config.General.Recovery.CTIndexFile)
@@ -136,3 +400,11 @@ func signalHandler(signals chan os.Signal, callback func()) {
 	callback()
 	os.Exit(0)
 }
+
+// reloadSignalHandler listens for SIGHUP signals and executes the callback function every time one is received.
+func reloadSignalHandler(signals chan os.Signal, callback func()) {
+	for sig := range signals {
+		log.Printf("Received signal %v. Reloading...\n", sig)
+		callback()
+	}
+}
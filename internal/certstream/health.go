@@ -0,0 +1,42 @@
+package certstream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/web"
+)
+
+// liveness returns an error if the broadcast manager stopped dispatching entries, so that the process is restarted.
+func (cs *Certstream) liveness() error {
+	if !web.ClientHandler.IsRunning() {
+		return errors.New("broadcast manager is not running")
+	}
+
+	return nil
+}
+
+// readiness returns an error if no request to any CT log succeeded within the configured staleness threshold,
+// e.g. because all logs are failing or the watcher didn't fetch anything yet.
+func (cs *Certstream) readiness() error {
+	if err := cs.liveness(); err != nil {
+		return err
+	}
+
+	if cs.watcher == nil {
+		return errors.New("watcher is not started")
+	}
+
+	lastFetch := cs.watcher.LastSuccessfulFetch()
+	if lastFetch.IsZero() {
+		return errors.New("no CT log was fetched successfully yet")
+	}
+
+	threshold := cs.config.Webserver.ReadinessThreshold
+	if since := time.Since(lastFetch); since > threshold {
+		return fmt.Errorf("no CT log was fetched successfully within %s (last success %s ago)", threshold, since.Round(time.Second))
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package certstream
+
+import (
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/internal/web"
+)
+
+// stats contains the data served by the stats endpoint. All counters cover the time since the server was started.
+type stats struct {
+	UptimeSeconds int64 `json:"uptime_seconds"`
+	// TotalEntries is the number of entries processed by all CT logs, i.e. ProcessedCerts + ProcessedPrecerts.
+	TotalEntries      int64 `json:"total_entries"`
+	ProcessedCerts    int64 `json:"processed_certs"`
+	ProcessedPrecerts int64 `json:"processed_precerts"`
+	// Duplicates is the number of certificates that were not broadcast, since they were already broadcast before.
+	Duplicates   uint64       `json:"duplicates"`
+	Dropped      droppedStats `json:"dropped"`
+	BytesFetched int64        `json:"bytes_fetched"`
+	Clients      clientStats  `json:"clients"`
+	// Logs contains the stats of each watched CT log, including the number of processed entries and the last index.
+	Logs []certificatetransparency.LogStats `json:"logs"`
+}
+
+// droppedStats contains the number of entries dropped because a client or an output couldn't keep up.
+type droppedStats struct {
+	Websocket uint64            `json:"websocket"`
+	Sinks     map[string]uint64 `json:"sinks"`
+}
+
+// clientStats contains the number of connected websocket clients per endpoint.
+type clientStats struct {
+	Total   int64 `json:"total"`
+	Full    int64 `json:"full"`
+	Lite    int64 `json:"lite"`
+	Domains int64 `json:"domains"`
+}
+
+// stats returns a snapshot of the current stats of the server. The counters are read from atomics, so that the
+// processing of the entries is not blocked.
+func (cs *Certstream) stats() any {
+	s := stats{
+		ProcessedCerts:    certificatetransparency.GetProcessedCerts(),
+		ProcessedPrecerts: certificatetransparency.GetProcessedPrecerts(),
+		Duplicates:        web.ClientHandler.GetDuplicateCerts(),
+		Dropped: droppedStats{
+			Websocket: web.ClientHandler.GetWebsocketDropped(),
+			Sinks:     web.ClientHandler.GetSinkDropped(),
+		},
+		BytesFetched: certificatetransparency.GetFetchedBytes(),
+		Clients: clientStats{
+			Full:    web.ClientHandler.ClientFullCount(),
+			Lite:    web.ClientHandler.ClientLiteCount(),
+			Domains: web.ClientHandler.ClientDomainsCount(),
+		},
+		Logs: cs.logStats(),
+	}
+
+	if !cs.startedAt.IsZero() {
+		s.UptimeSeconds = int64(time.Since(cs.startedAt).Seconds())
+	}
+
+	s.TotalEntries = s.ProcessedCerts + s.ProcessedPrecerts
+	s.Clients.Total = s.Clients.Full + s.Clients.Lite + s.Clients.Domains
+
+	return s
+}
+
+// logStats returns a snapshot of the stats of all watched CT logs.
+func (cs *Certstream) logStats() []certificatetransparency.LogStats {
+	if cs.watcher == nil {
+		return []certificatetransparency.LogStats{}
+	}
+
+	return cs.watcher.LogStats()
+}
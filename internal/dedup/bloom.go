@@ -0,0 +1,231 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Defaults of the Bloom filter, used for parameters that are not set.
+const (
+	DefaultBloomCapacity          = 1000000
+	DefaultBloomFalsePositiveRate = 0.000001
+)
+
+// bloomMagic identifies files written by Bloom.Save.
+var bloomMagic = [4]byte{'C', 'S', 'B', 'F'}
+
+const bloomFileVersion = 1
+
+// bloomHeader is the header of the files written by Bloom.Save. It is followed by the words of both generations.
+type bloomHeader struct {
+	Magic    [4]byte
+	Version  uint8
+	Capacity uint64
+	Bits     uint64
+	Hashes   uint64
+	Count    uint64
+}
+
+// Bloom is a rotating Bloom filter of recently seen keys with bounded memory usage. It consists of two generations:
+// new keys are added to the current generation and once it holds capacity keys, it replaces the previous generation
+// and a new one is started. A key is therefore remembered for at least capacity and at most twice as many other keys.
+// Unlike Cache, it can be saved to and loaded from a file, so that it survives restarts.
+// Being a Bloom filter, it may report keys as seen that never were, at about the configured false-positive rate.
+type Bloom struct {
+	mutex    sync.Mutex
+	capacity uint64
+	bits     uint64
+	hashes   uint64
+	current  []uint64
+	previous []uint64
+	count    uint64
+}
+
+// NewBloom creates an empty Bloom filter that remembers at least capacity keys at the given false-positive rate.
+// Parameters that are out of range are replaced by the defaults.
+func NewBloom(capacity int, falsePositiveRate float64) *Bloom {
+	if capacity <= 0 {
+		capacity = DefaultBloomCapacity
+	}
+
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = DefaultBloomFalsePositiveRate
+	}
+
+	// Both generations are checked, so each of them only gets half of the false-positive rate
+	n := float64(capacity)
+	bits := math.Ceil(-n * math.Log(falsePositiveRate/2) / (math.Ln2 * math.Ln2))
+	words := uint64(math.Ceil(bits / 64))
+	hashes := max(1, uint64(math.Round(float64(words*64)/n*math.Ln2)))
+
+	return &Bloom{
+		capacity: uint64(capacity),
+		bits:     words * 64,
+		hashes:   hashes,
+		current:  make([]uint64, words),
+		previous: make([]uint64, words),
+	}
+}
+
+// LoadBloom loads the Bloom filter saved at the given path. If the file doesn't exist yet, an empty filter is returned.
+// If the file can't be read or was saved with other parameters, an empty filter is returned along with the error.
+func LoadBloom(path string, capacity int, falsePositiveRate float64) (*Bloom, error) {
+	bloom := NewBloom(capacity, falsePositiveRate)
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return bloom, nil
+	} else if err != nil {
+		return bloom, fmt.Errorf("could not open dedup filter: %w", err)
+	}
+	defer file.Close()
+
+	if err = bloom.read(bufio.NewReader(file)); err != nil {
+		return NewBloom(capacity, falsePositiveRate), fmt.Errorf("could not load dedup filter from '%s': %w", path, err)
+	}
+
+	return bloom, nil
+}
+
+// read loads the state of the filter from r. The parameters saved in r must match the ones of the filter.
+func (b *Bloom) read(r io.Reader) error {
+	var header bloomHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return err
+	}
+
+	switch {
+	case header.Magic != bloomMagic:
+		return errors.New("not a dedup filter file")
+	case header.Version != bloomFileVersion:
+		return fmt.Errorf("unsupported file version %d", header.Version)
+	case header.Capacity != b.capacity || header.Bits != b.bits || header.Hashes != b.hashes:
+		return errors.New("the filter was saved with a different capacity or false-positive rate")
+	}
+
+	if err := binary.Read(r, binary.BigEndian, b.current); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, binary.BigEndian, b.previous); err != nil {
+		return err
+	}
+
+	b.count = header.Count
+
+	return nil
+}
+
+// Save writes the filter to the given path. The file is written to a temporary file first and then moved to the
+// actual path, so that the last saved filter is not clobbered if the program is killed in-between.
+func (b *Bloom) Save(path string) error {
+	tempPath := path + ".tmp"
+
+	file, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not save dedup filter to temporary file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+
+	// The lock is only held while the filter is written to the file, not while it is synced
+	b.mutex.Lock()
+	header := bloomHeader{
+		Magic:    bloomMagic,
+		Version:  bloomFileVersion,
+		Capacity: b.capacity,
+		Bits:     b.bits,
+		Hashes:   b.hashes,
+		Count:    b.count,
+	}
+	writeErr := errors.Join(
+		binary.Write(writer, binary.BigEndian, header),
+		binary.Write(writer, binary.BigEndian, b.current),
+		binary.Write(writer, binary.BigEndian, b.previous),
+	)
+	b.mutex.Unlock()
+
+	flushErr := writer.Flush()
+	syncErr := file.Sync()
+	closeErr := file.Close()
+
+	if err = errors.Join(writeErr, flushErr, syncErr, closeErr); err != nil {
+		return fmt.Errorf("error writing dedup filter temp file: %w", err)
+	}
+
+	if err = os.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("error renaming dedup filter temp file: %w", err)
+	}
+
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		_ = dir.Sync()
+		dir.Close()
+	}
+
+	return nil
+}
+
+// Seen returns true if the key was probably seen before. Otherwise, the key is recorded and false is returned.
+func (b *Bloom) Seen(key string) bool {
+	h1, h2 := bloomHash(key)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.contains(b.current, h1, h2) {
+		return true
+	}
+
+	// Keys of the previous generation are carried over, so that they are not forgotten on the next rotation
+	seen := b.contains(b.previous, h1, h2)
+
+	if b.count >= b.capacity {
+		b.rotate()
+	}
+
+	for i := range b.hashes {
+		bit := (h1 + i*h2) % b.bits
+		b.current[bit/64] |= 1 << (bit % 64)
+	}
+
+	b.count++
+
+	return seen
+}
+
+// contains returns true if all bits of the key are set in the given generation.
+func (b *Bloom) contains(generation []uint64, h1, h2 uint64) bool {
+	for i := range b.hashes {
+		bit := (h1 + i*h2) % b.bits
+		if generation[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rotate replaces the previous generation with the current one and starts a new, empty generation.
+func (b *Bloom) rotate() {
+	b.previous, b.current = b.current, b.previous
+	clear(b.current)
+	b.count = 0
+}
+
+// bloomHash returns two independent hashes of the key, which are combined to derive the bits of the key.
+func bloomHash(key string) (uint64, uint64) {
+	hash := fnv.New128a()
+	_, _ = hash.Write([]byte(key))
+	sum := hash.Sum(nil)
+
+	// The second hash must not be zero, otherwise all bits of the key would be the same
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
@@ -0,0 +1,81 @@
+package dedup
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestBloomRotation(t *testing.T) {
+	t.Parallel()
+
+	bloom := NewBloom(100, 0.0001)
+
+	if bloom.Seen("a") {
+		t.Fatal("first occurrence must not be reported as seen")
+	}
+
+	if !bloom.Seen("a") {
+		t.Fatal("second occurrence must be reported as seen")
+	}
+
+	// "a" is moved to the previous generation by the first rotation and forgotten by the second one
+	for i := range 200 {
+		bloom.Seen(strconv.Itoa(i))
+	}
+
+	if bloom.Seen("a") {
+		t.Fatal("key must be forgotten after two rotations")
+	}
+}
+
+func TestBloomSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ct_dedup.bloom")
+
+	bloom, err := LoadBloom(path, 1000, 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bloom.Seen("a")
+
+	if err = bloom.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadBloom(path, 1000, 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Seen("a") {
+		t.Error("key saved before the restart must be reported as seen")
+	}
+
+	// Different parameters would map the keys to other bits, so the saved filter is not used
+	if loaded, err = LoadBloom(path, 2000, 0.0001); err == nil || loaded.Seen("a") {
+		t.Error("filter saved with other parameters must be discarded with an error")
+	}
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	t.Parallel()
+
+	const capacity = 10000
+
+	bloom := NewBloom(capacity, 0.01)
+	falsePositives := 0
+
+	// Fill both generations, so that the false-positive rate applies to the filter as a whole
+	for i := range 2 * capacity {
+		if bloom.Seen("key-" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+
+	if rate := float64(falsePositives) / (2 * capacity); rate > 0.02 {
+		t.Errorf("false-positive rate = %v, want about 0.01", rate)
+	}
+}
@@ -0,0 +1,68 @@
+package dedup
+
+// The dedup package provides a fixed-capacity, time-bounded set of keys, which is used to suppress
+// certificates that were already seen recently, e.g. because they were logged to multiple CT logs.
+
+import (
+	"sync"
+	"time"
+)
+
+// slot is a single position in the ring buffer of the Cache.
+type slot struct {
+	key  string
+	seen time.Time
+}
+
+// Cache remembers keys for a limited time. Memory usage is bounded by the capacity: once the cache is full,
+// the oldest key is evicted, even if its TTL has not yet expired.
+type Cache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+	ring    []slot
+	next    int
+}
+
+// New creates a new Cache that holds at most capacity keys for the given ttl.
+func New(capacity int, ttl time.Duration) *Cache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &Cache{
+		ttl:     ttl,
+		entries: make(map[string]time.Time, capacity),
+		ring:    make([]slot, capacity),
+	}
+}
+
+// Seen returns true if the key was already seen within the TTL. Otherwise, the key is recorded and false is returned.
+func (c *Cache) Seen(key string, now time.Time) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if lastSeen, ok := c.entries[key]; ok && now.Sub(lastSeen) < c.ttl {
+		return true
+	}
+
+	// Evict the oldest slot. The map entry is only removed if it wasn't refreshed by a newer slot in the meantime.
+	oldest := c.ring[c.next]
+	if oldest.key != "" && c.entries[oldest.key].Equal(oldest.seen) {
+		delete(c.entries, oldest.key)
+	}
+
+	c.ring[c.next] = slot{key: key, seen: now}
+	c.entries[key] = now
+	c.next = (c.next + 1) % len(c.ring)
+
+	return false
+}
+
+// Len returns the number of keys currently held by the cache.
+func (c *Cache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.entries)
+}
@@ -0,0 +1,63 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheSeenWithinTTL(t *testing.T) {
+	t.Parallel()
+
+	cache := New(10, time.Minute)
+	now := time.Now()
+
+	if cache.Seen("a", now) {
+		t.Fatal("first occurrence must not be reported as seen")
+	}
+
+	if !cache.Seen("a", now.Add(30*time.Second)) {
+		t.Fatal("second occurrence within the TTL must be reported as seen")
+	}
+
+	if cache.Seen("a", now.Add(2*time.Minute)) {
+		t.Fatal("occurrence after the TTL must not be reported as seen")
+	}
+}
+
+func TestCacheIsBounded(t *testing.T) {
+	t.Parallel()
+
+	cache := New(3, time.Hour)
+	now := time.Now()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		cache.Seen(key, now)
+	}
+
+	if cache.Len() != 3 {
+		t.Fatalf("expected 3 keys, got %d", cache.Len())
+	}
+
+	// "a" was evicted to make room for "d"
+	if cache.Seen("a", now) {
+		t.Fatal("evicted key must not be reported as seen")
+	}
+}
+
+func TestCacheEvictionKeepsRefreshedKeys(t *testing.T) {
+	t.Parallel()
+
+	cache := New(3, time.Minute)
+	now := time.Now()
+
+	cache.Seen("a", now)
+	cache.Seen("b", now)
+	// "a" expired and is recorded again in a new slot
+	cache.Seen("a", now.Add(2*time.Minute))
+	// Evicts the stale slot of "a", which must not remove the refreshed entry
+	cache.Seen("c", now.Add(2*time.Minute))
+
+	if !cache.Seen("a", now.Add(2*time.Minute+time.Second)) {
+		t.Fatal("refreshed key must still be reported as seen")
+	}
+}
@@ -0,0 +1,112 @@
+package detection
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+const (
+	// defaultFirstSeenWindow is the time a registrable domain is remembered if none is configured.
+	defaultFirstSeenWindow = 7 * 24 * time.Hour
+	// defaultFirstSeenCapacity is the number of registrable domains remembered if none is configured.
+	defaultFirstSeenCapacity = 500000
+)
+
+// seenDomain is a registrable domain in the recently seen set with the time of its last sighting.
+type seenDomain struct {
+	name     string
+	lastSeen time.Time
+}
+
+// FirstSeen flags registrable domains that this server sees for the first time within a window, which is a cheap
+// proxy for newly registered domains. The set of recently seen domains is bounded: once it is full, the least
+// recently seen domain is evicted, so that it counts as new again on its next sighting. A FirstSeen is safe for
+// concurrent use.
+type FirstSeen struct {
+	mu       sync.Mutex
+	window   time.Duration
+	capacity int
+	domains  map[string]*list.Element
+	// recent holds the *seenDomain values ordered by their last sighting, the most recent one first.
+	recent *list.List
+}
+
+// NewFirstSeen creates a FirstSeen detector from the given config. It returns nil if the detection is disabled.
+func NewFirstSeen(conf config.FirstSeenConfig) *FirstSeen {
+	if !conf.Enabled {
+		return nil
+	}
+
+	window := conf.Window
+	if window <= 0 {
+		window = defaultFirstSeenWindow
+	}
+
+	capacity := conf.Capacity
+	if capacity <= 0 {
+		capacity = defaultFirstSeenCapacity
+	}
+
+	return &FirstSeen{
+		window:   window,
+		capacity: capacity,
+		domains:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// Check records the given registrable domains as seen at the given time and returns true if at least one of them was
+// not seen within the window before. It returns false if the detector is nil.
+func (f *FirstSeen) Check(registrableDomains []string, now time.Time) bool {
+	if f == nil {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	firstSeen := false
+
+	for _, name := range registrableDomains {
+		element, ok := f.domains[name]
+		if !ok {
+			firstSeen = true
+			f.add(name, now)
+
+			continue
+		}
+
+		domain := element.Value.(*seenDomain)
+		if now.Sub(domain.lastSeen) >= f.window {
+			firstSeen = true
+		}
+
+		domain.lastSeen = now
+		f.recent.MoveToFront(element)
+	}
+
+	return firstSeen
+}
+
+// add inserts the domain as the most recently seen one and evicts the least recently seen domain if the set is full.
+// f.mu must be held.
+func (f *FirstSeen) add(name string, now time.Time) {
+	if f.recent.Len() >= f.capacity {
+		oldest := f.recent.Back()
+		f.recent.Remove(oldest)
+		delete(f.domains, oldest.Value.(*seenDomain).name)
+	}
+
+	f.domains[name] = f.recent.PushFront(&seenDomain{name: name, lastSeen: now})
+}
+
+// Len returns the number of registrable domains currently remembered.
+func (f *FirstSeen) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.recent.Len()
+}
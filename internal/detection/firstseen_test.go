@@ -0,0 +1,47 @@
+package detection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestFirstSeen(t *testing.T) {
+	t.Parallel()
+
+	detector := NewFirstSeen(config.FirstSeenConfig{Enabled: true, Window: time.Hour, Capacity: 2})
+	start := time.Now()
+
+	tests := []struct {
+		name    string
+		domains []string
+		at      time.Duration
+		want    bool
+	}{
+		{"new domain", []string{"example.com"}, 0, true},
+		{"seen domain", []string{"example.com"}, time.Minute, false},
+		{"one new domain", []string{"example.com", "example.org"}, 2 * time.Minute, true},
+		{"all seen", []string{"example.org", "example.com"}, 3 * time.Minute, false},
+		{"window expired", []string{"example.com"}, 2 * time.Hour, true},
+		// example.org is the least recently seen domain and evicted to make room for example.net
+		{"evicts oldest", []string{"example.net"}, 2 * time.Hour, true},
+		{"evicted domain", []string{"example.org"}, 2 * time.Hour, true},
+		{"kept domain", []string{"example.net"}, 2 * time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		if got := detector.Check(tt.domains, start.Add(tt.at)); got != tt.want {
+			t.Errorf("%s: Check(%v) = %t, want %t", tt.name, tt.domains, got, tt.want)
+		}
+	}
+
+	if detector.Len() != 2 {
+		t.Errorf("got %d remembered domains, want 2", detector.Len())
+	}
+
+	var disabled *FirstSeen
+	if NewFirstSeen(config.FirstSeenConfig{}) != nil || disabled.Check([]string{"example.com"}, start) {
+		t.Error("disabled detector flagged a domain")
+	}
+}
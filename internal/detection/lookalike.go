@@ -0,0 +1,175 @@
+package detection
+
+// The detection package flags suspicious certificates, such as certificates for domains that imitate the domains of
+// well-known brands.
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// defaultLookalikeThreshold is the minimum similarity of lookalike domains if none is configured.
+const defaultLookalikeThreshold = 0.85
+
+// watchedDomain is a domain of the watchlist with its precomputed forms used for the comparison.
+type watchedDomain struct {
+	// name is the domain as configured.
+	name string
+	// ascii is the registrable domain (eTLD+1) in ASCII form, used to recognize the legitimate domain itself.
+	ascii    string
+	skeleton []rune
+}
+
+// Lookalike detects domains that are confusable with the domains of a watchlist. Domains are compared by their
+// registrable part (eTLD+1): a domain is a lookalike if its skeleton equals the skeleton of a watched domain, or if
+// their similarity based on the Levenshtein distance reaches the threshold. The watched domains themselves and their
+// subdomains are never reported, so other legitimate domains of a brand can be added to the watchlist to exclude them.
+// A Lookalike is safe for concurrent use.
+type Lookalike struct {
+	watchlist []watchedDomain
+	threshold float64
+}
+
+// NewLookalike creates a Lookalike detector from the given config. It returns nil if the watchlist is empty.
+func NewLookalike(conf config.LookalikeConfig) *Lookalike {
+	threshold := conf.Threshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultLookalikeThreshold
+	}
+
+	detector := &Lookalike{threshold: threshold}
+
+	for _, name := range conf.Watchlist {
+		ascii, unicodeDomain, ok := registrableDomain(name)
+		if !ok {
+			continue
+		}
+
+		detector.watchlist = append(detector.watchlist, watchedDomain{
+			name:     name,
+			ascii:    ascii,
+			skeleton: []rune(skeleton(unicodeDomain)),
+		})
+	}
+
+	if len(detector.watchlist) == 0 {
+		return nil
+	}
+
+	return detector
+}
+
+// Matches returns the domains of the watchlist that at least one of the given domains is confusable with.
+// It returns nil if there is no match or the detector is nil.
+func (l *Lookalike) Matches(domains []string) []string {
+	if l == nil {
+		return nil
+	}
+
+	var matches []string
+
+	checked := make(map[string]struct{}, len(domains))
+
+	for _, domain := range domains {
+		ascii, unicodeDomain, ok := registrableDomain(domain)
+		if !ok {
+			continue
+		}
+
+		// Certificates usually contain multiple subdomains of the same registrable domain
+		if _, ok := checked[ascii]; ok || l.isWatched(ascii) {
+			continue
+		}
+		checked[ascii] = struct{}{}
+
+		domainSkeleton := []rune(skeleton(unicodeDomain))
+
+		for _, watched := range l.watchlist {
+			if slices.Contains(matches, watched.name) {
+				continue
+			}
+
+			if similarity(domainSkeleton, watched.skeleton) >= l.threshold {
+				matches = append(matches, watched.name)
+			}
+		}
+	}
+
+	return matches
+}
+
+// isWatched returns true if the registrable domain is one of the watched domains, which are legitimate by definition.
+func (l *Lookalike) isWatched(ascii string) bool {
+	for _, watched := range l.watchlist {
+		if watched.ascii == ascii {
+			return true
+		}
+	}
+
+	return false
+}
+
+// registrableDomain returns the registrable part (eTLD+1) of the domain in its ASCII and Unicode form.
+// ok is false if the domain has no registrable part, e.g. because it is an IP address or a public suffix.
+func registrableDomain(domain string) (ascii, unicodeDomain string, ok bool) {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+
+	ascii, err := idna.Punycode.ToASCII(domain)
+	if err != nil {
+		return "", "", false
+	}
+
+	ascii, err = publicsuffix.EffectiveTLDPlusOne(ascii)
+	if err != nil {
+		return "", "", false
+	}
+
+	unicodeDomain, err = idna.Punycode.ToUnicode(ascii)
+	if err != nil {
+		unicodeDomain = ascii
+	}
+
+	return ascii, unicodeDomain, true
+}
+
+// similarity returns the similarity of a and b between 0 (completely different) and 1 (equal), based on the
+// Levenshtein distance relative to the length of the longer string.
+func similarity(a, b []rune) float64 {
+	maxLen := max(len(a), len(b))
+	if maxLen == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(a, b))/float64(maxLen)
+}
+
+// levenshtein returns the minimum number of single character insertions, deletions and substitutions to turn a into b.
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,50 @@
+package detection
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestLookalikeMatches(t *testing.T) {
+	t.Parallel()
+
+	detector := NewLookalike(config.LookalikeConfig{Watchlist: []string{"paypal.com", "google.com", "google.de", "amazon.com"}})
+
+	tests := []struct {
+		domains []string
+		want    []string
+	}{
+		// Homographs with cyrillic letters, in Unicode and punycode form
+		{[]string{"pаypal.com"}, []string{"paypal.com"}},
+		{[]string{"xn--pypal-4ve.com"}, []string{"paypal.com"}},
+		// Digits and letter sequences that look like other letters
+		{[]string{"www.paypa1.com"}, []string{"paypal.com"}},
+		{[]string{"*.arnazon.com"}, []string{"amazon.com"}},
+		// Typos
+		{[]string{"paypall.com", "gooogle.com"}, []string{"paypal.com", "google.com"}},
+		// The legitimate domains and their subdomains
+		{[]string{"paypal.com", "*.paypal.com", "mail.google.com", "google.de"}, nil},
+		{[]string{"example.com", "192.168.0.1", "com"}, nil},
+	}
+
+	for _, tt := range tests {
+		if got := detector.Matches(tt.domains); !slices.Equal(got, tt.want) {
+			t.Errorf("Matches(%v) = %v, want %v", tt.domains, got, tt.want)
+		}
+	}
+}
+
+func TestNewLookalikeWithoutWatchlist(t *testing.T) {
+	t.Parallel()
+
+	detector := NewLookalike(config.LookalikeConfig{})
+	if detector != nil {
+		t.Fatalf("NewLookalike() = %v, want nil", detector)
+	}
+
+	if got := detector.Matches([]string{"paypal.com"}); got != nil {
+		t.Errorf("Matches() of nil detector = %v, want nil", got)
+	}
+}
@@ -0,0 +1,81 @@
+package detection
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps characters to the ASCII letter they are commonly confused with. It is a subset of the Unicode
+// confusables (UTS #39), limited to the characters that are allowed in domains and used in homograph attacks.
+// Compatibility characters such as fullwidth letters are already mapped by the NFKD normalization.
+var confusables = map[rune]rune{
+	// Digits
+	'0': 'o',
+	'1': 'l',
+	// Latin
+	'ı': 'i',
+	'ȷ': 'j',
+	'ɑ': 'a',
+	'ɡ': 'g',
+	'ɩ': 'i',
+	'ʟ': 'l',
+	// Greek
+	'α': 'a',
+	'ε': 'e',
+	'ι': 'i',
+	'κ': 'k',
+	'ν': 'v',
+	'ο': 'o',
+	'ρ': 'p',
+	'τ': 't',
+	'υ': 'u',
+	'χ': 'x',
+	'ω': 'w',
+	// Cyrillic
+	'а': 'a',
+	'е': 'e',
+	'ё': 'e',
+	'і': 'i',
+	'ј': 'j',
+	'к': 'k',
+	'о': 'o',
+	'р': 'p',
+	'с': 'c',
+	'у': 'y',
+	'х': 'x',
+	'ѕ': 's',
+	'һ': 'h',
+	'ԁ': 'd',
+	'ԛ': 'q',
+	'ԝ': 'w',
+	'ӏ': 'l',
+}
+
+// confusableSequences replaces sequences of ASCII letters that look like a single letter.
+var confusableSequences = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// skeleton returns a normalized form of s, in which confusable characters are replaced by the ASCII letter they
+// resemble. Two strings with the same skeleton are visually confusable.
+func skeleton(s string) string {
+	var b strings.Builder
+
+	b.Grow(len(s))
+
+	for _, r := range norm.NFKD.String(s) {
+		// Decomposed diacritics are dropped, so that "é" becomes "e"
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+
+		r = unicode.ToLower(r)
+		if replacement, ok := confusables[r]; ok {
+			r = replacement
+		}
+
+		b.WriteRune(r)
+	}
+
+	return confusableSequences.Replace(b.String())
+}
@@ -0,0 +1,228 @@
+package grpcserver
+
+// The grpcserver package provides the gRPC API of the server. Clients subscribe via the server-streaming
+// StreamCertificates RPC. Backpressure is handled by the flow control of HTTP/2.
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/pkg/certstreampb"
+	"github.com/letrics/certstream-server-go/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Server serves the gRPC API. It is registered as a single sink at the broadcast manager and distributes the
+// entries to all connected streams.
+type Server struct {
+	certstreampb.UnimplementedCertStreamServer
+
+	addr       string
+	grpcServer *grpc.Server
+	bufferSize int
+
+	streams   map[*stream]struct{}
+	streamsMu sync.RWMutex
+	dropped   atomic.Uint64
+}
+
+// stream is a single StreamCertificates call of a client.
+type stream struct {
+	entryChan chan models.Entry
+	filter    *certificatetransparency.DomainFilter
+}
+
+// NewServer creates a new gRPC server listening on the given interface and port.
+// If certPath and keyPath are set, TLS is used. Each stream buffers up to bufferSize entries.
+func NewServer(networkIf string, port int, certPath, keyPath string, bufferSize int) (*Server, error) {
+	var opts []grpc.ServerOption
+
+	if certPath != "" && keyPath != "" {
+		creds, err := credentials.NewServerTLSFromFile(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	s := &Server{
+		addr:       net.JoinHostPort(networkIf, strconv.Itoa(port)),
+		grpcServer: grpc.NewServer(opts...),
+		bufferSize: bufferSize,
+		streams:    make(map[*stream]struct{}),
+	}
+	certstreampb.RegisterCertStreamServer(s.grpcServer, s)
+
+	return s, nil
+}
+
+// Start starts listening for gRPC connections. This method is blocking.
+func (s *Server) Start() {
+	log.Printf("Starting gRPC server on %s\n", s.addr)
+
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		log.Fatal("Error while starting gRPC server: ", err)
+	}
+
+	if err := s.grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		log.Fatal("Error while serving gRPC server: ", err)
+	}
+}
+
+// StreamCertificates streams all new certificates matching the request to the client until the call is cancelled.
+func (s *Server) StreamCertificates(req *certstreampb.StreamRequest, srv grpc.ServerStreamingServer[certstreampb.Certificate]) error {
+	st := &stream{
+		entryChan: make(chan models.Entry, s.bufferSize),
+		filter:    certificatetransparency.NewDomainFilter(req.GetDomainSuffixes(), nil),
+	}
+
+	s.streamsMu.Lock()
+	s.streams[st] = struct{}{}
+	s.streamsMu.Unlock()
+
+	defer func() {
+		s.streamsMu.Lock()
+		delete(s.streams, st)
+		s.streamsMu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case entry := <-st.entryChan:
+			if err := srv.Send(toProto(entry)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Name returns the name of the sink.
+func (s *Server) Name() string {
+	return "grpc"
+}
+
+// Send passes the entry to all streams whose filter matches. If the buffer of a stream is full, the entry is dropped
+// for this stream.
+func (s *Server) Send(entry models.Entry) {
+	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+
+	for st := range s.streams {
+		if !st.filter.Matches(entry.Data.LeafCert.AllDomains) {
+			continue
+		}
+
+		select {
+		case st.entryChan <- entry:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Errors always returns 0, since errors of individual streams are returned to the respective client.
+func (s *Server) Errors() uint64 {
+	return 0
+}
+
+// Dropped returns the number of entries that were dropped because the buffer of a stream was full.
+func (s *Server) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops the gRPC server and closes all streams.
+func (s *Server) Close() error {
+	log.Println("Stopping gRPC server...")
+	s.grpcServer.Stop()
+
+	return nil
+}
+
+// toProto converts an entry to its protobuf representation.
+func toProto(entry models.Entry) *certstreampb.Certificate {
+	chain := make([]*certstreampb.LeafCert, 0, len(entry.Data.Chain))
+	for i := range entry.Data.Chain {
+		chain = append(chain, leafCertToProto(&entry.Data.Chain[i]))
+	}
+
+	return &certstreampb.Certificate{
+		MessageType: entry.MessageType,
+		UpdateType:  string(entry.Data.UpdateType),
+		CertIndex:   entry.Data.CertIndex,
+		CertLink:    entry.Data.CertLink,
+		Seen:        float64(entry.Data.Seen),
+		Source: &certstreampb.Source{
+			Name: entry.Data.Source.Name,
+			Url:  entry.Data.Source.URL,
+		},
+		LeafCert: leafCertToProto(&entry.Data.LeafCert),
+		Chain:    chain,
+	}
+}
+
+// leafCertToProto converts a certificate to its protobuf representation.
+func leafCertToProto(cert *models.LeafCert) *certstreampb.LeafCert {
+	der := cert.DER
+	if len(der) == 0 && cert.AsDER != "" {
+		der, _ = base64.StdEncoding.DecodeString(cert.AsDER)
+	}
+
+	scts := make([]*certstreampb.SCT, 0, len(cert.SCTs))
+	for _, sct := range cert.SCTs {
+		scts = append(scts, &certstreampb.SCT{
+			LogId:     sct.LogID,
+			Timestamp: sct.Timestamp.UnixMilli(),
+		})
+	}
+
+	return &certstreampb.LeafCert{
+		AllDomains:         cert.AllDomains,
+		Der:                der,
+		Fingerprint:        cert.Fingerprint,
+		Sha1:               cert.SHA1,
+		Sha256:             cert.SHA256,
+		NotBefore:          int64(cert.NotBefore),
+		NotAfter:           int64(cert.NotAfter),
+		SerialNumber:       cert.SerialNumber,
+		SignatureAlgorithm: cert.SignatureAlgorithm,
+		Subject:            subjectToProto(cert.Subject),
+		Issuer:             subjectToProto(cert.Issuer),
+		IsCa:               cert.IsCA,
+		Scts:               scts,
+	}
+}
+
+// subjectToProto converts a subject to its protobuf representation. Missing fields are empty strings.
+func subjectToProto(subject models.Subject) *certstreampb.Subject {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+
+		return *s
+	}
+
+	return &certstreampb.Subject{
+		C:            value(subject.C),
+		Cn:           value(subject.CN),
+		L:            value(subject.L),
+		O:            value(subject.O),
+		Ou:           value(subject.OU),
+		St:           value(subject.ST),
+		Aggregated:   value(subject.Aggregated),
+		EmailAddress: value(subject.EmailAddress),
+	}
+}
@@ -0,0 +1,161 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/pkg/certstreampb"
+	"github.com/letrics/certstream-server-go/pkg/models"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves the gRPC API on an in-memory listener and returns a client connected to it.
+func startServer(t *testing.T, bufferSize int) (*Server, certstreampb.CertStreamClient) {
+	t.Helper()
+
+	s, err := NewServer("", 0, "", "", bufferSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = s.grpcServer.Serve(listener) }()
+	t.Cleanup(func() { _ = s.Close() })
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return s, certstreampb.NewCertStreamClient(conn)
+}
+
+// waitForStreams waits until the given number of streams is registered at the server.
+func waitForStreams(t *testing.T, s *Server, count int) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		s.streamsMu.RLock()
+		registered := len(s.streams)
+		s.streamsMu.RUnlock()
+
+		if registered == count {
+			return
+		}
+	}
+
+	t.Fatalf("timeout waiting for %d streams", count)
+}
+
+func TestStreamCertificatesFiltersDomainSuffixes(t *testing.T) {
+	t.Parallel()
+
+	s, client := startServer(t, 10)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	certStream, err := client.StreamCertificates(ctx, &certstreampb.StreamRequest{DomainSuffixes: []string{"example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForStreams(t, s, 1)
+
+	var other, matching models.Entry
+	other.Data.LeafCert.AllDomains = []string{"example.org"}
+	matching.Data.LeafCert.AllDomains = []string{"www.example.com"}
+
+	s.Send(other)
+	s.Send(matching)
+
+	cert, err := certStream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if domains := cert.GetLeafCert().GetAllDomains(); len(domains) != 1 || domains[0] != "www.example.com" {
+		t.Errorf("got certificate for %v, want www.example.com", domains)
+	}
+}
+
+func TestSendDropsIfBufferIsFull(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer("", 0, "", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A stream whose client doesn't receive anything
+	st := &stream{entryChan: make(chan models.Entry, 1), filter: certificatetransparency.NewDomainFilter(nil, nil)}
+	s.streams[st] = struct{}{}
+
+	for range 3 {
+		s.Send(models.Entry{})
+	}
+
+	if dropped := s.Dropped(); dropped != 2 {
+		t.Errorf("got %d dropped entries, want 2", dropped)
+	}
+
+	if queued := len(st.entryChan); queued != 1 {
+		t.Errorf("got %d queued entries, want 1", queued)
+	}
+}
+
+func TestToProto(t *testing.T) {
+	t.Parallel()
+
+	cn := "example.com"
+	der := []byte{0x30, 0x01, 0x02}
+
+	var entry models.Entry
+	entry.MessageType = "certificate_update"
+	entry.Data.UpdateType = models.UpdateTypePrecert
+	entry.Data.CertIndex = 42
+	entry.Data.Source = models.Source{Name: "Test log", URL: "https://ct.example.com/"}
+	entry.Data.LeafCert = models.LeafCert{
+		AllDomains:  []string{"example.com"},
+		DER:         der,
+		Fingerprint: "AA:BB",
+		NotBefore:   100,
+		NotAfter:    200,
+		Subject:     models.Subject{CN: &cn},
+		SCTs:        []models.SCT{{LogID: "log", Timestamp: time.UnixMilli(1234)}},
+	}
+	entry.Data.Chain = []models.LeafCert{{AsDER: base64.StdEncoding.EncodeToString(der), IsCA: true}}
+
+	cert := toProto(entry)
+
+	if cert.GetMessageType() != "certificate_update" || cert.GetUpdateType() != string(models.UpdateTypePrecert) ||
+		cert.GetCertIndex() != 42 || cert.GetSource().GetName() != "Test log" ||
+		cert.GetSource().GetUrl() != "https://ct.example.com/" {
+		t.Errorf("unexpected certificate fields: %v", cert)
+	}
+
+	leaf := cert.GetLeafCert()
+	if leaf.GetFingerprint() != "AA:BB" || string(leaf.GetDer()) != string(der) || leaf.GetNotBefore() != 100 ||
+		leaf.GetNotAfter() != 200 || leaf.GetSubject().GetCn() != cn || leaf.GetSubject().GetO() != "" {
+		t.Errorf("unexpected leaf certificate fields: %v", leaf)
+	}
+
+	if scts := leaf.GetScts(); len(scts) != 1 || scts[0].GetLogId() != "log" || scts[0].GetTimestamp() != 1234 {
+		t.Errorf("unexpected SCTs: %v", scts)
+	}
+
+	// The DER of chain certificates is decoded from AsDER if the raw bytes are not included
+	if chain := cert.GetChain(); len(chain) != 1 || string(chain[0].GetDer()) != string(der) || !chain[0].GetIsCa() {
+		t.Errorf("unexpected chain: %v", chain)
+	}
+}
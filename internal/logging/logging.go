@@ -0,0 +1,112 @@
+// Package logging provides the leveled logger used by the CT watcher and the library, so that applications embedding
+// the library can redirect or silence its logs.
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Logger is a leveled logger with structured fields. The args are alternating keys and values, as with slog,
+// e.g. logger.Info("Starting worker", "url", url).
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// levels maps the names of the log levels to the respective slog level.
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// slogLogger adapts a *slog.Logger to the Logger interface.
+type slogLogger struct {
+	logger *slog.Logger
+	// level overrides the minimum level of the logger's handler. Nil if the handler decides.
+	level slog.Leveler
+}
+
+// NewSlogLogger returns a Logger that writes to the given slog logger. If it is nil, the logs are written to
+// slog.Default(), which is resolved on every call, so that a default set later by the application is respected.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger: logger}
+}
+
+// New returns a Logger that writes to slog.Default() and discards the logs below the given level, e.g. "debug".
+// An unknown or empty level defaults to info. If quiet is set, only errors are logged regardless of the level.
+func New(level string, quiet bool) Logger {
+	return slogLogger{level: ParseLevel(level, quiet)}
+}
+
+// NewWithLevel returns a Logger that writes to slog.Default() and discards the logs below the level of the leveler.
+// Pass a *slog.LevelVar to change the level at runtime.
+func NewWithLevel(level slog.Leveler) Logger {
+	return slogLogger{level: level}
+}
+
+// ParseLevel returns the slog level of the given level name, e.g. "debug". An unknown or empty level defaults to info.
+// If quiet is set, the level is error regardless of the name.
+func ParseLevel(level string, quiet bool) slog.Level {
+	if quiet {
+		return slog.LevelError
+	}
+
+	minLevel, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return slog.LevelInfo
+	}
+
+	return minLevel
+}
+
+// Default returns the logger that is used if none is set. It writes to slog.Default(), which logs at Info level
+// unless configured otherwise.
+func Default() Logger {
+	return NewSlogLogger(nil)
+}
+
+func (l slogLogger) Debug(msg string, args ...any) { l.log(slog.LevelDebug, msg, args) }
+func (l slogLogger) Info(msg string, args ...any)  { l.log(slog.LevelInfo, msg, args) }
+func (l slogLogger) Warn(msg string, args ...any)  { l.log(slog.LevelWarn, msg, args) }
+func (l slogLogger) Error(msg string, args ...any) { l.log(slog.LevelError, msg, args) }
+
+func (l slogLogger) log(level slog.Level, msg string, args []any) {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	if l.level == nil {
+		logger.Log(context.Background(), level, msg, args...)
+		return
+	}
+
+	// The handler is called directly, since its own minimum level would drop debug logs, e.g. that of slog.Default()
+	if level < l.level.Level() {
+		return
+	}
+
+	record := slog.NewRecord(time.Now(), level, msg, 0)
+	record.Add(args...)
+	_ = logger.Handler().Handle(context.Background(), record)
+}
+
+// nopLogger discards all logs.
+type nopLogger struct{}
+
+// Nop returns a Logger that discards all logs.
+func Nop() Logger {
+	return nopLogger{}
+}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLevelOverridesHandler(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	// The handler itself drops debug logs, like the handler of slog.Default()
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+
+	tests := []struct {
+		level string
+		quiet bool
+		want  []string
+	}{
+		{"debug", false, []string{"debug", "info", "warn", "error"}},
+		{"", false, []string{"info", "warn", "error"}},
+		{"WARN", false, []string{"warn", "error"}},
+		{"debug", true, []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+
+		logger := New(tt.level, tt.quiet).(slogLogger)
+		logger.logger = slog.New(handler)
+
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Warn("warn")
+		logger.Error("error", "key", "value")
+
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if _, msg, ok := strings.Cut(line, "msg="); ok {
+				got = append(got, strings.Fields(msg)[0])
+			}
+		}
+
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("level '%s' (quiet: %t): got logs %v, want %v", tt.level, tt.quiet, got, tt.want)
+		}
+	}
+
+	if !strings.Contains(buf.String(), "key=value") {
+		t.Errorf("fields are missing in '%s'", buf.String())
+	}
+}
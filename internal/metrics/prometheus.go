@@ -14,6 +14,9 @@ import (
 )
 
 var (
+	// watcher is the CT watcher of the server, which provides the per-log metrics. It is nil until SetWatcher is called.
+	watcher *certificatetransparency.Watcher
+
 	ctLogMetricsInitialized = false
 	ctLogMetricsInitMutex   = &sync.Mutex{}
 
@@ -31,6 +34,11 @@ var (
 		return float64(web.ClientHandler.ClientDomainsCount())
 	})
 
+	// Number of currently open websocket connections, as counted for the connection limits.
+	websocketConnections = metrics.NewGauge("certstreamservergo_websocket_connections", func() float64 {
+		return float64(web.ConnectionCount())
+	})
+
 	// Number of certificates processed by the CT watcher.
 	processedCertificates = metrics.NewGauge("certstreamservergo_certificates_total{type=\"regular\"}", func() float64 {
 		return float64(certificatetransparency.GetProcessedCerts())
@@ -38,6 +46,70 @@ var (
 	processedPreCertificates = metrics.NewGauge("certstreamservergo_certificates_total{type=\"precert\"}", func() float64 {
 		return float64(certificatetransparency.GetProcessedPrecerts())
 	})
+
+	// Number of certificates discarded by the CT watcher due to filters.
+	wildcardFilteredCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"wildcard\"}", func() float64 {
+		return float64(certificatetransparency.GetWildcardFilteredCerts())
+	})
+	precertFilteredCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"precert\"}", func() float64 {
+		return float64(certificatetransparency.GetPrecertFilteredCerts())
+	})
+	ipSANFilteredCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"ip_san\"}", func() float64 {
+		return float64(certificatetransparency.GetIPSANFilteredCerts())
+	})
+	issuerFilteredCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"issuer\"}", func() float64 {
+		return float64(certificatetransparency.GetIssuerFilteredCerts())
+	})
+	sanCountFilteredCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"san_count\"}", func() float64 {
+		return float64(certificatetransparency.GetSANCountFilteredCerts())
+	})
+	sampledOutCertificates = metrics.NewGauge("certstreamservergo_filtered_certificates_total{filter=\"sample\"}", func() float64 {
+		return float64(certificatetransparency.GetSampledOutCerts())
+	})
+
+	// Number of entries currently waiting in the internal queues. The handler queue between the workers and the
+	// broadcast manager has a fixed size and is not affected by buffer_sizes.ctlog.
+	handlerQueueLength = metrics.NewGauge("certstreamservergo_queue_length{queue=\"handler\"}", func() float64 {
+		if watcher == nil {
+			return 0
+		}
+
+		return float64(watcher.QueueLength())
+	})
+	// Number of get-entries requests that were sent right away and that were delayed by the global rate limit.
+	allowedRequests = metrics.NewGauge("certstreamservergo_rate_limited_requests_total{result=\"allowed\"}", func() float64 {
+		if watcher == nil {
+			return 0
+		}
+
+		allowed, _ := watcher.RequestRateLimitStats()
+
+		return float64(allowed)
+	})
+	limitedRequests = metrics.NewGauge("certstreamservergo_rate_limited_requests_total{result=\"limited\"}", func() float64 {
+		if watcher == nil {
+			return 0
+		}
+
+		_, limited := watcher.RequestRateLimitStats()
+
+		return float64(limited)
+	})
+	parseQueueLength = metrics.NewGauge("certstreamservergo_queue_length{queue=\"parse\"}", func() float64 {
+		if watcher == nil {
+			return 0
+		}
+
+		return float64(watcher.ParseQueueLength())
+	})
+	broadcastQueueLength = metrics.NewGauge("certstreamservergo_queue_length{queue=\"broadcast\"}", func() float64 {
+		return float64(web.ClientHandler.QueueLength())
+	})
+
+	// Number of certificates that were not broadcast because they were already seen recently.
+	duplicateCertificates = metrics.NewGauge("certstreamservergo_duplicate_certificates_total", func() float64 {
+		return float64(web.ClientHandler.GetDuplicateCerts())
+	})
 )
 
 // WritePrometheus provides an easy way to write metrics to a writer.
@@ -49,6 +121,11 @@ func WritePrometheus(w io.Writer, exposeProcessMetrics bool) {
 	ctLogMetricsInitMutex.Unlock()
 
 	getSkippedCertMetrics()
+	getAPIKeyClientMetrics()
+	getRejectedConnectionMetrics()
+	getSinkMetrics()
+	getLogStatsMetrics()
+	getShardMetrics()
 
 	metrics.WritePrometheus(w, exposeProcessMetrics)
 }
@@ -116,3 +193,104 @@ func getSkippedCertMetrics() {
 		}
 	}
 }
+
+// getAPIKeyClientMetrics creates metrics for the number of connected clients per API key label.
+// It also removes metrics for labels without connected clients.
+func getAPIKeyClientMetrics() {
+	current := make(map[string]bool)
+
+	for label, count := range web.ClientHandler.ClientCountByAPIKey() {
+		escapedLabel := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(label)
+		metricName := fmt.Sprintf("certstreamservergo_clients_by_api_key_total{key=\"%s\"}", escapedLabel)
+		metrics.GetOrCreateGauge(metricName, nil).Set(float64(count))
+		current[metricName] = true
+	}
+
+	for _, metricName := range metrics.ListMetricNames() {
+		if strings.HasPrefix(metricName, "certstreamservergo_clients_by_api_key_total{") && !current[metricName] {
+			metrics.UnregisterMetric(metricName)
+		}
+	}
+}
+
+// getRejectedConnectionMetrics gets the number of rejected websocket connection attempts per exceeded limit.
+func getRejectedConnectionMetrics() {
+	for limit, count := range web.RejectedConnections() {
+		metrics.GetOrCreateCounter(fmt.Sprintf("certstreamservergo_rejected_connections_total{limit=\"%s\"}", limit)).Set(count)
+	}
+}
+
+// SetWatcher sets the CT watcher that provides the per-log and queue metrics.
+// It must be called before the metrics are served.
+func SetWatcher(w *certificatetransparency.Watcher) {
+	watcher = w
+}
+
+// getLogStatsMetrics creates metrics for the number of processed entries, the tree size gap, the clamped requests,
+// the get-entries requests and the fetched bytes of each CT log.
+// It also removes metrics for logs that are not watched anymore.
+func getLogStatsMetrics() {
+	if watcher == nil {
+		return
+	}
+
+	current := make(map[string]bool)
+
+	for _, stats := range watcher.LogStats() {
+		logName := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(stats.Name)
+
+		entriesName := fmt.Sprintf("certstreamservergo_entries_total{log=\"%s\"}", logName)
+		metrics.GetOrCreateCounter(entriesName).Set(stats.Entries)
+
+		gapName := fmt.Sprintf("certstreamservergo_tree_size_gap{log=\"%s\"}", logName)
+		metrics.GetOrCreateGauge(gapName, nil).Set(float64(stats.Gap))
+
+		clampedName := fmt.Sprintf("certstreamservergo_clamped_requests_total{log=\"%s\"}", logName)
+		metrics.GetOrCreateCounter(clampedName).Set(stats.ClampedRequests)
+
+		requestsName := fmt.Sprintf("certstreamservergo_get_entries_requests_total{log=\"%s\"}", logName)
+		metrics.GetOrCreateCounter(requestsName).Set(stats.GetEntriesRequests)
+
+		bytesName := fmt.Sprintf("certstreamservergo_fetched_bytes_total{log=\"%s\"}", logName)
+		metrics.GetOrCreateCounter(bytesName).Set(stats.BytesFetched)
+
+		current[entriesName] = true
+		current[gapName] = true
+		current[clampedName] = true
+		current[requestsName] = true
+		current[bytesName] = true
+	}
+
+	for _, metricName := range metrics.ListMetricNames() {
+		isLogMetric := strings.HasPrefix(metricName, "certstreamservergo_entries_total{") ||
+			strings.HasPrefix(metricName, "certstreamservergo_tree_size_gap{") ||
+			strings.HasPrefix(metricName, "certstreamservergo_clamped_requests_total{") ||
+			strings.HasPrefix(metricName, "certstreamservergo_get_entries_requests_total{") ||
+			strings.HasPrefix(metricName, "certstreamservergo_fetched_bytes_total{")
+
+		if isLogMetric && !current[metricName] {
+			metrics.UnregisterMetric(metricName)
+		}
+	}
+}
+
+// getSinkMetrics gets the number of errors and dropped entries for each sink and creates metrics for it.
+func getSinkMetrics() {
+	for sinkName, count := range web.ClientHandler.GetSinkErrors() {
+		metrics.GetOrCreateCounter(fmt.Sprintf("certstreamservergo_sink_errors_total{sink=\"%s\"}", sinkName)).Set(count)
+	}
+
+	// Dropped entries of all sinks including the websocket clients, labeled by the type of the sink
+	for sinkName, count := range web.ClientHandler.GetSinkDropped() {
+		metrics.GetOrCreateCounter(fmt.Sprintf("certstreamservergo_dropped_entries_total{sink=\"%s\"}", sinkName)).Set(count)
+	}
+}
+
+// getShardMetrics gets the number of entries waiting to be sent by each broadcast shard, which reveals if a shard
+// falls behind the others.
+func getShardMetrics() {
+	for shard, backlog := range web.ClientHandler.ShardBacklogs() {
+		name := fmt.Sprintf("certstreamservergo_queue_length{queue=\"broadcast_shard\",shard=\"%d\"}", shard)
+		metrics.GetOrCreateGauge(name, nil).Set(float64(backlog))
+	}
+}
@@ -0,0 +1,290 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// MsgPack encodes the entries as MessagePack (https://msgpack.org). Structs are encoded as maps with the same keys
+// as the JSON encoding, including omitempty, so that consumers can switch formats without remapping fields.
+// Timestamps are encoded with the timestamp extension type and byte slices as binary.
+type MsgPack struct{}
+
+// Marshal encodes the entry as MessagePack.
+func (MsgPack) Marshal(entry models.Entry) ([]byte, error) {
+	return appendMsgPack(make([]byte, 0, 2048), reflect.ValueOf(entry))
+}
+
+// MarshalDomains encodes the domains entry as MessagePack.
+func (MsgPack) MarshalDomains(entry models.DomainsEntry) ([]byte, error) {
+	return appendMsgPack(make([]byte, 0, 256), reflect.ValueOf(entry))
+}
+
+// Binary returns true, since MessagePack is a binary format.
+func (MsgPack) Binary() bool {
+	return true
+}
+
+// msgPackField is an exported struct field along with the key it is encoded with.
+type msgPackField struct {
+	index     int
+	name      string
+	omitEmpty bool
+}
+
+// msgPackFields caches the fields of the encoded struct types.
+var msgPackFields sync.Map // reflect.Type -> []msgPackField
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// fieldsOf returns the fields of the struct type that are encoded, in the order of their declaration.
+func fieldsOf(t reflect.Type) []msgPackField {
+	if fields, ok := msgPackFields.Load(t); ok {
+		return fields.([]msgPackField)
+	}
+
+	fields := make([]msgPackField, 0, t.NumField())
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, msgPackField{index: i, name: name, omitEmpty: strings.Contains(options, "omitempty")})
+	}
+
+	msgPackFields.Store(t, fields)
+
+	return fields
+}
+
+// isEmpty reports whether the value is empty in the sense of the omitempty option of encoding/json.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// appendMsgPack appends the MessagePack encoding of the value to b.
+func appendMsgPack(b []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendUint(b, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(b, v.String()), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+
+		return appendMsgPack(b, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBinary(b, v.Bytes()), nil
+		}
+
+		return appendArray(b, v)
+	case reflect.Array:
+		return appendArray(b, v)
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return appendTime(b, v.Interface().(time.Time)), nil
+		}
+
+		return appendStruct(b, v)
+	default:
+		return b, fmt.Errorf("msgpack: unsupported type %s", v.Type())
+	}
+}
+
+// appendInt appends the shortest encoding of the signed integer to b.
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+	}
+}
+
+// appendUint appends the shortest encoding of the unsigned integer to b.
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= math.MaxInt8:
+		return append(b, byte(u))
+	case u <= math.MaxUint8:
+		return append(b, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(u))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+	}
+}
+
+// appendString appends the string to b.
+func appendString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+
+	return append(b, s...)
+}
+
+// appendBinary appends the byte slice to b.
+func appendBinary(b, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+
+	return append(b, data...)
+}
+
+// appendArrayHeader appends the header of an array with n elements to b.
+func appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xdc), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdd), uint32(n))
+	}
+}
+
+// appendMapHeader appends the header of a map with n entries to b.
+func appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xde), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, 0xdf), uint32(n))
+	}
+}
+
+// appendArray appends the elements of the slice or array to b.
+func appendArray(b []byte, v reflect.Value) ([]byte, error) {
+	b = appendArrayHeader(b, v.Len())
+
+	var err error
+	for i := range v.Len() {
+		if b, err = appendMsgPack(b, v.Index(i)); err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// appendStruct appends the struct as map of its field names to their values to b.
+func appendStruct(b []byte, v reflect.Value) ([]byte, error) {
+	fields := fieldsOf(v.Type())
+
+	n := len(fields)
+	for _, field := range fields {
+		if field.omitEmpty && isEmpty(v.Field(field.index)) {
+			n--
+		}
+	}
+
+	b = appendMapHeader(b, n)
+
+	var err error
+	for _, field := range fields {
+		value := v.Field(field.index)
+		if field.omitEmpty && isEmpty(value) {
+			continue
+		}
+
+		b = appendString(b, field.name)
+		if b, err = appendMsgPack(b, value); err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// appendTime appends the time using the timestamp extension type -1 in the shortest of its three formats.
+func appendTime(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+
+	switch {
+	case sec>>34 != 0:
+		b = append(b, 0xc7, 12, 0xff)
+		b = binary.BigEndian.AppendUint32(b, uint32(nsec))
+
+		return binary.BigEndian.AppendUint64(b, uint64(sec))
+	case nsec == 0 && sec <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd6, 0xff), uint32(sec))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, 0xd7, 0xff), uint64(nsec)<<34|uint64(sec))
+	}
+}
@@ -0,0 +1,132 @@
+package serializer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestAppendMsgPack(t *testing.T) {
+	t.Parallel()
+
+	name := "a"
+
+	type inner struct {
+		Name *string `json:"name"`
+		Nil  *string `json:"nil"`
+	}
+
+	type sample struct {
+		Int      int       `json:"int"`
+		Negative int64     `json:"neg"`
+		Uint     uint64    `json:"u"`
+		Float    float64   `json:"f"`
+		Bool     bool      `json:"b"`
+		Bytes    []byte    `json:"bin"`
+		List     []string  `json:"list"`
+		Omitted  []string  `json:"omitted,omitempty"`
+		Skipped  string    `json:"-"`
+		Inner    inner     `json:"in"`
+		Time     time.Time `json:"t"`
+		private  int
+	}
+
+	value := sample{
+		Int:      300,
+		Negative: -33,
+		Uint:     1 << 40,
+		Float:    1.5,
+		Bool:     true,
+		Bytes:    []byte{1, 2},
+		List:     []string{"x"},
+		Skipped:  "skipped",
+		Inner:    inner{Name: &name},
+		Time:     time.Unix(1700000000, 0),
+		private:  1,
+	}
+
+	want := []byte{
+		0x89,
+		0xa3, 'i', 'n', 't', 0xcd, 0x01, 0x2c,
+		0xa3, 'n', 'e', 'g', 0xd0, 0xdf,
+		0xa1, 'u', 0xcf, 0, 0, 0x01, 0, 0, 0, 0, 0,
+		0xa1, 'f', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xa1, 'b', 0xc3,
+		0xa3, 'b', 'i', 'n', 0xc4, 0x02, 0x01, 0x02,
+		0xa4, 'l', 'i', 's', 't', 0x91, 0xa1, 'x',
+		0xa2, 'i', 'n', 0x82, 0xa4, 'n', 'a', 'm', 'e', 0xa1, 'a', 0xa3, 'n', 'i', 'l', 0xc0,
+		0xa1, 't', 0xd6, 0xff, 0x65, 0x53, 0xf1, 0x00,
+	}
+
+	got, err := appendMsgPack(nil, reflect.ValueOf(value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Errorf("appendMsgPack() =\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestAppendTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		time time.Time
+		want []byte
+	}{
+		{time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+		{time.Unix(1, 1), []byte{0xd7, 0xff, 0, 0, 0, 0x04, 0, 0, 0, 1}},
+		{time.Unix(-1, 0), []byte{0xc7, 12, 0xff, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		if got := appendTime(nil, tt.time); !bytes.Equal(got, tt.want) {
+			t.Errorf("appendTime(%v) = % x, want % x", tt.time, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	cn := "example.com"
+	entry := models.Entry{
+		MessageType: "certificate_update",
+		Data: models.Data{
+			CertIndex: 123456789,
+			CertLink:  "https://ct.example.com/ct/v1/get-entries?start=123456789&end=123456789",
+			LeafCert: models.LeafCert{
+				AllDomains:         []string{"example.com", "www.example.com"},
+				AllDomainsUnicode:  []string{"example.com", "www.example.com"},
+				RegistrableDomains: []string{"example.com"},
+				Fingerprint:        "AB:CD:EF",
+				Subject:            models.Subject{CN: &cn},
+				Issuer:             models.Subject{CN: &cn},
+				NotBeforeTime:      time.Now(),
+				NotAfterTime:       time.Now().Add(90 * 24 * time.Hour),
+				SCTs:               []models.SCT{{LogID: "log", Timestamp: time.Now()}},
+			},
+			Seen:       1700000000.123,
+			Source:     models.Source{Name: "Example log", URL: "https://ct.example.com/"},
+			UpdateType: models.UpdateTypeX509,
+		},
+	}
+
+	for _, format := range []Format{FormatJSON, FormatMsgPack} {
+		b.Run(format.String(), func(b *testing.B) {
+			serializer := format.Serializer()
+			b.ReportAllocs()
+
+			for b.Loop() {
+				data, err := serializer.Marshal(entry)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				b.SetBytes(int64(len(data)))
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// projectedField is a field of an entry that is selected by a projection. Structs of which only some fields are
+// selected have these fields instead of a value.
+type projectedField struct {
+	name   string
+	value  reflect.Value
+	fields []projectedField
+}
+
+var leafCertType = reflect.TypeFor[models.LeafCert]()
+
+// project returns the fields of the entry that are selected, in the order of their declaration. The message type is
+// always kept, so that consumers can tell the entries apart from other messages.
+func project(entry models.Entry, fields models.Fields) []projectedField {
+	data := reflect.ValueOf(entry.Data)
+
+	var dataFields []projectedField
+
+	for _, field := range fieldsOf(data.Type()) {
+		value := data.Field(field.index)
+
+		switch {
+		case fields.Selects(field.name):
+			if !field.omitEmpty || !isEmpty(value) {
+				dataFields = append(dataFields, projectedField{name: field.name, value: value})
+			}
+		case value.Type() == leafCertType:
+			if leafCertFields := selectFields(value, fields); len(leafCertFields) > 0 {
+				dataFields = append(dataFields, projectedField{name: field.name, fields: leafCertFields})
+			}
+		}
+	}
+
+	return []projectedField{
+		{name: "data", fields: dataFields},
+		{name: "message_type", value: reflect.ValueOf(entry.MessageType)},
+	}
+}
+
+// selectFields returns the selected fields of the struct.
+func selectFields(v reflect.Value, fields models.Fields) []projectedField {
+	var selected []projectedField
+
+	for _, field := range fieldsOf(v.Type()) {
+		value := v.Field(field.index)
+		if fields.Selects(field.name) && (!field.omitEmpty || !isEmpty(value)) {
+			selected = append(selected, projectedField{name: field.name, value: value})
+		}
+	}
+
+	return selected
+}
+
+// MarshalFields encodes the selected fields of the entry as JSON, followed by a newline.
+func (JSON) MarshalFields(entry models.Entry, fields models.Fields) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := writeJSONFields(&buf, enc, project(entry, fields)); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// writeJSONFields writes the fields as JSON object to buf. The values are encoded with enc, which writes to buf.
+func writeJSONFields(buf *bytes.Buffer, enc *json.Encoder, fields []projectedField) error {
+	buf.WriteByte('{')
+
+	for i, field := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		// The names are the JSON names of the struct fields, which don't need escaping
+		buf.WriteString(`"` + field.name + `":`)
+
+		if !field.value.IsValid() {
+			if err := writeJSONFields(buf, enc, field.fields); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := enc.Encode(field.value.Interface()); err != nil {
+			return err
+		}
+
+		// Encode terminates each value with a newline
+		buf.Truncate(buf.Len() - 1)
+	}
+
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// MarshalFields encodes the selected fields of the entry as MessagePack.
+func (MsgPack) MarshalFields(entry models.Entry, fields models.Fields) ([]byte, error) {
+	return appendMsgPackFields(make([]byte, 0, 512), project(entry, fields))
+}
+
+// appendMsgPackFields appends the fields as map of their names to their values to b.
+func appendMsgPackFields(b []byte, fields []projectedField) ([]byte, error) {
+	b = appendMapHeader(b, len(fields))
+
+	var err error
+	for _, field := range fields {
+		b = appendString(b, field.name)
+
+		if !field.value.IsValid() {
+			b, err = appendMsgPackFields(b, field.fields)
+		} else {
+			b, err = appendMsgPack(b, field.value)
+		}
+
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
@@ -0,0 +1,62 @@
+package serializer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestMarshalFields(t *testing.T) {
+	t.Parallel()
+
+	entry := models.Entry{
+		Data: models.Data{
+			CertIndex: 42,
+			LeafCert:  models.LeafCert{AllDomains: []string{"example.com"}, SerialNumber: "01"},
+			Seen:      1.5,
+		},
+		MessageType: "certificate_update",
+	}
+
+	fields, err := models.ParseFields([]string{"seen", "all_domains", "leaf_hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// leaf_hash is omitted, since it is empty and tagged with omitempty
+	want := `{"data":{"leaf_cert":{"all_domains":["example.com"]},"seen":1.5},"message_type":"certificate_update"}` + "\n"
+
+	got, err := JSON{}.MarshalFields(entry, fields)
+	if err != nil || string(got) != want {
+		t.Errorf("JSON: got %s (error %v), want %s", got, err, want)
+	}
+
+	wantMsgPack := []byte{
+		0x82,
+		0xa4, 'd', 'a', 't', 'a', 0x82,
+		0xa9, 'l', 'e', 'a', 'f', '_', 'c', 'e', 'r', 't', 0x81,
+		0xab, 'a', 'l', 'l', '_', 'd', 'o', 'm', 'a', 'i', 'n', 's', 0x91,
+		0xab, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm',
+		0xa4, 's', 'e', 'e', 'n', 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		0xac, 'm', 'e', 's', 's', 'a', 'g', 'e', '_', 't', 'y', 'p', 'e',
+		0xb2, 'c', 'e', 'r', 't', 'i', 'f', 'i', 'c', 'a', 't', 'e', '_', 'u', 'p', 'd', 'a', 't', 'e',
+	}
+
+	gotMsgPack, err := MsgPack{}.MarshalFields(entry, fields)
+	if err != nil || !bytes.Equal(gotMsgPack, wantMsgPack) {
+		t.Errorf("MsgPack: got % x (error %v), want % x", gotMsgPack, err, wantMsgPack)
+	}
+}
+
+func TestParseFieldsRejectsUnknownNames(t *testing.T) {
+	t.Parallel()
+
+	if _, err := models.ParseFields([]string{"all_domains", "domains"}); err == nil {
+		t.Error("got no error for unknown field 'domains'")
+	}
+
+	if fields, err := models.ParseFields([]string{""}); err != nil || !fields.All() {
+		t.Errorf("got %v (error %v) for an empty name, want all fields", fields, err)
+	}
+}
@@ -0,0 +1,84 @@
+// Package serializer encodes the entries for the output paths, e.g. the websocket clients and the Kafka sink.
+// JSON is the interoperable default. MessagePack is a cheaper binary alternative for throughput-bound consumers.
+package serializer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// Serializer encodes entries into a wire format.
+type Serializer interface {
+	// Marshal encodes the entry.
+	Marshal(entry models.Entry) ([]byte, error)
+	// MarshalDomains encodes the entry sent to the clients of the domains-only stream.
+	MarshalDomains(entry models.DomainsEntry) ([]byte, error)
+	// MarshalFields encodes the selected fields of the entry, keeping the structure of the entry.
+	MarshalFields(entry models.Entry, fields models.Fields) ([]byte, error)
+	// Binary returns true if the encoding is binary, so that it must be sent as binary websocket message.
+	Binary() bool
+}
+
+// Format identifies one of the available serializers. It is used as index, e.g. to cache one encoding per format.
+type Format int
+
+const (
+	FormatJSON Format = iota
+	FormatMsgPack
+
+	// NumFormats is the number of available formats.
+	NumFormats = iota
+)
+
+var formats = [NumFormats]struct {
+	name       string
+	serializer Serializer
+}{
+	FormatJSON:    {"json", JSON{}},
+	FormatMsgPack: {"msgpack", MsgPack{}},
+}
+
+// ParseFormat returns the format with the given name, which is case-insensitive. An empty name selects JSON.
+func ParseFormat(name string) (Format, error) {
+	if name == "" {
+		return FormatJSON, nil
+	}
+
+	for format, f := range formats {
+		if strings.EqualFold(name, f.name) {
+			return Format(format), nil
+		}
+	}
+
+	return FormatJSON, fmt.Errorf("unknown format '%s'", name)
+}
+
+// String returns the name of the format, as accepted by ParseFormat.
+func (f Format) String() string {
+	return formats[f].name
+}
+
+// Serializer returns the serializer of the format.
+func (f Format) Serializer() Serializer {
+	return formats[f].serializer
+}
+
+// JSON encodes the entries as JSON, followed by a newline. It produces the same output as Entry.JSON.
+type JSON struct{}
+
+// Marshal encodes the entry as JSON.
+func (JSON) Marshal(entry models.Entry) ([]byte, error) {
+	return entry.JSONNoCache(), nil
+}
+
+// MarshalDomains encodes the domains entry as JSON.
+func (JSON) MarshalDomains(entry models.DomainsEntry) ([]byte, error) {
+	return entry.JSON(), nil
+}
+
+// Binary returns false, since JSON is sent as text.
+func (JSON) Binary() bool {
+	return false
+}
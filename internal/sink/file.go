@@ -0,0 +1,298 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// rotatedTimeFormat is the format of the timestamp appended to the name of rotated files. It sorts chronologically.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// FileSink appends each entry as a line of JSON (NDJSON) to a file. The file is rotated once it reaches the
+// configured size or age. Rotated files are optionally compressed and only the newest ones are kept.
+type FileSink struct {
+	path             string
+	rotationSize     int64
+	rotationInterval time.Duration
+	maxFiles         int
+	compress         bool
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// rotated passes the rotated files to the housekeeping goroutine, which compresses them and removes old files
+	// one after another, so that the cleanup never sees a file that is still being compressed.
+	rotated chan string
+	// compressions tracks the rotated files that were not handled by the housekeeping goroutine yet.
+	compressions     sync.WaitGroup
+	housekeepingDone chan struct{}
+	queue            *queue
+}
+
+// NewFileSink creates a new FileSink from the given config and opens the file.
+func NewFileSink(conf config.FileConfig) (*FileSink, error) {
+	if conf.Path == "" {
+		return nil, errors.New("no file path configured")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(conf.Path), 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &FileSink{
+		path:             conf.Path,
+		rotationSize:     int64(conf.RotationSizeMB) * 1024 * 1024,
+		rotationInterval: conf.RotationInterval,
+		maxFiles:         conf.MaxFiles,
+		compress:         conf.Gzip,
+		rotated:          make(chan string, 16),
+		housekeepingDone: make(chan struct{}),
+	}
+
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	go s.housekeeping()
+
+	s.queue = newQueue(s.Name(), conf.QueueSize, 1000, time.Second, conf.Policy, s.write)
+
+	return s, nil
+}
+
+// Name returns the name of the sink.
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+// Send queues the entry for writing. It only blocks while the queue is full if the policy is block.
+func (s *FileSink) Send(entry models.Entry) {
+	s.queue.send(entry)
+}
+
+// Errors returns the number of failed writes.
+func (s *FileSink) Errors() uint64 {
+	return s.queue.errors.Load()
+}
+
+// Dropped returns the number of entries that were dropped because the queue was full or writing failed.
+func (s *FileSink) Dropped() uint64 {
+	return s.queue.dropped.Load()
+}
+
+// Close writes the remaining entries, closes the file and waits for running compressions.
+func (s *FileSink) Close() error {
+	s.queue.close()
+	close(s.rotated)
+	<-s.housekeepingDone
+
+	if s.file == nil {
+		return nil
+	}
+
+	return s.file.Close()
+}
+
+// open opens the file for appending. If the file already exists, its size and modification time are taken over.
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	s.file = file
+	s.size = info.Size()
+	s.openedAt = time.Now()
+
+	if s.size > 0 {
+		s.openedAt = info.ModTime()
+	}
+
+	return nil
+}
+
+// write appends the entries to the file and rotates it if necessary. It is only called by the queue's goroutine.
+// If writing fails, the file is truncated to its size before the batch, so that a retry doesn't duplicate entries.
+func (s *FileSink) write(_ context.Context, entries []models.Entry) error {
+	if s.file == nil {
+		// Reopening failed during the last rotation
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	if s.needsRotation() {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	var batch bytes.Buffer
+	for _, entry := range entries {
+		// JSON already ends with a newline
+		batch.Write(entry.JSON())
+	}
+
+	n, err := s.file.Write(batch.Bytes())
+	if err == nil {
+		s.size += int64(n)
+		return nil
+	}
+
+	if n > 0 {
+		if truncateErr := s.file.Truncate(s.size); truncateErr != nil {
+			// The file can't be repaired, so it is reopened and possibly rotated before the next attempt
+			log.Printf("Error while truncating file '%s' after failed write: %s\n", s.path, truncateErr)
+			s.file.Close()
+			s.file = nil
+		}
+	}
+
+	return err
+}
+
+// needsRotation returns true if the file reached the configured size or age.
+func (s *FileSink) needsRotation() bool {
+	if s.size == 0 {
+		return false
+	}
+
+	if s.rotationSize > 0 && s.size >= s.rotationSize {
+		return true
+	}
+
+	return s.rotationInterval > 0 && time.Since(s.openedAt) >= s.rotationInterval
+}
+
+// rotate renames the current file, reopens the original path and compresses and cleans up rotated files.
+// The file is renamed atomically, so readers never see a partially rotated file.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		log.Printf("Error while closing file '%s': %s\n", s.path, err)
+	}
+
+	s.file = nil
+	rotatedPath := s.path + "." + time.Now().UTC().Format(rotatedTimeFormat)
+
+	// Never overwrite a previously rotated file, even if two rotations happen within the same millisecond
+	for i := 1; fileExists(rotatedPath) || fileExists(rotatedPath+".gz"); i++ {
+		rotatedPath = fmt.Sprintf("%s.%s-%d", s.path, time.Now().UTC().Format(rotatedTimeFormat), i)
+	}
+
+	if err := os.Rename(s.path, rotatedPath); err != nil {
+		return fmt.Errorf("failed to rotate file: %w", err)
+	}
+
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	s.compressions.Add(1)
+	s.rotated <- rotatedPath
+
+	return nil
+}
+
+// housekeeping compresses the rotated files and removes old files until rotated is closed.
+func (s *FileSink) housekeeping() {
+	defer close(s.housekeepingDone)
+
+	for rotatedPath := range s.rotated {
+		// The file might have been removed by the cleanup after an earlier rotation already
+		if s.compress && fileExists(rotatedPath) {
+			if err := compressFile(rotatedPath); err != nil {
+				log.Printf("Error while compressing rotated file '%s': %s\n", rotatedPath, err)
+			}
+		}
+
+		s.removeOldFiles()
+		s.compressions.Done()
+	}
+}
+
+// fileExists returns true if a file exists at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile compresses the file with gzip and removes the uncompressed file afterward.
+// The compressed file is written to a temporary file first and renamed once it is complete.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := path + ".gz.tmp"
+
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter := gzip.NewWriter(dst)
+	_, copyErr := io.Copy(gzipWriter, src)
+	closeErr := errors.Join(gzipWriter.Close(), dst.Close())
+
+	if err = errors.Join(copyErr, closeErr); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err = os.Rename(tmpPath, path+".gz"); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// removeOldFiles removes the oldest rotated files, so that at most maxFiles rotated files are kept.
+func (s *FileSink) removeOldFiles() {
+	if s.maxFiles <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(s.path + ".*")
+	if err != nil {
+		return
+	}
+
+	// Ignore files that are currently being compressed
+	matches = slices.DeleteFunc(matches, func(match string) bool {
+		return strings.HasSuffix(match, ".tmp")
+	})
+
+	// The timestamps sort chronologically, so the oldest files come first
+	slices.Sort(matches)
+
+	for len(matches) > s.maxFiles {
+		if err = os.Remove(matches[0]); err != nil {
+			log.Printf("Error while removing rotated file '%s': %s\n", matches[0], err)
+		}
+
+		matches = matches[1:]
+	}
+}
@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestFileSinkRotatesAndRemovesOldFiles(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "certs.ndjson")
+
+	s, err := NewFileSink(config.FileConfig{Path: path, MaxFiles: 2, Gzip: true, QueueSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate after every write
+	s.rotationSize = 1
+
+	for range 4 {
+		if err = s.write(context.Background(), []models.Entry{{MessageType: "certificate_update"}}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Wait for the compression, so that the rotated files are cleaned up in order
+		s.compressions.Wait()
+	}
+
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Fatalf("expected 2 rotated files, got %v", rotated)
+	}
+
+	for _, file := range rotated {
+		if !strings.HasSuffix(file, ".gz") {
+			t.Errorf("expected rotated file %s to be compressed", file)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/serializer"
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaSink produces each entry as JSON or MessagePack message to a Kafka topic. The messages are keyed by the
+// fingerprint of the certificate, so that the same certificate always ends up in the same partition.
+type KafkaSink struct {
+	writer     *kafka.Writer
+	queue      *queue
+	serializer serializer.Serializer
+}
+
+// NewKafkaSink creates a new KafkaSink from the given config.
+func NewKafkaSink(conf config.KafkaConfig) (*KafkaSink, error) {
+	if len(conf.Brokers) == 0 {
+		return nil, errors.New("no kafka brokers configured")
+	}
+
+	if conf.Topic == "" {
+		return nil, errors.New("no kafka topic configured")
+	}
+
+	compression, err := kafkaCompression(conf.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := serializer.ParseFormat(conf.Format)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka format: %w", err)
+	}
+
+	s := &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(conf.Brokers...),
+			Topic:        conf.Topic,
+			Balancer:     &kafka.Hash{},
+			Compression:  compression,
+			BatchSize:    conf.BatchSize,
+			BatchTimeout: 10 * time.Millisecond,
+			// Retries are handled by the queue with backoff
+			MaxAttempts: 1,
+		},
+		serializer: format.Serializer(),
+	}
+	s.queue = newQueue(s.Name(), conf.QueueSize, conf.BatchSize, time.Second, conf.Policy, s.write)
+
+	return s, nil
+}
+
+// kafkaCompression maps the name of a compression codec to the respective kafka.Compression.
+func kafkaCompression(name string) (kafka.Compression, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return 0, nil
+	case "gzip":
+		return kafka.Gzip, nil
+	case "snappy":
+		return kafka.Snappy, nil
+	case "lz4":
+		return kafka.Lz4, nil
+	case "zstd":
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("unknown kafka compression '%s'", name)
+	}
+}
+
+// Name returns the name of the sink.
+func (s *KafkaSink) Name() string {
+	return "kafka"
+}
+
+// Send queues the entry for producing. It only blocks while the queue is full if the policy is block.
+func (s *KafkaSink) Send(entry models.Entry) {
+	s.queue.send(entry)
+}
+
+// Errors returns the number of failed attempts to produce messages.
+func (s *KafkaSink) Errors() uint64 {
+	return s.queue.errors.Load()
+}
+
+// Dropped returns the number of entries that were dropped because the queue was full or producing failed.
+func (s *KafkaSink) Dropped() uint64 {
+	return s.queue.dropped.Load()
+}
+
+// Close produces the remaining entries and closes the connection to Kafka.
+func (s *KafkaSink) Close() error {
+	s.queue.close()
+	return s.writer.Close()
+}
+
+// write produces the entries to Kafka.
+func (s *KafkaSink) write(ctx context.Context, entries []models.Entry) error {
+	messages := make([]kafka.Message, 0, len(entries))
+	for _, entry := range entries {
+		value, err := s.serializer.Marshal(entry)
+		if err != nil {
+			log.Printf("Error while encoding entry for sink '%s': %s\n", s.Name(), err)
+			continue
+		}
+
+		messages = append(messages, kafka.Message{
+			Key:   []byte(entry.Data.LeafCert.Fingerprint),
+			Value: value,
+		})
+	}
+
+	return s.writer.WriteMessages(ctx, messages...)
+}
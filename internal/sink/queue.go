@@ -0,0 +1,191 @@
+package sink
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+const (
+	// maxAttempts is the number of times a batch is written before it is discarded.
+	maxAttempts = 5
+	// minBackoff is the time to wait after the first failed attempt. It is doubled after each further attempt.
+	minBackoff = 500 * time.Millisecond
+	// maxBackoff is the upper limit of the time to wait between two attempts.
+	maxBackoff = 30 * time.Second
+)
+
+// writeFunc writes a batch of entries to the output of a sink.
+type writeFunc func(ctx context.Context, entries []models.Entry) error
+
+// queue decouples a sink from the broadcast manager. Entries are buffered in a bounded channel and written in
+// batches by a separate goroutine. If the channel is full, entries are dropped instead of blocking the broadcast,
+// unless the queue blocks. Failed batches are retried with exponential backoff.
+type queue struct {
+	name          string
+	entries       chan models.Entry
+	batchSize     int
+	flushInterval time.Duration
+	write         writeFunc
+	// block makes send wait for space in the channel instead of dropping the entry (config.PolicyBlock).
+	block bool
+
+	dropped atomic.Uint64
+	errors  atomic.Uint64
+
+	// closedMu guards closed, so that no entries are sent to the closed channel.
+	closedMu sync.RWMutex
+	closed   bool
+
+	// ctx is cancelled when the queue is closed, so that pending retries are aborted.
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newQueue creates a queue and starts its background goroutine. The policy decides whether send drops entries or
+// blocks if the queue is full.
+func newQueue(name string, size, batchSize int, flushInterval time.Duration, policy config.SinkPolicy, write writeFunc) *queue {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	if size < batchSize {
+		size = batchSize
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q := &queue{
+		name:          name,
+		entries:       make(chan models.Entry, size),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		write:         write,
+		block:         policy == config.PolicyBlock,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+
+	go q.run()
+
+	return q
+}
+
+// send adds the entry to the queue. If the queue is full, the entry is dropped or, if the queue blocks, send waits
+// until the goroutine of the queue made space.
+func (q *queue) send(entry models.Entry) {
+	q.closedMu.RLock()
+	defer q.closedMu.RUnlock()
+
+	if q.closed {
+		return
+	}
+
+	if q.block {
+		q.entries <- entry
+		return
+	}
+
+	select {
+	case q.entries <- entry:
+	default:
+		if q.dropped.Add(1)%1000 == 1 {
+			log.Printf("Queue of sink '%s' is full. Dropped entries: %d\n", q.name, q.dropped.Load())
+		}
+	}
+}
+
+// close stops accepting new entries, writes the remaining entries once and waits until the queue is finished.
+func (q *queue) close() {
+	q.closeOnce.Do(func() {
+		q.closedMu.Lock()
+		q.closed = true
+		close(q.entries)
+		q.closedMu.Unlock()
+
+		// Give the remaining entries a chance to be written, but don't wait forever for a broken output
+		select {
+		case <-q.done:
+		case <-time.After(maxBackoff):
+			q.cancel()
+			<-q.done
+		}
+
+		q.cancel()
+	})
+}
+
+// run collects entries into batches and writes them once the batch is full or the flush interval elapsed.
+func (q *queue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Entry, 0, q.batchSize)
+
+	for {
+		select {
+		case entry, ok := <-q.entries:
+			if !ok {
+				q.flush(batch)
+				return
+			}
+
+			batch = append(batch, entry)
+			if len(batch) >= q.batchSize {
+				q.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			q.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush writes the batch, retrying with exponential backoff. Each failed attempt is counted as an error.
+// If all attempts fail, the batch is discarded and counted as dropped.
+func (q *queue) flush(batch []models.Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	backoff := minBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := q.write(q.ctx, batch)
+		if err == nil {
+			return
+		}
+
+		q.errors.Add(1)
+		log.Printf("Error while writing %d entries to sink '%s' (attempt %d/%d): %s\n", len(batch), q.name, attempt, maxAttempts, err)
+
+		if attempt >= maxAttempts {
+			q.dropped.Add(uint64(len(batch)))
+			return
+		}
+
+		select {
+		case <-q.ctx.Done():
+			q.dropped.Add(uint64(len(batch)))
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
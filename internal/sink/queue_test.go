@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestQueueWritesBatchesAndFlushesOnClose(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var batches []int
+
+	q := newQueue("test", 10, 3, time.Hour, config.PolicyDrop, func(_ context.Context, entries []models.Entry) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		batches = append(batches, len(entries))
+
+		return nil
+	})
+
+	for range 7 {
+		q.send(models.Entry{})
+	}
+
+	q.close()
+	q.send(models.Entry{})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(batches) != 3 || batches[0] != 3 || batches[1] != 3 || batches[2] != 1 {
+		t.Errorf("batches = %v, want [3 3 1]", batches)
+	}
+}
+
+func TestQueueRetriesFailedBatches(t *testing.T) {
+	t.Parallel()
+
+	attempts := 0
+
+	q := newQueue("test", 1, 1, time.Hour, config.PolicyDrop, func(_ context.Context, _ []models.Entry) error {
+		attempts++
+		if attempts < 2 {
+			return errors.New("unavailable")
+		}
+
+		return nil
+	})
+
+	q.send(models.Entry{})
+	q.close()
+
+	if attempts != 2 || q.errors.Load() != 1 || q.dropped.Load() != 0 {
+		t.Errorf("attempts = %d, errors = %d, dropped = %d, want 2, 1, 0", attempts, q.errors.Load(), q.dropped.Load())
+	}
+}
+
+func TestQueueBlocksWhenFull(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	var written atomic.Int64
+
+	q := newQueue("test", 1, 1, time.Hour, config.PolicyBlock, func(_ context.Context, entries []models.Entry) error {
+		<-release
+		written.Add(int64(len(entries)))
+
+		return nil
+	})
+
+	// The first entry is taken by the goroutine of the queue, the second fills the queue and the third has to wait
+	sent := make(chan struct{})
+	go func() {
+		for range 3 {
+			q.send(models.Entry{})
+		}
+		close(sent)
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("send didn't block while the queue was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-sent
+	q.close()
+
+	if written.Load() != 3 || q.dropped.Load() != 0 {
+		t.Errorf("written = %d, dropped = %d, want 3, 0", written.Load(), q.dropped.Load())
+	}
+}
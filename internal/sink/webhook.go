@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// WebhookSink posts the entries as JSON array to an HTTP endpoint. Entries are sent once the batch size or the flush
+// interval is reached.
+type WebhookSink struct {
+	url         string
+	bearerToken string
+	client      *http.Client
+	queue       *queue
+}
+
+// NewWebhookSink creates a new WebhookSink from the given config.
+func NewWebhookSink(conf config.WebhookConfig) (*WebhookSink, error) {
+	if webhookURL, err := url.Parse(conf.URL); err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webhook url: '%s'", conf.URL)
+	}
+
+	s := &WebhookSink{
+		url:         conf.URL,
+		bearerToken: conf.BearerToken,
+		client:      &http.Client{Timeout: 30 * time.Second},
+	}
+	s.queue = newQueue(s.Name(), conf.QueueSize, conf.BatchSize, conf.FlushInterval, conf.Policy, s.write)
+
+	return s, nil
+}
+
+// Name returns the name of the sink.
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+// Send queues the entry for posting. It only blocks while the queue is full if the policy is block.
+func (s *WebhookSink) Send(entry models.Entry) {
+	s.queue.send(entry)
+}
+
+// Errors returns the number of failed requests.
+func (s *WebhookSink) Errors() uint64 {
+	return s.queue.errors.Load()
+}
+
+// Dropped returns the number of entries that were dropped because the queue was full or posting failed.
+func (s *WebhookSink) Dropped() uint64 {
+	return s.queue.dropped.Load()
+}
+
+// Close posts the remaining entries.
+func (s *WebhookSink) Close() error {
+	s.queue.close()
+	return nil
+}
+
+// write posts the entries as JSON array to the webhook url. Responses with a status code other than 2xx are errors.
+func (s *WebhookSink) write(ctx context.Context, entries []models.Entry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Read the body, so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected status code: " + resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package tracing
+
+// The tracing package provides optional OpenTelemetry tracing. As long as no tracer is set, Tracer returns nil and
+// callers skip creating spans entirely, so that disabled tracing doesn't cost any allocations.
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/letrics/certstream-server-go"
+
+// tracerHolder wraps the tracer, since atomic.Pointer can't point to an interface directly.
+type tracerHolder struct {
+	tracer trace.Tracer
+}
+
+var currentTracer atomic.Pointer[tracerHolder]
+
+// Init creates an OTLP exporter according to the config and sets the tracer.
+// It returns a function that flushes the remaining spans and shuts the exporter down.
+func Init(ctx context.Context, conf config.TracingConfig) (func(context.Context) error, error) {
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(conf.Endpoint)}
+	if conf.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(conf.ServiceName),
+			semconv.ServiceVersion(config.Version),
+		)),
+	)
+
+	SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// SetTracerProvider sets the provider of the tracer used for all spans. A nil provider disables tracing.
+func SetTracerProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		currentTracer.Store(nil)
+		return
+	}
+
+	currentTracer.Store(&tracerHolder{tracer: provider.Tracer(instrumentationName, trace.WithInstrumentationVersion(config.Version))})
+}
+
+// Tracer returns the tracer or nil if tracing is disabled.
+func Tracer() trace.Tracer {
+	holder := currentTracer.Load()
+	if holder == nil {
+		return nil
+	}
+
+	return holder.tracer
+}
+
+// transport creates a span for each HTTP request sent to a CT log.
+type transport struct {
+	base http.RoundTripper
+}
+
+// WrapTransport returns a transport that creates a span for each request if tracing is enabled.
+// If tracing is disabled, requests are passed to the base transport directly.
+func WrapTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &transport{base: base}
+}
+
+// RoundTrip sends the request and records its url, method and status code on the span.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tracer := Tracer()
+	if tracer == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := tracer.Start(req.Context(), "HTTP "+req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.full", req.URL.String()),
+		),
+	)
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+
+	return resp, nil
+}
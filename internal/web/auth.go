@@ -0,0 +1,85 @@
+package web
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// apiKeyLabelKey is the context key of the label of the API key a request was authenticated with.
+type apiKeyLabelKey struct{}
+
+// APIKeyAuth returns a middleware that rejects requests without a valid API key with 401 Unauthorized.
+// The key is read from the "Authorization: Bearer <key>" header or the "api_key" query parameter.
+// Since the middleware runs before the websocket upgrade, unauthenticated clients never complete the handshake.
+func APIKeyAuth(keys []config.APIKeyConfig) func(next http.Handler) http.Handler {
+	log.Printf("API key authentication enabled with %d keys\n", len(keys))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			label, ok := authenticate(keys, requestAPIKey(r))
+			if !ok {
+				log.Printf("Rejecting request from %s without valid API key\n", r.RemoteAddr)
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyLabelKey{}, label)))
+		})
+	}
+}
+
+// requestAPIKey returns the API key presented by the client. The Authorization header takes precedence over the
+// query parameter, which is meant for clients that can't set headers, such as browsers.
+func requestAPIKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	return r.URL.Query().Get("api_key")
+}
+
+// authenticate returns the label of the API key matching the given key. The keys are compared in constant time,
+// so that the response time doesn't reveal how much of a key was guessed correctly.
+func authenticate(keys []config.APIKeyConfig, key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	for _, apiKey := range keys {
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 {
+			return apiKey.Label, true
+		}
+	}
+
+	return "", false
+}
+
+// redactAPIKey returns the url with the value of the "api_key" query parameter replaced, so it can be logged.
+func redactAPIKey(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("api_key") {
+		return u.String()
+	}
+
+	query.Set("api_key", "REDACTED")
+
+	redacted := *u
+	redacted.RawQuery = query.Encode()
+
+	return redacted.String()
+}
+
+// apiKeyLabel returns the label of the API key the request was authenticated with, or an empty string if
+// authentication is disabled.
+func apiKeyLabel(r *http.Request) string {
+	label, _ := r.Context().Value(apiKeyLabelKey{}).(string)
+	return label
+}
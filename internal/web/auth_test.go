@@ -0,0 +1,51 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestAPIKeyAuth(t *testing.T) {
+	t.Parallel()
+
+	keys := []config.APIKeyConfig{{Key: "secret1", Label: "team-a"}, {Key: "secret2", Label: "team-b"}}
+
+	var gotLabel string
+
+	handler := APIKeyAuth(keys)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		gotLabel = apiKeyLabel(r)
+	}))
+
+	tests := []struct {
+		target     string
+		header     string
+		wantStatus int
+		wantLabel  string
+	}{
+		{"/full-stream", "Bearer secret2", http.StatusOK, "team-b"},
+		{"/full-stream?api_key=secret1", "", http.StatusOK, "team-a"},
+		{"/full-stream", "", http.StatusUnauthorized, ""},
+		{"/full-stream?api_key=secret", "", http.StatusUnauthorized, ""},
+		{"/full-stream", "Basic secret1", http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		gotLabel = ""
+
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus || gotLabel != tt.wantLabel {
+			t.Errorf("%s with header '%s': got status %d and label '%s', want %d and '%s'",
+				tt.target, tt.header, rec.Code, gotLabel, tt.wantStatus, tt.wantLabel)
+		}
+	}
+}
@@ -1,47 +1,210 @@
 package web
 
 import (
+	"context"
+	"github.com/letrics/certstream-server-go/internal/dedup"
+	"github.com/letrics/certstream-server-go/internal/tracing"
+	"github.com/letrics/certstream-server-go/pkg/config"
 	"github.com/letrics/certstream-server-go/pkg/models"
 	"log"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// Sink is an output of the BroadcastManager, such as the websocket clients or a message queue. Each sink buffers the
+// entries on its own. By default, it drops them if it can't keep up, so that a slow sink neither stalls the watcher
+// nor the other sinks. Sinks with config.PolicyBlock apply backpressure instead, so the broadcast runs at the speed
+// of the slowest blocking sink. Any combination of sinks can be registered.
+type Sink interface {
+	// Name returns a short, unique name of the sink, used for logs and metrics.
+	Name() string
+	// Send passes an entry to the sink. It must only block if the sink applies backpressure.
+	Send(entry models.Entry)
+	// Errors returns the number of errors that occurred while writing to the output of the sink.
+	Errors() uint64
+	// Dropped returns the number of entries that could not be written to the output of the sink.
+	Dropped() uint64
+	// Close writes the remaining entries and releases all resources of the sink.
+	Close() error
+}
+
 type BroadcastManager struct {
-	Broadcast  chan models.Entry
-	clients    []*client
-	clientLock sync.RWMutex
+	Broadcast chan models.Entry
+	// shards each own a subset of the clients. They are created by startShards before any client registers.
+	// Nil if the websocket output is disabled.
+	shards []*broadcastShard
+	// registerLock serializes the registration of clients, so that they are spread evenly across the shards.
+	registerLock sync.Mutex
+
+	sinks    []Sink
+	sinkLock sync.RWMutex
+
+	// websocketDropped is the number of entries dropped for all websocket clients, since their buffers were full.
+	websocketDropped atomic.Uint64
+
+	// dedup is used to suppress certificates that were already broadcast recently. Nil if deduplication is disabled.
+	dedup          *dedup.Cache
+	duplicateCerts uint64
+	// persistentDedup suppresses certificates that were already broadcast before a restart. Nil if it is disabled.
+	persistentDedup     *dedup.Bloom
+	persistentDedupFile string
+	// stopFlushing stops the goroutine that saves the persistent dedup filter, which closes flushDone once it returned.
+	stopFlushing chan struct{}
+	flushDone    chan struct{}
+
+	// latest holds the most recently broadcast entries for the latest endpoint.
+	latest *latestBuffer
+	// counts counts the broadcast entries per log within rolling time windows for the counts endpoint.
+	counts *entryCounter
+	// running indicates whether the broadcaster goroutine is running.
+	running atomic.Bool
+	// broadcasting is done once the broadcaster goroutine returned, see WaitBroadcast.
+	broadcasting sync.WaitGroup
+}
+
+// startShards creates the given number of broadcast shards and starts their goroutines.
+func (bm *BroadcastManager) startShards(count int) {
+	bm.shards = make([]*broadcastShard, max(1, count))
+
+	for i := range bm.shards {
+		bm.shards[i] = &broadcastShard{
+			entries: make(chan *sharedEntry, shardQueueSize),
+			dropped: func() { bm.websocketDropped.Add(1) },
+		}
+
+		go bm.shards[i].run()
+	}
 }
 
-// registerClient adds a client to the list of clients of the BroadcastManager.
+// registerClient adds a client to the shard with the fewest clients.
 // The client will receive certificate broadcasts right after registration.
 func (bm *BroadcastManager) registerClient(c *client) {
-	bm.clientLock.Lock()
-	bm.clients = append(bm.clients, c)
-	log.Printf("Clients: %d, Capacity: %d\n", len(bm.clients), cap(bm.clients))
-	bm.clientLock.Unlock()
+	bm.registerLock.Lock()
+	defer bm.registerLock.Unlock()
+
+	target, total := bm.shards[0], 0
+	for _, shard := range bm.shards {
+		count := shard.clientCount()
+		total += count
+
+		if count < target.clientCount() {
+			target = shard
+		}
+	}
+
+	target.add(c)
+	log.Printf("Clients: %d\n", total+1)
 }
 
-// unregisterClient removes a client from the list of clients of the BroadcastManager.
+// unregisterClient removes a client from its shard.
 // The client will no longer receive certificate broadcasts right after unregistering.
 func (bm *BroadcastManager) unregisterClient(c *client) {
-	bm.clientLock.Lock()
+	if c.shard != nil {
+		c.shard.remove(c)
+	}
+}
+
+// forEachClient calls fn for each registered client.
+func (bm *BroadcastManager) forEachClient(fn func(c *client)) {
+	for _, shard := range bm.shards {
+		shard.lock.RLock()
+		for _, c := range shard.clients {
+			fn(c)
+		}
+		shard.lock.RUnlock()
+	}
+}
+
+// ShardBacklogs returns the number of entries waiting to be sent to the clients of each broadcast shard.
+func (bm *BroadcastManager) ShardBacklogs() []int {
+	backlogs := make([]int, len(bm.shards))
+	for i, shard := range bm.shards {
+		backlogs[i] = len(shard.entries)
+	}
+
+	return backlogs
+}
 
-	for i, client := range bm.clients {
-		if c == client {
-			// Copy the last element of the slice to the position of the removed element
-			// Then remove the last element by re-slicing
-			bm.clients[i] = bm.clients[len(bm.clients)-1]
-			bm.clients[len(bm.clients)-1] = nil
-			bm.clients = bm.clients[:len(bm.clients)-1]
+// RegisterSink adds a sink to the BroadcastManager. The sink will receive all entries right after registration.
+func (bm *BroadcastManager) RegisterSink(s Sink) {
+	bm.sinkLock.Lock()
+	defer bm.sinkLock.Unlock()
 
-			// Close the broadcast channel of the client, otherwise this leads to a memory leak
-			close(c.broadcastChan)
+	log.Printf("Registering sink '%s'\n", s.Name())
+	bm.sinks = append(bm.sinks, s)
+}
 
-			break
+// ReplaceSink replaces the sink with the given name by s and closes the old sink, which writes its remaining entries.
+// If there is no sink with that name, s is added. If s is nil, the sink is only removed.
+func (bm *BroadcastManager) ReplaceSink(name string, s Sink) {
+	bm.sinkLock.Lock()
+	var old Sink
+	bm.sinks = slices.DeleteFunc(bm.sinks, func(sink Sink) bool {
+		if sink.Name() == name {
+			old = sink
+			return true
 		}
+
+		return false
+	})
+
+	if s != nil {
+		log.Printf("Registering sink '%s'\n", s.Name())
+		bm.sinks = append(bm.sinks, s)
 	}
+	bm.sinkLock.Unlock()
 
-	bm.clientLock.Unlock()
+	if old == nil {
+		return
+	}
+
+	log.Printf("Closing sink '%s'\n", name)
+
+	if err := old.Close(); err != nil {
+		log.Printf("Error while closing sink '%s': %s\n", name, err)
+	}
+}
+
+// CloseSinks removes all sinks from the BroadcastManager and closes them.
+func (bm *BroadcastManager) CloseSinks() {
+	bm.sinkLock.Lock()
+	sinks := bm.sinks
+	bm.sinks = nil
+	bm.sinkLock.Unlock()
+
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			log.Printf("Error while closing sink '%s': %s\n", s.Name(), err)
+		}
+	}
+}
+
+// GetSinkErrors returns the number of errors for each sink.
+func (bm *BroadcastManager) GetSinkErrors() map[string]uint64 {
+	return bm.sinkStats(Sink.Errors)
+}
+
+// GetSinkDropped returns the number of dropped entries for each sink.
+func (bm *BroadcastManager) GetSinkDropped() map[string]uint64 {
+	return bm.sinkStats(Sink.Dropped)
+}
+
+// sinkStats returns the value of the given stat function for each sink, keyed by the name of the sink.
+func (bm *BroadcastManager) sinkStats(stat func(Sink) uint64) map[string]uint64 {
+	bm.sinkLock.RLock()
+	defer bm.sinkLock.RUnlock()
+
+	stats := make(map[string]uint64, len(bm.sinks))
+	for _, s := range bm.sinks {
+		stats[s.Name()] = stat(s)
+	}
+
+	return stats
 }
 
 // ClientFullCount returns the current number of clients connected to the service on the `full` endpoint.
@@ -62,66 +225,166 @@ func (bm *BroadcastManager) ClientDomainsCount() (count int64) {
 // clientCountByType returns the current number of clients connected to the service on the endpoint matching
 // the specified SubscriptionType.
 func (bm *BroadcastManager) clientCountByType(subType SubscriptionType) (count int64) {
-	bm.clientLock.RLock()
-	defer bm.clientLock.RUnlock()
-
-	for _, c := range bm.clients {
+	bm.forEachClient(func(c *client) {
 		if c.subType == subType {
 			count++
 		}
-	}
+	})
 
 	return count
 }
 
-func (bm *BroadcastManager) GetSkippedCerts() map[string]uint64 {
-	bm.clientLock.RLock()
-	defer bm.clientLock.RUnlock()
+// ClientCountByAPIKey returns the current number of clients per label of the API key they authenticated with.
+// Clients that connected without authentication are not included.
+func (bm *BroadcastManager) ClientCountByAPIKey() map[string]int64 {
+	counts := make(map[string]int64)
+	bm.forEachClient(func(c *client) {
+		if c.apiKeyLabel != "" {
+			counts[c.apiKeyLabel]++
+		}
+	})
 
-	skippedCerts := make(map[string]uint64, len(bm.clients))
-	for _, c := range bm.clients {
-		skippedCerts[c.name] = c.skippedCerts
-	}
+	return counts
+}
+
+func (bm *BroadcastManager) GetSkippedCerts() map[string]uint64 {
+	skippedCerts := make(map[string]uint64)
+	bm.forEachClient(func(c *client) {
+		skippedCerts[c.name] = c.skippedCerts.Load()
+	})
 
 	return skippedCerts
 }
 
+// GetWebsocketDropped returns the number of entries dropped for all websocket clients because their buffers were full.
+func (bm *BroadcastManager) GetWebsocketDropped() uint64 {
+	return bm.websocketDropped.Load()
+}
+
+// QueueLength returns the number of entries waiting to be broadcast.
+func (bm *BroadcastManager) QueueLength() int {
+	return len(bm.Broadcast)
+}
+
+// GetDuplicateCerts returns the number of certificates that were not broadcast because they were already seen recently.
+func (bm *BroadcastManager) GetDuplicateCerts() uint64 {
+	return atomic.LoadUint64(&bm.duplicateCerts)
+}
+
+// IsRunning returns true if the broadcaster goroutine is running, i.e. entries are dispatched to the clients.
+func (bm *BroadcastManager) IsRunning() bool {
+	return bm.running.Load()
+}
+
+// WaitBroadcast blocks until the Broadcast channel was closed and all remaining entries were passed to the clients
+// and sinks, so that the sinks can be closed without losing entries.
+func (bm *BroadcastManager) WaitBroadcast() {
+	bm.broadcasting.Wait()
+}
+
 // broadcaster is run in a goroutine and handles the dispatching of entries to clients.
 func (bm *BroadcastManager) broadcaster() {
-	for {
-		var data []byte
-
-		entry := <-bm.Broadcast
-		dataLite := entry.JSONLite()
-		dataFull := entry.JSON()
-		dataDomain := entry.JSONDomains()
-
-		bm.clientLock.RLock()
-
-		for _, c := range bm.clients {
-			switch c.subType {
-			case SubTypeLite:
-				data = dataLite
-			case SubTypeFull:
-				data = dataFull
-			case SubTypeDomain:
-				data = dataDomain
-			default:
-				log.Printf("Unknown subscription type '%d' for client '%s'. Skipping this client!\n", c.subType, c.name)
-				continue
-			}
+	bm.running.Store(true)
+	defer bm.running.Store(false)
+
+	for entry := range bm.Broadcast {
+		// Certificates are often logged to multiple CT logs - only broadcast the first occurrence
+		if bm.isDuplicate(entry.Data.LeafCert.Fingerprint) {
+			atomic.AddUint64(&bm.duplicateCerts, 1)
+			continue
+		}
+
+		bm.broadcastEntry(entry)
+	}
+}
+
+// isDuplicate returns true if the certificate with the given fingerprint was already broadcast recently or,
+// if the persistent filter is enabled, before the last restart.
+func (bm *BroadcastManager) isDuplicate(fingerprint string) bool {
+	if bm.dedup == nil {
+		return false
+	}
 
+	if bm.dedup.Seen(fingerprint, time.Now()) {
+		return true
+	}
+
+	return bm.persistentDedup != nil && bm.persistentDedup.Seen(fingerprint)
+}
+
+// enablePersistentDedup loads the persistent dedup filter from its file and saves it in the configured interval.
+func (bm *BroadcastManager) enablePersistentDedup(conf config.PersistentDedupConfig) {
+	bloom, err := dedup.LoadBloom(conf.File, conf.Capacity, conf.FalsePositiveRate)
+	if err != nil {
+		log.Printf("Starting with an empty dedup filter: %s\n", err)
+	}
+
+	bm.persistentDedup = bloom
+	bm.persistentDedupFile = conf.File
+	bm.stopFlushing = make(chan struct{})
+	bm.flushDone = make(chan struct{})
+
+	go func() {
+		defer close(bm.flushDone)
+
+		ticker := time.NewTicker(conf.FlushInterval)
+		defer ticker.Stop()
+
+		for {
 			select {
-			case c.broadcastChan <- data:
-			default:
-				// Default case is executed if the client's broadcast channel is full.
-				c.skippedCerts++
-				if c.skippedCerts%1000 == 1 {
-					log.Printf("Not providing client '%s' with cert because client's buffer is full. The client can't keep up. Skipped certs: %d\n", c.name, c.skippedCerts)
-				}
+			case <-ticker.C:
+				bm.savePersistentDedup()
+			case <-bm.stopFlushing:
+				return
 			}
 		}
+	}()
+}
+
+// ClosePersistentDedup stops saving the persistent dedup filter in the configured interval and saves it a last time.
+// It is a no-op if the filter is disabled.
+func (bm *BroadcastManager) ClosePersistentDedup() {
+	if bm.stopFlushing != nil {
+		close(bm.stopFlushing)
+		<-bm.flushDone
+		bm.stopFlushing = nil
+	}
+
+	bm.savePersistentDedup()
+}
+
+// savePersistentDedup saves the persistent dedup filter to its file. It is a no-op if the filter is disabled.
+func (bm *BroadcastManager) savePersistentDedup() {
+	if bm.persistentDedup == nil {
+		return
+	}
+
+	if err := bm.persistentDedup.Save(bm.persistentDedupFile); err != nil {
+		log.Printf("Error while saving dedup filter: %s\n", err)
+	}
+}
+
+// broadcastEntry passes the entry to all sinks. If tracing is enabled, the fan-out is recorded in a span.
+func (bm *BroadcastManager) broadcastEntry(entry models.Entry) {
+	if tracer := tracing.Tracer(); tracer != nil {
+		_, span := tracer.Start(context.Background(), "certstream.broadcast", trace.WithAttributes(
+			attribute.String("ct.log.url", entry.Data.Source.URL),
+			attribute.Int64("ct.entry.index", int64(entry.Data.CertIndex)),
+		))
+		defer span.End()
+	}
+
+	if bm.latest != nil {
+		bm.latest.add(entry)
+	}
+
+	if bm.counts != nil {
+		bm.counts.add(entry.Data.Source.Name, time.Now())
+	}
 
-		bm.clientLock.RUnlock()
+	bm.sinkLock.RLock()
+	for _, s := range bm.sinks {
+		s.Send(entry)
 	}
+	bm.sinkLock.RUnlock()
 }
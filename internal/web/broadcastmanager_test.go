@@ -0,0 +1,81 @@
+package web
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestBroadcastShards(t *testing.T) {
+	t.Parallel()
+
+	bm := BroadcastManager{Broadcast: make(chan models.Entry)}
+	bm.enableWebsocket(2, config.PolicyDrop)
+
+	clients := make([]*client, 4)
+	for i := range clients {
+		clients[i] = newClient(nil, SubTypeDomain, clientOptions{}, "client"+strconv.Itoa(i), 10)
+		bm.registerClient(clients[i])
+	}
+
+	// The clients are spread evenly across the shards
+	for i, shard := range bm.shards {
+		if got := shard.clientCount(); got != 2 {
+			t.Errorf("shard %d has %d clients, want 2", i, got)
+		}
+	}
+
+	go bm.broadcaster()
+
+	entries := make([]models.Entry, 5)
+	for i := range entries {
+		entries[i].Data.LeafCert.AllDomains = []string{strconv.Itoa(i) + ".example"}
+		bm.Broadcast <- entries[i]
+	}
+
+	close(bm.Broadcast)
+
+	// Each client receives all entries in order
+	for _, c := range clients {
+		for i := range entries {
+			want := string(entries[i].JSONDomains())
+			if got := string(<-c.broadcastChan); got != want {
+				t.Errorf("%s: entry %d = %s, want %s", c.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestClosePersistentDedup(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "ct_dedup.bloom")
+
+	bm := BroadcastManager{}
+	bm.enablePersistentDedup(config.PersistentDedupConfig{
+		Enabled:           true,
+		File:              file,
+		Capacity:          100,
+		FalsePositiveRate: 0.01,
+		FlushInterval:     time.Hour,
+	})
+
+	flushDone := bm.flushDone
+	bm.ClosePersistentDedup()
+
+	// The flush goroutine returned and the filter was saved a last time
+	select {
+	case <-flushDone:
+	default:
+		t.Error("flush goroutine still running")
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		t.Errorf("filter was not saved: %v", err)
+	}
+}
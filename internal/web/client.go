@@ -3,9 +3,13 @@ package web
 import (
 	"log"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/internal/serializer"
+	"github.com/letrics/certstream-server-go/pkg/models"
 )
 
 const (
@@ -16,28 +20,76 @@ const (
 
 type SubscriptionType int
 
+// clientOptions contains the settings a client requested via the query parameters when connecting.
+type clientOptions struct {
+	fullPayload  bool
+	domainFilter *certificatetransparency.DomainFilter
+	// domains are the "domain" parameters the domain filter was created from.
+	domains     []string
+	apiKeyLabel string
+	format      serializer.Format
+	fields      models.Fields
+}
+
+// nextClientID is the ID of the last connected client. IDs are not reused while the server is running.
+var nextClientID atomic.Uint64
+
 // client represents a single client's connection to the server.
 type client struct {
+	// id identifies the client in the clients endpoint.
+	id            uint64
 	conn          *websocket.Conn
 	broadcastChan chan []byte
 	name          string
 	subType       SubscriptionType
-	skippedCerts  uint64
+	// fullPayload indicates whether the client receives the certificate details that are not part of the default payload.
+	fullPayload bool
+	// domainFilter restricts the entries sent to the client to the requested domains. Nil if the client requested all entries.
+	domainFilter *certificatetransparency.DomainFilter
+	// domains are the domains the client subscribed to. Empty if the client requested all entries.
+	domains []string
+	// apiKeyLabel is the label of the API key the client authenticated with. Empty if authentication is disabled.
+	apiKeyLabel string
+	// format is the wire format the entries are encoded in.
+	format serializer.Format
+	// fields are the fields of the entries sent to the client. All fields are sent if none were selected.
+	fields       models.Fields
+	skippedCerts atomic.Uint64
+	// bytesSent is the number of bytes of the entries written to the connection.
+	bytesSent   atomic.Uint64
+	connectedAt time.Time
+	// shard is the broadcast shard that sends the entries to the client. Nil until the client is registered.
+	shard *broadcastShard
+	// release frees the slot of the client in the connection limits. Nil if the client is not limited.
+	release func()
 }
 
-func newClient(conn *websocket.Conn, subType SubscriptionType, name string, certBufferSize int) *client {
+func newClient(conn *websocket.Conn, subType SubscriptionType, options clientOptions, name string, certBufferSize int) *client {
 	return &client{
+		id:            nextClientID.Add(1),
 		conn:          conn,
 		broadcastChan: make(chan []byte, certBufferSize),
 		name:          name,
 		subType:       subType,
+		fullPayload:   options.fullPayload,
+		domainFilter:  options.domainFilter,
+		domains:       options.domains,
+		apiKeyLabel:   options.apiKeyLabel,
+		format:        options.format,
+		fields:        options.fields,
+		connectedAt:   time.Now(),
 	}
 }
 
 // Each client has a broadcastHandler that runs in the background and sends out the broadcast messages to the client.
 func (c *client) broadcastHandler() {
 	writeWait := 60 * time.Second
-	pingTicker := time.NewTicker(30 * time.Second)
+
+	messageType := websocket.TextMessage
+	if c.format.Serializer().Binary() {
+		messageType = websocket.BinaryMessage
+	}
+	pingTicker := time.NewTicker(pingInterval)
 
 	defer func() {
 		log.Println("Closing broadcast handler for client:", c.conn.RemoteAddr())
@@ -57,20 +109,27 @@ func (c *client) broadcastHandler() {
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
-		case message := <-c.broadcastChan:
+		case message, ok := <-c.broadcastChan:
+			// The channel is closed once the client is unregistered
+			if !ok {
+				return
+			}
+
 			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
+			w, err := c.conn.NextWriter(messageType)
 			if err != nil {
 				log.Printf("Error while getting next writer: %v\n", err)
 				return
 			}
 
-			_, writeErr := w.Write(message)
+			n, writeErr := w.Write(message)
 			if writeErr != nil {
 				log.Printf("Error while writing: %v\n", writeErr)
 			}
 
+			c.bytesSent.Add(uint64(n))
+
 			if closeErr := w.Close(); closeErr != nil {
 				log.Printf("Error while closing: %v\n", closeErr)
 				return
@@ -81,14 +140,19 @@ func (c *client) broadcastHandler() {
 
 // listenWebsocket is running in the background on a goroutine and listens for messages from the client.
 // It responds to ping messages with a pong message. It closes the connection if the client sends
-// a close message or no ping is received within 65 seconds.
+// a close message or neither a ping nor a pong is received within pingInterval + pongTimeout, which reaps
+// half-open connections, e.g. of clients behind a NAT that dropped the connection.
 func (c *client) listenWebsocket() {
 	defer func() {
 		_ = c.conn.Close()
 		ClientHandler.unregisterClient(c)
+
+		if c.release != nil {
+			c.release()
+		}
 	}()
 
-	readWait := 65 * time.Second
+	readWait := pingInterval + pongTimeout
 
 	c.conn.SetReadLimit(512)
 	_ = c.conn.SetReadDeadline(time.Now().Add(readWait))
@@ -121,7 +185,7 @@ func (c *client) listenWebsocket() {
 			}
 
 			if strings.Contains(strings.ToLower(readErr.Error()), "i/o timeout") {
-				log.Printf("No ping received from client: %v\n", c.conn.RemoteAddr())
+				log.Printf("No ping or pong received from client: %v\n", c.conn.RemoteAddr())
 				closeMessage := websocket.FormatCloseMessage(websocket.CloseNoStatusReceived, "No ping received!")
 				c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(5*time.Second)) //nolint:errcheck
 			} else if strings.Contains(strings.ToLower(readErr.Error()), "an existing connection was forcibly closed by the remote host") {
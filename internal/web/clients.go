@@ -0,0 +1,130 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// ClientInfo describes a connected websocket client for the clients endpoint.
+type ClientInfo struct {
+	// ID identifies the client, e.g. to disconnect it. IDs are not reused while the server is running.
+	ID          uint64    `json:"id"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// Endpoint is the stream the client subscribed to: full, lite or domains-only.
+	Endpoint string `json:"endpoint"`
+	// Domains are the domains the client subscribed to. Empty if the client receives all entries.
+	Domains []string `json:"domains,omitempty"`
+	// Fields are the fields the client selected. Empty if the client receives all fields.
+	Fields      string `json:"fields,omitempty"`
+	Format      string `json:"format"`
+	APIKeyLabel string `json:"api_key_label,omitempty"`
+	BytesSent   uint64 `json:"bytes_sent"`
+	// QueueLength is the number of entries waiting to be sent to the client. A queue that stays at QueueCapacity
+	// indicates a client that can't keep up.
+	QueueLength   int    `json:"queue_length"`
+	QueueCapacity int    `json:"queue_capacity"`
+	SkippedCerts  uint64 `json:"skipped_certs"`
+}
+
+// endpointNames are the names of the subscription types in the clients endpoint.
+var endpointNames = map[SubscriptionType]string{
+	SubTypeFull:   "full",
+	SubTypeLite:   "lite",
+	SubTypeDomain: "domains-only",
+}
+
+// Clients returns a snapshot of all connected websocket clients, ordered by the time they connected.
+func (bm *BroadcastManager) Clients() []ClientInfo {
+	clients := []ClientInfo{}
+	bm.forEachClient(func(c *client) {
+		clients = append(clients, ClientInfo{
+			ID:            c.id,
+			RemoteAddr:    c.name,
+			ConnectedAt:   c.connectedAt,
+			Endpoint:      endpointNames[c.subType],
+			Domains:       c.domains,
+			Fields:        c.fields.String(),
+			Format:        c.format.String(),
+			APIKeyLabel:   c.apiKeyLabel,
+			BytesSent:     c.bytesSent.Load(),
+			QueueLength:   len(c.broadcastChan),
+			QueueCapacity: cap(c.broadcastChan),
+			SkippedCerts:  c.skippedCerts.Load(),
+		})
+	})
+
+	slices.SortFunc(clients, func(a, b ClientInfo) int {
+		return a.ConnectedAt.Compare(b.ConnectedAt)
+	})
+
+	return clients
+}
+
+// Disconnect closes the connection of the client with the given ID with a close frame and removes the client, which
+// discards the entries still waiting to be sent to it. It returns false if no such client is connected.
+func (bm *BroadcastManager) Disconnect(id uint64) bool {
+	var target *client
+	bm.forEachClient(func(c *client) {
+		if c.id == id {
+			target = c
+		}
+	})
+
+	if target == nil {
+		return false
+	}
+
+	log.Printf("Disconnecting client '%s' (ID %d)\n", target.name, target.id)
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "disconnected by administrator")
+	_ = target.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(5*time.Second))
+
+	// Unregistering closes the broadcast channel, which stops the broadcast handler. Closing the connection stops the
+	// listener, which releases the slot of the client in the connection limits.
+	bm.unregisterClient(target)
+	_ = target.conn.Close()
+
+	return true
+}
+
+// RegisterClients registers the clients endpoint at the given url. GET lists the connected websocket clients and
+// POST to <url>/<id>/disconnect disconnects a client. It responds with 404 if the client is not connected.
+// If keys are given, both routes require one of them, see APIKeyAuth.
+func (ws *WebServer) RegisterClients(url string, keys []config.APIKeyConfig) {
+	routes := chi.Router(ws.routes)
+	if len(keys) > 0 {
+		routes = ws.routes.With(APIKeyAuth(keys))
+	}
+
+	routes.Get(url, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(ClientHandler.Clients()); err != nil {
+			log.Println("Error while encoding clients: ", err)
+		}
+	})
+
+	routes.Post(url+"/{id}/disconnect", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid client ID", http.StatusBadRequest)
+			return
+		}
+
+		if !ClientHandler.Disconnect(id) {
+			http.Error(w, "Client not found", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
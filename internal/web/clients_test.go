@@ -0,0 +1,109 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// connectClient registers a client at the ClientHandler whose websocket connection is backed by a test server and
+// returns the other end of the connection.
+func connectClient(t *testing.T) (*client, *websocket.Conn) {
+	t.Helper()
+
+	conns := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conns <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	remote, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = remote.Close() })
+
+	c := newClient(<-conns, SubTypeFull, clientOptions{}, "client", 10)
+	ClientHandler.registerClient(c)
+
+	return c, remote
+}
+
+func TestRegisterClients(t *testing.T) {
+	// The handlers use the global ClientHandler
+	ClientHandler.startShards(1)
+
+	c, remote := connectClient(t)
+	id := strconv.FormatUint(c.id, 10)
+
+	ws := &WebServer{routes: chi.NewRouter()}
+	ws.RegisterClients("/clients", []config.APIKeyConfig{{Key: "secret", Label: "admin"}})
+
+	request := func(method, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+
+		rec := httptest.NewRecorder()
+		ws.routes.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := request(http.MethodGet, "/clients", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("listing the clients: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var clients []ClientInfo
+	if err := json.NewDecoder(rec.Body).Decode(&clients); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(clients) != 1 || clients[0].ID != c.id || clients[0].Endpoint != "full" {
+		t.Errorf("got clients %+v, want the connected client", clients)
+	}
+
+	tests := []struct {
+		method     string
+		target     string
+		key        string
+		wantStatus int
+	}{
+		{http.MethodGet, "/clients", "", http.StatusUnauthorized},
+		{http.MethodPost, "/clients/" + id + "/disconnect", "", http.StatusUnauthorized},
+		{http.MethodPost, "/clients/" + id + "/disconnect", "wrong", http.StatusUnauthorized},
+		{http.MethodPost, "/clients/abc/disconnect", "secret", http.StatusBadRequest},
+		{http.MethodPost, "/clients/" + strconv.FormatUint(c.id+1, 10) + "/disconnect", "secret", http.StatusNotFound},
+		{http.MethodPost, "/clients/" + id + "/disconnect", "secret", http.StatusNoContent},
+		// The client is gone after it was disconnected
+		{http.MethodPost, "/clients/" + id + "/disconnect", "secret", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if rec := request(tt.method, tt.target, tt.key); rec.Code != tt.wantStatus {
+			t.Errorf("%s %s with key '%s': got status %d, want %d", tt.method, tt.target, tt.key, rec.Code, tt.wantStatus)
+		}
+	}
+
+	// The disconnected client receives a close frame
+	var closeErr *websocket.CloseError
+	if _, _, err := remote.ReadMessage(); !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("got error %v, want close frame with status %d", err, websocket.ClosePolicyViolation)
+	}
+}
@@ -0,0 +1,203 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// countBuckets is the maximum number of buckets per window. The count of a window covers the full buckets of the
+// window and the current, partial bucket, so it is accurate to about 1/countBuckets of the window.
+const countBuckets = 60
+
+// windowCounter counts the entries per log within a rolling time window. The window is split into buckets,
+// which are cleared once they fall out of the window.
+type windowCounter struct {
+	window     time.Duration
+	bucketSize time.Duration
+	// last is the number of the bucket that was written last, counted since the unix epoch.
+	last  int64
+	total []uint64
+	logs  map[string][]uint64
+}
+
+func newWindowCounter(window time.Duration) *windowCounter {
+	buckets := max(1, min(countBuckets, int(window/time.Second)))
+
+	return &windowCounter{
+		window:     window,
+		bucketSize: window / time.Duration(buckets),
+		total:      make([]uint64, buckets),
+		logs:       make(map[string][]uint64),
+	}
+}
+
+// advance clears the buckets that fell out of the window since the last call and returns the index of the current
+// bucket.
+func (c *windowCounter) advance(now time.Time) int {
+	current := now.UnixNano() / int64(c.bucketSize)
+	buckets := int64(len(c.total))
+
+	// Clear at most all buckets, even if the counter wasn't used for longer than the window
+	for bucket := max(c.last+1, current-buckets+1); bucket <= current; bucket++ {
+		index := bucket % buckets
+		c.total[index] = 0
+
+		for _, counts := range c.logs {
+			counts[index] = 0
+		}
+	}
+
+	c.last = max(c.last, current)
+
+	return int(current % buckets)
+}
+
+// add counts an entry of the given log.
+func (c *windowCounter) add(logName string, now time.Time) {
+	index := c.advance(now)
+
+	counts, ok := c.logs[logName]
+	if !ok {
+		counts = make([]uint64, len(c.total))
+		c.logs[logName] = counts
+	}
+
+	c.total[index]++
+	counts[index]++
+}
+
+// windowCounts is the number of entries within a window, in total and per log.
+type windowCounts struct {
+	Total uint64            `json:"total"`
+	Logs  map[string]uint64 `json:"logs"`
+}
+
+// counts returns the number of entries within the window. Logs without entries within the window are included with
+// a count of zero.
+func (c *windowCounter) counts(now time.Time) windowCounts {
+	c.advance(now)
+
+	result := windowCounts{Logs: make(map[string]uint64, len(c.logs))}
+	for _, count := range c.total {
+		result.Total += count
+	}
+
+	for logName, counts := range c.logs {
+		var sum uint64
+		for _, count := range counts {
+			sum += count
+		}
+
+		result.Logs[logName] = sum
+	}
+
+	return result
+}
+
+// entryCounter counts the broadcast entries within multiple rolling time windows. It is safe for concurrent use.
+type entryCounter struct {
+	mu       sync.Mutex
+	counters []*windowCounter
+}
+
+func newEntryCounter(windows []time.Duration) *entryCounter {
+	counter := &entryCounter{}
+	for _, window := range windows {
+		counter.counters = append(counter.counters, newWindowCounter(window))
+	}
+
+	return counter
+}
+
+// add counts an entry of the given log in all windows.
+func (e *entryCounter) add(logName string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, counter := range e.counters {
+		counter.add(logName, now)
+	}
+}
+
+// counts returns the counts of the given windows, keyed by the formatted window. All windows are returned if
+// windows is empty.
+func (e *entryCounter) counts(windows []time.Duration, now time.Time) map[string]windowCounts {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := make(map[string]windowCounts, len(e.counters))
+
+	for _, counter := range e.counters {
+		if len(windows) == 0 || slices.Contains(windows, counter.window) {
+			result[formatWindow(counter.window)] = counter.counts(now)
+		}
+	}
+
+	return result
+}
+
+// hasWindow returns true if the counts of the window are tracked.
+func (e *entryCounter) hasWindow(window time.Duration) bool {
+	for _, counter := range e.counters {
+		if counter.window == window {
+			return true
+		}
+	}
+
+	return false
+}
+
+// windows returns the formatted windows that are tracked.
+func (e *entryCounter) windows() []string {
+	names := make([]string, len(e.counters))
+	for i, counter := range e.counters {
+		names[i] = formatWindow(counter.window)
+	}
+
+	return names
+}
+
+// formatWindow formats the window in its largest whole unit, e.g. "5m" instead of "5m0s".
+func formatWindow(window time.Duration) string {
+	switch {
+	case window%time.Hour == 0:
+		return fmt.Sprintf("%dh", window/time.Hour)
+	case window%time.Minute == 0:
+		return fmt.Sprintf("%dm", window/time.Minute)
+	default:
+		return window.String()
+	}
+}
+
+// entryCounts handles requests to the /counts endpoint. It returns the number of broadcast entries in total and per log
+// within the configured rolling windows as JSON object, keyed by the window. The "window" parameter, e.g.
+// "/counts?window=1m", restricts the response to the given windows. It can be repeated or contain a comma-separated list.
+func entryCounts(w http.ResponseWriter, r *http.Request) {
+	var windows []time.Duration
+
+	for _, param := range r.URL.Query()["window"] {
+		for _, name := range strings.Split(param, ",") {
+			window, err := time.ParseDuration(strings.TrimSpace(name))
+			if err != nil || !ClientHandler.counts.hasWindow(window) {
+				http.Error(w, fmt.Sprintf("Parameter 'window' must be one of %s", strings.Join(ClientHandler.counts.windows(), ", ")),
+					http.StatusBadRequest)
+
+				return
+			}
+
+			windows = append(windows, window)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(ClientHandler.counts.counts(windows, time.Now())); err != nil {
+		log.Printf("Error while writing counts: %v\n", err)
+	}
+}
@@ -0,0 +1,34 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	t.Parallel()
+
+	counter := newWindowCounter(time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	counter.add("argon", start)
+	counter.add("argon", start.Add(30*time.Second))
+	counter.add("xenon", start.Add(59*time.Second))
+
+	counts := counter.counts(start.Add(59 * time.Second))
+	if counts.Total != 3 || counts.Logs["argon"] != 2 || counts.Logs["xenon"] != 1 {
+		t.Errorf("counts() within window = %+v, want 3 in total, 2 of argon and 1 of xenon", counts)
+	}
+
+	// The first entry falls out of the window
+	counts = counter.counts(start.Add(80 * time.Second))
+	if counts.Total != 2 || counts.Logs["argon"] != 1 {
+		t.Errorf("counts() after 80s = %+v, want 2 in total and 1 of argon", counts)
+	}
+
+	// Logs without entries within the window are reported with zero
+	counts = counter.counts(start.Add(time.Hour))
+	if count, ok := counts.Logs["xenon"]; counts.Total != 0 || !ok || count != 0 {
+		t.Errorf("counts() after an hour = %+v, want zeros for all logs", counts)
+	}
+}
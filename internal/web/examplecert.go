@@ -9,16 +9,20 @@ var exampleCert models.Entry
 
 // exampleFull handles requests to the /full-stream/example.json endpoint.
 // It returns a JSON representation of the full example certificate.
-func exampleFull(w http.ResponseWriter, _ *http.Request) {
+func exampleFull(w http.ResponseWriter, r *http.Request) {
+	entry := exampleEntry(r)
+
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(exampleCert.JSON()) //nolint:errcheck
+	w.Write(entry.JSON()) //nolint:errcheck
 }
 
 // exampleLite handles requests to the /example.json endpoint.
 // It returns a JSON representation of the lite example certificate.
-func exampleLite(w http.ResponseWriter, _ *http.Request) {
+func exampleLite(w http.ResponseWriter, r *http.Request) {
+	entry := exampleEntry(r)
+
 	w.Header().Set("Content-Type", "application/json")
-	w.Write(exampleCert.JSONLite()) //nolint:errcheck
+	w.Write(entry.JSONLite()) //nolint:errcheck
 }
 
 // exampleDomains handles requests to the /domains-only/example.json endpoint.
@@ -28,6 +32,15 @@ func exampleDomains(w http.ResponseWriter, _ *http.Request) {
 	w.Write(exampleCert.JSONDomains()) //nolint:errcheck
 }
 
+// exampleEntry returns the example certificate with or without the certificate details, depending on the request.
+func exampleEntry(r *http.Request) models.Entry {
+	if fullPayloadRequested(r) {
+		return exampleCert
+	}
+
+	return exampleCert.WithoutDetails()
+}
+
 // SetExampleCert sets one certificate as the example Cert that is returned by the example endpoints.
 func SetExampleCert(cert models.Entry) {
 	exampleCert = cert
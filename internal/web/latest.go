@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// defaultLatestCount is the number of entries returned by the latest endpoint if the "n" parameter is missing.
+const defaultLatestCount = 100
+
+// latestBuffer is a ring buffer of the most recently broadcast entries. It is safe for concurrent use.
+type latestBuffer struct {
+	mu      sync.Mutex
+	entries []models.Entry
+	// next is the position the next entry is written to. Once the buffer is full, it holds the oldest entry.
+	next  int
+	count int
+}
+
+func newLatestBuffer(size int) *latestBuffer {
+	return &latestBuffer{entries: make([]models.Entry, max(1, size))}
+}
+
+// add adds the entry to the buffer and overwrites the oldest entry if the buffer is full.
+func (l *latestBuffer) add(entry models.Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	l.count = min(l.count+1, len(l.entries))
+}
+
+// latest returns up to n of the most recent entries, newest first.
+func (l *latestBuffer) latest(n int) []models.Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n = min(n, l.count)
+	entries := make([]models.Entry, n)
+
+	for i := range n {
+		entries[i] = l.entries[(l.next-1-i+len(l.entries))%len(l.entries)]
+	}
+
+	return entries
+}
+
+// latestCertificates handles requests to the /latest endpoint. It returns the most recent entries as JSON array,
+// newest first. The number of entries is set with the "n" parameter, e.g. "/latest?n=10", and capped to the size of
+// the buffer. Like in the streams, the certificate details are only included if the full payload was requested.
+func latestCertificates(w http.ResponseWriter, r *http.Request) {
+	n := defaultLatestCount
+
+	if param := r.URL.Query().Get("n"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 {
+			http.Error(w, "Parameter 'n' must be a positive number", http.StatusBadRequest)
+			return
+		}
+
+		n = parsed
+	}
+
+	entries := ClientHandler.latest.latest(n)
+
+	if !fullPayloadRequested(r) {
+		for i := range entries {
+			entries[i] = entries[i].WithoutDetails()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(entries); err != nil {
+		log.Printf("Error while writing latest certificates: %v\n", err)
+	}
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestLatestBuffer(t *testing.T) {
+	t.Parallel()
+
+	buffer := newLatestBuffer(3)
+
+	indexes := func(n int) []uint64 {
+		var indexes []uint64
+		for _, entry := range buffer.latest(n) {
+			indexes = append(indexes, entry.Data.CertIndex)
+		}
+
+		return indexes
+	}
+
+	if got := indexes(10); len(got) != 0 {
+		t.Errorf("latest() of empty buffer = %v, want []", got)
+	}
+
+	for i := range uint64(5) {
+		buffer.add(models.Entry{Data: models.Data{CertIndex: i}})
+
+		if i == 1 {
+			// Fewer entries than requested were seen
+			if got := indexes(3); len(got) != 2 || got[0] != 1 || got[1] != 0 {
+				t.Errorf("latest(3) after 2 entries = %v, want [1 0]", got)
+			}
+		}
+	}
+
+	if got := indexes(10); len(got) != 3 || got[0] != 4 || got[1] != 3 || got[2] != 2 {
+		t.Errorf("latest(10) after wrapping = %v, want [4 3 2]", got)
+	}
+
+	if got := indexes(1); len(got) != 1 || got[0] != 4 {
+		t.Errorf("latest(1) = %v, want [4]", got)
+	}
+}
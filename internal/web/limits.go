@@ -0,0 +1,171 @@
+package web
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+// limiterSweepInterval is the interval in which the token buckets of IPs that are not limited anymore are removed.
+const limiterSweepInterval = time.Minute
+
+// Reasons for rejected connection attempts, used as metric labels.
+const (
+	rejectRateLimit           = "rate_limit"
+	rejectMaxConnections      = "max_connections"
+	rejectMaxConnectionsPerIP = "max_connections_per_ip"
+)
+
+// tokenBucket limits the rate of connection attempts of a single IP.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// connectionLimiter limits the rate of websocket upgrade attempts per IP and the number of concurrent connections
+// in total and per IP. Limits that are zero are disabled. It is safe for concurrent use.
+type connectionLimiter struct {
+	limits config.ConnectionLimitsConfig
+
+	mu          sync.Mutex
+	buckets     map[string]*tokenBucket
+	connections map[string]int
+	total       int
+	lastSweep   time.Time
+
+	rejectedRateLimit           atomic.Uint64
+	rejectedMaxConnections      atomic.Uint64
+	rejectedMaxConnectionsPerIP atomic.Uint64
+}
+
+// newConnectionLimiter creates a connectionLimiter with the given limits.
+func newConnectionLimiter(limits config.ConnectionLimitsConfig) *connectionLimiter {
+	return &connectionLimiter{
+		limits:      withDefaultBurst(limits),
+		buckets:     make(map[string]*tokenBucket),
+		connections: make(map[string]int),
+	}
+}
+
+// withDefaultBurst returns the limits with the burst set to the rate limit, rounded up, if it is not set.
+func withDefaultBurst(limits config.ConnectionLimitsConfig) config.ConnectionLimitsConfig {
+	if limits.RateLimit > 0 && limits.Burst <= 0 {
+		limits.Burst = max(1, int(math.Ceil(limits.RateLimit)))
+	}
+
+	return limits
+}
+
+// setLimits replaces the limits. Existing connections are kept, even if they exceed the new limits.
+func (l *connectionLimiter) setLimits(limits config.ConnectionLimitsConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = withDefaultBurst(limits)
+}
+
+// acquire checks whether a new connection of the given IP is allowed. If so, the connection is counted until release
+// is called and an empty string is returned. Otherwise, the reason for the rejection is returned.
+func (l *connectionLimiter) acquire(ip string, now time.Time) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.allowAttempt(ip, now) {
+		l.rejectedRateLimit.Add(1)
+		return rejectRateLimit
+	}
+
+	if l.limits.MaxConnections > 0 && l.total >= l.limits.MaxConnections {
+		l.rejectedMaxConnections.Add(1)
+		return rejectMaxConnections
+	}
+
+	if l.limits.MaxConnectionsPerIP > 0 && l.connections[ip] >= l.limits.MaxConnectionsPerIP {
+		l.rejectedMaxConnectionsPerIP.Add(1)
+		return rejectMaxConnectionsPerIP
+	}
+
+	l.connections[ip]++
+	l.total++
+
+	return ""
+}
+
+// release removes a connection of the given IP, which was previously allowed by acquire.
+func (l *connectionLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total--
+	if l.connections[ip]--; l.connections[ip] <= 0 {
+		delete(l.connections, ip)
+	}
+}
+
+// allowAttempt takes a token from the bucket of the IP. It returns false if the bucket is empty.
+// The caller must hold the lock.
+func (l *connectionLimiter) allowAttempt(ip string, now time.Time) bool {
+	if l.limits.RateLimit <= 0 {
+		return true
+	}
+
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(l.limits.Burst), last: now}
+		l.buckets[ip] = bucket
+	}
+
+	l.refill(bucket, now)
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// refill adds the tokens that accrued since the last refill to the bucket, up to the burst size.
+func (l *connectionLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = min(float64(l.limits.Burst), bucket.tokens+elapsed*l.limits.RateLimit)
+	bucket.last = now
+}
+
+// sweep removes the buckets that are full again, since a new bucket is equivalent. The caller must hold the lock.
+func (l *connectionLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+
+	for ip, bucket := range l.buckets {
+		l.refill(bucket, now)
+
+		if bucket.tokens >= float64(l.limits.Burst) {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// connectionCount returns the current number of connections.
+func (l *connectionLimiter) connectionCount() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.total
+}
+
+// rejected returns the number of rejected connection attempts per reason.
+func (l *connectionLimiter) rejected() map[string]uint64 {
+	return map[string]uint64{
+		rejectRateLimit:           l.rejectedRateLimit.Load(),
+		rejectMaxConnections:      l.rejectedMaxConnections.Load(),
+		rejectMaxConnectionsPerIP: l.rejectedMaxConnectionsPerIP.Load(),
+	}
+}
@@ -0,0 +1,62 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+func TestConnectionLimiterRateLimit(t *testing.T) {
+	t.Parallel()
+
+	limiter := newConnectionLimiter(config.ConnectionLimitsConfig{RateLimit: 1, Burst: 2})
+	now := time.Now()
+
+	for i, want := range []string{"", "", rejectRateLimit} {
+		if got := limiter.acquire("192.0.2.1", now); got != want {
+			t.Errorf("attempt %d: acquire() = '%s', want '%s'", i+1, got, want)
+		}
+	}
+
+	// Other IPs have their own bucket
+	if got := limiter.acquire("192.0.2.2", now); got != "" {
+		t.Errorf("acquire() of other IP = '%s', want ''", got)
+	}
+
+	// One token accrues per second
+	if got := limiter.acquire("192.0.2.1", now.Add(time.Second)); got != "" {
+		t.Errorf("acquire() after refill = '%s', want ''", got)
+	}
+}
+
+func TestConnectionLimiterMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	limiter := newConnectionLimiter(config.ConnectionLimitsConfig{MaxConnections: 3, MaxConnectionsPerIP: 2})
+	now := time.Now()
+
+	for i, want := range []string{"", "", rejectMaxConnectionsPerIP} {
+		if got := limiter.acquire("192.0.2.1", now); got != want {
+			t.Errorf("attempt %d: acquire() = '%s', want '%s'", i+1, got, want)
+		}
+	}
+
+	if got := limiter.acquire("192.0.2.2", now); got != "" {
+		t.Errorf("acquire() of other IP = '%s', want ''", got)
+	}
+
+	if got := limiter.acquire("192.0.2.3", now); got != rejectMaxConnections {
+		t.Errorf("acquire() above total limit = '%s', want '%s'", got, rejectMaxConnections)
+	}
+
+	limiter.release("192.0.2.1")
+
+	if got := limiter.acquire("192.0.2.3", now); got != "" {
+		t.Errorf("acquire() after release = '%s', want ''", got)
+	}
+
+	if got := limiter.connectionCount(); got != 3 {
+		t.Errorf("connectionCount() = %d, want 3", got)
+	}
+}
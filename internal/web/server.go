@@ -3,7 +3,12 @@ package web
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/internal/dedup"
+	"github.com/letrics/certstream-server-go/internal/serializer"
 	"github.com/letrics/certstream-server-go/pkg/config"
 	"github.com/letrics/certstream-server-go/pkg/models"
 	"io"
@@ -11,6 +16,8 @@ import (
 	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -22,8 +29,27 @@ import (
 var (
 	ClientHandler = BroadcastManager{}
 	upgrader      websocket.Upgrader
+	// connLimiter limits the websocket connections. It is replaced with the configured limits by NewWebsocketServer.
+	connLimiter = newConnectionLimiter(config.ConnectionLimitsConfig{})
+	// pingInterval and pongTimeout control the heartbeat of the websocket connections. They are replaced with the
+	// configured values by NewWebsocketServer.
+	pingInterval = 30 * time.Second
+	pongTimeout  = 35 * time.Second
+	// defaultFormat is the wire format of clients that didn't request one. It is replaced with the configured
+	// format by NewWebsocketServer.
+	defaultFormat = serializer.FormatJSON
+	// defaultFields are the fields sent to clients that didn't select any. They are replaced with the configured
+	// fields by NewWebsocketServer.
+	defaultFields models.Fields
+	// maxDomainsPerEntry limits the domains of the entries sent to clients that didn't request the full payload.
+	// It is replaced with the configured limit by NewWebsocketServer. Zero means unlimited.
+	maxDomainsPerEntry int
 )
 
+// clientBufferSize is the number of entries buffered for each websocket client. It is set by NewWebsocketServer
+// and Reload and applies to new clients.
+var clientBufferSize atomic.Int64
+
 // WebServer is a struct that holds the necessary information to run a webserver.
 // It is used for the websocket server as well as the metrics server.
 type WebServer struct {
@@ -33,6 +59,27 @@ type WebServer struct {
 	server    *http.Server
 	certPath  string
 	keyPath   string
+	// probes are the health endpoints, keyed by path. They are served before the routes, so that they are not
+	// subject to the IP whitelist and the authentication.
+	probes map[string]http.HandlerFunc
+}
+
+// ConnectionCount returns the current number of websocket connections.
+func ConnectionCount() int {
+	return connLimiter.connectionCount()
+}
+
+// RejectedConnections returns the number of websocket connection attempts rejected due to the connection limits,
+// keyed by the exceeded limit.
+func RejectedConnections() map[string]uint64 {
+	return connLimiter.rejected()
+}
+
+// Reload applies the options of the websocket server that can be changed at runtime: the connection limits and
+// the buffer size of new clients.
+func Reload(conf config.Config) {
+	connLimiter.setLimits(conf.Webserver.Limits)
+	clientBufferSize.Store(int64(conf.General.BufferSizes.Websocket))
 }
 
 // RegisterPrometheus registers a new handler that listens on the given url and calls the given function
@@ -44,6 +91,18 @@ func (ws *WebServer) RegisterPrometheus(url string, callback func(w io.Writer, e
 	})
 }
 
+// RegisterStats registers a new handler that listens on the given url and returns the result of the given function
+// as JSON.
+func (ws *WebServer) RegisterStats(url string, callback func() any) {
+	ws.routes.HandleFunc(url, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(callback()); err != nil {
+			log.Println("Error while encoding stats: ", err)
+		}
+	})
+}
+
 // IPWhitelist returns a middleware that checks if the IP of the client is in the whitelist.
 func IPWhitelist(whitelist []string) func(next http.Handler) http.Handler {
 	// build a list of whitelisted IPs and CIDRs
@@ -112,37 +171,60 @@ func IPWhitelist(whitelist []string) func(next http.Handler) http.Handler {
 // initFullWebsocket is called when a client connects to the /full-stream endpoint.
 // It upgrades the connection to a websocket and starts a goroutine to listen for messages from the client.
 func initFullWebsocket(w http.ResponseWriter, r *http.Request) {
-	connection, err := upgradeConnection(w, r)
-	if err != nil {
-		log.Println("Error while trying to upgrade connection:", err)
-		return
-	}
-
-	setupClient(connection, SubTypeFull, r.RemoteAddr)
+	serveWebsocket(w, r, SubTypeFull)
 }
 
 // initLiteWebsocket is called when a client connects to the / endpoint.
 // It upgrades the connection to a websocket and starts a goroutine to listen for messages from the client.
 func initLiteWebsocket(w http.ResponseWriter, r *http.Request) {
-	connection, err := upgradeConnection(w, r)
-	if err != nil {
-		log.Println("Error while trying to upgrade connection:", err)
-		return
-	}
-
-	setupClient(connection, SubTypeLite, r.RemoteAddr)
+	serveWebsocket(w, r, SubTypeLite)
 }
 
 // initDomainWebsocket is called when a client connects to the /domains-only endpoint.
 // It upgrades the connection to a websocket and starts a goroutine to listen for messages from the client.
 func initDomainWebsocket(w http.ResponseWriter, r *http.Request) {
+	serveWebsocket(w, r, SubTypeDomain)
+}
+
+// serveWebsocket upgrades the connection to a websocket, unless the connection limits are exceeded, and starts the
+// client with the given subscription type.
+func serveWebsocket(w http.ResponseWriter, r *http.Request, subscriptionType SubscriptionType) {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	if reason := connLimiter.acquire(ip, time.Now()); reason != "" {
+		log.Printf("Rejecting connection from %s, since the limit '%s' is exceeded\n", r.RemoteAddr, reason)
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+
+		return
+	}
+
+	release := func() { connLimiter.release(ip) }
+
+	options, err := parseClientOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		release()
+
+		return
+	}
+
 	connection, err := upgradeConnection(w, r)
 	if err != nil {
 		log.Println("Error while trying to upgrade connection:", err)
+		release()
+
 		return
 	}
 
-	setupClient(connection, SubTypeDomain, r.RemoteAddr)
+	// The format can be negotiated via the subprotocol as well, but the query parameter takes precedence
+	if subprotocol := connection.Subprotocol(); subprotocol != "" && !r.URL.Query().Has("format") {
+		options.format, _ = serializer.ParseFormat(subprotocol)
+	}
+
+	setupClient(connection, subscriptionType, options, r.RemoteAddr, release)
 }
 
 // upgradeConnection upgrades the connection to a websocket and returns the connection.
@@ -156,25 +238,101 @@ func upgradeConnection(w http.ResponseWriter, r *http.Request) (*websocket.Conn,
 		remoteAddr = fmt.Sprintf("'%s'", r.RemoteAddr)
 	}
 
-	log.Printf("Starting new websocket for %s - %s\n", remoteAddr, r.URL)
+	if label := apiKeyLabel(r); label != "" {
+		remoteAddr += fmt.Sprintf(" (API key: '%s')", label)
+	}
+
+	// The API key must not end up in the logs
+	requestURL := redactAPIKey(r.URL)
+
+	log.Printf("Starting new websocket for %s - %s\n", remoteAddr, requestURL)
 
 	connection, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// Compression is only used if the client offered permessage-deflate in the handshake
+	if level := config.AppConfig.Webserver.CompressionLevel; upgrader.EnableCompression && level != 0 {
+		if err := connection.SetCompressionLevel(level); err != nil {
+			log.Printf("Could not set compression level %d: %v\n", level, err)
+		}
+	}
+
 	defaultCloseHandler := connection.CloseHandler()
 	connection.SetCloseHandler(func(code int, text string) error {
-		log.Printf("Stopping websocket for %s - %s\n", remoteAddr, r.URL)
+		log.Printf("Stopping websocket for %s - %s\n", remoteAddr, requestURL)
 		return defaultCloseHandler(code, text)
 	})
 
 	return connection, nil
 }
 
+// fullPayloadRequested returns true if the certificate details should be sent to the client, either because the full
+// payload is enabled in the config or the client requested it via the "full" query parameter.
+func fullPayloadRequested(r *http.Request) bool {
+	if config.AppConfig.Webserver.FullPayload {
+		return true
+	}
+
+	full, err := strconv.ParseBool(r.URL.Query().Get("full"))
+
+	return err == nil && full
+}
+
+// parseClientOptions returns the settings requested via the query parameters. Only entries containing a domain that
+// is equal to or a subdomain of one of the "domain" parameters are sent to the client, e.g. "?domain=example.com".
+// Multiple "domain" parameters are combined with OR semantics. The "format" parameter selects the wire format,
+// e.g. "?format=msgpack". The "fields" parameter selects the fields of the entries, e.g. "?fields=all_domains,seen",
+// and an empty "fields" parameter selects all fields, regardless of the configured fields.
+// An error is returned for unknown formats and fields.
+func parseClientOptions(r *http.Request) (clientOptions, error) {
+	options := clientOptions{
+		fullPayload: fullPayloadRequested(r),
+		apiKeyLabel: apiKeyLabel(r),
+		format:      defaultFormat,
+		fields:      defaultFields,
+	}
+
+	if name := r.URL.Query().Get("format"); name != "" {
+		format, err := serializer.ParseFormat(name)
+		if err != nil {
+			return options, err
+		}
+
+		options.format = format
+	}
+
+	if r.URL.Query().Has("fields") {
+		var names []string
+		for _, param := range r.URL.Query()["fields"] {
+			names = append(names, strings.Split(param, ",")...)
+		}
+
+		fields, err := models.ParseFields(names)
+		if err != nil {
+			return options, err
+		}
+
+		options.fields = fields
+	}
+
+	if domains := r.URL.Query()["domain"]; len(domains) > 0 {
+		filter := certificatetransparency.NewDomainFilter(domains, nil)
+		if !filter.IsEmpty() {
+			options.domainFilter = filter
+			options.domains = domains
+		}
+	}
+
+	return options, nil
+}
+
 // setupClient initializes a client struct and starts the broadcastHandler and websocket listener.
-func setupClient(connection *websocket.Conn, subscriptionType SubscriptionType, name string) {
-	c := newClient(connection, subscriptionType, name, config.AppConfig.General.BufferSizes.Websocket)
+// release is called once the client disconnected.
+func setupClient(connection *websocket.Conn, subscriptionType SubscriptionType, options clientOptions, name string, release func()) {
+	c := newClient(connection, subscriptionType, options, name, int(clientBufferSize.Load()))
+	c.release = release
 	go c.broadcastHandler()
 	go c.listenWebsocket()
 
@@ -185,23 +343,64 @@ func setupClient(connection *websocket.Conn, subscriptionType SubscriptionType,
 func setupWebsocketRoutes(r *chi.Mux) {
 	r.Use(middleware.Recoverer)
 	r.Route("/", func(r chi.Router) {
-		r.Route(config.AppConfig.Webserver.FullURL, func(r chi.Router) {
-			r.HandleFunc("/", initFullWebsocket)
-			r.HandleFunc("/example.json", exampleFull)
-		})
-
-		r.Route(config.AppConfig.Webserver.LiteURL, func(r chi.Router) {
-			r.HandleFunc("/", initLiteWebsocket)
-			r.HandleFunc("/example.json", exampleLite)
-		})
+		if !config.AppConfig.Output.DisableWebsocket {
+			r.Route(config.AppConfig.Webserver.FullURL, func(r chi.Router) {
+				r.HandleFunc("/", initFullWebsocket)
+				r.HandleFunc("/example.json", exampleFull)
+			})
+
+			r.Route(config.AppConfig.Webserver.LiteURL, func(r chi.Router) {
+				r.HandleFunc("/", initLiteWebsocket)
+				r.HandleFunc("/example.json", exampleLite)
+			})
+
+			r.Route(config.AppConfig.Webserver.DomainsOnlyURL, func(r chi.Router) {
+				r.HandleFunc("/", initDomainWebsocket)
+				r.HandleFunc("/example.json", exampleDomains)
+			})
+		}
 
-		r.Route(config.AppConfig.Webserver.DomainsOnlyURL, func(r chi.Router) {
-			r.HandleFunc("/", initDomainWebsocket)
-			r.HandleFunc("/example.json", exampleDomains)
-		})
+		r.HandleFunc("/latest", latestCertificates)
+		r.HandleFunc("/counts", entryCounts)
 	})
 }
 
+// RegisterHealth registers the liveness (/healthz) and readiness (/readyz) endpoints. They respond with status 200
+// if the respective check returns nil and with 503 and the error otherwise. They must be registered before the server
+// is started.
+func (ws *WebServer) RegisterHealth(liveness, readiness func() error) {
+	ws.probes = map[string]http.HandlerFunc{
+		"/healthz": healthHandler(liveness),
+		"/readyz":  healthHandler(readiness),
+	}
+}
+
+// healthHandler returns a handler that responds with the result of the given check.
+func healthHandler(check func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if err := check(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, err)
+
+			return
+		}
+
+		fmt.Fprintln(w, "ok")
+	}
+}
+
+// ServeHTTP serves the health endpoints and passes all other requests to the routes.
+func (ws *WebServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if probe, ok := ws.probes[r.URL.Path]; ok {
+		probe(w, r)
+		return
+	}
+
+	ws.routes.ServeHTTP(w, r)
+}
+
 func (ws *WebServer) initServer() {
 	addr := net.JoinHostPort(ws.networkIf, strconv.Itoa(ws.port))
 
@@ -219,7 +418,7 @@ func (ws *WebServer) initServer() {
 
 	ws.server = &http.Server{
 		Addr:              addr,
-		Handler:           ws.routes,
+		Handler:           ws,
 		TLSConfig:         tlsConfig,
 		IdleTimeout:       time.Minute,
 		ReadTimeout:       10 * time.Second,
@@ -265,8 +464,31 @@ func NewWebsocketServer(networkIf string, port int, certPath, keyPath string) *W
 		keyPath:   keyPath,
 	}
 
+	pingInterval = config.AppConfig.Webserver.PingInterval
+	pongTimeout = config.AppConfig.Webserver.PongTimeout
+	maxDomainsPerEntry = config.AppConfig.Webserver.MaxDomainsPerEntry
+
+	format, err := serializer.ParseFormat(config.AppConfig.Webserver.Format)
+	if err != nil {
+		log.Printf("Invalid websocket format: %v - defaulting to %s\n", err, serializer.FormatJSON)
+	}
+
+	defaultFormat = format
+
+	// The fields were checked when the config was loaded
+	defaultFields, err = models.ParseFields(config.AppConfig.Webserver.Fields)
+	if err != nil {
+		log.Printf("Invalid websocket fields: %v - sending all fields\n", err)
+	}
+
+	subprotocols := make([]string, serializer.NumFormats)
+	for i := range subprotocols {
+		subprotocols[i] = serializer.Format(i).String()
+	}
+
 	upgrader = websocket.Upgrader{
 		EnableCompression: config.AppConfig.Webserver.CompressionEnabled,
+		Subprotocols:      subprotocols,
 		CheckOrigin: func(_ *http.Request) bool {
 			// Allow all connections by default
 			return true
@@ -282,11 +504,43 @@ func NewWebsocketServer(networkIf string, port int, certPath, keyPath string) *W
 		server.routes.Use(IPWhitelist(config.AppConfig.Webserver.Whitelist))
 	}
 
+	if auth := config.AppConfig.Webserver.Auth; auth != nil {
+		server.routes.Use(APIKeyAuth(auth.Keys))
+	}
+
+	connLimiter = newConnectionLimiter(config.AppConfig.Webserver.Limits)
+	clientBufferSize.Store(int64(config.AppConfig.General.BufferSizes.Websocket))
+
 	setupWebsocketRoutes(server.routes)
 	server.initServer()
 
 	ClientHandler.Broadcast = make(chan models.Entry, config.AppConfig.General.BufferSizes.BroadcastManager)
-	go ClientHandler.broadcaster()
+
+	if dedupConfig := config.AppConfig.General.Deduplicate; dedupConfig.Enabled {
+		log.Printf("Deduplicating certificates within %s (capacity: %d)\n", dedupConfig.TTL, dedupConfig.Capacity)
+		ClientHandler.dedup = dedup.New(dedupConfig.Capacity, dedupConfig.TTL)
+
+		if persistent := dedupConfig.Persistent; persistent.Enabled {
+			log.Printf("Persisting deduplication filter to %s (capacity: %d, false-positive rate: %v)\n",
+				persistent.File, persistent.Capacity, persistent.FalsePositiveRate)
+			ClientHandler.enablePersistentDedup(persistent)
+		}
+	}
+
+	ClientHandler.latest = newLatestBuffer(config.AppConfig.Webserver.LatestBufferSize)
+	ClientHandler.counts = newEntryCounter(config.AppConfig.Webserver.CountWindows)
+
+	if config.AppConfig.Output.DisableWebsocket {
+		log.Println("Websocket output is disabled")
+	} else {
+		ClientHandler.enableWebsocket(config.AppConfig.Webserver.BroadcastShards, config.AppConfig.Output.WebsocketPolicy)
+	}
+
+	ClientHandler.broadcasting.Add(1)
+	go func() {
+		defer ClientHandler.broadcasting.Done()
+		ClientHandler.broadcaster()
+	}()
 
 	return server
 }
@@ -297,12 +551,19 @@ func (ws *WebServer) Start() {
 
 	var err error
 	if ws.keyPath != "" && ws.certPath != "" {
-		err = ws.server.ListenAndServeTLS(ws.certPath, ws.keyPath)
+		reloader, reloaderErr := newCertReloader(ws.certPath, ws.keyPath)
+		if reloaderErr != nil {
+			log.Fatal("Error while loading TLS certificate: ", reloaderErr)
+		}
+
+		ws.server.TLSConfig.GetCertificate = reloader.GetCertificate
+		err = ws.server.ListenAndServeTLS("", "")
 	} else {
 		err = ws.server.ListenAndServe()
 	}
 
-	if err != nil {
+	// Stop makes ListenAndServe return ErrServerClosed, which is no error
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Fatal("Error while serving webserver: ", err)
 	}
 }
@@ -0,0 +1,217 @@
+package web
+
+import (
+	"log"
+	"sync"
+
+	"github.com/letrics/certstream-server-go/internal/serializer"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// shardQueueSize is the number of entries buffered per broadcast shard.
+const shardQueueSize = 1000
+
+// lazyBytes is a representation of an entry that is encoded once, when it is first needed.
+type lazyBytes struct {
+	once sync.Once
+	data []byte
+}
+
+// get returns the representation and encodes it first if necessary. It is safe for concurrent use.
+func (l *lazyBytes) get(encode func() []byte) []byte {
+	l.once.Do(func() { l.data = encode() })
+	return l.data
+}
+
+// representations are the encodings of an entry in a single format, one for each kind of subscription.
+type representations struct {
+	full, fullDetails, lite, liteDetails, domains lazyBytes
+}
+
+// sharedEntry is an entry passed to all broadcast shards. Each representation is only encoded once the first
+// client of any shard needs it, e.g. the certificate details are only encoded if at least one client requested them.
+type sharedEntry struct {
+	entry        models.Entry
+	defaultEntry models.Entry
+
+	formats [serializer.NumFormats]representations
+	// projections are the encodings for clients that selected a subset of the fields, keyed by projectionKey.
+	projections sync.Map
+}
+
+// projectionKey identifies the encoding of an entry for clients that selected the same fields.
+type projectionKey struct {
+	format      serializer.Format
+	subType     SubscriptionType
+	fullPayload bool
+	fields      string
+}
+
+// newSharedEntry creates the shared entry. The default entry is truncated to maxDomainsPerEntry domains, so that
+// certificates with thousands of SANs don't stall slow clients. Clients that requested the full payload receive
+// all domains.
+func newSharedEntry(entry models.Entry) *sharedEntry {
+	defaultEntry := entry.WithoutDetails()
+	if maxDomainsPerEntry > 0 {
+		defaultEntry = defaultEntry.WithMaxDomains(maxDomainsPerEntry)
+	}
+
+	return &sharedEntry{entry: entry, defaultEntry: defaultEntry}
+}
+
+// dataFor returns the representation of the entry the given client subscribed to, in the format the client
+// requested. ok is false for unknown types.
+func (s *sharedEntry) dataFor(c *client) (data []byte, ok bool) {
+	r := &s.formats[c.format]
+	ser := c.format.Serializer()
+
+	switch {
+	case c.subType != SubTypeDomain && !c.fields.All():
+		return s.projectionFor(c), true
+	case c.subType == SubTypeLite && c.fullPayload:
+		return r.liteDetails.get(func() []byte { return marshal(ser, s.entry.Lite()) }), true
+	case c.subType == SubTypeLite:
+		return r.lite.get(func() []byte { return marshal(ser, s.defaultEntry.Lite()) }), true
+	case c.subType == SubTypeFull && c.fullPayload:
+		return r.fullDetails.get(func() []byte { return marshal(ser, s.entry) }), true
+	case c.subType == SubTypeFull:
+		return r.full.get(func() []byte { return marshal(ser, s.defaultEntry) }), true
+	case c.subType == SubTypeDomain:
+		return r.domains.get(func() []byte {
+			data, err := ser.MarshalDomains(s.entry.DomainsEntry())
+			if err != nil {
+				log.Printf("Error while encoding domains: %v\n", err)
+			}
+
+			return data
+		}), true
+	default:
+		return nil, false
+	}
+}
+
+// projectionFor returns the encoding of the fields the client selected. The projection is applied to the entry the
+// client would receive otherwise, e.g. without the chain for the lite stream.
+func (s *sharedEntry) projectionFor(c *client) []byte {
+	key := projectionKey{format: c.format, subType: c.subType, fullPayload: c.fullPayload, fields: c.fields.String()}
+
+	projection, ok := s.projections.Load(key)
+	if !ok {
+		projection, _ = s.projections.LoadOrStore(key, &lazyBytes{})
+	}
+
+	return projection.(*lazyBytes).get(func() []byte {
+		entry := s.defaultEntry
+		if c.fullPayload {
+			entry = s.entry
+		}
+
+		if c.subType == SubTypeLite {
+			entry = entry.Lite()
+		}
+
+		data, err := c.format.Serializer().MarshalFields(entry, c.fields)
+		if err != nil {
+			log.Printf("Error while encoding entry: %v\n", err)
+		}
+
+		return data
+	})
+}
+
+// marshal encodes the entry with the serializer. Errors are logged.
+func marshal(ser serializer.Serializer, entry models.Entry) []byte {
+	data, err := ser.Marshal(entry)
+	if err != nil {
+		log.Printf("Error while encoding entry: %v\n", err)
+	}
+
+	return data
+}
+
+// broadcastShard sends the entries to a subset of the websocket clients in its own goroutine, so that the fan-out
+// to many clients is spread across cores. Each client belongs to exactly one shard, which keeps its entries in order.
+type broadcastShard struct {
+	entries chan *sharedEntry
+	clients []*client
+	lock    sync.RWMutex
+	// dropped is incremented for each entry a client of the shard couldn't take, since its buffer was full.
+	dropped func()
+}
+
+// run sends the entries to the clients of the shard until the entries channel is closed. This method is blocking.
+func (s *broadcastShard) run() {
+	for entry := range s.entries {
+		s.broadcast(entry)
+	}
+}
+
+// broadcast passes the entry to all clients of the shard that are interested in it.
+func (s *broadcastShard) broadcast(entry *sharedEntry) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for _, c := range s.clients {
+		if !c.domainFilter.Matches(entry.entry.Data.LeafCert.AllDomains) {
+			continue
+		}
+
+		data, ok := entry.dataFor(c)
+		if !ok {
+			log.Printf("Unknown subscription type '%d' for client '%s'. Skipping this client!\n", c.subType, c.name)
+			continue
+		}
+
+		select {
+		case c.broadcastChan <- data:
+		default:
+			// Default case is executed if the client's broadcast channel is full.
+			skipped := c.skippedCerts.Add(1)
+			s.dropped()
+			if skipped%1000 == 1 {
+				log.Printf("Not providing client '%s' with cert because client's buffer is full. The client can't keep up. Skipped certs: %d\n", c.name, skipped)
+			}
+		}
+	}
+}
+
+// add adds the client to the shard.
+func (s *broadcastShard) add(c *client) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	c.shard = s
+	s.clients = append(s.clients, c)
+}
+
+// remove removes the client from the shard and closes its broadcast channel. It returns false if the client is not
+// part of the shard.
+func (s *broadcastShard) remove(c *client) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, client := range s.clients {
+		if c == client {
+			// Copy the last element of the slice to the position of the removed element
+			// Then remove the last element by re-slicing
+			s.clients[i] = s.clients[len(s.clients)-1]
+			s.clients[len(s.clients)-1] = nil
+			s.clients = s.clients[:len(s.clients)-1]
+
+			// Close the broadcast channel of the client, otherwise this leads to a memory leak
+			close(c.broadcastChan)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientCount returns the number of clients of the shard.
+func (s *broadcastShard) clientCount() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.clients)
+}
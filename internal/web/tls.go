@@ -0,0 +1,104 @@
+package web
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is the minimum interval in which the certificate files are checked for changes.
+const certCheckInterval = 10 * time.Second
+
+// certReloader provides the TLS certificate of a server and reloads it when the certificate or key file changes,
+// so that renewed certificates are used without a restart. It is safe for concurrent use.
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// newCertReloader loads the certificate and key from the given files.
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	r := &certReloader{certPath: certPath, keyPath: keyPath}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It can be used as tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastCheck) >= certCheckInterval {
+		r.lastCheck = now
+
+		if r.modified() {
+			// Keep serving the previous certificate if the new files are invalid, e.g. while they are being written
+			if err := r.reload(); err != nil {
+				log.Printf("Error while reloading TLS certificate: %v\n", err)
+			} else {
+				log.Printf("Reloaded TLS certificate from '%s'\n", r.certPath)
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// modified returns true if the modification time of the certificate or key file changed since they were loaded.
+// The caller must hold the lock.
+func (r *certReloader) modified() bool {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		log.Printf("Error while checking TLS certificate for changes: %v\n", err)
+		return false
+	}
+
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)
+}
+
+// reload loads the certificate and key from disk. The caller must hold the lock, unless the reloader is not shared yet.
+func (r *certReloader) reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return fmt.Errorf("could not load certificate '%s' and key '%s': %w", r.certPath, r.keyPath, err)
+	}
+
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+
+	return nil
+}
+
+// modTimes returns the modification times of the certificate and key file.
+func (r *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate with the given common name and its key to the given files.
+func writeCertificate(t *testing.T, certPath, keyPath, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+
+	if _, err := newCertReloader(certPath, keyPath); err == nil {
+		t.Fatal("newCertReloader() with missing files succeeded, want error")
+	}
+
+	writeCertificate(t, certPath, keyPath, "old")
+
+	reloader, err := newCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("newCertReloader() failed: %v", err)
+	}
+
+	commonName := func() string {
+		cert, _ := reloader.GetCertificate(nil)
+		return cert.Leaf.Subject.CommonName
+	}
+
+	if got := commonName(); got != "old" {
+		t.Fatalf("initial certificate is '%s', want 'old'", got)
+	}
+
+	// Renew the certificate with a different modification time and make the next handshake check for changes
+	writeCertificate(t, certPath, keyPath, "new")
+
+	modTime := time.Now().Add(time.Minute)
+	for _, path := range []string{certPath, keyPath} {
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloader.lastCheck = time.Time{}
+
+	if got := commonName(); got != "new" {
+		t.Errorf("certificate after renewal is '%s', want 'new'", got)
+	}
+
+	// An invalid certificate keeps the previous one
+	if err := os.WriteFile(certPath, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime = modTime.Add(time.Minute)
+	if err := os.Chtimes(certPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	reloader.lastCheck = time.Time{}
+
+	if got := commonName(); got != "new" {
+		t.Errorf("certificate after invalid renewal is '%s', want 'new'", got)
+	}
+}
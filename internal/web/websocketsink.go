@@ -0,0 +1,68 @@
+package web
+
+import (
+	"sync/atomic"
+
+	"github.com/letrics/certstream-server-go/pkg/config"
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// websocketSink passes the entries to the broadcast shards, which send them to the websocket clients.
+// If the queue of a shard is full, the entry is dropped for the clients of that shard, unless the sink blocks.
+type websocketSink struct {
+	shards []*broadcastShard
+	// block makes Send wait for space in the queues of the shards instead of dropping the entry (config.PolicyBlock).
+	block bool
+	// dropped is shared with the shards, which count the entries dropped for single clients.
+	dropped *atomic.Uint64
+}
+
+// enableWebsocket starts the broadcast shards and registers the sink that feeds them with the given policy.
+func (bm *BroadcastManager) enableWebsocket(shardCount int, policy config.SinkPolicy) {
+	bm.startShards(shardCount)
+	bm.RegisterSink(&websocketSink{shards: bm.shards, block: policy == config.PolicyBlock, dropped: &bm.websocketDropped})
+}
+
+// Name returns the name of the sink.
+func (s *websocketSink) Name() string {
+	return "websocket"
+}
+
+// Send passes the entry to all shards. It only blocks while the queue of a shard is full if the sink blocks.
+// The entry is shared by the shards, so that each of its representations is only encoded once.
+func (s *websocketSink) Send(entry models.Entry) {
+	shared := newSharedEntry(entry)
+
+	for _, shard := range s.shards {
+		if s.block {
+			shard.entries <- shared
+			continue
+		}
+
+		select {
+		case shard.entries <- shared:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Errors returns the number of failed writes, which is always zero, since slow clients are skipped instead.
+func (s *websocketSink) Errors() uint64 {
+	return 0
+}
+
+// Dropped returns the number of entries dropped for the websocket clients, either because the queue of their shard
+// or their own buffer was full.
+func (s *websocketSink) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close stops the shards once they sent their queued entries.
+func (s *websocketSink) Close() error {
+	for _, shard := range s.shards {
+		close(shard.entries)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package certstream
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultBatchWait is the maximum time a partial batch is held back if SubscribeBatch is called without a wait time.
+const defaultBatchWait = time.Second
+
+// batchPool contains the backing arrays of released batches, so that they can be reused for new batches.
+var batchPool sync.Pool
+
+// SubscribeBatch returns a new channel that receives the certificates in batches of up to maxBatch entries, e.g. for
+// bulk inserts into a database. A batch is sent once it is full or maxWait passed since its first entry, so partial
+// batches are delayed by at most maxWait. If maxWait is not positive, it defaults to one second.
+// Like Subscribe, the channel never slows down the CT workers: certificates are dropped if the consumer can't keep up.
+// Pass each batch to ReleaseBatch once it was processed to reuse its memory. The channel is closed once the
+// certstream stops or UnsubscribeBatch is called.
+func (cs *CertStream) SubscribeBatch(maxBatch int, maxWait time.Duration) <-chan []Entry {
+	if maxBatch <= 0 {
+		maxBatch = 1
+	}
+
+	if maxWait <= 0 {
+		maxWait = defaultBatchWait
+	}
+
+	entryChan := cs.Subscribe()
+	batchChan := make(chan []Entry)
+
+	cs.batchSubscribers.Store((<-chan []Entry)(batchChan), entryChan)
+
+	go batchEntries(entryChan, batchChan, maxBatch, maxWait)
+
+	return batchChan
+}
+
+// UnsubscribeBatch removes a subscriber created by SubscribeBatch. Its channel is closed after the pending batch.
+// Unknown channels are ignored.
+func (cs *CertStream) UnsubscribeBatch(batchChan <-chan []Entry) {
+	if entryChan, ok := cs.batchSubscribers.LoadAndDelete(batchChan); ok {
+		cs.Unsubscribe(entryChan.(<-chan Entry))
+	}
+}
+
+// ReleaseBatch returns a batch received from SubscribeBatch, so that its memory is reused for a later batch.
+// The batch must not be used anymore afterward. Releasing batches is optional.
+func ReleaseBatch(batch []Entry) {
+	// The entries are cleared, so that the pool doesn't keep them alive
+	batch = batch[:cap(batch)]
+	clear(batch)
+	batch = batch[:0]
+
+	batchPool.Put(&batch)
+}
+
+// newBatch returns an empty batch with room for size entries, reusing a released batch if possible.
+func newBatch(size int) []Entry {
+	if batch, ok := batchPool.Get().(*[]Entry); ok && cap(*batch) >= size {
+		return (*batch)[:0]
+	}
+
+	return make([]Entry, 0, size)
+}
+
+// batchEntries groups the entries of the input channel into batches of up to maxBatch entries and sends them to the
+// output channel once they are full or maxWait passed since their first entry. The output channel is closed after
+// the input channel was closed and the last batch was sent.
+func batchEntries(input <-chan Entry, output chan<- []Entry, maxBatch int, maxWait time.Duration) {
+	defer close(output)
+
+	timer := time.NewTimer(maxWait)
+	timer.Stop()
+
+	var batch []Entry
+
+	flush := func() {
+		timer.Stop()
+
+		if len(batch) > 0 {
+			output <- batch
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case entry, ok := <-input:
+			if !ok {
+				flush()
+				return
+			}
+
+			if batch == nil {
+				batch = newBatch(maxBatch)
+				timer.Reset(maxWait)
+			}
+
+			batch = append(batch, entry)
+
+			if len(batch) >= maxBatch {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
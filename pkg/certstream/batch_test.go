@@ -0,0 +1,48 @@
+package certstream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchEntries(t *testing.T) {
+	t.Parallel()
+
+	input := make(chan Entry)
+	output := make(chan []Entry)
+
+	go batchEntries(input, output, 2, 50*time.Millisecond)
+
+	// A full batch is sent right away
+	input <- Entry{}
+	input <- Entry{}
+
+	if batch := <-output; len(batch) != 2 {
+		t.Errorf("got batch of %d entries, want 2", len(batch))
+	}
+
+	// A partial batch is sent once the wait time passed
+	input <- Entry{}
+
+	select {
+	case batch := <-output:
+		if len(batch) != 1 {
+			t.Errorf("got batch of %d entries, want 1", len(batch))
+		}
+		ReleaseBatch(batch)
+	case <-time.After(5 * time.Second):
+		t.Fatal("partial batch was not sent after the wait time")
+	}
+
+	// The pending batch is sent before the output channel is closed
+	input <- Entry{}
+	close(input)
+
+	if batch := <-output; len(batch) != 1 {
+		t.Errorf("got batch of %d entries, want 1", len(batch))
+	}
+
+	if _, ok := <-output; ok {
+		t.Error("output channel was not closed")
+	}
+}
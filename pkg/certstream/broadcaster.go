@@ -0,0 +1,330 @@
+package certstream
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/dedup"
+)
+
+// subscriber is a single consumer of the certstream with its own buffered channel.
+type subscriber struct {
+	entryChan chan Entry
+	// out is the channel handed to the consumer. For non-blocking subscribers, it is the same as entryChan.
+	// Blocking subscribers get an unbuffered channel that is fed by forward, so that each entry can be acknowledged
+	// as soon as the consumer received it.
+	out chan Entry
+	// forwarded is closed once forward passed on all entries and closed out. Nil for non-blocking subscribers.
+	forwarded chan struct{}
+	// domainChan receives only the domains of each entry. It is set instead of entryChan and out for the subscribers
+	// created by SubscribeDomains.
+	domainChan chan []string
+	// blocking subscribers apply backpressure to the CT workers. Non-blocking subscribers drop entries if they are full.
+	blocking bool
+	dropped  atomic.Uint64
+	mu       sync.Mutex
+	closed   bool
+}
+
+// send passes the entry to the subscriber. Blocking subscribers wait until there is space in the buffer,
+// all others drop the entry and increment their dropped counter. It returns false if the entry was dropped.
+func (s *subscriber) send(entry Entry) bool {
+	if s.blocking {
+		s.entryChan <- entry
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return true
+	}
+
+	if s.domainChan != nil {
+		return s.sendDomains(entry.Data.LeafCert.AllDomains)
+	}
+
+	select {
+	case s.entryChan <- entry:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// sendDomains passes the domains of an entry to a domains subscriber. Entries without any domains are skipped.
+// It returns false if the domains were dropped.
+func (s *subscriber) sendDomains(domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	select {
+	case s.domainChan <- domains:
+		return true
+	default:
+		s.dropped.Add(1)
+		return false
+	}
+}
+
+// close closes the subscriber's channel. It is safe to call close multiple times.
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.closed = true
+
+	if s.domainChan != nil {
+		close(s.domainChan)
+		return
+	}
+
+	close(s.entryChan)
+}
+
+// forward passes the buffered entries to the consumer one by one and acknowledges each of them once it was received.
+// Once discarded is closed, the remaining entries are dropped without being acknowledged.
+func (s *subscriber) forward(ack func(Entry), discarded <-chan struct{}) {
+	defer close(s.forwarded)
+	defer close(s.out)
+
+	for entry := range s.entryChan {
+		select {
+		case s.out <- entry:
+			if ack != nil {
+				ack(entry)
+			}
+		case <-discarded:
+		}
+	}
+}
+
+// broadcaster copies each entry coming from the watcher to all subscribers.
+type broadcaster struct {
+	// subscribers is replaced on every change (copy-on-write), so that the hot path doesn't need to lock.
+	subscribers atomic.Pointer[[]*subscriber]
+	mu          sync.Mutex
+	done        bool
+
+	// dedup is used to suppress certificates that were already broadcast recently. Nil if deduplication is disabled.
+	dedup          *dedup.Cache
+	duplicateCerts atomic.Uint64
+	// persistentDedup suppresses certificates that were already broadcast before a restart. Nil if it is disabled.
+	persistentDedup     *dedup.Bloom
+	persistentDedupFile string
+	// droppedCerts is the number of entries dropped for all subscribers, including those that unsubscribed since.
+	droppedCerts atomic.Uint64
+	// discard makes the broadcaster drop all remaining entries, so that the watcher can finish without waiting for
+	// the consumer of a blocking subscriber.
+	discard atomic.Bool
+	// discarded is closed by discardRemaining to stop the blocking subscribers from forwarding their buffered entries.
+	discarded chan struct{}
+	// ack is called for each entry received by the consumer of a blocking subscriber and for each suppressed duplicate,
+	// so that the recovery index only covers entries that were handled. Nil if no acknowledgement is needed.
+	ack func(Entry)
+}
+
+// subscribe registers a new subscriber. If the broadcaster already finished, the returned subscriber is closed.
+func (b *broadcaster) subscribe(bufferSize int, blocking bool) *subscriber {
+	sub := &subscriber{
+		entryChan: make(chan Entry, bufferSize),
+		blocking:  blocking,
+	}
+	sub.out = sub.entryChan
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if blocking {
+		if b.discarded == nil {
+			b.discarded = make(chan struct{})
+		}
+
+		sub.out = make(chan Entry)
+		sub.forwarded = make(chan struct{})
+
+		go sub.forward(b.ack, b.discarded)
+	}
+
+	b.add(sub)
+
+	return sub
+}
+
+// subscribeDomains registers a new non-blocking subscriber that only receives the domains of each entry.
+// If the broadcaster already finished, the returned subscriber is closed.
+func (b *broadcaster) subscribeDomains(bufferSize int) *subscriber {
+	sub := &subscriber{domainChan: make(chan []string, bufferSize)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.add(sub)
+
+	return sub
+}
+
+// add appends the subscriber to the list of subscribers or closes it if the broadcaster already finished.
+// b.mu must be held.
+func (b *broadcaster) add(sub *subscriber) {
+	if b.done {
+		sub.close()
+		return
+	}
+
+	var subscribers []*subscriber
+	if current := b.subscribers.Load(); current != nil {
+		subscribers = append(subscribers, *current...)
+	}
+
+	subscribers = append(subscribers, sub)
+	b.subscribers.Store(&subscribers)
+}
+
+// unsubscribe removes the non-blocking subscriber owning the given channel and closes it.
+// Blocking subscribers can't be removed, since the broadcaster might currently be waiting to send to them.
+// It returns false if no such subscriber exists.
+func (b *broadcaster) unsubscribe(entryChan <-chan Entry) bool {
+	return b.remove(func(sub *subscriber) bool {
+		return sub.domainChan == nil && sub.out == entryChan && !sub.blocking
+	})
+}
+
+// unsubscribeDomains removes the domains subscriber owning the given channel and closes it.
+// It returns false if no such subscriber exists.
+func (b *broadcaster) unsubscribeDomains(domainChan <-chan []string) bool {
+	return b.remove(func(sub *subscriber) bool {
+		return sub.domainChan != nil && sub.domainChan == domainChan
+	})
+}
+
+// remove removes the first subscriber matching the given function and closes it.
+// It returns false if no subscriber matches.
+func (b *broadcaster) remove(matches func(*subscriber) bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := b.subscribers.Load()
+	if current == nil {
+		return false
+	}
+
+	subscribers := make([]*subscriber, 0, len(*current))
+	var removed *subscriber
+
+	for _, sub := range *current {
+		if removed == nil && matches(sub) {
+			removed = sub
+			continue
+		}
+
+		subscribers = append(subscribers, sub)
+	}
+
+	if removed == nil {
+		return false
+	}
+
+	b.subscribers.Store(&subscribers)
+	removed.close()
+
+	return true
+}
+
+// find returns the subscriber owning the given channel or nil if there is none.
+func (b *broadcaster) find(entryChan <-chan Entry) *subscriber {
+	current := b.subscribers.Load()
+	if current == nil {
+		return nil
+	}
+
+	for _, sub := range *current {
+		if sub.domainChan == nil && sub.out == entryChan {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+// run copies all entries from the input channel to the subscribers until the input channel is closed.
+// Afterward, all subscriber channels are closed. This method is blocking.
+func (b *broadcaster) run(input <-chan Entry) {
+	for entry := range input {
+		// Certificates are often logged to multiple CT logs - only broadcast the first occurrence
+		if !entry.IsHeartbeat() && b.isDuplicate(entry.Data.LeafCert.Fingerprint) {
+			b.duplicateCerts.Add(1)
+
+			if b.ack != nil {
+				b.ack(entry)
+			}
+
+			continue
+		}
+
+		if b.discard.Load() {
+			continue
+		}
+
+		if current := b.subscribers.Load(); current != nil {
+			for _, sub := range *current {
+				if !sub.send(entry) {
+					b.droppedCerts.Add(1)
+				}
+			}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done = true
+
+	if current := b.subscribers.Load(); current != nil {
+		for _, sub := range *current {
+			sub.close()
+		}
+	}
+
+	b.subscribers.Store(nil)
+}
+
+// isDuplicate returns true if the certificate with the given fingerprint was already broadcast recently or,
+// if the persistent filter is enabled, before the last restart.
+func (b *broadcaster) isDuplicate(fingerprint string) bool {
+	if b.dedup == nil {
+		return false
+	}
+
+	if b.dedup.Seen(fingerprint, time.Now()) {
+		return true
+	}
+
+	return b.persistentDedup != nil && b.persistentDedup.Seen(fingerprint)
+}
+
+// discardRemaining drops all entries that were not received by the blocking subscribers yet, including the ones in
+// their buffers, and all entries that still come in. The discarded entries are not acknowledged.
+// The channels are closed as usual once the input channel is closed.
+func (b *broadcaster) discardRemaining() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.discard.Swap(true) {
+		return
+	}
+
+	if b.discarded == nil {
+		b.discarded = make(chan struct{})
+	}
+
+	close(b.discarded)
+}
@@ -0,0 +1,89 @@
+package certstream
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+func TestBroadcasterDiscardRemaining(t *testing.T) {
+	t.Parallel()
+
+	var acked atomic.Int64
+
+	b := broadcaster{ack: func(Entry) { acked.Add(1) }}
+
+	sub := b.subscribe(1, true)
+	input := make(chan Entry)
+
+	runDone := make(chan struct{})
+	go func() {
+		b.run(input)
+		close(runDone)
+	}()
+
+	input <- Entry{}
+	<-sub.out
+
+	// The next entry waits in the forwarder, the one after fills the buffer and the broadcaster then blocks,
+	// since nobody consumes
+	input <- Entry{}
+	input <- Entry{}
+	input <- Entry{}
+
+	b.discardRemaining()
+
+	// Further entries are dropped instead of blocking the input
+	input <- Entry{}
+	close(input)
+
+	select {
+	case <-runDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcaster did not finish after discardRemaining")
+	}
+
+	// The channel is closed once the remaining entries were discarded
+	received := int64(1)
+	for range sub.out {
+		received++
+	}
+
+	<-sub.forwarded
+
+	// Only the entries received by the consumer are acknowledged
+	if got := acked.Load(); got != received {
+		t.Errorf("acknowledged %d entries, want %d", got, received)
+	}
+}
+
+func TestBroadcasterDomains(t *testing.T) {
+	t.Parallel()
+
+	var b broadcaster
+
+	sub := b.subscribeDomains(2)
+	input := make(chan Entry, 2)
+
+	// Entries without domains are skipped
+	input <- Entry{}
+	input <- Entry{Data: models.Data{LeafCert: models.LeafCert{AllDomains: []string{"example.com"}}}}
+	close(input)
+
+	b.run(input)
+
+	var received [][]string
+	for domains := range sub.domainChan {
+		received = append(received, domains)
+	}
+
+	if len(received) != 1 || len(received[0]) != 1 || received[0][0] != "example.com" {
+		t.Errorf("got domains %v, want [[example.com]]", received)
+	}
+
+	if b.unsubscribeDomains(sub.domainChan) {
+		t.Error("unsubscribed a domains subscriber after the broadcaster finished")
+	}
+}
@@ -0,0 +1,19 @@
+package certstream
+
+// SubscribeDomains returns a new channel that receives only the domains (AllDomains) of every certificate, for the
+// common case of consuming a stream of hostnames. The certificates are parsed in full as for Subscribe, since the
+// deduplication, the filters and the other subscribers need the whole entry; only the channel carries less data.
+// Certificates without any domains are skipped.
+// Deduplication and filters apply as for all other subscribers. The slices are shared with the entries of the other
+// subscribers and must not be modified.
+// Like Subscribe, the channel never slows down the CT workers: certificates are dropped if the consumer can't keep up.
+// The channel is closed once the certstream stops or UnsubscribeDomains is called.
+func (cs *CertStream) SubscribeDomains() <-chan []string {
+	return cs.broadcaster.subscribeDomains(cs.config.General.BufferSizes.BroadcastManager).domainChan
+}
+
+// UnsubscribeDomains removes a subscriber created by SubscribeDomains and closes its channel.
+// Unknown channels are ignored.
+func (cs *CertStream) UnsubscribeDomains(domainChan <-chan []string) {
+	cs.broadcaster.unsubscribeDomains(domainChan)
+}
@@ -1,7 +1,12 @@
 package certstream_test
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os/signal"
+	"regexp"
+	"syscall"
 	"time"
 
 	"github.com/letrics/certstream-server-go/pkg/certstream"
@@ -78,9 +83,135 @@ func ExampleCertStream_slowProcessing() {
 	}
 }
 
+// ExampleCertStream_StartWithContext shows how to tie the certstream lifecycle to your own context
+func ExampleCertStream_StartWithContext() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cs := certstream.New()
+
+	// No signal handlers are registered - the channel is closed once ctx is cancelled
+	certChan := cs.StartWithContext(ctx)
+
+	for cert := range certChan {
+		processCertificate(cert)
+	}
+}
+
+// ExampleCertStream_Errors shows how to get notified about failing CT logs
+func ExampleCertStream_Errors() {
+	cs := certstream.New()
+	certChan := cs.Start()
+
+	go func() {
+		for err := range cs.Errors() {
+			var logErr *certstream.LogError
+			if errors.As(err, &logErr) {
+				log.Printf("CT log '%s' reported an error: %v\n", logErr.LogName, logErr.Err)
+				continue
+			}
+
+			log.Println("Error:", err)
+		}
+	}()
+
+	for cert := range certChan {
+		processCertificate(cert)
+	}
+}
+
+// ExampleCertStream_SetDomainFilter shows how to only receive certificates for specific domains
+func ExampleCertStream_SetDomainFilter() {
+	cs := certstream.New()
+
+	// Only forward certificates for .gov and .bank domains as well as example.com and its subdomains
+	cs.SetDomainFilter([]string{".gov", ".bank", "example.com"})
+
+	certChan := cs.Start()
+
+	for cert := range certChan {
+		processCertificate(cert)
+	}
+}
+
+// ExampleCertStream_SetDomainRegex shows how to hunt for lookalike domains with regular expressions
+func ExampleCertStream_SetDomainRegex() {
+	cs := certstream.New()
+
+	// Forward certificates matching any of the patterns or the suffix filter
+	cs.SetDomainRegex([]*regexp.Regexp{regexp.MustCompile(`paypa[l1]-secure`)})
+	cs.SetDomainFilter([]string{"example.com"})
+
+	certChan := cs.Start()
+
+	for cert := range certChan {
+		processCertificate(cert)
+	}
+}
+
+// ExampleCertStream_OnCertificate shows how to consume certificates with callbacks instead of a channel
+func ExampleCertStream_OnCertificate() {
+	cs := certstream.New()
+
+	// Callbacks are invoked in registration order
+	cs.OnCertificate(processCertificate)
+	cs.OnCertificate(func(cert certstream.Entry) {
+		log.Printf("Seen at: %f\n", cert.Data.Seen)
+	})
+
+	// Blocks until the context is cancelled. The handler is optional if callbacks are registered.
+	_ = cs.Run(context.Background(), nil)
+}
+
+// ExampleCertStream_Run shows how to consume certificates until SIGINT or SIGTERM is received
+func ExampleCertStream_Run() {
+	cs := certstream.New()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// The certstream stops as soon as the handler returns an error
+	if err := cs.Run(ctx, saveCertificate); err != nil {
+		log.Fatalln("Error while handling certificates:", err)
+	}
+}
+
+// ExampleCertStream_Subscribe shows how to feed multiple independent pipelines
+func ExampleCertStream_Subscribe() {
+	cs := certstream.New()
+
+	// Each subscriber gets its own copy of every certificate. A slow subscriber drops certificates instead of
+	// slowing down the others.
+	alerting := cs.Subscribe()
+
+	go func() {
+		for cert := range alerting {
+			processCertificate(cert)
+		}
+
+		log.Printf("Alerting dropped %d certificates\n", cs.Dropped(alerting))
+	}()
+
+	// The primary channel applies backpressure, e.g. for database writes that must not miss certificates
+	for cert := range cs.Start() {
+		processCertificate(cert)
+	}
+}
+
 // Helper function for examples
 func processCertificate(cert certstream.Entry) {
 	// Your custom logic here
 	log.Printf("Domains: %v\n", cert.Data.LeafCert.AllDomains)
 }
 
+
+// Helper function for examples that can fail
+func saveCertificate(cert certstream.Entry) error {
+	if len(cert.Data.LeafCert.AllDomains) == 0 {
+		return errors.New("certificate without domains")
+	}
+
+	log.Printf("Saving certificate for %v\n", cert.Data.LeafCert.AllDomains)
+
+	return nil
+}
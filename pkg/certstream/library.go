@@ -4,36 +4,148 @@ package certstream
 // directly in Go code without needing WebSocket connections.
 
 import (
+	"context"
 	"github.com/letrics/certstream-server-go/pkg/config"
 	"github.com/letrics/certstream-server-go/pkg/models"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+	"github.com/letrics/certstream-server-go/internal/dedup"
+	"github.com/letrics/certstream-server-go/internal/detection"
+	"github.com/letrics/certstream-server-go/internal/logging"
+	"github.com/letrics/certstream-server-go/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // CertStream is a library interface for consuming CT logs directly
 type CertStream struct {
-	watcher  *certificatetransparency.Watcher
-	certChan chan models.Entry
-	config   config.Config
-	doneChan chan struct{}
+	watcher *certificatetransparency.Watcher
+	// sourceChan receives the entries of the watcher, which are then copied to all subscribers by the broadcaster
+	sourceChan  chan models.Entry
+	broadcaster broadcaster
+	// certChan is the channel of the primary subscriber returned by Start
+	certChan <-chan models.Entry
+	// primary is the subscriber owning certChan
+	primary *subscriber
+	// drainedChan is closed once the certstream stopped, the consumer received all certificates of certChan
+	// and their indexes were saved
+	drainedChan chan struct{}
+	config      config.Config
+	doneChan    chan struct{}
+	doneOnce    sync.Once
+	cancel      context.CancelFunc
+	// logger is set via SetLogger or created from the log level of the config on start. Use getLogger to access it.
+	logger logging.Logger
+
+	domainSuffixes []string
+	domainPatterns []*regexp.Regexp
+
+	// batchSubscribers maps the channels returned by SubscribeBatch to the channels of their subscribers
+	batchSubscribers sync.Map
+
+	callbacks   []func(Entry)
+	callbacksMu sync.RWMutex
+	// continueOnError makes Run log errors of the handler instead of stopping.
+	continueOnError bool
+
+	// startedAt is the time the certstream was started in unix nanoseconds. Zero if it was not started yet.
+	startedAt atomic.Int64
+	// statsBaseline contains the counters at the time of the last ResetStats. Nil if the stats were never reset.
+	statsBaseline atomic.Pointer[statsBaseline]
 }
 
 // Entry re-exports the internal Entry type for public use
 type Entry = models.Entry
 
-// NewFromConfig creates a certstream library instance with the provided config
+// MessageTypeHeartbeat is the message type of the heartbeat entries, which are sent if General.Heartbeat is
+// configured and no certificate was delivered for that long. Use Entry.IsHeartbeat to tell them apart.
+const MessageTypeHeartbeat = models.MessageTypeHeartbeat
+
+// ErrUnknownLog is returned when an operation refers to a CT log that is not being watched
+var ErrUnknownLog = certificatetransparency.ErrUnknownLog
+
+// ErrLogAlreadyWatched is returned by AddLog if the CT log is already being watched
+var ErrLogAlreadyWatched = certificatetransparency.ErrLogAlreadyWatched
+
+// ErrCircuitOpen is reported via Errors when requests to a CT log are suspended because it failed too many times in a row
+var ErrCircuitOpen = certificatetransparency.ErrCircuitOpen
+
+// ErrInvalidRange is returned by Backfill if the range of entries is empty or exceeds the tree size of the CT log
+var ErrInvalidRange = certificatetransparency.ErrInvalidRange
+
+// ErrWatcherStopped is returned by AddLog and ReloadLogs once the certstream began to shut down
+var ErrWatcherStopped = certificatetransparency.ErrWatcherStopped
+
+// BackfillProgress re-exports the internal BackfillProgress type, which is the progress of a backfill
+type BackfillProgress = certificatetransparency.BackfillProgress
+
+// LogStats re-exports the internal LogStats type, which contains information about a single watched CT log
+type LogStats = certificatetransparency.LogStats
+
+// RecoveryStore re-exports the internal RecoveryStore interface, which persists the index of the last processed entry
+// of each CT log. The logs are identified by their url without scheme and trailing slash.
+type RecoveryStore = certificatetransparency.RecoveryStore
+
+// FileRecoveryStore re-exports the internal FileRecoveryStore type, which keeps the indexes in a single JSON file
+type FileRecoveryStore = certificatetransparency.FileRecoveryStore
+
+// NewFileRecoveryStore creates a RecoveryStore backed by the JSON file at the given path, as used by EnableRecovery
+func NewFileRecoveryStore(path string) (*FileRecoveryStore, error) {
+	return certificatetransparency.NewFileRecoveryStore(path)
+}
+
+// NewCompressedFileRecoveryStore does the same as NewFileRecoveryStore, but the file is gzip compressed, as used by
+// EnableRecovery if recovery.compress is set in the config
+func NewCompressedFileRecoveryStore(path string) (*FileRecoveryStore, error) {
+	return certificatetransparency.NewCompressedFileRecoveryStore(path)
+}
+
+// LogError re-exports the internal LogError type, which wraps errors that concern a specific CT log
+type LogError = certificatetransparency.LogError
+
+// Logger re-exports the internal Logger interface, a leveled logger with structured fields.
+// The args of each method are alternating keys and values, as with slog.
+type Logger = logging.Logger
+
+// NewSlogLogger returns a Logger that writes to the given slog logger. If it is nil, slog.Default() is used.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return logging.NewSlogLogger(logger)
+}
+
+// NopLogger returns a Logger that discards all logs.
+func NopLogger() Logger {
+	return logging.Nop()
+}
+
+// NewFromConfig creates a certstream library instance with the provided config. The config is expected to have its
+// defaults applied (see config.Config.ApplyDefaults), as done by config.ReadConfig.
 func NewFromConfig(conf config.Config) *CertStream {
-	certChan := make(chan models.Entry, conf.General.BufferSizes.BroadcastManager)
+	// The entries are buffered in the subscriber channels, so the source channel doesn't need a buffer
+	sourceChan := make(chan models.Entry)
 
-	return &CertStream{
-		certChan: certChan,
-		config:   conf,
-		doneChan: make(chan struct{}),
+	cs := &CertStream{
+		watcher:     certificatetransparency.NewWatcher(sourceChan),
+		sourceChan:  sourceChan,
+		config:      conf,
+		doneChan:    make(chan struct{}),
+		drainedChan: make(chan struct{}),
 	}
+
+	// The recovery index only advances for the certificates the consumer of certChan actually received
+	cs.watcher.EnableAcks()
+	cs.broadcaster.ack = cs.watcher.Acknowledge
+
+	return cs
 }
 
 // NewFromConfigFile creates a certstream library instance from a config file
@@ -50,19 +162,17 @@ func NewFromConfigFile(configPath string) (*CertStream, error) {
 func New() *CertStream {
 	conf := config.Config{}
 
-	// Set reasonable defaults
-	conf.General.BufferSizes.CTLog = 1000
+	// The library buffers fewer entries than the server, everything else uses the same defaults
 	conf.General.BufferSizes.BroadcastManager = 5000
-	conf.General.Recovery.Enabled = false
-
-	dropOldLogs := true
-	conf.General.DropOldLogs = &dropOldLogs
+	conf.ApplyDefaults()
 
 	return NewFromConfig(conf)
 }
 
 // Start begins consuming CT logs. Returns a read-only channel you can consume from.
 // This is non-blocking - the watcher runs in the background.
+// Start registers its own SIGINT/SIGTERM handler and stops the certstream when one of these signals is received.
+// If your application handles signals itself, use StartWithContext instead.
 //
 // Usage:
 //
@@ -72,40 +182,380 @@ func New() *CertStream {
 //	    processCertificate(cert)
 //	}
 func (cs *CertStream) Start() <-chan Entry {
-	log.Printf("Starting certstream library v%s\n", config.Version)
+	logger := cs.getLogger()
 
 	// Handle signals for graceful shutdown
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
-		sig := <-signals
-		log.Printf("Received signal %v. Shutting down...\n", sig)
-		cs.Stop()
+		select {
+		case sig := <-signals:
+			logger.Info("Received signal, shutting down", "signal", sig.String())
+			cs.Stop()
+		case <-cs.doneChan:
+		}
+		signal.Stop(signals)
 	}()
 
+	return cs.StartWithContext(context.Background())
+}
+
+// StartWithContext begins consuming CT logs and returns a read-only channel you can consume from.
+// The certstream is stopped and the channel is closed as soon as the given context is cancelled.
+// Unlike Start, no signal handlers are registered, so the lifecycle is entirely controlled by the caller.
+// This is non-blocking - the watcher runs in the background.
+func (cs *CertStream) StartWithContext(ctx context.Context) <-chan Entry {
+	logger := cs.getLogger()
+	logger.Info("Starting certstream library", "version", config.Version)
+
+	cs.startedAt.Store(time.Now().UnixNano())
+
 	// Apply effective config globally so the watcher uses these values
 	config.AppConfig = cs.config
 
-	// Create and start watcher
-	cs.watcher = certificatetransparency.NewWatcher(cs.certChan)
+	var shutdownTracing func(context.Context) error
+	if cs.config.General.Tracing.Enabled {
+		var err error
+		if shutdownTracing, err = tracing.Init(ctx, cs.config.General.Tracing); err != nil {
+			logger.Error("Failed to initialize tracing", "error", err)
+		}
+	}
+
+	cs.watcher.SetSampleRate(cs.config.General.SampleRate)
+
+	cs.watcher.SetHeartbeatInterval(cs.config.General.Heartbeat)
+	cs.watcher.SetLookalikeDetector(detection.NewLookalike(cs.config.Detection.Lookalike))
+	cs.watcher.SetFirstSeenDetector(detection.NewFirstSeen(cs.config.Detection.FirstSeen))
+
+	if dedupConfig := cs.config.General.Deduplicate; dedupConfig.Enabled {
+		cs.broadcaster.dedup = dedup.New(dedupConfig.Capacity, dedupConfig.TTL)
+
+		if dedupConfig.Persistent.Enabled {
+			cs.enablePersistentDedup(dedupConfig.Persistent)
+		}
+	}
+
+	// The primary subscriber applies backpressure, so no certificates are lost if the consumer is slow
+	cs.primary = cs.broadcaster.subscribe(cs.config.General.BufferSizes.BroadcastManager, true)
+	cs.certChan = cs.primary.out
+	go cs.broadcaster.run(cs.sourceChan)
+
+	// The context is created here and not in the watcher's goroutine,
+	// so that Stop can be called safely right after this method returns.
+	var watcherCtx context.Context
+	watcherCtx, cs.cancel = context.WithCancel(ctx)
 
 	// Start watcher in background and signal completion
 	go func() {
-		cs.watcher.Start()
-		close(cs.doneChan)
+		if err := cs.watcher.StartWithContext(watcherCtx); err != nil {
+			logger.Error("Could not start certstream", "error", err)
+		}
+
+		if shutdownTracing != nil {
+			if err := shutdownTracing(context.Background()); err != nil {
+				logger.Error("Error while shutting down tracing", "error", err)
+			}
+		}
+
+		cs.doneOnce.Do(func() { close(cs.doneChan) })
+	}()
+
+	// The watcher saves the indexes one last time when it stops, but the consumer may still receive buffered
+	// certificates afterward. Their indexes are saved once the certificate channel was drained.
+	go func() {
+		<-cs.doneChan
+		<-cs.primary.forwarded
+
+		cs.watcher.SaveIndexes()
+		cs.savePersistentDedup()
+		close(cs.drainedChan)
 	}()
 
 	return cs.certChan
 }
 
+// enablePersistentDedup loads the persistent dedup filter and saves it in the configured interval until the
+// certificate channel was drained.
+func (cs *CertStream) enablePersistentDedup(conf config.PersistentDedupConfig) {
+	logger := cs.getLogger()
+
+	// The config might not be validated, so the defaults are applied here as well
+	path := conf.File
+	if path == "" {
+		path = filepath.Join(filepath.Dir(cs.config.General.Recovery.CTIndexFile), "ct_dedup.bloom")
+	}
+
+	interval := conf.FlushInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	bloom, err := dedup.LoadBloom(path, conf.Capacity, conf.FalsePositiveRate)
+	if err != nil {
+		logger.Warn("Starting with an empty dedup filter", "error", err)
+	}
+
+	cs.broadcaster.persistentDedup = bloom
+	cs.broadcaster.persistentDedupFile = path
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				cs.savePersistentDedup()
+			case <-cs.drainedChan:
+				return
+			}
+		}
+	}()
+}
+
+// savePersistentDedup saves the persistent dedup filter to its file. It is a no-op if the filter is disabled.
+func (cs *CertStream) savePersistentDedup() {
+	if cs.broadcaster.persistentDedup == nil {
+		return
+	}
+
+	if err := cs.broadcaster.persistentDedup.Save(cs.broadcaster.persistentDedupFile); err != nil {
+		cs.getLogger().Error("Could not save dedup filter", "path", cs.broadcaster.persistentDedupFile, "error", err)
+	}
+}
+
+// Subscribe returns a new channel that receives a copy of every certificate. It can be called multiple times,
+// e.g. to feed independent pipelines, and before or after the certstream was started.
+// Unlike the channel returned by Start, subscriber channels never slow down the CT workers: if a subscriber can't
+// keep up and its buffer is full, certificates are dropped for this subscriber only (see Dropped).
+// Keep in mind that the channel returned by Start still needs to be consumed, since it applies backpressure.
+// The channel is closed once the certstream stops or Unsubscribe is called.
+func (cs *CertStream) Subscribe() <-chan Entry {
+	return cs.broadcaster.subscribe(cs.config.General.BufferSizes.BroadcastManager, false).out
+}
+
+// Unsubscribe removes a subscriber created by Subscribe and closes its channel.
+// Unknown channels, including the one returned by Start, are ignored.
+func (cs *CertStream) Unsubscribe(entryChan <-chan Entry) {
+	cs.broadcaster.unsubscribe(entryChan)
+}
+
+// Dropped returns the number of certificates dropped for the given subscriber because its buffer was full.
+func (cs *CertStream) Dropped(entryChan <-chan Entry) uint64 {
+	sub := cs.broadcaster.find(entryChan)
+	if sub == nil {
+		return 0
+	}
+
+	return sub.dropped.Load()
+}
+
+// DuplicateCount returns the number of certificates that were suppressed by the deduplication
+func (cs *CertStream) DuplicateCount() uint64 {
+	return cs.broadcaster.duplicateCerts.Load()
+}
+
+// OnCertificate registers a callback that is invoked by Run for each certificate.
+// Multiple callbacks can be registered; they are invoked sequentially in registration order.
+// Callbacks run on the goroutine that called Run, so a slow callback slows down the CT workers (backpressure).
+// Registering callbacks does not affect the channel returned by Start or StartWithContext.
+func (cs *CertStream) OnCertificate(callback func(Entry)) {
+	cs.callbacksMu.Lock()
+	defer cs.callbacksMu.Unlock()
+
+	cs.callbacks = append(cs.callbacks, callback)
+}
+
+// Run starts the certstream and passes each certificate to the callbacks registered via OnCertificate and then to
+// the handler, which may be nil. It blocks until the context is cancelled or Stop is called and returns nil in that
+// case. No signal handlers are registered, use signal.NotifyContext to stop on SIGINT or SIGTERM.
+// If the handler returns an error, the certstream is stopped and Run returns the error once the workers finished,
+// unless SetContinueOnError is enabled. Then, the error is logged and the next certificate is processed.
+func (cs *CertStream) Run(ctx context.Context, handler func(Entry) error) error {
+	certChan := cs.StartWithContext(ctx)
+
+	for entry := range certChan {
+		cs.dispatch(entry)
+
+		if handler == nil {
+			continue
+		}
+
+		if err := handler(entry); err != nil {
+			if cs.continueOnError {
+				cs.getLogger().Error("Error while handling certificate", "fingerprint", entry.Data.LeafCert.Fingerprint, "error", err)
+				continue
+			}
+
+			cs.Stop()
+
+			// The remaining entries are discarded, so that the workers don't block while shutting down
+			for range certChan {
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetContinueOnError makes Run log errors returned by the handler and continue with the next certificate instead
+// of stopping the certstream.
+func (cs *CertStream) SetContinueOnError(enabled bool) {
+	cs.continueOnError = enabled
+}
+
+// dispatch invokes all registered callbacks for the given entry in registration order.
+func (cs *CertStream) dispatch(entry Entry) {
+	cs.callbacksMu.RLock()
+	defer cs.callbacksMu.RUnlock()
+
+	for _, callback := range cs.callbacks {
+		callback(entry)
+	}
+}
+
+// SetLogger sets the logger used by the certstream and its CT workers, e.g. NewSlogLogger with the slog logger of your
+// application or NopLogger to silence the certstream. A nil logger discards all logs. By default, the logs are written
+// to slog.Default() at the level set via SetLogLevel. It takes precedence over SetLogLevel and SetQuiet.
+// Call it before starting the certstream.
+func (cs *CertStream) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = logging.Nop()
+	}
+
+	cs.logger = logger
+	cs.watcher.SetLogger(logger)
+}
+
+// SetLogLevel sets the minimum level of the default logger: "debug", "info" (the default), "warn" or "error".
+// At debug level, the progress of each CT log is logged once per minute. Call it before starting the certstream.
+func (cs *CertStream) SetLogLevel(level string) {
+	cs.config.General.LogLevel = level
+}
+
+// SetQuiet makes the default logger suppress all logs except errors, regardless of the log level.
+// Call it before starting the certstream.
+func (cs *CertStream) SetQuiet(quiet bool) {
+	cs.config.General.Quiet = quiet
+}
+
+// getLogger returns the logger set via SetLogger. If none was set, a logger is created from the log level and quiet
+// mode of the config and passed to the watcher.
+func (cs *CertStream) getLogger() Logger {
+	if cs.logger == nil {
+		cs.logger = logging.New(cs.config.General.LogLevel, cs.config.General.Quiet)
+		cs.watcher.SetLogger(cs.logger)
+	}
+
+	return cs.logger
+}
+
+// SetTracerProvider makes the certstream create spans for the CT log requests, the parsing of entries and the
+// broadcast with the given provider, e.g. the one already set up by your application. A nil provider disables tracing.
+// The provider is shared by all CertStream instances. Unlike the tracing config, the provider is not shut down on Stop.
+func (cs *CertStream) SetTracerProvider(provider trace.TracerProvider) {
+	tracing.SetTracerProvider(provider)
+}
+
 // Stop gracefully stops the certstream and closes the certificate channel
 func (cs *CertStream) Stop() {
-	log.Println("Stopping certstream library...")
-	if cs.watcher != nil {
-		cs.watcher.Stop()
+	cs.getLogger().Info("Stopping certstream library")
+	if cs.cancel != nil {
+		cs.cancel()
 	}
 }
 
+// StopGraceful stops fetching new certificates, but lets the consumer drain the certificates that were already fetched.
+// It blocks until the certificate channel returned by Start is closed and empty, which means that every certificate
+// was received by the consumer, and the recovery index covering exactly the consumed certificates was saved.
+// If the context is done before, the remaining certificates are discarded, the channel is closed and the context's
+// error is returned. Since the recovery index only advances for received certificates, the discarded ones are
+// fetched again after a restart.
+// Subscribers created via Subscribe are closed as usual, but not waited for.
+func (cs *CertStream) StopGraceful(ctx context.Context) error {
+	if cs.cancel == nil {
+		// The certstream was never started
+		return nil
+	}
+
+	cs.Stop()
+
+	select {
+	case <-cs.drainedChan:
+		return nil
+	case <-ctx.Done():
+		cs.broadcaster.discardRemaining()
+		return ctx.Err()
+	}
+}
+
+// Errors returns a channel of non-fatal errors such as HTTP failures, unreachable CT logs or entries that could not be parsed.
+// Errors concerning a specific CT log are of type *LogError and contain the name and url of the log.
+// The channel is buffered; if you don't drain it, new errors are dropped instead of slowing down the CT workers.
+// The channel is closed once the certstream is stopped.
+func (cs *CertStream) Errors() <-chan error {
+	return cs.watcher.Errors()
+}
+
+// DroppedErrors returns the number of errors that were dropped because the error channel was full
+func (cs *CertStream) DroppedErrors() int64 {
+	return certificatetransparency.GetDroppedErrors()
+}
+
+// PauseLog pauses fetching from the CT log with the given name or url, e.g. while the log is rate-limiting you.
+// The position in the log is kept, so fetching continues where it left off once ResumeLog is called.
+// Entries that were already fetched before the pause are still delivered.
+// An error wrapping ErrUnknownLog is returned if the log is not being watched.
+func (cs *CertStream) PauseLog(name string) error {
+	return cs.watcher.PauseLog(name)
+}
+
+// ResumeLog resumes fetching from a CT log that was paused with PauseLog.
+// An error wrapping ErrUnknownLog is returned if the log is not being watched.
+func (cs *CertStream) ResumeLog(name string) error {
+	return cs.watcher.ResumeLog(name)
+}
+
+// Backfill fetches the entries from start to end, both inclusive, of the CT log with the given name or url,
+// independent of the live stream. The returned channel is closed once all entries were fetched or the certstream stops.
+// The entries are not necessarily in order, are not filtered and are not passed to the subscribers.
+// Errors while fetching the entries are published via Errors.
+// An error wrapping ErrUnknownLog is returned if the log is not being watched and one wrapping ErrInvalidRange if
+// the range is empty or exceeds the tree size of the log.
+func (cs *CertStream) Backfill(name string, start, end uint64) (<-chan Entry, error) {
+	return cs.watcher.Backfill(name, start, end)
+}
+
+// BackfillWithProgress does the same as Backfill, but stops early if the given context is cancelled. If onProgress
+// is not nil, the number of fetched entries and the highest fetched index are reported to it once per second, e.g.
+// for a progress bar, and a last time with Done set before the channel is closed. The last report tells how far the
+// backfill got and, in Err, why it stopped early. onProgress is called from a single goroutine.
+func (cs *CertStream) BackfillWithProgress(ctx context.Context, name string, start, end uint64, onProgress func(BackfillProgress)) (<-chan Entry, error) {
+	return cs.watcher.BackfillWithProgress(ctx, name, start, end, onProgress)
+}
+
+// AddLog adds a custom CT log (e.g. a private or test log) that is not part of the log list.
+// The log must respond to get-sth, otherwise an error is returned. If a public key is given, the signature is verified.
+// The log can be added before or after the certstream was started and takes part in recovery like any other log.
+// An error wrapping ErrLogAlreadyWatched is returned if a log with the same url is already being watched.
+func (cs *CertStream) AddLog(logConfig config.LogConfig) error {
+	return cs.watcher.AddLog(logConfig)
+}
+
+// ReloadLogs re-fetches the CT log list. Workers for newly added logs are started and workers for logs that were
+// removed or retired are stopped (unless drop_old_logs is disabled). Workers of unchanged logs keep their position.
+// The log list is also reloaded automatically once per hour.
+func (cs *CertStream) ReloadLogs() error {
+	return cs.watcher.ReloadLogList()
+}
+
+// LogStats returns a snapshot of all currently watched CT logs, including the effective worker count and batch size
+func (cs *CertStream) LogStats() []LogStats {
+	return cs.watcher.LogStats()
+}
+
 // Wait blocks until the certstream is stopped
 func (cs *CertStream) Wait() {
 	<-cs.doneChan
@@ -117,6 +567,147 @@ func (cs *CertStream) EnableRecovery(indexFilePath string) {
 	cs.config.General.Recovery.CTIndexFile = indexFilePath
 }
 
+// SetRecoveryStore enables recovery with a custom store, e.g. backed by a database shared by multiple instances.
+// The store takes precedence over the index file set via EnableRecovery. Each worker loads the index of its log
+// when it starts, and the changed indexes are saved once per flush interval (see the recovery config, default 5s)
+// and once more when the certstream stops.
+// Call it before starting the certstream.
+func (cs *CertStream) SetRecoveryStore(store RecoveryStore) {
+	cs.watcher.SetRecoveryStore(store)
+}
+
+// SetDomainFilter restricts the certstream to certificates that contain at least one domain matching one of the given suffixes.
+// A suffix matches the domain itself and all of its subdomains, e.g. "example.com" matches "example.com" and "www.example.com".
+// Matching is case-insensitive and ignores trailing dots. Entries are filtered before they are put into the
+// certificate channel, so no buffer space is wasted on discarded certificates. An empty list disables the filter.
+// The suffix filter can be combined with SetDomainRegex - an entry is forwarded if it matches either of them.
+func (cs *CertStream) SetDomainFilter(suffixes []string) {
+	cs.domainSuffixes = suffixes
+	cs.updateDomainFilter()
+}
+
+// SetDomainRegex restricts the certstream to certificates that contain at least one domain matching one of the given
+// regular expressions. Each pattern is evaluated against every domain of a certificate, so the cost per certificate
+// grows with the number of patterns times the number of domains. Prefer SetDomainFilter for plain suffixes, it is
+// considerably cheaper. The regex filter can be combined with SetDomainFilter - an entry is forwarded if it matches
+// either of them. An empty list disables the regex filter.
+func (cs *CertStream) SetDomainRegex(patterns []*regexp.Regexp) {
+	cs.domainPatterns = patterns
+	cs.updateDomainFilter()
+}
+
+// updateDomainFilter passes the combination of the configured suffixes and patterns to the watcher.
+func (cs *CertStream) updateDomainFilter() {
+	cs.watcher.SetDomainFilter(certificatetransparency.NewDomainFilter(cs.domainSuffixes, cs.domainPatterns))
+}
+
+// SetWildcardOnly restricts the certstream to certificates that contain at least one wildcard domain (e.g. "*.example.com").
+// Certificates are discarded right after parsing. Use WildcardFilteredCount to get the number of discarded certificates.
+func (cs *CertStream) SetWildcardOnly(enabled bool) {
+	cs.config.General.WildcardOnly = enabled
+}
+
+// WildcardFilteredCount returns the number of certificates that were discarded because they contain no wildcard domain
+func (cs *CertStream) WildcardFilteredCount() int64 {
+	return certificatetransparency.GetWildcardFilteredCerts()
+}
+
+// SetIPSANOnly restricts the certstream to certificates issued to at least one IP address (see LeafCert.IPAddresses).
+// Certificates are discarded right after parsing. Use IPSANFilteredCount to get the number of discarded certificates.
+func (cs *CertStream) SetIPSANOnly(enabled bool) {
+	cs.config.General.IncludeIPSANOnly = enabled
+}
+
+// IPSANFilteredCount returns the number of certificates that were discarded because they contain no IP address.
+func (cs *CertStream) IPSANFilteredCount() int64 {
+	return certificatetransparency.GetIPSANFilteredCerts()
+}
+
+// SetIssuerFilter restricts the certstream to certificates whose issuer organization or common name contains one of the
+// include strings (if any) and none of the exclude strings, compared case-insensitively, e.g. "Let's Encrypt".
+// Certificates are discarded right after parsing. Use IssuerFilteredCount to get the number of discarded certificates.
+// It must be called before the certstream is started.
+func (cs *CertStream) SetIssuerFilter(include, exclude []string) {
+	cs.config.General.IncludeIssuers = include
+	cs.config.General.ExcludeIssuers = exclude
+}
+
+// IssuerFilteredCount returns the number of certificates that were discarded because of the issuer filter.
+func (cs *CertStream) IssuerFilteredCount() int64 {
+	return certificatetransparency.GetIssuerFilteredCerts()
+}
+
+// SetSANCountRange restricts the certstream to certificates with at least minSANs and at most maxSANs names, counted
+// by LeafCert.SANCount. Zero means unbounded on that side. Certificates are discarded right after parsing.
+// Use SANCountFilteredCount to get the number of discarded certificates. It must be called before the certstream is started.
+func (cs *CertStream) SetSANCountRange(minSANs, maxSANs int) {
+	cs.config.General.MinSANs = minSANs
+	cs.config.General.MaxSANs = maxSANs
+}
+
+// SANCountFilteredCount returns the number of certificates that were discarded because their number of SANs is
+// outside the range set with SetSANCountRange.
+func (cs *CertStream) SANCountFilteredCount() int64 {
+	return certificatetransparency.GetSANCountFilteredCerts()
+}
+
+// SetLookalikeWatchlist enables the detection of certificates for domains that are confusable with the given domains,
+// e.g. "paypa1.com" or "pаypal.com" with a cyrillic "а" for "paypal.com". The matched watchlist domains are attached to
+// the entries in Data.Detection.LookalikeMatches. threshold is the minimum similarity between 0 and 1; 0 uses the
+// default of 0.85. An empty watchlist disables the detection. It must be called before the certstream is started.
+func (cs *CertStream) SetLookalikeWatchlist(domains []string, threshold float64) {
+	cs.config.Detection.Lookalike = config.LookalikeConfig{Watchlist: domains, Threshold: threshold}
+}
+
+// SetFirstSeenDetection enables flagging certificates for registrable domains that were not seen within the given
+// window, which hints at newly registered domains. Such entries have Data.Detection.FirstSeenDomain set. At most
+// capacity domains are remembered; 0 uses the defaults of 7 days and 500000 domains. Since the domains are only kept
+// in memory, all domains count as new after a start. It must be called before the certstream is started.
+func (cs *CertStream) SetFirstSeenDetection(window time.Duration, capacity int) {
+	cs.config.Detection.FirstSeen = config.FirstSeenConfig{Enabled: true, Window: window, Capacity: capacity}
+}
+
+// SetExcludePrecerts makes the certstream discard precertificates right after parsing, so that each certificate is
+// only received once as final certificate. Use PrecertFilteredCount to get the number of discarded precertificates.
+func (cs *CertStream) SetExcludePrecerts(enabled bool) {
+	cs.config.General.ExcludePrecerts = enabled
+}
+
+// PrecertFilteredCount returns the number of precertificates that were discarded because precertificates are excluded.
+func (cs *CertStream) PrecertFilteredCount() int64 {
+	return certificatetransparency.GetPrecertFilteredCerts()
+}
+
+// SetSampleRate makes the certstream forward only a random fraction of the certificates, e.g. 0.01 for about one in
+// a hundred. The rate is between 0 and 1, where 1 (the default) forwards all certificates. Certificates are sampled
+// after parsing and filtering, but before they are put into the certificate channel. Since sampling happens before the
+// deduplication, a certificate logged to multiple CT logs is more likely to be forwarded than one logged to a single log.
+// The rate can be changed while the certstream is running.
+func (cs *CertStream) SetSampleRate(rate float64) {
+	cs.config.General.SampleRate = rate
+	cs.watcher.SetSampleRate(rate)
+}
+
+// SetHeartbeat makes the certstream emit a heartbeat entry whenever no certificate was delivered for the given
+// interval, so that consumers of a narrowly filtered stream can tell that the watcher is still alive. Heartbeats are
+// passed to all subscribers and callbacks and can be recognized with Entry.IsHeartbeat. They are disabled by default.
+// Call it before starting the certstream.
+func (cs *CertStream) SetHeartbeat(interval time.Duration) {
+	cs.config.General.Heartbeat = interval
+}
+
+// SampledOutCount returns the number of certificates that were discarded by the sampling
+func (cs *CertStream) SampledOutCount() int64 {
+	return certificatetransparency.GetSampledOutCerts()
+}
+
+// SetHTTPClient sets the HTTP client used for all requests to the CT logs and the log list, e.g. to use custom TLS
+// roots or a proxy. It replaces the http_client options of the config. Backoff and tracing are still applied on top
+// of the client's transport, and its timeout is applied to each attempt. Call it before starting the certstream.
+func (cs *CertStream) SetHTTPClient(httpClient *http.Client) {
+	cs.watcher.SetHTTPClient(httpClient)
+}
+
 // SetBufferSizes configures the buffer sizes for the CT log fetching and certificate processing
 func (cs *CertStream) SetBufferSizes(ctLogBuffer, broadcastBuffer int) {
 	cs.config.General.BufferSizes.CTLog = ctLogBuffer
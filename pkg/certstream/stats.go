@@ -0,0 +1,100 @@
+package certstream
+
+import (
+	"time"
+
+	"github.com/letrics/certstream-server-go/internal/certificatetransparency"
+)
+
+// Stats is a snapshot of the statistics of the certstream. The counters cover the time since the certstream was
+// started or, if ResetStats was called, since the last reset.
+type Stats struct {
+	// Uptime is the time since the certstream was started. It is not affected by ResetStats.
+	Uptime time.Duration
+	// ResetAt is the time of the last call of ResetStats. Zero if the stats were never reset.
+	ResetAt time.Time
+	// TotalEntries is the number of entries processed by all CT logs, i.e. ProcessedCerts + ProcessedPrecerts
+	TotalEntries int64
+	// ProcessedCerts is the number of regular certificates processed by all CT logs
+	ProcessedCerts int64
+	// ProcessedPrecerts is the number of precertificates processed by all CT logs
+	ProcessedPrecerts int64
+	// Dropped is the number of certificates dropped for subscribers that couldn't keep up (see Subscribe)
+	Dropped uint64
+	// BytesFetched is the number of bytes of the responses received from all CT logs
+	BytesFetched int64
+	// Logs contains the statistics of each watched CT log, such as the number of processed entries, the last index
+	// and the gap between the last known tree size and the processed index.
+	// Only Entries, BytesFetched and GetEntriesRequests are affected by ResetStats.
+	Logs []LogStats
+}
+
+// statsBaseline contains the counters at the time of the last reset, which are subtracted from the current ones.
+type statsBaseline struct {
+	resetAt           time.Time
+	processedCerts    int64
+	processedPrecerts int64
+	dropped           uint64
+	bytesFetched      int64
+	// logs contains the entries, bytes and requests of each log, keyed by url
+	logs map[string]LogStats
+}
+
+// Stats returns a snapshot of the statistics of the certstream. It is cheap and safe to call Stats concurrently.
+func (cs *CertStream) Stats() Stats {
+	stats := Stats{
+		ProcessedCerts:    certificatetransparency.GetProcessedCerts(),
+		ProcessedPrecerts: certificatetransparency.GetProcessedPrecerts(),
+		Dropped:           cs.broadcaster.droppedCerts.Load(),
+		BytesFetched:      certificatetransparency.GetFetchedBytes(),
+		Logs:              cs.watcher.LogStats(),
+	}
+
+	if started := cs.startedAt.Load(); started != 0 {
+		stats.Uptime = time.Since(time.Unix(0, started))
+	}
+
+	if baseline := cs.statsBaseline.Load(); baseline != nil {
+		stats.ResetAt = baseline.resetAt
+		stats.ProcessedCerts -= baseline.processedCerts
+		stats.ProcessedPrecerts -= baseline.processedPrecerts
+		stats.Dropped -= baseline.dropped
+		stats.BytesFetched -= baseline.bytesFetched
+
+		for i := range stats.Logs {
+			logStats := &stats.Logs[i]
+
+			// Workers that were restarted in the meantime started counting from zero again
+			if previous, ok := baseline.logs[logStats.URL]; ok && previous.Entries <= logStats.Entries {
+				logStats.Entries -= previous.Entries
+				logStats.BytesFetched -= min(previous.BytesFetched, logStats.BytesFetched)
+				logStats.GetEntriesRequests -= min(previous.GetEntriesRequests, logStats.GetEntriesRequests)
+			}
+		}
+	}
+
+	stats.TotalEntries = stats.ProcessedCerts + stats.ProcessedPrecerts
+
+	return stats
+}
+
+// ResetStats resets the counters of Stats, so that subsequent snapshots only cover the time since the reset.
+// This is useful to get periodic deltas in long-running processes. The uptime and the position of each log are kept.
+func (cs *CertStream) ResetStats() {
+	logs := cs.watcher.LogStats()
+
+	baseline := &statsBaseline{
+		resetAt:           time.Now(),
+		processedCerts:    certificatetransparency.GetProcessedCerts(),
+		processedPrecerts: certificatetransparency.GetProcessedPrecerts(),
+		dropped:           cs.broadcaster.droppedCerts.Load(),
+		bytesFetched:      certificatetransparency.GetFetchedBytes(),
+		logs:              make(map[string]LogStats, len(logs)),
+	}
+
+	for _, logStats := range logs {
+		baseline.logs[logStats.URL] = logStats
+	}
+
+	cs.statsBaseline.Store(baseline)
+}
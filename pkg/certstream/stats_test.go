@@ -0,0 +1,30 @@
+package certstream
+
+import "testing"
+
+func TestResetStats(t *testing.T) {
+	t.Parallel()
+
+	cs := New()
+	cs.broadcaster.droppedCerts.Add(3)
+
+	if got := cs.Stats().Dropped; got != 3 {
+		t.Fatalf("Dropped = %d before reset, want 3", got)
+	}
+
+	cs.ResetStats()
+	cs.broadcaster.droppedCerts.Add(2)
+
+	stats := cs.Stats()
+	if stats.Dropped != 2 {
+		t.Errorf("Dropped = %d after reset, want 2", stats.Dropped)
+	}
+
+	if stats.ResetAt.IsZero() {
+		t.Error("ResetAt is zero after reset")
+	}
+
+	if stats.Uptime != 0 {
+		t.Errorf("Uptime = %s before start, want 0", stats.Uptime)
+	}
+}
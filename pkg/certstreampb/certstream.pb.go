@@ -0,0 +1,636 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: certstream.proto
+
+package certstreampb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StreamRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only certificates containing at least one domain that equals or is a subdomain of one of these suffixes are
+	// streamed, e.g. "example.com" matches "example.com" and "www.example.com". Empty means all certificates.
+	DomainSuffixes []string `protobuf:"bytes,1,rep,name=domain_suffixes,json=domainSuffixes,proto3" json:"domain_suffixes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_certstream_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamRequest) GetDomainSuffixes() []string {
+	if x != nil {
+		return x.DomainSuffixes
+	}
+	return nil
+}
+
+type Certificate struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	MessageType string                 `protobuf:"bytes,1,opt,name=message_type,json=messageType,proto3" json:"message_type,omitempty"`
+	UpdateType  string                 `protobuf:"bytes,2,opt,name=update_type,json=updateType,proto3" json:"update_type,omitempty"`
+	// Index of the entry in the tree of the CT log it was fetched from.
+	CertIndex uint64 `protobuf:"varint,3,opt,name=cert_index,json=certIndex,proto3" json:"cert_index,omitempty"`
+	CertLink  string `protobuf:"bytes,4,opt,name=cert_link,json=certLink,proto3" json:"cert_link,omitempty"`
+	// Unix timestamp in seconds when the certificate was seen.
+	Seen          float64     `protobuf:"fixed64,5,opt,name=seen,proto3" json:"seen,omitempty"`
+	Source        *Source     `protobuf:"bytes,6,opt,name=source,proto3" json:"source,omitempty"`
+	LeafCert      *LeafCert   `protobuf:"bytes,7,opt,name=leaf_cert,json=leafCert,proto3" json:"leaf_cert,omitempty"`
+	Chain         []*LeafCert `protobuf:"bytes,8,rep,name=chain,proto3" json:"chain,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Certificate) Reset() {
+	*x = Certificate{}
+	mi := &file_certstream_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Certificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Certificate) ProtoMessage() {}
+
+func (x *Certificate) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Certificate.ProtoReflect.Descriptor instead.
+func (*Certificate) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Certificate) GetMessageType() string {
+	if x != nil {
+		return x.MessageType
+	}
+	return ""
+}
+
+func (x *Certificate) GetUpdateType() string {
+	if x != nil {
+		return x.UpdateType
+	}
+	return ""
+}
+
+func (x *Certificate) GetCertIndex() uint64 {
+	if x != nil {
+		return x.CertIndex
+	}
+	return 0
+}
+
+func (x *Certificate) GetCertLink() string {
+	if x != nil {
+		return x.CertLink
+	}
+	return ""
+}
+
+func (x *Certificate) GetSeen() float64 {
+	if x != nil {
+		return x.Seen
+	}
+	return 0
+}
+
+func (x *Certificate) GetSource() *Source {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *Certificate) GetLeafCert() *LeafCert {
+	if x != nil {
+		return x.LeafCert
+	}
+	return nil
+}
+
+func (x *Certificate) GetChain() []*LeafCert {
+	if x != nil {
+		return x.Chain
+	}
+	return nil
+}
+
+type Source struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Url           string                 `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Source) Reset() {
+	*x = Source{}
+	mi := &file_certstream_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Source) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Source) ProtoMessage() {}
+
+func (x *Source) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Source.ProtoReflect.Descriptor instead.
+func (*Source) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Source) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Source) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type LeafCert struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	AllDomains []string               `protobuf:"bytes,1,rep,name=all_domains,json=allDomains,proto3" json:"all_domains,omitempty"`
+	// DER encoded certificate.
+	Der         []byte `protobuf:"bytes,2,opt,name=der,proto3" json:"der,omitempty"`
+	Fingerprint string `protobuf:"bytes,3,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Sha1        string `protobuf:"bytes,4,opt,name=sha1,proto3" json:"sha1,omitempty"`
+	Sha256      string `protobuf:"bytes,5,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	// Unix timestamps in seconds.
+	NotBefore          int64    `protobuf:"varint,6,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	NotAfter           int64    `protobuf:"varint,7,opt,name=not_after,json=notAfter,proto3" json:"not_after,omitempty"`
+	SerialNumber       string   `protobuf:"bytes,8,opt,name=serial_number,json=serialNumber,proto3" json:"serial_number,omitempty"`
+	SignatureAlgorithm string   `protobuf:"bytes,9,opt,name=signature_algorithm,json=signatureAlgorithm,proto3" json:"signature_algorithm,omitempty"`
+	Subject            *Subject `protobuf:"bytes,10,opt,name=subject,proto3" json:"subject,omitempty"`
+	Issuer             *Subject `protobuf:"bytes,11,opt,name=issuer,proto3" json:"issuer,omitempty"`
+	IsCa               bool     `protobuf:"varint,12,opt,name=is_ca,json=isCa,proto3" json:"is_ca,omitempty"`
+	Scts               []*SCT   `protobuf:"bytes,13,rep,name=scts,proto3" json:"scts,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *LeafCert) Reset() {
+	*x = LeafCert{}
+	mi := &file_certstream_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LeafCert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LeafCert) ProtoMessage() {}
+
+func (x *LeafCert) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LeafCert.ProtoReflect.Descriptor instead.
+func (*LeafCert) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LeafCert) GetAllDomains() []string {
+	if x != nil {
+		return x.AllDomains
+	}
+	return nil
+}
+
+func (x *LeafCert) GetDer() []byte {
+	if x != nil {
+		return x.Der
+	}
+	return nil
+}
+
+func (x *LeafCert) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *LeafCert) GetSha1() string {
+	if x != nil {
+		return x.Sha1
+	}
+	return ""
+}
+
+func (x *LeafCert) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *LeafCert) GetNotBefore() int64 {
+	if x != nil {
+		return x.NotBefore
+	}
+	return 0
+}
+
+func (x *LeafCert) GetNotAfter() int64 {
+	if x != nil {
+		return x.NotAfter
+	}
+	return 0
+}
+
+func (x *LeafCert) GetSerialNumber() string {
+	if x != nil {
+		return x.SerialNumber
+	}
+	return ""
+}
+
+func (x *LeafCert) GetSignatureAlgorithm() string {
+	if x != nil {
+		return x.SignatureAlgorithm
+	}
+	return ""
+}
+
+func (x *LeafCert) GetSubject() *Subject {
+	if x != nil {
+		return x.Subject
+	}
+	return nil
+}
+
+func (x *LeafCert) GetIssuer() *Subject {
+	if x != nil {
+		return x.Issuer
+	}
+	return nil
+}
+
+func (x *LeafCert) GetIsCa() bool {
+	if x != nil {
+		return x.IsCa
+	}
+	return false
+}
+
+func (x *LeafCert) GetScts() []*SCT {
+	if x != nil {
+		return x.Scts
+	}
+	return nil
+}
+
+type Subject struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	C             string                 `protobuf:"bytes,1,opt,name=c,proto3" json:"c,omitempty"`
+	Cn            string                 `protobuf:"bytes,2,opt,name=cn,proto3" json:"cn,omitempty"`
+	L             string                 `protobuf:"bytes,3,opt,name=l,proto3" json:"l,omitempty"`
+	O             string                 `protobuf:"bytes,4,opt,name=o,proto3" json:"o,omitempty"`
+	Ou            string                 `protobuf:"bytes,5,opt,name=ou,proto3" json:"ou,omitempty"`
+	St            string                 `protobuf:"bytes,6,opt,name=st,proto3" json:"st,omitempty"`
+	Aggregated    string                 `protobuf:"bytes,7,opt,name=aggregated,proto3" json:"aggregated,omitempty"`
+	EmailAddress  string                 `protobuf:"bytes,8,opt,name=email_address,json=emailAddress,proto3" json:"email_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subject) Reset() {
+	*x = Subject{}
+	mi := &file_certstream_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subject) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subject) ProtoMessage() {}
+
+func (x *Subject) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subject.ProtoReflect.Descriptor instead.
+func (*Subject) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Subject) GetC() string {
+	if x != nil {
+		return x.C
+	}
+	return ""
+}
+
+func (x *Subject) GetCn() string {
+	if x != nil {
+		return x.Cn
+	}
+	return ""
+}
+
+func (x *Subject) GetL() string {
+	if x != nil {
+		return x.L
+	}
+	return ""
+}
+
+func (x *Subject) GetO() string {
+	if x != nil {
+		return x.O
+	}
+	return ""
+}
+
+func (x *Subject) GetOu() string {
+	if x != nil {
+		return x.Ou
+	}
+	return ""
+}
+
+func (x *Subject) GetSt() string {
+	if x != nil {
+		return x.St
+	}
+	return ""
+}
+
+func (x *Subject) GetAggregated() string {
+	if x != nil {
+		return x.Aggregated
+	}
+	return ""
+}
+
+func (x *Subject) GetEmailAddress() string {
+	if x != nil {
+		return x.EmailAddress
+	}
+	return ""
+}
+
+// Signed Certificate Timestamp embedded in a certificate.
+type SCT struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Base64 encoded ID of the CT log that issued the SCT.
+	LogId string `protobuf:"bytes,1,opt,name=log_id,json=logId,proto3" json:"log_id,omitempty"`
+	// Unix timestamp in milliseconds.
+	Timestamp     int64 `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SCT) Reset() {
+	*x = SCT{}
+	mi := &file_certstream_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SCT) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SCT) ProtoMessage() {}
+
+func (x *SCT) ProtoReflect() protoreflect.Message {
+	mi := &file_certstream_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SCT.ProtoReflect.Descriptor instead.
+func (*SCT) Descriptor() ([]byte, []int) {
+	return file_certstream_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SCT) GetLogId() string {
+	if x != nil {
+		return x.LogId
+	}
+	return ""
+}
+
+func (x *SCT) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+var File_certstream_proto protoreflect.FileDescriptor
+
+const file_certstream_proto_rawDesc = "" +
+	"\n" +
+	"\x10certstream.proto\x12\rcertstream.v1\"8\n" +
+	"\rStreamRequest\x12'\n" +
+	"\x0fdomain_suffixes\x18\x01 \x03(\tR\x0edomainSuffixes\"\xb5\x02\n" +
+	"\vCertificate\x12!\n" +
+	"\fmessage_type\x18\x01 \x01(\tR\vmessageType\x12\x1f\n" +
+	"\vupdate_type\x18\x02 \x01(\tR\n" +
+	"updateType\x12\x1d\n" +
+	"\n" +
+	"cert_index\x18\x03 \x01(\x04R\tcertIndex\x12\x1b\n" +
+	"\tcert_link\x18\x04 \x01(\tR\bcertLink\x12\x12\n" +
+	"\x04seen\x18\x05 \x01(\x01R\x04seen\x12-\n" +
+	"\x06source\x18\x06 \x01(\v2\x15.certstream.v1.SourceR\x06source\x124\n" +
+	"\tleaf_cert\x18\a \x01(\v2\x17.certstream.v1.LeafCertR\bleafCert\x12-\n" +
+	"\x05chain\x18\b \x03(\v2\x17.certstream.v1.LeafCertR\x05chain\".\n" +
+	"\x06Source\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"\xbc\x03\n" +
+	"\bLeafCert\x12\x1f\n" +
+	"\vall_domains\x18\x01 \x03(\tR\n" +
+	"allDomains\x12\x10\n" +
+	"\x03der\x18\x02 \x01(\fR\x03der\x12 \n" +
+	"\vfingerprint\x18\x03 \x01(\tR\vfingerprint\x12\x12\n" +
+	"\x04sha1\x18\x04 \x01(\tR\x04sha1\x12\x16\n" +
+	"\x06sha256\x18\x05 \x01(\tR\x06sha256\x12\x1d\n" +
+	"\n" +
+	"not_before\x18\x06 \x01(\x03R\tnotBefore\x12\x1b\n" +
+	"\tnot_after\x18\a \x01(\x03R\bnotAfter\x12#\n" +
+	"\rserial_number\x18\b \x01(\tR\fserialNumber\x12/\n" +
+	"\x13signature_algorithm\x18\t \x01(\tR\x12signatureAlgorithm\x120\n" +
+	"\asubject\x18\n" +
+	" \x01(\v2\x16.certstream.v1.SubjectR\asubject\x12.\n" +
+	"\x06issuer\x18\v \x01(\v2\x16.certstream.v1.SubjectR\x06issuer\x12\x13\n" +
+	"\x05is_ca\x18\f \x01(\bR\x04isCa\x12&\n" +
+	"\x04scts\x18\r \x03(\v2\x12.certstream.v1.SCTR\x04scts\"\xa8\x01\n" +
+	"\aSubject\x12\f\n" +
+	"\x01c\x18\x01 \x01(\tR\x01c\x12\x0e\n" +
+	"\x02cn\x18\x02 \x01(\tR\x02cn\x12\f\n" +
+	"\x01l\x18\x03 \x01(\tR\x01l\x12\f\n" +
+	"\x01o\x18\x04 \x01(\tR\x01o\x12\x0e\n" +
+	"\x02ou\x18\x05 \x01(\tR\x02ou\x12\x0e\n" +
+	"\x02st\x18\x06 \x01(\tR\x02st\x12\x1e\n" +
+	"\n" +
+	"aggregated\x18\a \x01(\tR\n" +
+	"aggregated\x12#\n" +
+	"\remail_address\x18\b \x01(\tR\femailAddress\":\n" +
+	"\x03SCT\x12\x15\n" +
+	"\x06log_id\x18\x01 \x01(\tR\x05logId\x12\x1c\n" +
+	"\ttimestamp\x18\x02 \x01(\x03R\ttimestamp2^\n" +
+	"\n" +
+	"CertStream\x12P\n" +
+	"\x12StreamCertificates\x12\x1c.certstream.v1.StreamRequest\x1a\x1a.certstream.v1.Certificate0\x01B:Z8github.com/letrics/certstream-server-go/pkg/certstreampbb\x06proto3"
+
+var (
+	file_certstream_proto_rawDescOnce sync.Once
+	file_certstream_proto_rawDescData []byte
+)
+
+func file_certstream_proto_rawDescGZIP() []byte {
+	file_certstream_proto_rawDescOnce.Do(func() {
+		file_certstream_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_certstream_proto_rawDesc), len(file_certstream_proto_rawDesc)))
+	})
+	return file_certstream_proto_rawDescData
+}
+
+var file_certstream_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_certstream_proto_goTypes = []any{
+	(*StreamRequest)(nil), // 0: certstream.v1.StreamRequest
+	(*Certificate)(nil),   // 1: certstream.v1.Certificate
+	(*Source)(nil),        // 2: certstream.v1.Source
+	(*LeafCert)(nil),      // 3: certstream.v1.LeafCert
+	(*Subject)(nil),       // 4: certstream.v1.Subject
+	(*SCT)(nil),           // 5: certstream.v1.SCT
+}
+var file_certstream_proto_depIdxs = []int32{
+	2, // 0: certstream.v1.Certificate.source:type_name -> certstream.v1.Source
+	3, // 1: certstream.v1.Certificate.leaf_cert:type_name -> certstream.v1.LeafCert
+	3, // 2: certstream.v1.Certificate.chain:type_name -> certstream.v1.LeafCert
+	4, // 3: certstream.v1.LeafCert.subject:type_name -> certstream.v1.Subject
+	4, // 4: certstream.v1.LeafCert.issuer:type_name -> certstream.v1.Subject
+	5, // 5: certstream.v1.LeafCert.scts:type_name -> certstream.v1.SCT
+	0, // 6: certstream.v1.CertStream.StreamCertificates:input_type -> certstream.v1.StreamRequest
+	1, // 7: certstream.v1.CertStream.StreamCertificates:output_type -> certstream.v1.Certificate
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_certstream_proto_init() }
+func file_certstream_proto_init() {
+	if File_certstream_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_certstream_proto_rawDesc), len(file_certstream_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_certstream_proto_goTypes,
+		DependencyIndexes: file_certstream_proto_depIdxs,
+		MessageInfos:      file_certstream_proto_msgTypes,
+	}.Build()
+	File_certstream_proto = out.File
+	file_certstream_proto_goTypes = nil
+	file_certstream_proto_depIdxs = nil
+}
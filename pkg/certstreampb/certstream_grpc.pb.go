@@ -0,0 +1,130 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: certstream.proto
+
+package certstreampb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CertStream_StreamCertificates_FullMethodName = "/certstream.v1.CertStream/StreamCertificates"
+)
+
+// CertStreamClient is the client API for CertStream service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CertStream streams the certificates found in the Certificate Transparency logs.
+type CertStreamClient interface {
+	// StreamCertificates streams all new certificates matching the request until the client cancels the call.
+	StreamCertificates(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Certificate], error)
+}
+
+type certStreamClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCertStreamClient(cc grpc.ClientConnInterface) CertStreamClient {
+	return &certStreamClient{cc}
+}
+
+func (c *certStreamClient) StreamCertificates(ctx context.Context, in *StreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Certificate], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CertStream_ServiceDesc.Streams[0], CertStream_StreamCertificates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamRequest, Certificate]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CertStream_StreamCertificatesClient = grpc.ServerStreamingClient[Certificate]
+
+// CertStreamServer is the server API for CertStream service.
+// All implementations must embed UnimplementedCertStreamServer
+// for forward compatibility.
+//
+// CertStream streams the certificates found in the Certificate Transparency logs.
+type CertStreamServer interface {
+	// StreamCertificates streams all new certificates matching the request until the client cancels the call.
+	StreamCertificates(*StreamRequest, grpc.ServerStreamingServer[Certificate]) error
+	mustEmbedUnimplementedCertStreamServer()
+}
+
+// UnimplementedCertStreamServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCertStreamServer struct{}
+
+func (UnimplementedCertStreamServer) StreamCertificates(*StreamRequest, grpc.ServerStreamingServer[Certificate]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamCertificates not implemented")
+}
+func (UnimplementedCertStreamServer) mustEmbedUnimplementedCertStreamServer() {}
+func (UnimplementedCertStreamServer) testEmbeddedByValue()                    {}
+
+// UnsafeCertStreamServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CertStreamServer will
+// result in compilation errors.
+type UnsafeCertStreamServer interface {
+	mustEmbedUnimplementedCertStreamServer()
+}
+
+func RegisterCertStreamServer(s grpc.ServiceRegistrar, srv CertStreamServer) {
+	// If the following call pancis, it indicates UnimplementedCertStreamServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CertStream_ServiceDesc, srv)
+}
+
+func _CertStream_StreamCertificates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CertStreamServer).StreamCertificates(m, &grpc.GenericServerStream[StreamRequest, Certificate]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CertStream_StreamCertificatesServer = grpc.ServerStreamingServer[Certificate]
+
+// CertStream_ServiceDesc is the grpc.ServiceDesc for CertStream service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CertStream_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "certstream.v1.CertStream",
+	HandlerType: (*CertStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamCertificates",
+			Handler:       _CertStream_StreamCertificates_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "certstream.proto",
+}
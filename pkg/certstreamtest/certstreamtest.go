@@ -0,0 +1,278 @@
+// Package certstreamtest provides a fake CT log for testing applications that consume the certstream library. The log
+// runs in-process and serves get-sth and get-entries from canned certificates, so that tests neither depend on the
+// network nor on the certificates that happen to be logged at the time.
+//
+//	ctLog := certstreamtest.NewLog(t, certstreamtest.Fixtures()...)
+//	cs := certstreamtest.NewCertStream(t, ctLog)
+//
+//	for entry := range cs.Start() {
+//	    // The entries arrive in the order of the log
+//	}
+package certstreamtest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	ctx509 "github.com/google/certificate-transparency-go/x509"
+	"github.com/letrics/certstream-server-go/pkg/certstream"
+	"github.com/letrics/certstream-server-go/pkg/config"
+)
+
+const (
+	// maxEntriesPerRequest is the maximum number of entries the log returns per get-entries request, like real logs
+	// do. The watcher caps its batch size accordingly.
+	maxEntriesPerRequest = 100
+	// sthPollInterval is the time between two get-sth requests of the certstream created by NewCertStream, so that
+	// certificates added to a running log show up quickly.
+	sthPollInterval = 50 * time.Millisecond
+)
+
+// oidCTPoison is the OID of the poison extension, which marks a precertificate.
+var oidCTPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+// Cert describes a certificate of the fake log.
+type Cert struct {
+	// Domains are the DNS names of the certificate. The first one is used as common name as well.
+	Domains []string
+	// Precert makes the entry a precertificate instead of a final certificate.
+	Precert bool
+}
+
+// The fixtures cover the kinds of certificates that consumers usually treat differently.
+var (
+	// NormalCert is a final certificate for example.com and www.example.com.
+	NormalCert = Cert{Domains: []string{"example.com", "www.example.com"}}
+	// PrecertCert is a precertificate for precert.example.com.
+	PrecertCert = Cert{Domains: []string{"precert.example.com"}, Precert: true}
+	// WildcardCert is a final certificate for *.example.com and example.com.
+	WildcardCert = Cert{Domains: []string{"*.example.com", "example.com"}}
+	// IDNCert is a final certificate for the internationalized domain bücher.example, encoded as xn--bcher-kva.example.
+	IDNCert = Cert{Domains: []string{"xn--bcher-kva.example"}}
+)
+
+// Fixtures returns one certificate of each kind: NormalCert, PrecertCert, WildcardCert and IDNCert.
+func Fixtures() []Cert {
+	return []Cert{NormalCert, PrecertCert, WildcardCert, IDNCert}
+}
+
+// Log is a fake CT log that serves the certificates added to it. The signatures of its tree heads are not valid, so
+// it must be watched without public key.
+type Log struct {
+	tb     testing.TB
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mu      sync.Mutex
+	entries []ct.LeafEntry
+}
+
+// NewLog starts a fake CT log holding the given certificates. It is closed when the test finishes.
+func NewLog(tb testing.TB, certs ...Cert) *Log {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	l := &Log{tb: tb, key: key}
+	l.server = httptest.NewServer(http.HandlerFunc(l.serveHTTP))
+	tb.Cleanup(l.server.Close)
+
+	l.Add(certs...)
+
+	return l
+}
+
+// URL returns the url of the log.
+func (l *Log) URL() string {
+	return l.server.URL
+}
+
+// TreeSize returns the number of entries of the log.
+func (l *Log) TreeSize() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return uint64(len(l.entries))
+}
+
+// Add appends the given certificates to the log. A running certstream picks them up within a few seconds.
+// Like all methods of testing.TB, it must be called from the goroutine running the test.
+func (l *Log) Add(certs ...Cert) {
+	l.tb.Helper()
+
+	for _, cert := range certs {
+		l.mu.Lock()
+		index := len(l.entries)
+		l.mu.Unlock()
+
+		entry, err := l.newEntry(cert, int64(index))
+		if err != nil {
+			l.tb.Fatalf("could not create entry for %v: %v", cert.Domains, err)
+		}
+
+		l.mu.Lock()
+		l.entries = append(l.entries, entry)
+		l.mu.Unlock()
+	}
+}
+
+// LogConfig returns the config of the log for config.General.AdditionalLogs or CertStream.AddLog.
+func (l *Log) LogConfig() config.LogConfig {
+	return config.LogConfig{
+		Operator:    "certstreamtest",
+		URL:         l.server.URL,
+		Description: "certstreamtest log " + l.server.Listener.Addr().String(),
+	}
+}
+
+// newEntry creates the log entry for the given certificate. The certificates are self-signed with the key of the log
+// and the index is used as serial number.
+func (l *Log) newEntry(cert Cert, index int64) (ct.LeafEntry, error) {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(index + 1),
+		Subject:      pkix.Name{CommonName: cert.Domains[0]},
+		DNSNames:     cert.Domains,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+	}
+
+	if cert.Precert {
+		template.ExtraExtensions = []pkix.Extension{{Id: oidCTPoison, Critical: true, Value: asn1.NullBytes}}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &l.key.PublicKey, l.key)
+	if err != nil {
+		return ct.LeafEntry{}, err
+	}
+
+	timestampedEntry := &ct.TimestampedEntry{
+		Timestamp: uint64(time.Now().UnixMilli()),
+		EntryType: ct.X509LogEntryType,
+		X509Entry: &ct.ASN1Cert{Data: der},
+	}
+
+	// The extra data of a final certificate is its chain, which is empty for self-signed certificates
+	var extraData any = ct.CertificateChain{}
+
+	if cert.Precert {
+		parsed, err := x509.ParseCertificate(der)
+		if err != nil {
+			return ct.LeafEntry{}, err
+		}
+
+		// The log entry of a precertificate contains its TBSCertificate without the poison extension
+		tbs, err := ctx509.RemoveCTPoison(parsed.RawTBSCertificate)
+		if err != nil {
+			return ct.LeafEntry{}, err
+		}
+
+		timestampedEntry.EntryType = ct.PrecertLogEntryType
+		timestampedEntry.X509Entry = nil
+		timestampedEntry.PrecertEntry = &ct.PreCert{
+			IssuerKeyHash:  sha256.Sum256(parsed.RawSubjectPublicKeyInfo),
+			TBSCertificate: tbs,
+		}
+		extraData = ct.PrecertChainEntry{PreCertificate: ct.ASN1Cert{Data: der}}
+	}
+
+	leafInput, err := tls.Marshal(ct.MerkleTreeLeaf{
+		Version:          ct.V1,
+		LeafType:         ct.TimestampedEntryLeafType,
+		TimestampedEntry: timestampedEntry,
+	})
+	if err != nil {
+		return ct.LeafEntry{}, err
+	}
+
+	extraDataBytes, err := tls.Marshal(extraData)
+	if err != nil {
+		return ct.LeafEntry{}, err
+	}
+
+	return ct.LeafEntry{LeafInput: leafInput, ExtraData: extraDataBytes}, nil
+}
+
+// serveHTTP answers the get-sth and get-entries requests.
+func (l *Log) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.Lock()
+	entries := l.entries
+	l.mu.Unlock()
+
+	switch r.URL.Path {
+	case ct.GetSTHPath:
+		_ = json.NewEncoder(w).Encode(ct.GetSTHResponse{
+			TreeSize:          uint64(len(entries)),
+			Timestamp:         uint64(time.Now().UnixMilli()),
+			SHA256RootHash:    make([]byte, sha256.Size),
+			TreeHeadSignature: []byte{4, 3, 0, 0},
+		})
+	case ct.GetEntriesPath:
+		start, startErr := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		end, endErr := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		if startErr != nil || endErr != nil || start < 0 || start > end || start >= int64(len(entries)) {
+			http.Error(w, fmt.Sprintf("invalid range %d-%d for tree size %d", start, end, len(entries)), http.StatusBadRequest)
+			return
+		}
+
+		end = min(end+1, int64(len(entries)), start+maxEntriesPerRequest)
+		_ = json.NewEncoder(w).Encode(ct.GetEntriesResponse{Entries: entries[start:end]})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// NewCertStream creates a certstream that watches only the given logs, starting at their first entry. It doesn't
+// fetch the official log list and fetches a single entry per request, so that new certificates arrive quickly.
+// The logs of the certstream are discarded. It is stopped when the test finishes; certificates that were not
+// consumed by then are discarded.
+func NewCertStream(tb testing.TB, logs ...*Log) *certstream.CertStream {
+	tb.Helper()
+
+	conf := config.Config{}
+	conf.General.BufferSizes.BroadcastManager = 5000
+	conf.ApplyDefaults()
+	conf.General.DisableDefaultLogs = true
+	conf.General.StartPosition = "tail"
+	conf.General.STHPollInterval = sthPollInterval
+
+	for _, l := range logs {
+		logConfig := l.LogConfig()
+		// The scanner waits for the log to grow by a whole batch before it fetches new entries
+		logConfig.BatchSize = 1
+		conf.General.AdditionalLogs = append(conf.General.AdditionalLogs, logConfig)
+	}
+
+	cs := certstream.NewFromConfig(conf)
+	cs.SetLogger(certstream.NopLogger())
+
+	tb.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := cs.StopGraceful(ctx); err != nil {
+			cs.Wait()
+		}
+	})
+
+	return cs
+}
@@ -0,0 +1,50 @@
+package certstreamtest
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/certstream"
+)
+
+func TestNewCertStream(t *testing.T) {
+	ctLog := NewLog(t, Fixtures()...)
+	certChan := NewCertStream(t, ctLog).Start()
+
+	receive := func() certstream.Entry {
+		t.Helper()
+
+		select {
+		case entry := <-certChan:
+			return entry
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for certificate")
+			return certstream.Entry{}
+		}
+	}
+
+	for i, cert := range Fixtures() {
+		entry := receive()
+
+		leafCert := entry.Data.LeafCert
+		matches := slices.Equal(leafCert.AllDomains, cert.Domains) && leafCert.IsPrecert == cert.Precert
+		if entry.Data.CertIndex != uint64(i) || !matches {
+			t.Errorf("got entry %d with domains %v and precert %t, want entry %d with domains %v and precert %t",
+				entry.Data.CertIndex, leafCert.AllDomains, leafCert.IsPrecert, i, cert.Domains, cert.Precert)
+		}
+
+		if cert.Precert && entry.Data.UpdateType != "PrecertLogEntry" {
+			t.Errorf("got update type %s for precert", entry.Data.UpdateType)
+		}
+	}
+
+	// Certificates added to the running log are picked up as well
+	ctLog.Add(IDNCert)
+
+	entry := receive()
+	if entry.Data.CertIndex != 4 || !slices.Equal(entry.Data.LeafCert.AllDomainsUnicode, []string{"bücher.example"}) {
+		t.Errorf("got entry %d with unicode domains %v, want entry 4 with bücher.example",
+			entry.Data.CertIndex, entry.Data.LeafCert.AllDomainsUnicode)
+	}
+}
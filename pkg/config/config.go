@@ -1,12 +1,21 @@
 package config
 
 import (
+	"compress/flate"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"log"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -29,6 +38,23 @@ type LogConfig struct {
 	Operator    string `yaml:"operator"`
 	URL         string `yaml:"url"`
 	Description string `yaml:"description"`
+	// PublicKey is the base64 encoded DER public key of the log. If set, the signatures of STHs are verified.
+	PublicKey  string `yaml:"public_key"`
+	LogOptions `yaml:",inline"`
+}
+
+// LogOptions contains tunables for a single CT log. Unset values fall back to the global ScannerOptions.
+type LogOptions struct {
+	// WorkerCount is the number of worker goroutines processing the certificates of the log.
+	WorkerCount int `yaml:"worker_count"`
+	// BatchSize is the number of entries fetched per get-entries request.
+	// It is capped to the maximum number of entries the log returns per request.
+	BatchSize int `yaml:"batch_size"`
+	// DisableHTTP2 restricts the requests to the log to HTTP/1.1, for logs that misbehave with HTTP/2.
+	DisableHTTP2 bool `yaml:"disable_http2"`
+	// STHPollInterval is the minimum time between two get-sth requests to the log. Defaults to
+	// General.STHPollInterval.
+	STHPollInterval time.Duration `yaml:"sth_poll_interval"`
 }
 
 type BufferSizes struct {
@@ -43,6 +69,208 @@ type ScannerOptions struct {
 	NumWorkers    int `yaml:"num_workers"`
 }
 
+// TracingConfig configures the export of OpenTelemetry traces.
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the host and port of the OTLP gRPC receiver.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure disables TLS for the connection to the endpoint.
+	Insecure bool `yaml:"insecure"`
+	// SampleRatio is the fraction of traces that are sampled, between 0 and 1.
+	SampleRatio float64 `yaml:"sample_ratio"`
+	// ServiceName is the name of the service reported in the traces.
+	ServiceName string `yaml:"service_name"`
+}
+
+// HTTPClientConfig configures the HTTP client used for the requests to the CT logs and the log list.
+type HTTPClientConfig struct {
+	// Timeout limits each request, including reading the response. Defaults to 30s.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxIdleConnsPerHost is the number of idle connections kept open per host. Defaults to 16.
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+	// DisableHTTP2 restricts all requests to HTTP/1.1. By default, HTTP/2 is used if the server supports it.
+	DisableHTTP2 bool `yaml:"disable_http2"`
+	// ProxyURL is the url of an HTTP or HTTPS proxy. If empty, the proxy from the environment (HTTPS_PROXY) is used.
+	ProxyURL string `yaml:"proxy_url"`
+}
+
+// RetryConfig configures the exponential backoff after failed requests to a CT log.
+type RetryConfig struct {
+	// InitialDelay is the delay after the first failed request. Defaults to 1s.
+	InitialDelay time.Duration `yaml:"initial_delay"`
+	// MaxDelay is the upper limit of the delay. Defaults to 1m.
+	MaxDelay time.Duration `yaml:"max_delay"`
+	// Multiplier is the factor by which the delay grows with each consecutive failure. Defaults to 2.
+	Multiplier float64 `yaml:"multiplier"`
+	// Jitter randomizes each delay by up to the given fraction, e.g. 0.2 for ±20%.
+	Jitter float64 `yaml:"jitter"`
+}
+
+// ConnectionLimitsConfig configures the limits for websocket connections. Limits that are zero are disabled.
+type ConnectionLimitsConfig struct {
+	// RateLimit is the number of connection attempts per second allowed per IP.
+	RateLimit float64 `yaml:"rate_limit"`
+	// Burst is the number of connection attempts an IP can make at once. Defaults to the rate limit, but at least 1.
+	Burst int `yaml:"burst"`
+	// MaxConnections is the maximum number of concurrent connections in total.
+	MaxConnections int `yaml:"max_connections"`
+	// MaxConnectionsPerIP is the maximum number of concurrent connections per IP.
+	MaxConnectionsPerIP int `yaml:"max_connections_per_ip"`
+}
+
+// AuthConfig configures the API keys clients must present to connect to the websocket server.
+type AuthConfig struct {
+	Keys []APIKeyConfig `yaml:"keys"`
+	// KeysFile is the path to a YAML file containing a list of additional keys in the same format as Keys.
+	KeysFile string `yaml:"keys_file"`
+}
+
+// APIKeyConfig is an API key that grants access to the websocket server.
+type APIKeyConfig struct {
+	Key string `yaml:"key"`
+	// Label identifies the key in logs and metrics without revealing it. Defaults to "key<n>".
+	Label string `yaml:"label"`
+}
+
+// LookalikeConfig configures the detection of certificates for domains that are confusable with the domains of a watchlist.
+type LookalikeConfig struct {
+	// Watchlist contains the domains to protect, e.g. "paypal.com". The detection is disabled if it is empty.
+	Watchlist []string `yaml:"watchlist"`
+	// Threshold is the minimum similarity between 0 and 1 for a domain to be reported as lookalike. Defaults to 0.85.
+	Threshold float64 `yaml:"threshold"`
+}
+
+// FirstSeenConfig configures the detection of registrable domains that the server sees for the first time, as a cheap
+// proxy for newly registered domains.
+type FirstSeenConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Window is the time a registrable domain is remembered after its last sighting. Defaults to 7 days.
+	Window time.Duration `yaml:"window"`
+	// Capacity is the maximum number of remembered registrable domains. Once it is reached, the least recently seen
+	// domain is forgotten. Defaults to 500000.
+	Capacity int `yaml:"capacity"`
+}
+
+// CircuitBreakerConfig configures the suspension of requests to a CT log that keeps failing.
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests that open the circuit. Zero disables it.
+	FailureThreshold int `yaml:"failure_threshold"`
+	// OpenDuration is the cool-down during which no requests are sent to the log. Defaults to 5m.
+	OpenDuration time.Duration `yaml:"open_duration"`
+}
+
+// RequestRateLimitConfig configures the rate limit of the get-entries requests to all CT logs together.
+type RequestRateLimitConfig struct {
+	// RequestsPerSecond is the maximum rate of get-entries requests of all workers. Zero disables the rate limit.
+	RequestsPerSecond float64 `yaml:"requests_per_second"`
+	// Burst is the number of requests that can be sent at once. Defaults to the rate, but at least 1.
+	Burst int `yaml:"burst"`
+}
+
+// LogListConfig configures the fetching of the CT log list at startup.
+type LogListConfig struct {
+	// StartupAttempts is the number of attempts to fetch the log list at startup. The delay between two attempts
+	// follows the retry backoff (see RetryConfig). Defaults to 5.
+	StartupAttempts int `yaml:"startup_attempts"`
+	// StartupTimeout is the maximum time spent on the attempts at startup. Defaults to 2m.
+	StartupTimeout time.Duration `yaml:"startup_timeout"`
+}
+
+// LogListCacheConfig configures the file the fetched CT log list is cached in.
+type LogListCacheConfig struct {
+	// Path is the file the last successfully fetched log list is saved to. It is used instead of fetching the log
+	// list at startup if it is fresh, and as fallback if the log list can't be fetched at startup. Empty disables the
+	// cache.
+	Path string `yaml:"path"`
+	// TTL is the maximum age of the cached log list to be used at startup without fetching the log list. Older
+	// copies are only used as fallback. Defaults to 1h.
+	TTL time.Duration `yaml:"ttl"`
+}
+
+type DeduplicateConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// TTL is the time window in which certificates with the same fingerprint are considered duplicates.
+	TTL time.Duration `yaml:"ttl"`
+	// Capacity is the maximum number of fingerprints that are remembered at the same time.
+	Capacity int `yaml:"capacity"`
+	// Persistent configures an additional Bloom filter of the broadcast fingerprints, which survives restarts.
+	Persistent PersistentDedupConfig `yaml:"persistent"`
+}
+
+// PersistentDedupConfig configures the Bloom filter that suppresses certificates that were already broadcast before
+// a restart. Since it is a Bloom filter, a small fraction of genuine certificates is dropped as false positives.
+type PersistentDedupConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// File is the path the filter is saved to. Defaults to ct_dedup.bloom next to the recovery index file.
+	File string `yaml:"file"`
+	// Capacity is the number of fingerprints per generation. The filter remembers between one and two generations.
+	Capacity int `yaml:"capacity"`
+	// FalsePositiveRate is the probability of a genuine certificate being dropped when the filter is full.
+	FalsePositiveRate float64 `yaml:"false_positive_rate"`
+	// FlushInterval is the interval in which the filter is saved. Defaults to 10s.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// SinkPolicy decides what an output does with the certificates it can't keep up with.
+type SinkPolicy string
+
+const (
+	// PolicyDrop drops the certificates if the queue of the output is full and counts them. This is the default.
+	PolicyDrop SinkPolicy = "drop"
+	// PolicyBlock waits until there is space in the queue of the output. This slows down the whole server, including
+	// the other outputs, to the speed of the output, but no certificates are dropped unless writing them fails
+	// repeatedly.
+	PolicyBlock SinkPolicy = "block"
+)
+
+// KafkaConfig configures the Kafka output of the server.
+type KafkaConfig struct {
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	// Compression is the compression codec of the messages: none, gzip, snappy, lz4 or zstd.
+	Compression string `yaml:"compression"`
+	// BatchSize is the maximum number of messages produced at once.
+	BatchSize int `yaml:"batch_size"`
+	// QueueSize is the maximum number of entries waiting to be produced. Further entries are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// Format is the wire format of the messages: json (default) or msgpack.
+	Format string `yaml:"format"`
+	// Policy decides whether the output drops certificates or slows down the server if it can't keep up.
+	Policy SinkPolicy `yaml:"policy"`
+}
+
+// WebhookConfig configures the webhook output of the server.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+	// BatchSize is the maximum number of entries posted at once.
+	BatchSize int `yaml:"batch_size"`
+	// FlushInterval is the maximum time entries are collected before they are posted.
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// BearerToken is sent in the Authorization header, if set.
+	BearerToken string `yaml:"bearer_token"`
+	// QueueSize is the maximum number of entries waiting to be posted. Further entries are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// Policy decides whether the output drops certificates or slows down the server if it can't keep up.
+	Policy SinkPolicy `yaml:"policy"`
+}
+
+// FileConfig configures the file output of the server.
+type FileConfig struct {
+	Path string `yaml:"path"`
+	// RotationSizeMB is the size in MB after which the file is rotated. 0 disables size based rotation.
+	RotationSizeMB int `yaml:"rotation_size_mb"`
+	// RotationInterval is the age after which the file is rotated. 0 disables time based rotation.
+	RotationInterval time.Duration `yaml:"rotation_interval"`
+	// MaxFiles is the number of rotated files to keep. 0 keeps all files.
+	MaxFiles int `yaml:"max_files"`
+	// Gzip indicates whether rotated files should be compressed.
+	Gzip bool `yaml:"gzip"`
+	// QueueSize is the maximum number of entries waiting to be written. Further entries are dropped.
+	QueueSize int `yaml:"queue_size"`
+	// Policy decides whether the output drops certificates or slows down the server if it can't keep up.
+	Policy SinkPolicy `yaml:"policy"`
+}
+
 type Config struct {
 	Webserver struct {
 		ServerConfig       `yaml:",inline"`
@@ -50,41 +278,224 @@ type Config struct {
 		LiteURL            string `yaml:"lite_url"`
 		DomainsOnlyURL     string `yaml:"domains_only_url"`
 		CompressionEnabled bool   `yaml:"compression_enabled"`
+		// CompressionLevel is the flate compression level used for clients that negotiated compression.
+		// Valid levels are -2 (huffman only) and 1 (best speed) to 9 (best compression). Defaults to 1.
+		CompressionLevel int `yaml:"compression_level"`
+		// BroadcastShards is the number of goroutines that send the entries to the websocket clients, each for
+		// an equal share of the clients. Defaults to 1.
+		BroadcastShards int `yaml:"broadcast_shards"`
+		// LatestBufferSize is the number of recent certificates kept for the /latest endpoint. Defaults to 100.
+		LatestBufferSize int `yaml:"latest_buffer_size"`
+		// CountWindows are the rolling time windows the /counts endpoint reports the number of certificates for.
+		// Defaults to 1m, 5m and 1h.
+		CountWindows []time.Duration `yaml:"count_windows"`
+		// ReadinessThreshold is the maximum time since the last successful request to any CT log for the server to
+		// be reported as ready at /readyz. Defaults to 2m.
+		ReadinessThreshold time.Duration `yaml:"readiness_threshold"`
+		// PingInterval is the interval in which pings are sent to the websocket clients. Defaults to 30s.
+		PingInterval time.Duration `yaml:"ping_interval"`
+		// PongTimeout is the grace period for a client to respond to a ping. Connections without any ping or pong
+		// from the client within PingInterval + PongTimeout are closed. Defaults to 35s.
+		PongTimeout time.Duration `yaml:"pong_timeout"`
+		// FullPayload indicates whether the certificate details (key usages, CRL and OCSP urls) are sent to all
+		// websocket clients. Otherwise, clients can request them via the "full" query parameter.
+		FullPayload bool `yaml:"full_payload"`
+		// Format is the wire format of the entries for clients that don't request one via the "format" query
+		// parameter or the websocket subprotocol: json (default) or msgpack.
+		Format string `yaml:"format"`
+		// Fields restricts the entries sent to the clients of the full and lite streams to the given fields, e.g.
+		// all_domains and seen (see models.Fields). Clients can select other fields via the "fields" query parameter.
+		// All fields are sent if it is empty.
+		Fields []string `yaml:"fields"`
+		// MaxDomainsPerEntry limits the number of domains in the entries sent to the clients of the full and lite
+		// streams. Entries with more domains are truncated and flagged with domains_truncated and total_domains.
+		// Clients that request the full payload receive all domains. Zero means unlimited.
+		MaxDomainsPerEntry int `yaml:"max_domains_per_entry"`
+		// Auth requires clients to present an API key. If it is nil, all clients can connect.
+		Auth *AuthConfig `yaml:"auth"`
+		// Limits protects the server from clients opening too many connections.
+		Limits ConnectionLimitsConfig `yaml:"limits"`
 	}
 	Prometheus struct {
-		ServerConfig        `yaml:",inline"`
-		Enabled             bool   `yaml:"enabled"`
-		MetricsURL          string `yaml:"metrics_url"`
+		ServerConfig `yaml:",inline"`
+		Enabled      bool   `yaml:"enabled"`
+		MetricsURL   string `yaml:"metrics_url"`
+		// StatsURL is the url of the JSON stats endpoint, which is served on the same interface as the metrics.
+		// Defaults to /stats if prometheus is enabled. If it is set, the endpoint is served even if prometheus is
+		// disabled.
+		StatsURL string `yaml:"stats_url"`
+		// ClientsURL is the url of the JSON endpoint listing and disconnecting the connected websocket clients, which
+		// is served on the same interface as the metrics. It is disabled by default and only served if it is protected
+		// by webserver.auth or a whitelist. If it is set, the endpoint is served even if prometheus is disabled.
+		ClientsURL          string `yaml:"clients_url"`
 		ExposeSystemMetrics bool   `yaml:"expose_system_metrics"`
 	}
+	// GRPC configures the gRPC API. Only listen_addr, listen_port, cert_path and cert_key_path are used.
+	GRPC struct {
+		ServerConfig `yaml:",inline"`
+		Enabled      bool `yaml:"enabled"`
+	} `yaml:"grpc"`
 	General struct {
 		// DisableDefaultLogs indicates whether the default logs used in Google Chrome and provided by Google should be disabled.
 		DisableDefaultLogs bool `yaml:"disable_default_logs"`
 		// AdditionalLogs contains additional logs provided by the user that can be used in addition to the default logs.
-		AdditionalLogs []LogConfig    `yaml:"additional_logs"`
+		AdditionalLogs []LogConfig `yaml:"additional_logs"`
+		// IncludeOperators restricts the watched logs to those of the given operators (case-insensitive).
+		IncludeOperators []string `yaml:"include_operators"`
+		// ExcludeOperators prevents the logs of the given operators from being watched (case-insensitive).
+		// It is applied after IncludeOperators.
+		ExcludeOperators []string `yaml:"exclude_operators"`
+		// LogFilterFile is the path to a file with one CT log name or url per line. Depending on LogFilterMode, only
+		// the listed logs are watched ("include", the default) or the listed logs are not watched ("exclude").
+		// Empty lines and lines starting with # are ignored. It is applied after the operator filters.
+		LogFilterFile string `yaml:"log_filter_file"`
+		LogFilterMode string `yaml:"log_filter_mode"`
+		// LogFilter contains the logs listed in LogFilterFile. It is filled when the config is read.
+		LogFilter []string `yaml:"-"`
+		// LogStates restricts the watched logs to those with one of the given states in the log list.
+		// Defaults to DefaultLogStates.
+		LogStates      []string       `yaml:"log_states"`
 		BufferSizes    BufferSizes    `yaml:"buffer_sizes"`
 		ScannerOptions ScannerOptions `yaml:"scanner_options"`
-		DropOldLogs    *bool          `yaml:"drop_old_logs"`
-		Recovery       struct {
+		// CompactDomains reduces the allocations for the domains of each certificate by letting AllDomains,
+		// AllDomainsUnicode and RegistrableDomains share their memory. The slices must not be modified.
+		CompactDomains bool `yaml:"compact_domains"`
+		// ParseWorkers is the number of goroutines shared by all logs that parse the fetched entries. If 0 (default),
+		// the entries are parsed by the worker goroutines of each log (see ScannerOptions.NumWorkers).
+		ParseWorkers int `yaml:"parse_workers"`
+		// STHPollInterval is the minimum time between two get-sth requests to a CT log, which are sent to check for
+		// new entries. Logs that ask for a longer interval via the max-age of their get-sth responses are polled
+		// less often. It can be overwritten per log via LogOptions. Defaults to 5s.
+		STHPollInterval time.Duration `yaml:"sth_poll_interval"`
+		// LogOptions contains tunables for specific CT logs, keyed by the url of the log.
+		LogOptions map[string]LogOptions `yaml:"log_options"`
+		// HTTPClient configures the HTTP client shared by all workers.
+		HTTPClient HTTPClientConfig `yaml:"http_client"`
+		// HTTPHeaders are set on all get-sth and get-entries requests to the CT logs, e.g. for authenticating proxies.
+		// A User-Agent set here replaces the default one, which contains the server version. The Host header can't
+		// be set.
+		HTTPHeaders map[string]string `yaml:"http_headers"`
+		// Heartbeat is the interval after which the library emits a heartbeat entry (see models.NewHeartbeat) if no
+		// certificate was delivered in the meantime, so that consumers can tell a quiet stream from a dead watcher.
+		// Disabled if zero. The server doesn't send heartbeats to its clients.
+		Heartbeat time.Duration `yaml:"heartbeat"`
+		// StartPosition is the position at which logs without recovery position start: "head" (default), "tail" or
+		// "head_minus:N". See StartIndex.
+		StartPosition string `yaml:"start_position"`
+		// Retry configures the backoff after failed requests to a CT log. The backoff is tracked per log.
+		Retry RetryConfig `yaml:"retry"`
+		// CircuitBreaker configures the suspension of requests to CT logs that keep failing.
+		CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+		// RequestRateLimit limits the rate of the get-entries requests to all CT logs together.
+		RequestRateLimit RequestRateLimitConfig `yaml:"request_rate_limit"`
+		// LogList configures the retries of fetching the CT log list at startup.
+		LogList LogListConfig `yaml:"log_list"`
+		// LogListCache configures the cache of the CT log list, which speeds up restarts and bridges outages of
+		// the log list source.
+		LogListCache LogListCacheConfig `yaml:"log_list_cache"`
+		DropOldLogs  *bool              `yaml:"drop_old_logs"`
+		// WildcardOnly indicates whether only certificates containing at least one wildcard domain should be processed.
+		WildcardOnly bool `yaml:"wildcard_only"`
+		// IncludeIPSANOnly indicates whether only certificates issued to at least one IP address should be processed.
+		IncludeIPSANOnly bool `yaml:"include_ip_san_only"`
+		// IncludeIssuers restricts the processed certificates to those whose issuer organization or common name
+		// contains one of the given strings (case-insensitive), e.g. "Let's Encrypt".
+		IncludeIssuers []string `yaml:"include_issuers"`
+		// ExcludeIssuers discards the certificates whose issuer organization or common name contains one of the given
+		// strings (case-insensitive). It is applied after IncludeIssuers.
+		ExcludeIssuers []string `yaml:"exclude_issuers"`
+		// MinSANs and MaxSANs restrict the processed certificates to those with a number of names within the range,
+		// counting the domains and IP addresses (see SANCount). Zero means unbounded on that side.
+		MinSANs int `yaml:"min_sans"`
+		MaxSANs int `yaml:"max_sans"`
+		// ExcludePrecerts indicates whether precertificates should be discarded, so that each certificate is only
+		// processed once as final certificate.
+		ExcludePrecerts bool `yaml:"exclude_precerts"`
+		// SampleRate is the fraction of certificates that are processed, between 0 and 1. Defaults to 1 (all certificates).
+		SampleRate float64 `yaml:"sample_rate"`
+		// IncludeDER indicates whether the raw DER bytes of the leaf certificate should be included in each entry.
+		IncludeDER bool `yaml:"include_der"`
+		// IncludeChainDER indicates whether the DER of the chain certificates should be included in each entry.
+		// If IncludeDER is enabled as well, the raw DER bytes are included too. Defaults to true.
+		IncludeChainDER *bool `yaml:"include_chain_der"`
+		// IncludeLeafHash indicates whether the RFC 6962 Merkle tree leaf hash of each entry should be included,
+		// which is needed to request an inclusion proof from the log.
+		IncludeLeafHash bool `yaml:"include_leaf_hash"`
+		// Deduplicate configures the suppression of certificates that were already broadcast recently.
+		Deduplicate DeduplicateConfig `yaml:"deduplicate"`
+		// LogLevel is the minimum level of the logs of the CT watcher: debug, info, warn or error. Defaults to info.
+		LogLevel string `yaml:"log_level"`
+		// Quiet suppresses all logs of the CT watcher except errors, regardless of LogLevel.
+		Quiet bool `yaml:"quiet"`
+		// TimeFormat is the format of the seen timestamp and the validity timestamps in the JSON encoding of the
+		// entries: seconds, millis or rfc3339. Defaults to seconds.
+		TimeFormat string `yaml:"time_format"`
+		// Tracing configures the optional OpenTelemetry tracing of the fetch-to-delivery pipeline.
+		Tracing  TracingConfig `yaml:"tracing"`
+		Recovery struct {
 			Enabled     bool   `yaml:"enabled"`
 			CTIndexFile string `yaml:"ct_index_file"`
+			// FlushInterval is the interval in which the indexes are saved. Defaults to 5s.
+			FlushInterval time.Duration `yaml:"flush_interval"`
+			// SaveEveryN saves the indexes as soon as this many entries were consumed since the last save, if that
+			// happens before the FlushInterval elapsed. After a crash, fewer than SaveEveryN entries and at most the
+			// entries of one FlushInterval are processed again. Zero only saves in the FlushInterval.
+			SaveEveryN int `yaml:"save_every_n"`
+			// Compress gzips the index file on each save. Compressed and uncompressed files are both loaded, so the
+			// option can be switched at any time.
+			Compress bool `yaml:"compress"`
 		} `yaml:"recovery"`
 	}
+	// Output contains the outputs of the server. The websocket output is enabled unless DisableWebsocket is set, all
+	// other outputs are disabled unless they are configured. Any combination of outputs can be enabled.
+	Output struct {
+		// DisableWebsocket disables the websocket endpoints, e.g. if the entries are only written to Kafka or a file.
+		DisableWebsocket bool `yaml:"disable_websocket"`
+		// WebsocketPolicy decides whether certificates are dropped for the websocket clients or the server slows down
+		// if the broadcast shards can't keep up. Slow clients are skipped in either case.
+		WebsocketPolicy SinkPolicy     `yaml:"websocket_policy"`
+		Kafka           *KafkaConfig   `yaml:"kafka"`
+		Webhook         *WebhookConfig `yaml:"webhook"`
+		File            *FileConfig    `yaml:"file"`
+	} `yaml:"output"`
+	// Detection configures the detectors that flag suspicious certificates. Detectors that are not configured are disabled.
+	Detection struct {
+		Lookalike LookalikeConfig `yaml:"lookalike"`
+		FirstSeen FirstSeenConfig `yaml:"first_seen"`
+	} `yaml:"detection"`
 }
 
-// ReadConfig reads the config file and returns a filled Config struct.
+// ReadConfig reads the config file and returns a filled Config struct. The config is stored in AppConfig.
 func ReadConfig(configPath string) (Config, error) {
+	conf, err := LoadConfig(configPath)
+	if err != nil {
+		return Config{}, err
+	}
+
+	AppConfig = conf
+
+	return conf, nil
+}
+
+// LoadConfig reads the config file, applies the defaults and validates it. Unlike ReadConfig, it doesn't change
+// AppConfig, so a config can be checked before it is used.
+func LoadConfig(configPath string) (Config, error) {
 	log.Printf("Reading config file '%s'...\n", configPath)
 
-	conf, parseErr := parseConfigFromFile(configPath)
-	if parseErr != nil {
-		log.Fatalln("Error while parsing yaml file:", parseErr)
+	conf, err := parseConfigFromFile(configPath)
+	if err != nil {
+		return Config{}, fmt.Errorf("error while parsing yaml file: %w", err)
+	}
+
+	conf.ApplyDefaults()
+
+	if err = validateConfig(conf); err != nil {
+		return Config{}, fmt.Errorf("invalid config: %w", err)
 	}
 
-	if !validateConfig(conf) {
-		log.Fatalln("Invalid config")
+	if err = conf.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config:\n%w", err)
 	}
-	AppConfig = *conf
 
 	return *conf, nil
 }
@@ -150,59 +561,193 @@ func parseConfigFromBytes(data []byte) (*Config, error) {
 	return &config, nil
 }
 
-// validateConfig validates the config values and sets defaults for missing values.
-func validateConfig(config *Config) bool {
+// readAPIKeys reads a YAML file containing a list of API keys.
+func readAPIKeys(path string) ([]APIKeyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKeyConfig
+	if err = yaml.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API keys file '%s': %w", path, err)
+	}
+
+	return keys, nil
+}
+
+// readLogFilter reads a file with one CT log name or url per line. Empty lines and comments are skipped.
+func readLogFilter(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []string{}
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			logs = append(logs, line)
+		}
+	}
+
+	return logs, nil
+}
+
+// The modes of the log filter file, see LogFilterMode.
+const (
+	LogFilterInclude = "include"
+	LogFilterExclude = "exclude"
+)
+
+// LogStates contains the valid states of a CT log in the log list.
+var LogStates = []string{"pending", "qualified", "usable", "readonly", "retired", "rejected"}
+
+// DefaultLogStates contains the states of the CT logs that are watched by default.
+var DefaultLogStates = []string{"usable", "qualified"}
+
+// logURLRegex matches valid CT log urls. It still matches invalid IP addresses but is good enough for detecting
+// completely wrong formats.
+var logURLRegex = regexp.MustCompile(`^https?://[a-zA-Z0-9\-._]+(:[0-9]+)?(/[a-zA-Z0-9\-._]+)*/?$`)
+
+// IsValidLogURL checks whether the given string is a valid CT log url, including the scheme.
+func IsValidLogURL(url string) bool {
+	return logURLRegex.MatchString(url)
+}
+
+// StartIndex returns the index at which a log without recovery position starts, given its current tree size.
+// The position "head" (or an empty position) starts at the tree size, i.e. only with new entries, "tail" starts at
+// index 0 and "head_minus:N" starts N entries before the tree size.
+func StartIndex(position string, treeSize uint64) (uint64, error) {
+	switch position {
+	case "", "head":
+		return treeSize, nil
+	case "tail":
+		return 0, nil
+	}
+
+	offsetValue, ok := strings.CutPrefix(position, "head_minus:")
+	if !ok {
+		return 0, fmt.Errorf("invalid start position '%s' - valid positions are 'head', 'tail' and 'head_minus:N'", position)
+	}
+
+	offset, err := strconv.ParseUint(offsetValue, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset in start position '%s': %w", position, err)
+	}
+
+	return treeSize - min(offset, treeSize), nil
+}
+
+// validateTLS checks that the certificate and key of a server are either both set or both empty and that they form a
+// valid pair, so that a broken TLS setup is detected at startup instead of at the first handshake.
+func validateTLS(name string, server ServerConfig) error {
+	if server.CertPath == "" && server.CertKeyPath == "" {
+		return nil
+	}
+
+	if server.CertPath == "" || server.CertKeyPath == "" {
+		return fmt.Errorf("both cert_path and cert_key_path must be set to enable TLS for the %s", name)
+	}
+
+	if _, err := tls.LoadX509KeyPair(server.CertPath, server.CertKeyPath); err != nil {
+		return fmt.Errorf("could not load TLS certificate of the %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// validateConfig validates the config values and corrects values that are out of range.
+func validateConfig(config *Config) error {
 	// Still matches invalid IP addresses but good enough for detecting completely wrong formats
 	URLPathRegex := regexp.MustCompile(`^(/[a-zA-Z0-9\-._]+)+$`)
-	URLRegex := regexp.MustCompile(`^https?://[a-zA-Z0-9\-._]+(:[0-9]+)?(/[a-zA-Z0-9\-._]+)*/?$`)
 
 	// Check webserver config
 	if config.Webserver.ListenAddr == "" || net.ParseIP(config.Webserver.ListenAddr) == nil {
-		log.Fatalln("Webhook listen IP is not a valid IP: ", config.Webserver.ListenAddr)
-		return false
+		return fmt.Errorf("webhook listen IP is not a valid IP: '%s'", config.Webserver.ListenAddr)
 	}
 
 	if config.Webserver.ListenPort == 0 {
-		log.Fatalln("Webhook listen port is not set")
-		return false
+		return errors.New("webhook listen port is not set")
 	}
 
-	if config.Webserver.FullURL == "" || !URLPathRegex.MatchString(config.Webserver.FullURL) {
-		log.Println("Webhook full URL is not set or does not match pattern '/...'")
+	if !URLPathRegex.MatchString(config.Webserver.FullURL) {
+		log.Println("Webhook full URL does not match pattern '/...'")
 		config.Webserver.FullURL = "/full-stream"
 	}
 
-	if config.Webserver.LiteURL == "" || !URLPathRegex.MatchString(config.Webserver.FullURL) {
-		log.Println("Webhook lite URL is not set or does not match pattern '/...'")
+	// The lite stream is served at the root by default, which the pattern doesn't match
+	if config.Webserver.LiteURL != "/" && !URLPathRegex.MatchString(config.Webserver.LiteURL) {
+		log.Println("Webhook lite URL does not match pattern '/...'")
 		config.Webserver.LiteURL = "/"
 	}
 
-	if config.Webserver.DomainsOnlyURL == "" || !URLPathRegex.MatchString(config.Webserver.DomainsOnlyURL) {
-		log.Println("Webhook domains only URL is not set or does not match pattern '/...'")
-		config.Webserver.FullURL = "/domains-only"
+	if !URLPathRegex.MatchString(config.Webserver.DomainsOnlyURL) {
+		log.Println("Webhook domains only URL does not match pattern '/...'")
+		config.Webserver.DomainsOnlyURL = "/domains-only"
 	}
 
-	if config.Webserver.FullURL == config.Webserver.LiteURL {
-		log.Fatalln("Webhook full URL is the same as lite URL - please fix the config!")
+	if err := validateTLS("webserver", config.Webserver.ServerConfig); err != nil {
+		return err
 	}
 
-	if config.Webserver.DomainsOnlyURL == "" {
-		config.Webserver.FullURL = "/domains-only"
+	if config.Webserver.CompressionLevel != flate.HuffmanOnly &&
+		(config.Webserver.CompressionLevel < flate.BestSpeed || config.Webserver.CompressionLevel > flate.BestCompression) {
+		log.Printf("Compression level %d is not between 1 and 9 - defaulting to %d\n", config.Webserver.CompressionLevel, flate.BestSpeed)
+		config.Webserver.CompressionLevel = flate.BestSpeed
+	}
+
+	if general := &config.General; general.LogFilterFile != "" {
+		general.LogFilterMode = strings.ToLower(general.LogFilterMode)
+		if general.LogFilterMode != LogFilterInclude && general.LogFilterMode != LogFilterExclude {
+			return fmt.Errorf("log filter mode must be '%s' or '%s', but is '%s'", LogFilterInclude, LogFilterExclude, general.LogFilterMode)
+		}
+
+		logs, err := readLogFilter(general.LogFilterFile)
+		if err != nil {
+			return fmt.Errorf("could not read log filter file: %w", err)
+		}
+
+		general.LogFilter = logs
+	}
+
+	if auth := config.Webserver.Auth; auth != nil {
+		if auth.KeysFile != "" {
+			keys, err := readAPIKeys(auth.KeysFile)
+			if err != nil {
+				return fmt.Errorf("could not read API keys file: %w", err)
+			}
+
+			auth.Keys = append(auth.Keys, keys...)
+		}
+
+		auth.Keys = slices.DeleteFunc(auth.Keys, func(key APIKeyConfig) bool { return key.Key == "" })
+		if len(auth.Keys) == 0 {
+			return errors.New("authentication is enabled, but no API keys are configured")
+		}
+
+		for i := range auth.Keys {
+			if auth.Keys[i].Label == "" {
+				auth.Keys[i].Label = fmt.Sprintf("key%d", i+1)
+			}
+		}
+	}
+
+	if config.Webserver.FullURL == config.Webserver.LiteURL {
+		return errors.New("webhook full URL is the same as lite URL")
 	}
 
 	if config.Prometheus.Enabled {
 		if config.Prometheus.ListenAddr == "" || net.ParseIP(config.Prometheus.ListenAddr) == nil {
-			log.Fatalln("Metrics export IP is not a valid IP")
-			return false
+			return fmt.Errorf("metrics export IP is not a valid IP: '%s'", config.Prometheus.ListenAddr)
 		}
 
 		if config.Prometheus.ListenPort == 0 {
-			log.Fatalln("Metrics export port is not set")
-			return false
+			return errors.New("metrics export port is not set")
 		}
 
-		if config.Prometheus.Whitelist == nil {
-			config.Prometheus.Whitelist = []string{}
+		if err := validateTLS("metrics server", config.Prometheus.ServerConfig); err != nil {
+			return err
 		}
 
 		// Check if IPs in whitelist match pattern
@@ -211,65 +756,96 @@ func validateConfig(config *Config) bool {
 				// Provided entry is not an IP, check if it's a CIDR range
 				_, _, err := net.ParseCIDR(ip)
 				if err != nil {
-					log.Fatalln("Invalid IP in metrics whitelist: ", ip)
-					return false
+					return fmt.Errorf("invalid IP in metrics whitelist: '%s'", ip)
 				}
 			}
 		}
 	}
 
+	if config.GRPC.Enabled {
+		if err := validateTLS("gRPC server", config.GRPC.ServerConfig); err != nil {
+			return err
+		}
+	}
+
 	var validLogs []LogConfig
 	if len(config.General.AdditionalLogs) > 0 {
 		for _, ctLog := range config.General.AdditionalLogs {
-			if !URLRegex.MatchString(ctLog.URL) {
+			if !IsValidLogURL(ctLog.URL) {
 				log.Println("Ignoring invalid additional log URL: ", ctLog.URL)
 				continue
 			}
 
+			if ctLog.PublicKey != "" {
+				if _, err := base64.StdEncoding.DecodeString(ctLog.PublicKey); err != nil {
+					log.Println("Ignoring additional log with invalid public key: ", ctLog.URL)
+					continue
+				}
+			}
+
 			validLogs = append(validLogs, ctLog)
 		}
 	} else if len(config.General.AdditionalLogs) == 0 && config.General.DisableDefaultLogs {
-		log.Fatalln("Default logs are disabled, but no additional logs are configured. Please add at least one log to the config or enable default logs.")
+		return errors.New("default logs are disabled, but no additional logs are configured - add at least one log or enable the default logs")
 	}
 
 	config.General.AdditionalLogs = validLogs
 
-	if config.General.BufferSizes.Websocket <= 0 {
-		config.General.BufferSizes.Websocket = 300
+	var validStates []string
+	for _, state := range config.General.LogStates {
+		state = strings.ToLower(strings.TrimSpace(state))
+		if !slices.Contains(LogStates, state) {
+			log.Printf("Ignoring invalid log state '%s'. Valid states are: %s\n", state, strings.Join(LogStates, ", "))
+			continue
+		}
+
+		validStates = append(validStates, state)
 	}
 
-	if config.General.BufferSizes.CTLog <= 0 {
-		config.General.BufferSizes.CTLog = 1000
+	if len(validStates) == 0 {
+		validStates = DefaultLogStates
 	}
 
-	if config.General.BufferSizes.BroadcastManager <= 0 {
-		config.General.BufferSizes.BroadcastManager = 10000
+	config.General.LogStates = validStates
+
+	if config.General.ParseWorkers < 0 {
+		log.Printf("Number of parse workers %d is negative - parsing in the log workers\n", config.General.ParseWorkers)
+		config.General.ParseWorkers = 0
 	}
 
-	// Set defaults for scanner options
-	if config.General.ScannerOptions.BatchSize <= 0 {
-		config.General.ScannerOptions.BatchSize = 100
+	if proxyURL := config.General.HTTPClient.ProxyURL; proxyURL != "" {
+		if parsedURL, err := url.Parse(proxyURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+			return fmt.Errorf("proxy URL is not a valid HTTP or HTTPS URL: '%s'", proxyURL)
+		}
+	}
+
+	for url := range config.General.LogOptions {
+		if !IsValidLogURL(url) {
+			log.Println("Ignoring log options for invalid log URL: ", url)
+			delete(config.General.LogOptions, url)
+		}
 	}
 
-	if config.General.ScannerOptions.ParallelFetch <= 0 {
-		config.General.ScannerOptions.ParallelFetch = 1
+	if lookalike := &config.Detection.Lookalike; lookalike.Threshold < 0 || lookalike.Threshold > 1 {
+		log.Printf("Lookalike threshold %v is not between 0 and 1 - defaulting to 0.85\n", lookalike.Threshold)
+		lookalike.Threshold = 0.85
 	}
 
-	if config.General.ScannerOptions.NumWorkers <= 0 {
-		config.General.ScannerOptions.NumWorkers = 1
+	config.General.LogLevel = strings.ToLower(config.General.LogLevel)
+	if !slices.Contains([]string{"debug", "info", "warn", "error"}, config.General.LogLevel) {
+		log.Printf("Log level '%s' is not one of debug, info, warn or error - defaulting to info\n", config.General.LogLevel)
+		config.General.LogLevel = "info"
 	}
 
-	// If the cleanup flag is not set, default to true
-	if config.General.DropOldLogs == nil {
-		log.Println("drop_old_logs is not set, defaulting to true")
-		defaultCleanup := true
-		config.General.DropOldLogs = &defaultCleanup
+	if persistent := &config.General.Deduplicate.Persistent; config.General.Deduplicate.Enabled && persistent.Enabled &&
+		(persistent.FalsePositiveRate < 0 || persistent.FalsePositiveRate >= 1) {
+		log.Printf("False-positive rate %v is not between 0 and 1 - defaulting to 0.000001\n", persistent.FalsePositiveRate)
+		persistent.FalsePositiveRate = 0.000001
 	}
 
-	if config.General.Recovery.Enabled && config.General.Recovery.CTIndexFile == "" {
-		log.Println("Recovery enabled but no index file specified. Defaulting to ./ct_index.json")
-		config.General.Recovery.CTIndexFile = "./ct_index.json"
+	if _, err := StartIndex(config.General.StartPosition, 0); err != nil {
+		return err
 	}
 
-	return true
+	return nil
 }
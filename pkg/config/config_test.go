@@ -0,0 +1,160 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestStartIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		position string
+		want     uint64
+		wantErr  bool
+	}{
+		{"", 1000, false},
+		{"head", 1000, false},
+		{"tail", 0, false},
+		{"head_minus:100", 900, false},
+		{"head_minus:5000", 0, false},
+		{"head_minus:", 0, true},
+		{"head_minus:-1", 0, true},
+		{"middle", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := StartIndex(tt.position, 1000)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("StartIndex(%q) returned error %v, want error: %t", tt.position, err, tt.wantErr)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("StartIndex(%q) = %d, want %d", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	var conf Config
+	conf.General.BufferSizes.Websocket = 300
+	conf.General.BufferSizes.CTLog = -1
+	conf.General.Recovery.Enabled = true
+	conf.General.Recovery.CTIndexFile = filepath.Join(dir, "missing", "ct_index.json")
+	conf.Output.Kafka = &KafkaConfig{Brokers: []string{"localhost:9092"}}
+	conf.Output.Webhook = &WebhookConfig{URL: "example.com/hook"}
+	conf.General.MinSANs = 10
+	conf.General.MaxSANs = 5
+	conf.General.SampleRate = 1.5
+	conf.General.LogFilterMode = LogFilterInclude
+
+	err := conf.Validate()
+	if err == nil {
+		t.Fatal("Validate() returned no error")
+	}
+
+	for _, want := range []string{
+		"general.buffer_sizes.ctlog must be positive, but is -1",
+		"general.buffer_sizes.broadcastmanager must be positive, but is 0",
+		"general.recovery.ct_index_file",
+		"output.kafka.topic must be set",
+		"output.webhook.url",
+		"general.min_sans (10) must not be greater than general.max_sans (5)",
+		"general.sample_rate must be between 0 and 1, but is 1.5",
+		"general.log_filter_mode is 'include', but general.log_filter_file is not set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() error does not contain '%s':\n%v", want, err)
+		}
+	}
+
+	if strings.Contains(err.Error(), "websocket") || strings.Contains(err.Error(), "brokers") {
+		t.Errorf("Validate() reported valid fields:\n%v", err)
+	}
+
+	conf.General.BufferSizes.CTLog = 1000
+	conf.General.BufferSizes.BroadcastManager = 10000
+	conf.General.Recovery.CTIndexFile = filepath.Join(dir, "ct_index.json")
+	conf.Output.Kafka.Topic = "certstream"
+	conf.Output.Webhook.URL = "https://example.com/hook"
+	conf.General.MaxSANs = 0
+	conf.General.SampleRate = 0.5
+	conf.General.LogFilterMode = ""
+
+	if err = conf.Validate(); err != nil {
+		t.Errorf("Validate() of valid config returned error:\n%v", err)
+	}
+}
+
+func TestApplyDefaults(t *testing.T) {
+	t.Parallel()
+
+	var conf Config
+	if err := yaml.Unmarshal([]byte("general:\n  buffer_sizes:\n    websocket: 50\n  http_client:\n    timeout: 5s\n"), &conf); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.ApplyDefaults()
+
+	// Fields set in the file are preserved
+	if conf.General.BufferSizes.Websocket != 50 {
+		t.Errorf("websocket buffer size = %d, want 50", conf.General.BufferSizes.Websocket)
+	}
+
+	if conf.General.HTTPClient.Timeout != 5*time.Second {
+		t.Errorf("http client timeout = %v, want 5s", conf.General.HTTPClient.Timeout)
+	}
+
+	if conf.General.BufferSizes.CTLog != 1000 || conf.General.BufferSizes.BroadcastManager != 10000 {
+		t.Errorf("buffer sizes = %+v, want defaults for ctlog and broadcastmanager", conf.General.BufferSizes)
+	}
+
+	if conf.General.Recovery.FlushInterval != 5*time.Second {
+		t.Errorf("recovery flush interval = %v, want 5s", conf.General.Recovery.FlushInterval)
+	}
+
+	if err := conf.Validate(); err != nil {
+		t.Errorf("Validate() of defaults returned error: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	var current Config
+	current.Webserver.ListenAddr = "0.0.0.0"
+	current.Webserver.ListenPort = 8080
+	current.General.LogLevel = "info"
+	current.ApplyDefaults()
+
+	next := current
+	next.Webserver.ListenPort = 9090
+	next.Webserver.Limits.MaxConnections = 10
+	next.General.LogLevel = "debug"
+	next.General.BufferSizes.CTLog = 50
+	next.Output.File = &FileConfig{Path: "certs.jsonl"}
+
+	reloaded, ignored := current.Reload(next)
+
+	if want := []string{"webserver.listen_port", "general.buffer_sizes.ctlog"}; !slices.Equal(ignored, want) {
+		t.Errorf("Reload() ignored %v, want %v", ignored, want)
+	}
+
+	if reloaded.Webserver.ListenPort != 8080 || reloaded.General.BufferSizes.CTLog != current.General.BufferSizes.CTLog {
+		t.Error("Reload() applied options that can only be changed with a restart")
+	}
+
+	if reloaded.Webserver.Limits.MaxConnections != 10 || reloaded.General.LogLevel != "debug" || reloaded.Output.File == nil {
+		t.Error("Reload() didn't apply the reloadable options")
+	}
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"compress/flate"
+	"path/filepath"
+	"time"
+)
+
+// ApplyDefaults sets all fields that are not set to their defaults. Fields that are set are preserved, even if
+// they are out of range - these are corrected or reported when the config is read.
+// ReadConfig applies the defaults before validating the config, so partial config files are safe to use.
+func (c *Config) ApplyDefaults() {
+	c.applyWebserverDefaults()
+	c.applyGeneralDefaults()
+	c.applyOutputDefaults()
+
+	if c.Prometheus.Enabled {
+		if c.Prometheus.StatsURL == "" {
+			c.Prometheus.StatsURL = "/stats"
+		}
+
+		if c.Prometheus.Whitelist == nil {
+			c.Prometheus.Whitelist = []string{}
+		}
+	}
+
+	if c.Detection.Lookalike.Threshold == 0 {
+		c.Detection.Lookalike.Threshold = 0.85
+	}
+
+	if firstSeen := &c.Detection.FirstSeen; firstSeen.Enabled {
+		if firstSeen.Window <= 0 {
+			firstSeen.Window = 7 * 24 * time.Hour
+		}
+
+		if firstSeen.Capacity <= 0 {
+			firstSeen.Capacity = 500000
+		}
+	}
+}
+
+func (c *Config) applyWebserverDefaults() {
+	webserver := &c.Webserver
+
+	if webserver.FullURL == "" {
+		webserver.FullURL = "/full-stream"
+	}
+
+	if webserver.LiteURL == "" {
+		webserver.LiteURL = "/"
+	}
+
+	if webserver.DomainsOnlyURL == "" {
+		webserver.DomainsOnlyURL = "/domains-only"
+	}
+
+	if webserver.BroadcastShards <= 0 {
+		webserver.BroadcastShards = 1
+	}
+
+	if webserver.LatestBufferSize <= 0 {
+		webserver.LatestBufferSize = 100
+	}
+
+	if len(webserver.CountWindows) == 0 {
+		webserver.CountWindows = []time.Duration{time.Minute, 5 * time.Minute, time.Hour}
+	}
+
+	if webserver.ReadinessThreshold <= 0 {
+		webserver.ReadinessThreshold = 2 * time.Minute
+	}
+
+	if webserver.PingInterval <= 0 {
+		webserver.PingInterval = 30 * time.Second
+	}
+
+	if webserver.PongTimeout <= 0 {
+		webserver.PongTimeout = 35 * time.Second
+	}
+
+	if webserver.CompressionLevel == 0 {
+		webserver.CompressionLevel = flate.BestSpeed
+	}
+}
+
+func (c *Config) applyGeneralDefaults() {
+	general := &c.General
+
+	// Negative buffer sizes are reported by Validate
+	if general.BufferSizes.Websocket == 0 {
+		general.BufferSizes.Websocket = 300
+	}
+
+	if general.BufferSizes.CTLog == 0 {
+		general.BufferSizes.CTLog = 1000
+	}
+
+	if general.BufferSizes.BroadcastManager == 0 {
+		general.BufferSizes.BroadcastManager = 10000
+	}
+
+	if general.ScannerOptions.BatchSize <= 0 {
+		general.ScannerOptions.BatchSize = 100
+	}
+
+	if general.ScannerOptions.ParallelFetch <= 0 {
+		general.ScannerOptions.ParallelFetch = 1
+	}
+
+	if general.ScannerOptions.NumWorkers <= 0 {
+		general.ScannerOptions.NumWorkers = 1
+	}
+
+	if general.STHPollInterval <= 0 {
+		general.STHPollInterval = 5 * time.Second
+	}
+
+	if general.HTTPClient.Timeout <= 0 {
+		general.HTTPClient.Timeout = 30 * time.Second
+	}
+
+	if general.HTTPClient.MaxIdleConnsPerHost <= 0 {
+		general.HTTPClient.MaxIdleConnsPerHost = 16
+	}
+
+	if general.LogList.StartupAttempts <= 0 {
+		general.LogList.StartupAttempts = 5
+	}
+
+	if general.LogList.StartupTimeout <= 0 {
+		general.LogList.StartupTimeout = 2 * time.Minute
+	}
+
+	if general.LogListCache.Path != "" && general.LogListCache.TTL <= 0 {
+		general.LogListCache.TTL = time.Hour
+	}
+
+	if general.LogFilterFile != "" && general.LogFilterMode == "" {
+		general.LogFilterMode = LogFilterInclude
+	}
+
+	if general.DropOldLogs == nil {
+		dropOldLogs := true
+		general.DropOldLogs = &dropOldLogs
+	}
+
+	if general.IncludeChainDER == nil {
+		includeChainDER := true
+		general.IncludeChainDER = &includeChainDER
+	}
+
+	if general.SampleRate == 0 {
+		general.SampleRate = 1
+	}
+
+	if general.LogLevel == "" {
+		general.LogLevel = "info"
+	}
+
+	if tracing := &general.Tracing; tracing.Enabled {
+		if tracing.Endpoint == "" {
+			tracing.Endpoint = "localhost:4317"
+		}
+
+		if tracing.SampleRatio <= 0 || tracing.SampleRatio > 1 {
+			tracing.SampleRatio = 1
+		}
+
+		if tracing.ServiceName == "" {
+			tracing.ServiceName = "certstream-server-go"
+		}
+	}
+
+	if general.Recovery.Enabled && general.Recovery.CTIndexFile == "" {
+		general.Recovery.CTIndexFile = "./ct_index.json"
+	}
+
+	if general.Recovery.FlushInterval <= 0 {
+		general.Recovery.FlushInterval = 5 * time.Second
+	}
+
+	if dedup := &general.Deduplicate; dedup.Enabled {
+		if dedup.TTL <= 0 {
+			dedup.TTL = 30 * time.Second
+		}
+
+		if dedup.Capacity <= 0 {
+			dedup.Capacity = 100000
+		}
+
+		if persistent := &dedup.Persistent; persistent.Enabled {
+			if persistent.File == "" {
+				persistent.File = filepath.Join(filepath.Dir(general.Recovery.CTIndexFile), "ct_dedup.bloom")
+			}
+
+			if persistent.Capacity <= 0 {
+				persistent.Capacity = 1000000
+			}
+
+			if persistent.FalsePositiveRate == 0 {
+				persistent.FalsePositiveRate = 0.000001
+			}
+
+			if persistent.FlushInterval <= 0 {
+				persistent.FlushInterval = 10 * time.Second
+			}
+		}
+	}
+}
+
+func (c *Config) applyOutputDefaults() {
+	if c.Output.WebsocketPolicy == "" {
+		c.Output.WebsocketPolicy = PolicyDrop
+	}
+
+	if kafka := c.Output.Kafka; kafka != nil {
+		if kafka.BatchSize <= 0 {
+			kafka.BatchSize = 100
+		}
+
+		if kafka.QueueSize <= 0 {
+			kafka.QueueSize = 10000
+		}
+
+		if kafka.Policy == "" {
+			kafka.Policy = PolicyDrop
+		}
+	}
+
+	if webhook := c.Output.Webhook; webhook != nil {
+		if webhook.BatchSize <= 0 {
+			webhook.BatchSize = 100
+		}
+
+		if webhook.FlushInterval <= 0 {
+			webhook.FlushInterval = 5 * time.Second
+		}
+
+		if webhook.QueueSize <= 0 {
+			webhook.QueueSize = 10000
+		}
+
+		if webhook.Policy == "" {
+			webhook.Policy = PolicyDrop
+		}
+	}
+
+	if file := c.Output.File; file != nil {
+		if file.QueueSize <= 0 {
+			file.QueueSize = 10000
+		}
+
+		if file.Policy == "" {
+			file.Policy = PolicyDrop
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Reload returns a copy of c with the options that can be changed at runtime taken from next. These are:
+//   - general.buffer_sizes.websocket, which applies to new connections
+//   - general.disable_default_logs, general.additional_logs, general.include_operators, general.exclude_operators,
+//     general.log_filter_file, general.log_filter_mode and general.log_states, which select the watched logs.
+//     The log filter file is read again when next is loaded
+//   - general.log_level, general.quiet and general.time_format
+//   - webserver.limits
+//   - output.kafka, output.webhook and output.file, which restart the respective output
+//
+// All other options keep their current value. Their yaml paths are returned if they differ in next, so that
+// the caller can report that they were ignored.
+func (c Config) Reload(next Config) (Config, []string) {
+	reloaded := c
+
+	reloaded.General.BufferSizes.Websocket = next.General.BufferSizes.Websocket
+	reloaded.General.DisableDefaultLogs = next.General.DisableDefaultLogs
+	reloaded.General.AdditionalLogs = next.General.AdditionalLogs
+	reloaded.General.IncludeOperators = next.General.IncludeOperators
+	reloaded.General.ExcludeOperators = next.General.ExcludeOperators
+	reloaded.General.LogFilterFile = next.General.LogFilterFile
+	reloaded.General.LogFilterMode = next.General.LogFilterMode
+	reloaded.General.LogFilter = next.General.LogFilter
+	reloaded.General.LogStates = next.General.LogStates
+	reloaded.General.LogLevel = next.General.LogLevel
+	reloaded.General.Quiet = next.General.Quiet
+	reloaded.General.TimeFormat = next.General.TimeFormat
+	reloaded.Webserver.Limits = next.Webserver.Limits
+	reloaded.Output = next.Output
+	// The websocket endpoints are registered on start
+	reloaded.Output.DisableWebsocket = c.Output.DisableWebsocket
+	reloaded.Output.WebsocketPolicy = c.Output.WebsocketPolicy
+
+	return reloaded, changedFields("", reflect.ValueOf(reloaded), reflect.ValueOf(next))
+}
+
+// changedFields returns the yaml paths of the fields that differ between a and b, which are values of the same type.
+// Structs are compared field by field, all other values as a whole.
+func changedFields(path string, a, b reflect.Value) []string {
+	if a.Kind() != reflect.Struct {
+		if reflect.DeepEqual(a.Interface(), b.Interface()) {
+			return nil
+		}
+
+		return []string{path}
+	}
+
+	var changed []string
+
+	for i := range a.NumField() {
+		field := a.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// Inlined fields share the path of their parent, other fields without a name are lowercased like yaml does
+		fieldPath := path
+		if options != "inline" {
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			fieldPath = strings.TrimPrefix(path+"."+name, ".")
+		}
+
+		changed = append(changed, changedFields(fieldPath, a.Field(i), b.Field(i))...)
+	}
+
+	return changed
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/letrics/certstream-server-go/pkg/models"
+)
+
+// Validate checks the config for problems that would otherwise only show at runtime, often as a server that starts
+// but doesn't do anything: buffer sizes that are not positive, recovery files that can't be written and outputs
+// without their required fields. All problems are returned at once, joined with errors.Join.
+// Defaults are not applied, so a config built in code must set all buffer sizes.
+func (c *Config) Validate() error {
+	var errs []error
+
+	buffers := []struct {
+		name string
+		size int
+	}{
+		{"websocket", c.General.BufferSizes.Websocket},
+		{"ctlog", c.General.BufferSizes.CTLog},
+		{"broadcastmanager", c.General.BufferSizes.BroadcastManager},
+	}
+	for _, buffer := range buffers {
+		if buffer.size <= 0 {
+			errs = append(errs, fmt.Errorf("general.buffer_sizes.%s must be positive, but is %d", buffer.name, buffer.size))
+		}
+	}
+
+	for _, window := range c.Webserver.CountWindows {
+		if window < time.Second {
+			errs = append(errs, fmt.Errorf("webserver.count_windows must be at least 1s, but contains %s", window))
+		}
+	}
+
+	if _, err := models.ParseFields(c.Webserver.Fields); err != nil {
+		errs = append(errs, fmt.Errorf("webserver.fields: %w", err))
+	}
+
+	if c.Webserver.MaxDomainsPerEntry < 0 {
+		errs = append(errs, fmt.Errorf("webserver.max_domains_per_entry must not be negative, but is %d", c.Webserver.MaxDomainsPerEntry))
+	}
+
+	if _, err := models.ParseTimeFormat(c.General.TimeFormat); err != nil {
+		errs = append(errs, fmt.Errorf("general.time_format: %w", err))
+	}
+
+	if minSANs, maxSANs := c.General.MinSANs, c.General.MaxSANs; minSANs < 0 || maxSANs < 0 {
+		errs = append(errs, fmt.Errorf("general.min_sans and general.max_sans must not be negative, but are %d and %d", minSANs, maxSANs))
+	} else if maxSANs > 0 && minSANs > maxSANs {
+		errs = append(errs, fmt.Errorf("general.min_sans (%d) must not be greater than general.max_sans (%d)", minSANs, maxSANs))
+	}
+
+	if c.General.LogFilterMode != "" && c.General.LogFilterFile == "" {
+		errs = append(errs, fmt.Errorf("general.log_filter_mode is '%s', but general.log_filter_file is not set", c.General.LogFilterMode))
+	}
+
+	if limit := c.General.RequestRateLimit; limit.RequestsPerSecond < 0 || limit.Burst < 0 {
+		errs = append(errs, fmt.Errorf("general.request_rate_limit.requests_per_second and burst must not be negative, but are %v and %d",
+			limit.RequestsPerSecond, limit.Burst))
+	}
+
+	if firstSeen := c.Detection.FirstSeen; firstSeen.Window < 0 || firstSeen.Capacity < 0 {
+		errs = append(errs, fmt.Errorf("detection.first_seen.window and capacity must not be negative, but are %s and %d",
+			firstSeen.Window, firstSeen.Capacity))
+	}
+
+	if c.General.SampleRate < 0 || c.General.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("general.sample_rate must be between 0 and 1, but is %v", c.General.SampleRate))
+	}
+
+	if c.General.Heartbeat < 0 {
+		errs = append(errs, fmt.Errorf("general.heartbeat must not be negative, but is %s", c.General.Heartbeat))
+	}
+
+	for name := range c.General.HTTPHeaders {
+		if strings.TrimSpace(name) == "" || strings.EqualFold(name, "Host") {
+			errs = append(errs, fmt.Errorf("general.http_headers must not contain the header '%s'", name))
+		}
+	}
+
+	if c.General.Recovery.SaveEveryN < 0 {
+		errs = append(errs, fmt.Errorf("general.recovery.save_every_n must not be negative, but is %d", c.General.Recovery.SaveEveryN))
+	}
+
+	if recovery := c.General.Recovery; recovery.Enabled {
+		if err := checkWritable(recovery.CTIndexFile); err != nil {
+			errs = append(errs, fmt.Errorf("general.recovery.ct_index_file '%s' is not writable: %w", recovery.CTIndexFile, err))
+		}
+	}
+
+	if dedup := c.General.Deduplicate; dedup.Enabled && dedup.Persistent.Enabled {
+		if err := checkWritable(dedup.Persistent.File); err != nil {
+			errs = append(errs, fmt.Errorf("general.deduplicate.persistent.file '%s' is not writable: %w", dedup.Persistent.File, err))
+		}
+	}
+
+	if cachePath := c.General.LogListCache.Path; cachePath != "" {
+		if err := checkWritable(cachePath); err != nil {
+			errs = append(errs, fmt.Errorf("general.log_list_cache.path '%s' is not writable: %w", cachePath, err))
+		}
+	}
+
+	if err := checkPolicy("output.websocket_policy", c.Output.WebsocketPolicy); err != nil {
+		errs = append(errs, err)
+	}
+
+	if kafka := c.Output.Kafka; kafka != nil {
+		if err := checkPolicy("output.kafka.policy", kafka.Policy); err != nil {
+			errs = append(errs, err)
+		}
+
+		if len(kafka.Brokers) == 0 {
+			errs = append(errs, errors.New("output.kafka.brokers must contain at least one broker"))
+		}
+
+		if kafka.Topic == "" {
+			errs = append(errs, errors.New("output.kafka.topic must be set"))
+		}
+	}
+
+	if webhook := c.Output.Webhook; webhook != nil {
+		if err := checkPolicy("output.webhook.policy", webhook.Policy); err != nil {
+			errs = append(errs, err)
+		}
+
+		if parsedURL, err := url.Parse(webhook.URL); webhook.URL == "" || err != nil ||
+			(parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+			errs = append(errs, fmt.Errorf("output.webhook.url must be an HTTP or HTTPS url, but is '%s'", webhook.URL))
+		}
+	}
+
+	if file := c.Output.File; file != nil {
+		if err := checkPolicy("output.file.policy", file.Policy); err != nil {
+			errs = append(errs, err)
+		}
+
+		if file.Path == "" {
+			errs = append(errs, errors.New("output.file.path must be set"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkPolicy checks that the policy of the output with the given yaml path is known. An empty policy means the
+// default policy.
+func checkPolicy(name string, policy SinkPolicy) error {
+	switch policy {
+	case "", PolicyDrop, PolicyBlock:
+		return nil
+	default:
+		return fmt.Errorf("%s must be '%s' or '%s', but is '%s'", name, PolicyDrop, PolicyBlock, policy)
+	}
+}
+
+// checkWritable checks that the file at the given path can be written. Files are replaced via a temporary file in the
+// same directory, so the directory must be writable as well, even if the file already exists.
+func checkWritable(path string) error {
+	if path == "" {
+		return errors.New("no path set")
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return errors.New("it is a directory")
+	case err == nil:
+		file, openErr := os.OpenFile(path, os.O_WRONLY, 0)
+		if openErr != nil {
+			return openErr
+		}
+
+		file.Close()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".certstream-write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory is not writable: %w", err)
+	}
+
+	file.Close()
+
+	return os.Remove(file.Name())
+}
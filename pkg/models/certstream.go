@@ -2,10 +2,16 @@ package models
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"log"
+	"time"
 )
 
+// MessageTypeHeartbeat is the message type of the heartbeat entries, see NewHeartbeat.
+const MessageTypeHeartbeat = "heartbeat"
+
 type Entry struct {
 	Data           Data   `json:"data"`
 	MessageType    string `json:"message_type"`
@@ -13,6 +19,17 @@ type Entry struct {
 	cachedJSONLite []byte
 }
 
+// NewHeartbeat returns a heartbeat entry, which signals that the watcher is alive although no certificate was
+// delivered for a while. It carries no certificate, only the given time as Data.Seen.
+func NewHeartbeat(t time.Time) Entry {
+	return Entry{Data: Data{Seen: NewTimestamp(t)}, MessageType: MessageTypeHeartbeat}
+}
+
+// IsHeartbeat returns true if the entry is a heartbeat instead of a certificate, see NewHeartbeat.
+func (e *Entry) IsHeartbeat() bool {
+	return e.MessageType == MessageTypeHeartbeat
+}
+
 // Clone returns a new copy of the Entry.
 func (e *Entry) Clone() Entry {
 	return Entry{
@@ -50,26 +67,71 @@ func (e *Entry) JSONLite() []byte {
 
 // JSONLiteNoCache does the same as JSONNoCache() but removes the chain and cert's DER representation.
 func (e *Entry) JSONLiteNoCache() []byte {
-	newEntry := e.Clone()
-	newEntry.Data.Chain = nil
-	newEntry.Data.LeafCert.AsDER = ""
-
+	newEntry := e.Lite()
 	return newEntry.entryToJSONBytes()
 }
 
+// Lite returns a copy of the entry without the chain and cert's DER representation, as sent to the clients of the
+// lite stream. The cached JSON representations are not copied.
+func (e *Entry) Lite() Entry {
+	entry := Entry{Data: e.Data, MessageType: e.MessageType}
+	entry.Data.Chain = nil
+	entry.Data.LeafCert.AsDER = ""
+	entry.Data.LeafCert.DER = nil
+
+	return entry
+}
+
+// WithoutDetails returns a copy of the entry without the details of the leaf and chain certificates that are not
+// part of the default websocket payload, see LeafCert. The cached JSON representations are not copied.
+func (e *Entry) WithoutDetails() Entry {
+	entry := Entry{Data: e.Data, MessageType: e.MessageType}
+	entry.Data.LeafCert.clearDetails()
+
+	if e.Data.Chain != nil {
+		entry.Data.Chain = make([]LeafCert, len(e.Data.Chain))
+		for i, cert := range e.Data.Chain {
+			cert.clearDetails()
+			entry.Data.Chain[i] = cert
+		}
+	}
+
+	return entry
+}
+
+// WithMaxDomains returns a copy of the entry with at most limit domains in AllDomains and AllDomainsUnicode of the
+// leaf certificate. If domains were removed, DomainsTruncated and TotalDomains are set. The cached JSON
+// representations are not copied.
+func (e *Entry) WithMaxDomains(limit int) Entry {
+	entry := Entry{Data: e.Data, MessageType: e.MessageType}
+
+	leafCert := &entry.Data.LeafCert
+	if total := len(leafCert.AllDomains); total > limit {
+		// The capacity is limited as well, so that appending to the copy doesn't overwrite the domains of e
+		leafCert.AllDomains = leafCert.AllDomains[:limit:limit]
+		if len(leafCert.AllDomainsUnicode) > limit {
+			leafCert.AllDomainsUnicode = leafCert.AllDomainsUnicode[:limit:limit]
+		}
+
+		leafCert.DomainsTruncated = true
+		leafCert.TotalDomains = total
+	}
+
+	return entry
+}
+
 // JSONDomains returns the json encoded domains (DomainsEntry) as byte slice.
 func (e *Entry) JSONDomains() []byte {
-	domainsEntry := DomainsEntry{
+	domainsEntry := e.DomainsEntry()
+	return domainsEntry.JSON()
+}
+
+// DomainsEntry returns the domains of the entry, as sent to the clients of the domains-only stream.
+func (e *Entry) DomainsEntry() DomainsEntry {
+	return DomainsEntry{
 		Data:        e.Data.LeafCert.AllDomains,
 		MessageType: "dns_entries",
 	}
-
-	domainsEntryBytes, err := json.Marshal(domainsEntry)
-	if err != nil {
-		log.Println(err)
-	}
-
-	return domainsEntryBytes
 }
 
 // entryToJSONBytes encodes an Entry to a JSON byte slice.
@@ -87,16 +149,52 @@ func (e *Entry) entryToJSONBytes() []byte {
 }
 
 type Data struct {
-	CertIndex  uint64     `json:"cert_index"`
-	CertLink   string     `json:"cert_link"`
-	Chain      []LeafCert `json:"chain,omitempty"`
-	LeafCert   LeafCert   `json:"leaf_cert"`
-	Seen       float64    `json:"seen"`
-	Source     Source     `json:"source"`
-	UpdateType string     `json:"update_type"`
+	// CertIndex is the index of the entry in the tree of the CT log it was fetched from.
+	CertIndex uint64 `json:"cert_index"`
+	CertLink  string `json:"cert_link"`
+	// Chain contains the intermediate certificates up to the root, as submitted to the CT log.
+	// The DER of the chain certificates is left out if the config option IncludeChainDER is disabled.
+	Chain []LeafCert `json:"chain,omitempty"`
+	// Detection contains the findings of the detectors. It is nil if no detector flagged the certificate.
+	Detection *Detection `json:"detection,omitempty"`
+	LeafCert  LeafCert   `json:"leaf_cert"`
+	// LeafHash is the base64 encoded Merkle tree leaf hash of the entry (RFC 6962), which identifies the entry in
+	// get-proof-by-hash requests to the log. It is only populated if the config option IncludeLeafHash is enabled.
+	LeafHash string `json:"leaf_hash,omitempty"`
+	// Seen is the time the CT watcher received the entry from the log, with microsecond precision.
+	Seen   Timestamp `json:"seen"`
+	Source Source    `json:"source"`
+	// UpdateType is the type of the CT log entry, see UpdateType.
+	UpdateType UpdateType `json:"update_type"`
 }
 
+// UpdateType is the type of a CT log entry, as given by the entry type of its Merkle tree leaf (RFC 6962).
+// It is encoded as its name, e.g. "X509LogEntry".
+type UpdateType string
+
+const (
+	// UpdateTypeX509 is the type of entries of the log entry type x509_entry, which contain a final certificate.
+	UpdateTypeX509 UpdateType = "X509LogEntry"
+	// UpdateTypePrecert is the type of entries of the log entry type precert_entry, which contain a precertificate.
+	UpdateTypePrecert UpdateType = "PrecertLogEntry"
+)
+
+// Detection contains the findings of the detectors that flag suspicious certificates.
+type Detection struct {
+	// LookalikeMatches contains the domains of the watchlist that the domains of the certificate are confusable with.
+	LookalikeMatches []string `json:"lookalike_matches,omitempty"`
+	// CommonNameMatches contains the domains of the watchlist that the CN of the subject is confusable with. The CN is
+	// checked on its own, since it is not part of AllDomains for CA certificates, and a lookalike that only appears
+	// in the CN is a strong hint for phishing.
+	CommonNameMatches []string `json:"common_name_matches,omitempty"`
+	// FirstSeenDomain is true if at least one registrable domain of the certificate was seen by the server for the
+	// first time within the configured window, which hints at a newly registered domain.
+	FirstSeenDomain bool `json:"first_seen_domain,omitempty"`
+}
+
+// Source describes the CT log an entry was fetched from.
 type Source struct {
+	// Name is the description of the CT log as found in the log list. Falls back to the url if no description exists.
 	Name          string `json:"name"`
 	URL           string `json:"url"`
 	Operator      string `json:"-"`
@@ -104,19 +202,120 @@ type Source struct {
 }
 
 type LeafCert struct {
-	AllDomains         []string   `json:"all_domains"`
-	AsDER              string     `json:"as_der,omitempty"`
-	Extensions         Extensions `json:"extensions"`
-	Fingerprint        string     `json:"fingerprint"`
-	SHA1               string     `json:"sha1"`
-	SHA256             string     `json:"sha256"`
-	NotAfter           int64      `json:"not_after"`
-	NotBefore          int64      `json:"not_before"`
-	SerialNumber       string     `json:"serial_number"`
-	SignatureAlgorithm string     `json:"signature_algorithm"`
-	Subject            Subject    `json:"subject"`
-	Issuer             Subject    `json:"issuer"`
-	IsCA               bool       `json:"is_ca"`
+	// AllDomains contains the DNS names of the SANs and the CN. IP addresses are listed in IPAddresses instead.
+	AllDomains []string `json:"all_domains"`
+	// AllDomainsUnicode contains the domains of AllDomains in the same order, with internationalized domains decoded
+	// from punycode to Unicode. Domains that can't be decoded are kept as they are.
+	AllDomainsUnicode []string `json:"all_domains_unicode"`
+	// DomainsTruncated is true if AllDomains and AllDomainsUnicode were truncated, see Entry.WithMaxDomains.
+	// TotalDomains is the number of domains before the truncation in this case.
+	DomainsTruncated bool `json:"domains_truncated,omitempty"`
+	TotalDomains     int  `json:"total_domains,omitempty"`
+	// RegistrableDomains contains the distinct registrable domains (eTLD+1) of AllDomains, e.g. "example.co.uk" for
+	// "*.www.example.co.uk". IP addresses are skipped.
+	RegistrableDomains []string `json:"registrable_domains"`
+	// IPAddresses contains the IP addresses of the IP SANs, as well as DNS SANs and the CN that are IP addresses.
+	// IPv4 addresses are written in dotted decimal and IPv6 addresses in their compressed form, e.g. "2001:db8::1".
+	IPAddresses []string `json:"ip_addresses,omitempty"`
+	AsDER       string   `json:"as_der,omitempty"`
+	// DER contains the exact bytes of the certificate as found in the CT log entry.
+	// It is only populated if the config option IncludeDER is enabled.
+	DER        []byte     `json:"der,omitempty"`
+	Extensions Extensions `json:"extensions"`
+	// Fingerprint is the SHA-256 fingerprint of the certificate, the same as SHA256. Duplicates are detected by it.
+	Fingerprint string `json:"fingerprint"`
+	// FingerprintSHA1 is the SHA-1 fingerprint of the certificate, the same as SHA1, for tools that still key on it.
+	FingerprintSHA1 string `json:"fingerprint_sha1"`
+	SHA1            string `json:"sha1"`
+	SHA256          string `json:"sha256"`
+	// NotAfter and NotBefore are serialized in the time format set with SetTimeFormat, unix timestamps by default.
+	NotAfter  UnixTime `json:"not_after"`
+	NotBefore UnixTime `json:"not_before"`
+	// NotBeforeTime and NotAfterTime contain the validity window in UTC. They are always serialized as RFC3339
+	// timestamps.
+	NotBeforeTime time.Time `json:"not_before_time"`
+	NotAfterTime  time.Time `json:"not_after_time"`
+	// ValidityDays is the number of full days the certificate is valid, e.g. 90 for most Let's Encrypt certificates.
+	ValidityDays int `json:"validity_days"`
+	// SerialNumber is the serial number of the certificate in uppercase hex with an even number of digits.
+	SerialNumber string `json:"serial_number"`
+	// SubjectKeyID and AuthorityKeyID are the key identifiers of the subject and the issuer in uppercase hex,
+	// e.g. to find the certificates issued by a CA certificate. Empty if the certificate doesn't contain them.
+	SubjectKeyID       string  `json:"subject_key_id,omitempty"`
+	AuthorityKeyID     string  `json:"authority_key_id,omitempty"`
+	SignatureAlgorithm string  `json:"signature_algorithm"`
+	Subject            Subject `json:"subject"`
+	Issuer             Subject `json:"issuer"`
+	IsCA               bool    `json:"is_ca"`
+	// IsPrecert is true if the entry is a precertificate, i.e. the CA logged it before issuing the final certificate.
+	// Most certificates show up twice in CT logs: once as precertificate and once as final certificate.
+	IsPrecert bool `json:"is_precert"`
+	// PublicKey describes the subject public key of the certificate.
+	PublicKey PublicKey `json:"public_key"`
+	// ExtendedKeyUsages, KeyUsages, CRLDistributionPoints and OCSPServers describe the purpose and the revocation
+	// endpoints of the certificate. They are only sent to websocket clients that requested the full payload.
+	ExtendedKeyUsages     []string `json:"extended_key_usages,omitempty"`
+	KeyUsages             []string `json:"key_usages,omitempty"`
+	CRLDistributionPoints []string `json:"crl_distribution_points,omitempty"`
+	OCSPServers           []string `json:"ocsp_servers,omitempty"`
+	// SCTs contains the Signed Certificate Timestamps embedded in the certificate.
+	SCTs []SCT `json:"scts"`
+}
+
+// SANCount returns the number of names the certificate was issued to: the domains, including wildcard domains and the
+// CN if it isn't among the SANs, and the IP addresses. Each name is counted once.
+func (l *LeafCert) SANCount() int {
+	return len(l.AllDomains) + len(l.IPAddresses)
+}
+
+// clearDetails removes the details that are not part of the default websocket payload.
+func (l *LeafCert) clearDetails() {
+	l.ExtendedKeyUsages = nil
+	l.KeyUsages = nil
+	l.CRLDistributionPoints = nil
+	l.OCSPServers = nil
+}
+
+// AsPEM returns the PEM encoded certificate. It is computed on demand from DER, or from AsDER if DER is not populated.
+// An empty string is returned if neither of them is available.
+func (l *LeafCert) AsPEM() string {
+	der := l.DER
+	if len(der) == 0 {
+		decoded, err := base64.StdEncoding.DecodeString(l.AsDER)
+		if err != nil {
+			return ""
+		}
+
+		der = decoded
+	}
+
+	if len(der) == 0 {
+		return ""
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// PublicKey describes the public key of a certificate.
+type PublicKey struct {
+	// Algorithm is the public key algorithm, e.g. "RSA", "ECDSA" or "Ed25519".
+	Algorithm string `json:"algorithm"`
+	// Size is the key size in bits, e.g. 2048 for RSA keys or 256 for keys on the P-256 curve.
+	Size int `json:"size"`
+	// Curve is the name of the elliptic curve for ECDSA keys, e.g. "P-256".
+	Curve string `json:"curve,omitempty"`
+	// SPKISHA256 is the SHA-256 hash of the DER encoded SubjectPublicKeyInfo. It is the same for all certificates
+	// that use the same key.
+	SPKISHA256 string `json:"spki_sha256"`
+	// Unparseable is true if the key could not be parsed. Size and Curve are empty in this case.
+	Unparseable bool `json:"unparseable"`
+}
+
+// SCT is a Signed Certificate Timestamp embedded in a certificate.
+type SCT struct {
+	// LogID is the base64 encoded ID of the CT log that issued the SCT.
+	LogID     string    `json:"log_id"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 type Subject struct {
@@ -147,3 +346,13 @@ type DomainsEntry struct {
 	Data        []string `json:"data"`
 	MessageType string   `json:"message_type"`
 }
+
+// JSON returns the json encoded DomainsEntry as byte slice.
+func (d *DomainsEntry) JSON() []byte {
+	domainsEntryBytes, err := json.Marshal(d)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return domainsEntryBytes
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWithMaxDomains(t *testing.T) {
+	t.Parallel()
+
+	entry := Entry{Data: Data{LeafCert: LeafCert{
+		AllDomains:        []string{"a.example", "b.example", "c.example"},
+		AllDomainsUnicode: []string{"a.example", "b.example", "c.example"},
+	}}}
+
+	truncated := entry.WithMaxDomains(2)
+
+	leafCert := truncated.Data.LeafCert
+	if want := []string{"a.example", "b.example"}; !slices.Equal(leafCert.AllDomains, want) ||
+		!slices.Equal(leafCert.AllDomainsUnicode, want) {
+		t.Errorf("got domains %v and %v, want %v", leafCert.AllDomains, leafCert.AllDomainsUnicode, want)
+	}
+
+	if !leafCert.DomainsTruncated || leafCert.TotalDomains != 3 {
+		t.Errorf("got domains_truncated %t and total_domains %d, want true and 3", leafCert.DomainsTruncated, leafCert.TotalDomains)
+	}
+
+	// Appending to the truncated domains must not overwrite the domains of the original entry
+	_ = append(leafCert.AllDomains, "d.example")
+	if entry.Data.LeafCert.AllDomains[2] != "c.example" || entry.Data.LeafCert.DomainsTruncated {
+		t.Errorf("original entry was modified: %+v", entry.Data.LeafCert)
+	}
+
+	if untouched := entry.WithMaxDomains(3); untouched.Data.LeafCert.DomainsTruncated {
+		t.Error("got domains_truncated for an entry within the limit")
+	}
+}
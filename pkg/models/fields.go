@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Fields selects a subset of the fields of the entries, so that consumers only receive what they need.
+// Fields are selected by their JSON name, which is either the name of a field of Data, e.g. "seen", or of LeafCert,
+// e.g. "all_domains". The structure of the entries is kept: selecting "all_domains" and "seen" yields
+// {"data": {"leaf_cert": {"all_domains": [...]}, "seen": ...}, "message_type": "certificate_update"}.
+// The zero value selects all fields.
+type Fields struct {
+	names map[string]bool
+	// key is the sorted, comma-separated list of the selected names.
+	key string
+}
+
+// fieldNames are the JSON names of the fields of Data and LeafCert, which can be selected.
+var fieldNames = slices.Concat(jsonFieldNames(reflect.TypeFor[Data]()), jsonFieldNames(reflect.TypeFor[LeafCert]()))
+
+// jsonFieldNames returns the JSON names of the encoded fields of the struct type.
+func jsonFieldNames(t reflect.Type) []string {
+	var names []string
+
+	for i := range t.NumField() {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if field.IsExported() && name != "-" && name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// ParseFields returns the selection of the given field names. Empty names are ignored, so that no names at all
+// select all fields. An error is returned for unknown names.
+func ParseFields(names []string) (Fields, error) {
+	selected := make(map[string]bool)
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if !slices.Contains(fieldNames, name) {
+			return Fields{}, fmt.Errorf("unknown field '%s', must be one of %s", name, strings.Join(fieldNames, ", "))
+		}
+
+		selected[name] = true
+	}
+
+	if len(selected) == 0 {
+		return Fields{}, nil
+	}
+
+	return Fields{names: selected, key: strings.Join(slices.Sorted(maps.Keys(selected)), ",")}, nil
+}
+
+// All returns true if all fields are selected.
+func (f Fields) All() bool {
+	return f.names == nil
+}
+
+// Selects returns true if the field with the given JSON name is selected.
+func (f Fields) Selects(name string) bool {
+	return f.names == nil || f.names[name]
+}
+
+// String returns the sorted, comma-separated list of the selected names. It is empty if all fields are selected.
+func (f Fields) String() string {
+	return f.key
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// TimeFormat is the format of the timestamps in the JSON encoding of the entries, see SetTimeFormat.
+type TimeFormat int32
+
+const (
+	// TimeFormatSeconds encodes timestamps as unix timestamps in seconds. Seen keeps its fractional part.
+	TimeFormatSeconds TimeFormat = iota
+	// TimeFormatMillis encodes timestamps as unix timestamps in milliseconds.
+	TimeFormatMillis
+	// TimeFormatRFC3339 encodes timestamps as RFC3339 strings in UTC. Seen keeps its fractional seconds.
+	TimeFormatRFC3339
+)
+
+var timeFormatNames = []string{
+	TimeFormatSeconds: "seconds",
+	TimeFormatMillis:  "millis",
+	TimeFormatRFC3339: "rfc3339",
+}
+
+// timeFormat is the format used by the JSON encoding. It is read concurrently while encoding the entries.
+var timeFormat atomic.Int32
+
+// ParseTimeFormat returns the time format with the given name, which is case-insensitive. An empty name selects
+// TimeFormatSeconds.
+func ParseTimeFormat(name string) (TimeFormat, error) {
+	if name == "" {
+		return TimeFormatSeconds, nil
+	}
+
+	for format, formatName := range timeFormatNames {
+		if strings.EqualFold(name, formatName) {
+			return TimeFormat(format), nil
+		}
+	}
+
+	return TimeFormatSeconds, fmt.Errorf("unknown time format '%s', must be one of %s", name, strings.Join(timeFormatNames, ", "))
+}
+
+// String returns the name of the time format, as accepted by ParseTimeFormat.
+func (f TimeFormat) String() string {
+	return timeFormatNames[f]
+}
+
+// SetTimeFormat sets the format of Timestamp and UnixTime in the JSON encoding of all entries. It defaults to
+// TimeFormatSeconds. The cached JSON representations of existing entries are not updated.
+func SetTimeFormat(format TimeFormat) {
+	timeFormat.Store(int32(format))
+}
+
+// Timestamp is a unix timestamp in seconds with sub-second precision.
+type Timestamp float64
+
+// NewTimestamp returns the timestamp of t with microsecond precision.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp(float64(t.UnixMicro()) / 1e6)
+}
+
+// Time returns the timestamp as time.Time, rounded to microseconds.
+func (t Timestamp) Time() time.Time {
+	return time.UnixMicro(int64(math.Round(float64(t) * 1e6)))
+}
+
+// MarshalJSON encodes the timestamp in the format set with SetTimeFormat.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	switch TimeFormat(timeFormat.Load()) {
+	case TimeFormatMillis:
+		return strconv.AppendInt(nil, int64(math.Round(float64(t)*1e3)), 10), nil
+	case TimeFormatRFC3339:
+		return strconv.AppendQuote(nil, t.Time().UTC().Format(time.RFC3339Nano)), nil
+	default:
+		return strconv.AppendFloat(nil, float64(t), 'f', -1, 64), nil
+	}
+}
+
+// UnixTime is a unix timestamp in seconds.
+type UnixTime int64
+
+// Time returns the timestamp as time.Time.
+func (t UnixTime) Time() time.Time {
+	return time.Unix(int64(t), 0)
+}
+
+// MarshalJSON encodes the timestamp in the format set with SetTimeFormat.
+func (t UnixTime) MarshalJSON() ([]byte, error) {
+	switch TimeFormat(timeFormat.Load()) {
+	case TimeFormatMillis:
+		return strconv.AppendInt(nil, int64(t)*1e3, 10), nil
+	case TimeFormatRFC3339:
+		return strconv.AppendQuote(nil, t.Time().UTC().Format(time.RFC3339)), nil
+	default:
+		return strconv.AppendInt(nil, int64(t), 10), nil
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTimeFormat(t *testing.T) {
+	t.Cleanup(func() { SetTimeFormat(TimeFormatSeconds) })
+
+	data := Data{
+		LeafCert: LeafCert{NotBefore: 1700000000},
+		Seen:     1700000000.123456,
+	}
+
+	tests := []struct {
+		format          string
+		seen, notBefore string
+	}{
+		{"seconds", `1700000000.123456`, `1700000000`},
+		{"millis", `1700000000123`, `1700000000000`},
+		{"RFC3339", `"2023-11-14T22:13:20.123456Z"`, `"2023-11-14T22:13:20Z"`},
+	}
+
+	for _, tt := range tests {
+		format, err := ParseTimeFormat(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		SetTimeFormat(format)
+
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded struct {
+			LeafCert struct {
+				NotBefore json.RawMessage `json:"not_before"`
+			} `json:"leaf_cert"`
+			Seen json.RawMessage `json:"seen"`
+		}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(decoded.Seen) != tt.seen || string(decoded.LeafCert.NotBefore) != tt.notBefore {
+			t.Errorf("%s: got seen %s and not_before %s, want %s and %s",
+				tt.format, decoded.Seen, decoded.LeafCert.NotBefore, tt.seen, tt.notBefore)
+		}
+	}
+
+	if _, err := ParseTimeFormat("nanos"); err == nil {
+		t.Error("got no error for unknown time format 'nanos'")
+	}
+}